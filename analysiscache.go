@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"goodchanges/internal/analyzer"
+	"goodchanges/internal/git"
+	"goodchanges/internal/log"
+	"goodchanges/internal/rush"
+)
+
+// defaultAnalysisCacheDir is the analysis cache directory relative to the repository
+// root, next to the import index cache.
+const defaultAnalysisCacheDir = "common/temp/goodchanges/analysis"
+
+// analysisCacheKey returns the cache key of a package analysis: a hash of the package
+// tree at HEAD and at the merge base plus every other input of AnalyzeLibraryPackage
+// (goodchanges version, parser backend, analysis flags, the project config including
+// inherited projectDefaults, the root config's skipDirs, nested projects, the
+// side-effect-free packages, entrypoints, changed files, upstream taint and changed
+// external deps). It is empty, disabling the cache, when
+// the package has uncommitted changes or is not committed at all, since analysis
+// reads the working tree.
+func analysisCacheKey(ctx context.Context, projectFolder string, cfg *rush.ProjectConfig, entrypoints []analyzer.Entrypoint, mergeBase string, changedFiles []string, upstreamTaint map[string]map[string]bool, changedDeps map[string]bool) string {
+	if analyzer.AnalysisCacheDir == "" {
+		return ""
+	}
+	headTree, err := git.TreeHash(ctx, "HEAD", projectFolder)
+	if err != nil || headTree == "" {
+		return ""
+	}
+	if clean, err := git.IsClean(ctx, projectFolder); err != nil || !clean {
+		log.Debugf("analysis cache: %s has uncommitted changes, not cached", projectFolder)
+		return ""
+	}
+	// A project moved in rush.json is compared with its old folder.
+	baseTree, err := git.TreeHash(ctx, mergeBase, strings.TrimSuffix(analyzer.BasePath(projectFolder+"/"), "/"))
+	if err != nil {
+		return ""
+	}
+
+	taint := make(map[string][]string, len(upstreamTaint))
+	for specifier, names := range upstreamTaint {
+		taint[specifier] = sortedKeys(names)
+	}
+	// Inputs read from outside the package tree: nested projects are skipped when
+	// globbing, and bare imports of side-effect-free packages are dropped.
+	var nested []string
+	for folder := range analyzer.ProjectFolders {
+		if strings.HasPrefix(folder, projectFolder+"/") {
+			nested = append(nested, folder)
+		}
+	}
+	sort.Strings(nested)
+	var sideEffectFree []string
+	if analyzer.RespectSideEffects {
+		sideEffectFree = sortedKeys(analyzer.SideEffectFreePackages)
+	}
+	// encoding/json sorts map keys, so the encoding is deterministic.
+	inputs, err := json.Marshal(map[string]any{
+		"version":          strings.TrimSpace(version),
+		"parser":           flagParserBackend + "/" + flagParserCommand,
+		"flags":            []bool{flagIncludeTypes, flagIncludeCSS, flagIncludeGraphQL, flagRespectSideEffects},
+		"skipDirs":         analyzer.SkipDirs,
+		"guards":           []int64{analyzer.MaxFileSize, int64(analyzer.MaxPackageFiles)},
+		"folder":           projectFolder,
+		"config":           cfg,
+		"nestedProjects":   nested,
+		"sideEffectFree":   sideEffectFree,
+		"headTree":         headTree,
+		"baseTree":         baseTree,
+		"entrypoints":      entrypoints,
+		"changedFiles":     changedFiles,
+		"hunkBases":        analyzer.HunkBaseDigests(projectFolder),
+		"upstreamTaint":    taint,
+		"changedExternals": sortedKeys(changedDeps),
+	})
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(inputs)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"goodchanges/internal/diff"
+	"goodchanges/internal/git"
+)
+
+// AnnotatedFile is the blast radius of a changed file, per hunk, for `goodchanges
+// annotate`.
+type AnnotatedFile struct {
+	File           string          `json:"file"` // repo-relative
+	Package        string          `json:"package,omitempty"`
+	RemovedSymbols []string        `json:"removedSymbols,omitempty"` // changed symbols no longer declared
+	Hunks          []AnnotatedHunk `json:"hunks"`
+}
+
+// AnnotatedHunk is a changed line range (lines of the new file) with what it affects.
+// Symbols and Exports are the hunk's own; Downstream and Targets are those of the
+// file's changes, as the analysis diffs whole files.
+type AnnotatedHunk struct {
+	StartLine int      `json:"startLine"`
+	EndLine   int      `json:"endLine"`
+	Symbols   []string `json:"symbols,omitempty"` // changed symbols whose declaration overlaps the range
+	// Exports are the package's affected exports named like those symbols, qualified
+	// by entrypoint ("pkg/sub#name") outside the main one.
+	Exports []string `json:"exports,omitempty"`
+	// Downstream are the affected exports of dependent packages ("specifier#name").
+	// Empty for a hunk changing no symbol of a file whose changes were narrowed down
+	// to symbols (comments, formatting).
+	Downstream []string `json:"downstream,omitempty"`
+	Targets    []string `json:"targets"` // targets the file's changes select
+}
+
+// runAnnotate implements `goodchanges annotate [--format json|lsif-like] [--since <rev>]
+// [--output <file>] [--pretty]`: it maps every changed line range of the diff to the
+// changed symbols, affected exports and selected targets it leads to, for review bots
+// annotating PR hunks with their blast radius. Each changed file is analyzed on its
+// own (a detection run per file), so a file's hunks get only its own consequences;
+// targets selected by minimumRun sampling or selection policies aren't attributed.
+func runAnnotate(args []string) int {
+	format := "json"
+	output := ""
+	pretty := false
+	for i := 0; i < len(args); i++ {
+		if value, ok := optionValue(args, &i, "--format"); ok {
+			if value != "json" && value != "lsif-like" {
+				fmt.Fprintf(os.Stderr, "Error: unknown --format %q: must be \"json\" or \"lsif-like\"\n", value)
+				return 2
+			}
+			format = value
+			continue
+		}
+		if args[i] == "--pretty" {
+			pretty = true
+			continue
+		}
+		if value, ok := optionValue(args, &i, "--since"); ok {
+			flagSince = value
+			continue
+		}
+		if value, ok := optionValue(args, &i, "--output"); ok {
+			output = value
+			continue
+		}
+		fmt.Fprintln(os.Stderr, "Usage: goodchanges annotate [--format json|lsif-like] [--since <rev>] [--output <file>] [--pretty]")
+		return 2
+	}
+	loadEnvFlags()
+
+	ctx := context.Background()
+	mergeBase, err := resolveMergeBase(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		return 1
+	}
+	changedFiles, err := git.ChangedFilesSince(ctx, mergeBase)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting changed files: %v\n", err)
+		return 1
+	}
+	files := []AnnotatedFile{}
+	for _, file := range changedFiles {
+		af, err := annotateFile(ctx, file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error annotating %s: %v\n", file, err)
+			return 1
+		}
+		files = append(files, af)
+	}
+
+	w := io.Writer(os.Stdout)
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: writing %s: %v\n", output, err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	if format == "lsif-like" {
+		err = writeLSIFLike(w, mergeBase, files)
+	} else {
+		err = writeJSONOutput(w, files, pretty)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// annotateFile runs the detection with only file changed and maps its diff hunks to
+// the result.
+func annotateFile(ctx context.Context, file string) (AnnotatedFile, error) {
+	mergeBase, _, report, err := runDetection(ctx, []string{file})
+	if err != nil {
+		return AnnotatedFile{}, err
+	}
+	af := AnnotatedFile{File: file, Hunks: []AnnotatedHunk{}}
+
+	var own *reportPackage
+	var downstream []string
+	for i, p := range report.Packages {
+		if p.Reason == "changed files" && slices.Contains(p.Details, file) {
+			own = &report.Packages[i]
+			af.Package = p.Name
+			continue
+		}
+		for _, e := range p.Exports {
+			for _, name := range e.Names {
+				downstream = append(downstream, e.Specifier+"#"+name)
+			}
+		}
+	}
+	targets := []string{}
+	for _, t := range report.Targets {
+		if t.Reason != "selection policy" && !strings.HasPrefix(t.Reason, "minimum-run") {
+			targets = append(targets, t.Name)
+		}
+	}
+	sort.Strings(targets)
+
+	// Symbol-level analysis of the file narrows its hunks down to changed symbols.
+	analyzed := false
+	if own != nil {
+		for _, seed := range own.Seeds {
+			if seed.File != file {
+				continue
+			}
+			analyzed = true
+			if seed.Line == 0 {
+				af.RemovedSymbols = append(af.RemovedSymbols, seed.Symbol)
+			}
+		}
+	}
+
+	patch, err := git.DiffFile(ctx, mergeBase, file)
+	if err != nil {
+		return AnnotatedFile{}, err
+	}
+	for _, fd := range diff.Parse(patch) {
+		for _, h := range fd.Hunks {
+			hunk := AnnotatedHunk{StartLine: h.NewStart, EndLine: h.NewEnd(), Targets: targets}
+			if own != nil {
+				for _, seed := range own.Seeds {
+					if seed.File != file || seed.Line == 0 || seed.Line > hunk.EndLine || seed.EndLine < hunk.StartLine {
+						continue
+					}
+					hunk.Symbols = append(hunk.Symbols, seed.Symbol)
+					name := seed.ExportName
+					if name == "" {
+						name = seed.Symbol
+					}
+					for _, e := range own.Exports {
+						if !slices.Contains(e.Names, name) {
+							continue
+						}
+						qualified := name
+						if e.Specifier != own.Name {
+							qualified = e.Specifier + "#" + name
+						}
+						if !slices.Contains(hunk.Exports, qualified) {
+							hunk.Exports = append(hunk.Exports, qualified)
+						}
+					}
+				}
+				sort.Strings(hunk.Exports)
+			}
+			// Removed symbols leave no declaration to overlap: any hunk may be theirs.
+			if !analyzed || len(hunk.Symbols) > 0 || len(af.RemovedSymbols) > 0 {
+				hunk.Downstream = downstream
+			}
+			af.Hunks = append(af.Hunks, hunk)
+		}
+	}
+	return af, nil
+}
+
+// lsifElement is a vertex or an edge of the lsif-like output.
+type lsifElement struct {
+	ID    int    `json:"id"`
+	Type  string `json:"type"` // "vertex" or "edge"
+	Label string `json:"label"`
+
+	// metaData
+	Version   string `json:"version,omitempty"`
+	MergeBase string `json:"mergeBase,omitempty"`
+	// document
+	URI     string `json:"uri,omitempty"`
+	Package string `json:"package,omitempty"`
+	// range (1-based lines, unlike LSIF)
+	Start   *lsifPosition `json:"start,omitempty"`
+	End     *lsifPosition `json:"end,omitempty"`
+	Symbols []string      `json:"symbols,omitempty"`
+	// export and target
+	Specifier string `json:"specifier,omitempty"`
+	Name      string `json:"name,omitempty"`
+	// edge
+	OutV int   `json:"outV,omitempty"`
+	InVs []int `json:"inVs,omitempty"`
+}
+
+type lsifPosition struct {
+	Line int `json:"line"`
+}
+
+// writeLSIFLike writes the annotations as JSON lines of LSIF-style vertices and edges: a
+// metaData vertex, a document vertex per file with a "contains" edge to its range
+// vertices (one per hunk), and an "affects" edge from each range to the export and
+// target vertices it affects. Exports and targets get one vertex each, shared by
+// every range affecting them.
+func writeLSIFLike(w io.Writer, mergeBase string, files []AnnotatedFile) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	id := 0
+	emit := func(e lsifElement) (int, error) {
+		id++
+		e.ID = id
+		return id, enc.Encode(e)
+	}
+	if _, err := emit(lsifElement{Type: "vertex", Label: "metaData", Version: strings.TrimSpace(version), MergeBase: mergeBase}); err != nil {
+		return err
+	}
+	exports := make(map[string]int)
+	targets := make(map[string]int)
+	for _, f := range files {
+		doc, err := emit(lsifElement{Type: "vertex", Label: "document", URI: f.File, Package: f.Package})
+		if err != nil {
+			return err
+		}
+		var ranges []int
+		for _, h := range f.Hunks {
+			rng, err := emit(lsifElement{Type: "vertex", Label: "range", Start: &lsifPosition{h.StartLine}, End: &lsifPosition{h.EndLine}, Symbols: h.Symbols})
+			if err != nil {
+				return err
+			}
+			ranges = append(ranges, rng)
+			var affects []int
+			for _, qualified := range append(slices.Clone(h.Exports), h.Downstream...) {
+				specifier, name, ok := strings.Cut(qualified, "#")
+				if !ok { // an export of the file's package's main entrypoint
+					specifier, name = f.Package, qualified
+				}
+				key := specifier + "#" + name
+				if exports[key] == 0 {
+					if exports[key], err = emit(lsifElement{Type: "vertex", Label: "export", Specifier: specifier, Name: name}); err != nil {
+						return err
+					}
+				}
+				affects = append(affects, exports[key])
+			}
+			for _, t := range h.Targets {
+				if targets[t] == 0 {
+					if targets[t], err = emit(lsifElement{Type: "vertex", Label: "target", Name: t}); err != nil {
+						return err
+					}
+				}
+				affects = append(affects, targets[t])
+			}
+			if len(affects) > 0 {
+				if _, err := emit(lsifElement{Type: "edge", Label: "affects", OutV: rng, InVs: affects}); err != nil {
+					return err
+				}
+			}
+		}
+		if len(ranges) > 0 {
+			if _, err := emit(lsifElement{Type: "edge", Label: "contains", OutV: doc, InVs: ranges}); err != nil {
+				return err
+			}
+		}
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"strings"
+
+	"goodchanges/internal/analyzer"
+	"goodchanges/internal/log"
+	"goodchanges/internal/rush"
+)
+
+// APISurfaceResult classifies the affected exports of a published library against
+// its api-extractor report.
+type APISurfaceResult struct {
+	Package  string      `json:"package"`
+	Report   string      `json:"report"`
+	Public   []APIExport `json:"public,omitempty"`   // affected exports in the report (any non-internal release tag)
+	Internal []string    `json:"internal,omitempty"` // affected exports not in the report, or tagged @internal
+}
+
+// APIExport is an affected export that is part of the public API surface.
+type APIExport struct {
+	Name       string `json:"name"`
+	ReleaseTag string `json:"releaseTag"`           // "public", "beta" or "alpha"
+	Unconsumed bool   `json:"unconsumed,omitempty"` // no workspace project imports it (UNCONSUMED_EXPORTS)
+}
+
+// buildAPISurfaceReport classifies the affected exports of every published library
+// (shouldPublish in rush.json) that has an api-extractor report. Exports of non-root
+// entrypoints are never in the report and are listed as "specifier#name". unconsumed
+// (specifier → names) flags public exports no workspace project imports.
+func buildAPISurfaceReport(rushConfig *rush.Config, affectedExports map[string][]analyzer.AffectedExport, unconsumed map[string]map[string]bool) []APISurfaceResult {
+	var results []APISurfaceResult
+	for _, rp := range rushConfig.Projects {
+		affected := affectedExports[rp.PackageName]
+		if !rp.ShouldPublish || len(affected) == 0 {
+			continue
+		}
+		reportPath := analyzer.FindAPIReport(rp.ProjectFolder, rp.PackageName)
+		if reportPath == "" {
+			log.Debugf("API surface: no api-extractor report for %s", rp.PackageName)
+			continue
+		}
+		reported, err := analyzer.ParseAPIReport(reportPath)
+		if err != nil {
+			log.Debugf("API surface: reading %s: %v", reportPath, err)
+			continue
+		}
+
+		result := APISurfaceResult{Package: rp.PackageName, Report: reportPath}
+		seen := make(map[string]bool)
+		for _, ae := range affected {
+			for _, name := range ae.ExportNames {
+				if ae.EntrypointPath != "." {
+					qualified := rp.PackageName + strings.TrimPrefix(ae.EntrypointPath, ".") + "#" + name
+					if !seen[qualified] {
+						seen[qualified] = true
+						result.Internal = append(result.Internal, qualified)
+					}
+					continue
+				}
+				if seen[name] {
+					continue
+				}
+				seen[name] = true
+				if tag, ok := reported[name]; ok && tag != "internal" {
+					result.Public = append(result.Public, APIExport{Name: name, ReleaseTag: tag, Unconsumed: unconsumed[rp.PackageName][name]})
+				} else {
+					result.Internal = append(result.Internal, name)
+				}
+			}
+		}
+		sort.Slice(result.Public, func(i, j int) bool { return result.Public[i].Name < result.Public[j].Name })
+		sort.Strings(result.Internal)
+		results = append(results, result)
+	}
+
+	if len(results) > 0 {
+		log.Basicf("Affected API surface of published packages:")
+		for _, r := range results {
+			log.Basicf("  %s: %d public, %d internal", r.Package, len(r.Public), len(r.Internal))
+			for _, e := range r.Public {
+				log.Basicf("    @%s %s", e.ReleaseTag, e.Name)
+			}
+		}
+	}
+	return results
+}
+
+// writeAPISurfaceReport writes the API surface classification as JSON to path.
+func writeAPISurfaceReport(path string, results []APISurfaceResult) error {
+	if results == nil {
+		results = []APISurfaceResult{}
+	}
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"goodchanges/internal/git"
+	"goodchanges/internal/rush"
+	"goodchanges/internal/tracing"
+)
+
+// auditRecord is one run's selection decisions, appended to AUDIT_LOG as a JSON line
+// or POSTed to it when it is an http(s) URL. When a regression escapes, the records of
+// its PR's runs explain why the catching target was not selected.
+type auditRecord struct {
+	Time         string            `json:"time"` // RFC 3339, UTC
+	Version      string            `json:"version"`
+	Commit       string            `json:"commit,omitempty"` // HEAD
+	MergeBase    string            `json:"mergeBase"`
+	TimedOut     bool              `json:"timedOut,omitempty"`
+	Reason       string            `json:"reason,omitempty"` // "header-only": detection was skipped
+	ChangedFiles []string          `json:"changedFiles"`
+	Packages     []string          `json:"packages,omitempty"` // projects owning changed files, sorted
+	Configs      map[string]string `json:"configs"`            // config file → sha256 of its content
+	Targets      []auditTarget     `json:"targets"`            // every evaluated target, sorted by name
+}
+
+type auditTarget struct {
+	Name       string `json:"name"`
+	Selected   bool   `json:"selected"`
+	Reason     string `json:"reason,omitempty"`     // detector, sampling or policy reason
+	Detections int    `json:"detections,omitempty"` // fine-grained files, when not a full run
+}
+
+// buildAuditRecord collects the decisions for every evaluated target (selected or
+// not) and the hashes of the configs they were made with.
+func buildAuditRecord(ctx context.Context, mergeBase string, changedFiles []string, rushConfig *rush.Config, evaluated []*DetectorTarget, selected []*TargetResult, reasons map[string]string, timedOut bool) auditRecord {
+	rec := auditRecord{
+		Time:         time.Now().UTC().Format(time.RFC3339),
+		Version:      strings.TrimSpace(version),
+		MergeBase:    mergeBase,
+		TimedOut:     timedOut,
+		ChangedFiles: changedFiles,
+		Configs:      make(map[string]string),
+	}
+	// The run's context may have timed out; the commit is still worth recording.
+	rec.Commit, _ = git.Cmd(context.WithoutCancel(ctx), "rev-parse", "HEAD")
+
+	packages := make(map[string]bool)
+	for _, f := range changedFiles {
+		if rp := rushConfig.ProjectForFile(f); rp != nil {
+			packages[rp.PackageName] = true
+		}
+	}
+	rec.Packages = sortedKeys(packages)
+
+	configFiles := append([]string{"rush.json"}, rush.ConfigFileNames...)
+	for _, rp := range rushConfig.Projects {
+		for _, name := range rush.ConfigFileNames {
+			configFiles = append(configFiles, filepath.ToSlash(filepath.Join(rp.ProjectFolder, name)))
+		}
+	}
+	for _, f := range configFiles {
+		if data, err := os.ReadFile(f); err == nil {
+			sum := sha256.Sum256(data)
+			rec.Configs[f] = hex.EncodeToString(sum[:])
+		}
+	}
+
+	results := make(map[string]*TargetResult, len(selected))
+	for _, r := range selected {
+		results[r.Name] = r
+	}
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		t := auditTarget{Name: name, Reason: reasons[name]}
+		if r := results[name]; r != nil {
+			t.Selected = true
+			t.Detections = len(r.Detections)
+		}
+		rec.Targets = append(rec.Targets, t)
+	}
+	for _, t := range evaluated {
+		add(t.Name)
+	}
+	for _, r := range selected { // targets added by selection policies
+		add(r.Name)
+	}
+	sort.Slice(rec.Targets, func(i, j int) bool { return rec.Targets[i].Name < rec.Targets[j].Name })
+	return rec
+}
+
+// writeAuditRecord appends the record to a JSONL file, or POSTs it as JSON to an
+// http(s) endpoint with AUDIT_LOG_HEADERS ("k1=v1,k2=v2").
+func writeAuditRecord(dest string, rec auditRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(dest, "http://") || strings.HasPrefix(dest, "https://") {
+		req, err := http.NewRequest(http.MethodPost, dest, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range tracing.ParseHeaders(os.Getenv("AUDIT_LOG_HEADERS")) {
+			req.Header.Set(k, v)
+		}
+		client := &http.Client{Timeout: 10 * time.Second}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		return nil
+	}
+	if dir := filepath.Dir(dest); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(dest, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,36 @@
+package main
+
+import "slices"
+
+// flagOutput (--output) is the stdout format: "json" (default) for the target list,
+// "rush-build-args" for rush project selection arguments (see rushBuildArgs), or
+// "projects" for the affected projects and their test commands (see affectedProjects).
+var flagOutput = "json"
+
+// rushBuildArgs returns the rush project selection arguments building what the run
+// affects: `--from` for every project changed by its files or the lockfile (itself,
+// its dependents and everything they need), then `--to` for the package of every
+// selected target outside that set (e.g. selected by constantTargets or sampling).
+// Root targets have no package. Empty when nothing is affected: rush without
+// selection arguments builds everything, so callers must check for it.
+func rushBuildArgs(report *runReport) []string {
+	var args []string
+	affected := make(map[string]bool, len(report.Packages))
+	for _, p := range report.Packages {
+		affected[p.Name] = true
+		if p.Reason != "dependency" {
+			args = append(args, "--from", p.Name)
+		}
+	}
+	var to []string
+	for _, t := range report.Targets {
+		if t.Project != "" && !affected[t.Project] && !slices.Contains(to, t.Project) {
+			to = append(to, t.Project)
+		}
+	}
+	slices.Sort(to)
+	for _, pkg := range to {
+		args = append(args, "--to", pkg)
+	}
+	return args
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// runCompareResults implements `goodchanges compare-results old.json new.json`.
+// It reports targets added and removed between two runs, and targets whose
+// detection changed (full run ↔ fine-grained, or detected files added/removed).
+// Given the runs' METADATA_OUTPUT files, it also reports targets selected in both
+// runs for a different reason (e.g. another detector).
+// Exits non-zero when the new result has reduced coverage: a target was removed,
+// a full run became fine-grained or tagged, or detections or tags were dropped.
+func runCompareResults(args []string) int {
+	var files []string
+	var oldMetadata, newMetadata string
+	for i := 0; i < len(args); i++ {
+		if v, ok := optionValue(args, &i, "--old-metadata"); ok {
+			oldMetadata = v
+		} else if v, ok := optionValue(args, &i, "--new-metadata"); ok {
+			newMetadata = v
+		} else {
+			files = append(files, args[i])
+		}
+	}
+	if len(files) != 2 || (oldMetadata != "") != (newMetadata != "") {
+		fmt.Fprintln(os.Stderr, "Usage: goodchanges compare-results [--old-metadata <metadata.json> --new-metadata <metadata.json>] <old.json> <new.json>")
+		return 2
+	}
+	oldResults, err := readTargetResults(files[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", files[0], err)
+		return 1
+	}
+	newResults, err := readTargetResults(files[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", files[1], err)
+		return 1
+	}
+	var oldReasons, newReasons map[string]string
+	if oldMetadata != "" {
+		if oldReasons, err = readTargetReasons(oldMetadata); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", oldMetadata, err)
+			return 1
+		}
+		if newReasons, err = readTargetReasons(newMetadata); err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", newMetadata, err)
+			return 1
+		}
+	}
+
+	names := make(map[string]bool)
+	for name := range oldResults {
+		names[name] = true
+	}
+	for name := range newResults {
+		names[name] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+
+	reduced := false
+	differing := make(map[string]bool)
+	for _, name := range sorted {
+		oldRes, inOld := oldResults[name]
+		newRes, inNew := newResults[name]
+		switch {
+		case inOld && !inNew:
+			fmt.Printf("- %s: removed\n", name)
+			reduced = true
+			differing[name] = true
+		case !inOld && inNew:
+			fmt.Printf("+ %s: added (%s)\n", name, describeTargetResult(newRes))
+			differing[name] = true
+		default:
+			oldFull := len(oldRes.Detections) == 0
+			newFull := len(newRes.Detections) == 0
+			if oldFull && newFull {
+				// An untagged full run covers any tags; tags dropped by the new run reduce coverage.
+				if len(newRes.Tags) == 0 {
+					continue
+				}
+				if len(oldRes.Tags) == 0 {
+					fmt.Printf("~ %s: %s → %s\n", name, describeTargetResult(oldRes), describeTargetResult(newRes))
+					reduced = true
+					differing[name] = true
+					continue
+				}
+				removed, added := diffStringSets(oldRes.Tags, newRes.Tags)
+				if len(removed) == 0 && len(added) == 0 {
+					continue
+				}
+				fmt.Printf("~ %s: tags changed\n", name)
+				for _, tag := range removed {
+					fmt.Printf("    - %s\n", tag)
+				}
+				for _, tag := range added {
+					fmt.Printf("    + %s\n", tag)
+				}
+				if len(removed) > 0 {
+					reduced = true
+				}
+				differing[name] = true
+				continue
+			}
+			if oldFull != newFull {
+				fmt.Printf("~ %s: %s → %s\n", name, describeTargetResult(oldRes), describeTargetResult(newRes))
+				if oldFull {
+					reduced = true
+				}
+				differing[name] = true
+				continue
+			}
+			removed, added := diffStringSets(oldRes.Detections, newRes.Detections)
+			if len(removed) == 0 && len(added) == 0 {
+				continue
+			}
+			fmt.Printf("~ %s: detections changed\n", name)
+			for _, d := range removed {
+				fmt.Printf("    - %s\n", d)
+			}
+			for _, d := range added {
+				fmt.Printf("    + %s\n", d)
+			}
+			if len(removed) > 0 {
+				reduced = true
+			}
+			differing[name] = true
+		}
+	}
+
+	// A reason change alone doesn't reduce coverage: the target is still selected.
+	for _, name := range sorted {
+		oldReason, inOld := oldReasons[name]
+		newReason, inNew := newReasons[name]
+		if !inOld || !inNew || oldReason == newReason || oldResults[name] == nil || newResults[name] == nil {
+			continue
+		}
+		fmt.Printf("~ %s: reason changed\n", name)
+		fmt.Printf("    - %s\n", oldReason)
+		fmt.Printf("    + %s\n", newReason)
+		differing[name] = true
+	}
+
+	fmt.Printf("%d target(s) differ (old: %d, new: %d)\n", len(differing), len(oldResults), len(newResults))
+	if reduced {
+		fmt.Println("Coverage reduced")
+		return 1
+	}
+	return 0
+}
+
+// readTargetResults parses a goodchanges JSON output file keyed by target name.
+func readTargetResults(path string) (map[string]*TargetResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var list []*TargetResult
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	result := make(map[string]*TargetResult, len(list))
+	for _, r := range list {
+		result[r.Name] = r
+	}
+	return result, nil
+}
+
+// readTargetReasons reads the per-target selection reasons from a METADATA_OUTPUT file.
+func readTargetReasons(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var meta RunMetadata
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, err
+	}
+	return meta.TargetReasons, nil
+}
+
+func describeTargetResult(r *TargetResult) string {
+	if len(r.Detections) == 0 && len(r.Tags) > 0 {
+		return "tagged run: " + strings.Join(r.Tags, ", ")
+	}
+	if len(r.Detections) == 0 {
+		return "full run"
+	}
+	return fmt.Sprintf("fine-grained: %d files", len(r.Detections))
+}
+
+// diffStringSets returns the sorted elements only in a (removed) and only in b (added).
+func diffStringSets(a, b []string) (removed, added []string) {
+	inA := make(map[string]bool, len(a))
+	for _, s := range a {
+		inA[s] = true
+	}
+	inB := make(map[string]bool, len(b))
+	for _, s := range b {
+		inB[s] = true
+		if !inA[s] {
+			added = append(added, s)
+		}
+	}
+	for _, s := range a {
+		if !inB[s] {
+			removed = append(removed, s)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(added)
+	return removed, added
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"goodchanges/internal/git"
+	"goodchanges/internal/log"
+)
+
+// flagAutoFetch (--auto-fetch) fetches the compare branch when it is missing, as in
+// single-branch CI clones; flagFetchDepth (FETCH_DEPTH) limits the fetched history,
+// 0 fetching all of it.
+var flagAutoFetch bool
+var flagFetchDepth int
+
+// ensureCompareBranch checks that the compare branch exists, fetching it with
+// --auto-fetch, so a missing branch fails with a hint instead of git's
+// "Not a valid object name".
+func ensureCompareBranch(ctx context.Context, branch string) error {
+	exists, err := git.RefExists(ctx, branch)
+	if err != nil || exists {
+		return err
+	}
+	if !flagAutoFetch {
+		return fmt.Errorf("compare branch %s not found: fetch it first (e.g. git fetch origin master), set COMPARE_BRANCH or COMPARE_COMMIT, or pass --auto-fetch", branch)
+	}
+	log.Basicf("Compare branch %s not found, fetching it", branch)
+	if err := git.FetchBranch(ctx, branch, flagFetchDepth); err != nil {
+		return fmt.Errorf("compare branch %s not found, and fetching it failed: %w", branch, err)
+	}
+	return nil
+}
+
+// mergeBaseError explains a failed merge-base lookup: in a shallow clone, the common
+// ancestor is usually just not fetched.
+func mergeBaseError(ctx context.Context, branch string, err error) error {
+	if git.IsShallow(ctx) {
+		hint := "deepen it (git fetch --deepen=<n> or --unshallow)"
+		if flagAutoFetch && flagFetchDepth > 0 {
+			hint = "raise FETCH_DEPTH"
+		}
+		return fmt.Errorf("finding merge-base with %s: %w; the clone is shallow, so the common ancestor may not be fetched: %s", branch, err, hint)
+	}
+	return fmt.Errorf("finding merge-base with %s: %w", branch, err)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"goodchanges/internal/analyzer"
+	"goodchanges/internal/rush"
+)
+
+// consumerIndexPath is the default output of `goodchanges index`, relative to the
+// repository root.
+const consumerIndexPath = "common/temp/goodchanges/consumer-index.json"
+
+// runIndex implements `goodchanges index [--output <path>]`: it writes an index of
+// every library export to the workspace files importing it and the targets watching
+// those files, for tools answering "which targets consume export X" without a diff.
+func runIndex(args []string) int {
+	output := consumerIndexPath
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--output" && i+1 < len(args):
+			i++
+			output = args[i]
+		case strings.HasPrefix(args[i], "--output="):
+			output = strings.TrimPrefix(args[i], "--output=")
+		default:
+			fmt.Fprintln(os.Stderr, "Usage: goodchanges index [--output <path>]")
+			return 2
+		}
+	}
+
+	imports, rushConfig, err := loadWorkspaceImportIndex()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	configMap, err := rush.LoadAllProjectConfigs(rushConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	projectMap := rush.BuildProjectMap(rushConfig)
+
+	defaultChangeDirs := []rush.ChangeDir{{Glob: "**/*"}}
+	var libraries []analyzer.IndexLibrary
+	var targets []analyzer.IndexTarget
+	for _, rp := range rushConfig.Projects {
+		cfg := configMap[rp.ProjectFolder]
+		if info := projectMap[rp.PackageName]; info != nil && analyzer.IsLibrary(cfg, info.Package) {
+			libraries = append(libraries, analyzer.IndexLibrary{
+				PackageName:   rp.PackageName,
+				ProjectFolder: rp.ProjectFolder,
+				Entrypoints:   analyzer.FindEntrypoints(rp.ProjectFolder, info.Package, cfg),
+			})
+		}
+		if cfg == nil {
+			continue
+		}
+		for _, td := range cfg.Targets {
+			if td.IsUnit() {
+				continue // listed by affected exports, not selected by imports
+			}
+			changeDirs := td.ChangeDirs
+			if len(changeDirs) == 0 {
+				changeDirs = defaultChangeDirs
+			}
+			if td.IsStorybook() {
+				changeDirs = storybookChangeDirs(changeDirs)
+			}
+			targets = append(targets, analyzer.IndexTarget{
+				Name:          td.OutputName(rp.PackageName),
+				ProjectFolder: rp.ProjectFolder,
+				ChangeDirs:    changeDirs,
+				Config:        cfg.WithTargetIgnores(td),
+			})
+		}
+	}
+
+	index := analyzer.BuildConsumerIndex(imports, libraries, targets, strings.TrimSpace(version))
+	if err := analyzer.WriteConsumerIndex(output, index); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: writing %s: %v\n", output, err)
+		return 1
+	}
+	consumed := 0
+	for _, ec := range index.Exports {
+		if len(ec.Consumers) > 0 {
+			consumed++
+		}
+	}
+	fmt.Printf("Indexed %d exports of %d libraries (%d consumed) for %d targets in %s\n", len(index.Exports), len(libraries), consumed, len(targets), output)
+	return 0
+}
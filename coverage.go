@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// targetCoverage aggregates the audit records of one target.
+type targetCoverage struct {
+	name      string
+	evaluated int
+	selected  int
+	lastRun   time.Time      // zero if never selected
+	packages  map[string]int // changed package → runs it was changed in that selected the target
+}
+
+// runCoverage implements `goodchanges coverage --audit-log <file>... [--stale-days N]`:
+// it aggregates AUDIT_LOG files and reports how often each target was selected, which
+// changed packages most often came with its selection, and which targets have not
+// run in the last N days.
+func runCoverage(args []string) int {
+	var logs []string
+	staleDays := 30
+	usage := func() int {
+		fmt.Fprintln(os.Stderr, "Usage: goodchanges coverage --audit-log <file.jsonl> [--audit-log <file.jsonl>...] [--stale-days N]")
+		return 2
+	}
+	for i := 0; i < len(args); i++ {
+		var value string
+		name, v, hasValue := strings.Cut(args[i], "=")
+		switch {
+		case hasValue:
+			value = v
+		case i+1 < len(args):
+			i++
+			value = args[i]
+		default:
+			return usage()
+		}
+		switch name {
+		case "--audit-log":
+			logs = append(logs, value)
+		case "--stale-days":
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				fmt.Fprintf(os.Stderr, "Error: --stale-days must be a positive number of days, got %q\n", value)
+				return 2
+			}
+			staleDays = n
+		default:
+			return usage()
+		}
+	}
+	if len(logs) == 0 {
+		return usage()
+	}
+
+	var records []auditRecord
+	for _, path := range logs {
+		recs, skipped, err := readAuditLog(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+			return 1
+		}
+		if skipped > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: skipped %d unparseable lines of %s\n", skipped, path)
+		}
+		records = append(records, recs...)
+	}
+	if len(records) == 0 {
+		fmt.Fprintln(os.Stderr, "Error: no audit records")
+		return 1
+	}
+
+	fmt.Print(formatCoverage(records, staleDays, time.Now()))
+	return 0
+}
+
+// readAuditLog parses a JSONL audit log. Lines that don't parse, e.g. a record cut
+// short by a killed run, are counted and skipped.
+func readAuditLog(path string) ([]auditRecord, int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer f.Close()
+	var records []auditRecord
+	skipped := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var rec auditRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			skipped++
+			continue
+		}
+		records = append(records, rec)
+	}
+	return records, skipped, scanner.Err()
+}
+
+// aggregateCoverage folds the records into per-target coverage, sorted by name.
+// Records with an unparseable time still count, but never as a target's last run.
+func aggregateCoverage(records []auditRecord) []*targetCoverage {
+	byName := make(map[string]*targetCoverage)
+	for _, rec := range records {
+		when, _ := time.Parse(time.RFC3339, rec.Time)
+		for _, t := range rec.Targets {
+			c := byName[t.Name]
+			if c == nil {
+				c = &targetCoverage{name: t.Name, packages: make(map[string]int)}
+				byName[t.Name] = c
+			}
+			c.evaluated++
+			if !t.Selected {
+				continue
+			}
+			c.selected++
+			if when.After(c.lastRun) {
+				c.lastRun = when
+			}
+			for _, pkg := range rec.Packages {
+				c.packages[pkg]++
+			}
+		}
+	}
+	result := make([]*targetCoverage, 0, len(byName))
+	for _, name := range sortedKeys(byName) {
+		result = append(result, byName[name])
+	}
+	return result
+}
+
+// topPackages returns the n packages most often changed in runs selecting the
+// target, as "name (runs)".
+func (c *targetCoverage) topPackages(n int) []string {
+	names := sortedKeys(c.packages)
+	sort.SliceStable(names, func(i, j int) bool { return c.packages[names[i]] > c.packages[names[j]] })
+	if len(names) > n {
+		names = names[:n]
+	}
+	top := make([]string, len(names))
+	for i, name := range names {
+		top[i] = fmt.Sprintf("%s (%d)", name, c.packages[name])
+	}
+	return top
+}
+
+// formatCoverage renders the coverage report: a line per target, then the targets
+// whose last selection is older than staleDays (or that were never selected).
+func formatCoverage(records []auditRecord, staleDays int, now time.Time) string {
+	var first, last time.Time
+	for _, rec := range records {
+		when, err := time.Parse(time.RFC3339, rec.Time)
+		if err != nil {
+			continue
+		}
+		if first.IsZero() || when.Before(first) {
+			first = when
+		}
+		if when.After(last) {
+			last = when
+		}
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d runs", len(records))
+	if !first.IsZero() {
+		fmt.Fprintf(&b, " from %s to %s", first.Format(time.DateOnly), last.Format(time.DateOnly))
+	}
+	b.WriteString("\n\n")
+
+	coverage := aggregateCoverage(records)
+	width := len("target")
+	for _, c := range coverage {
+		width = max(width, len(c.name))
+	}
+	fmt.Fprintf(&b, "%-*s  %-16s  %-10s  %s\n", width, "target", "selected", "last run", "most frequent changed packages")
+	for _, c := range coverage {
+		lastRun := "never"
+		if !c.lastRun.IsZero() {
+			lastRun = c.lastRun.Format(time.DateOnly)
+		}
+		selected := fmt.Sprintf("%d/%d (%d%%)", c.selected, c.evaluated, c.selected*100/c.evaluated)
+		line := fmt.Sprintf("%-*s  %-16s  %-10s  %s", width, c.name, selected, lastRun, strings.Join(c.topPackages(3), ", "))
+		b.WriteString(strings.TrimRight(line, " ") + "\n")
+	}
+
+	cutoff := now.AddDate(0, 0, -staleDays)
+	var stale []string
+	for _, c := range coverage {
+		switch {
+		case c.lastRun.IsZero():
+			stale = append(stale, c.name+" (never)")
+		case c.lastRun.Before(cutoff):
+			stale = append(stale, fmt.Sprintf("%s (last %s)", c.name, c.lastRun.Format(time.DateOnly)))
+		}
+	}
+	if len(stale) > 0 {
+		fmt.Fprintf(&b, "\nNot run in the last %d days:\n", staleDays)
+		for _, s := range stale {
+			fmt.Fprintf(&b, "  %s\n", s)
+		}
+	}
+	return b.String()
+}
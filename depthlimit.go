@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"goodchanges/internal/log"
+	"goodchanges/internal/rush"
+)
+
+// flagMaxPropagationDepth (MAX_PROPAGATION_DEPTH) caps how many dependency hops taint
+// travels from a changed package; 0 means no limit.
+var flagMaxPropagationDepth int
+
+// TruncatedPath is a package a propagation depth limit left out of the run, although
+// it depends (transitively) on a changed package.
+type TruncatedPath struct {
+	Package string   `json:"package"`
+	Depth   int      `json:"depth"` // dependency hops from the nearest changed package
+	Limit   int      `json:"limit"` // the limit of that changed package
+	Chain   []string `json:"chain"` // from the changed package to Package
+}
+
+// propagationLimit returns the depth limit of a changed package: the smaller of
+// MAX_PROPAGATION_DEPTH and its maxPropagationDepth, or -1 for none.
+func propagationLimit(cfg *rush.ProjectConfig) int {
+	limit := -1
+	if flagMaxPropagationDepth > 0 {
+		limit = flagMaxPropagationDepth
+	}
+	if cfg != nil && cfg.MaxPropagationDepth != nil && (limit < 0 || *cfg.MaxPropagationDepth < limit) {
+		limit = *cfg.MaxPropagationDepth
+	}
+	return limit
+}
+
+// limitPropagationDepth removes from the affected set the packages no changed package
+// reaches within its propagation depth limit, and returns them with the shortest
+// dependency chain they were reached by.
+func limitPropagationDepth(projectMap, changedProjects map[string]*rush.ProjectInfo, affectedSet map[string]bool, configMap map[string]*rush.ProjectConfig) []TruncatedPath {
+	limits := make(map[string]int, len(changedProjects))
+	limited := false
+	for name, info := range changedProjects {
+		limits[name] = propagationLimit(configMap[info.ProjectFolder])
+		limited = limited || limits[name] >= 0
+	}
+	if !limited {
+		return nil
+	}
+
+	inRange := make(map[string]bool)
+	for _, seed := range sortedKeys(changedProjects) {
+		if !affectedSet[seed] {
+			continue
+		}
+		limit := limits[seed]
+		visited := map[string]bool{seed: true}
+		frontier := []string{seed}
+		for depth := 1; len(frontier) > 0 && (limit < 0 || depth <= limit); depth++ {
+			var next []string
+			for _, name := range frontier {
+				for _, dep := range projectMap[name].DependedOnBy {
+					if affectedSet[dep] && !visited[dep] && projectMap[dep] != nil {
+						visited[dep] = true
+						next = append(next, dep)
+					}
+				}
+			}
+			frontier = next
+		}
+		for name := range visited {
+			inRange[name] = true
+		}
+	}
+
+	var truncated []TruncatedPath
+	for _, name := range sortedKeys(affectedSet) {
+		if inRange[name] {
+			continue
+		}
+		chain := propagationChain(projectMap, changedProjects, affectedSet, name)
+		if len(chain) == 0 {
+			continue
+		}
+		truncated = append(truncated, TruncatedPath{Package: name, Depth: len(chain) - 1, Limit: limits[chain[0]], Chain: chain})
+	}
+	for _, tp := range truncated {
+		delete(affectedSet, tp.Package)
+		fmt.Fprintf(os.Stderr, "Warning: propagation truncated at depth %d: %s not analyzed (%s)\n", tp.Limit, tp.Package, strings.Join(tp.Chain, " → "))
+	}
+	if len(truncated) > 0 {
+		log.Basicf("Propagation depth limits left out %d affected packages", len(truncated))
+	}
+	return truncated
+}
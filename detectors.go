@@ -0,0 +1,745 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bmatcuk/doublestar/v4"
+
+	"goodchanges/internal/analyzer"
+	"goodchanges/internal/log"
+	"goodchanges/internal/rush"
+)
+
+// DetectionContext is the run-wide state detectors evaluate targets against.
+type DetectionContext struct {
+	Context             context.Context // cancelled when the run times out
+	MergeBase           string
+	ChangedFiles        []string                              // all changed files (repo-relative)
+	ProjectChangedFiles map[string][]string                   // changed files per owning project folder
+	DepChangedDeps      map[string]map[string]bool            // project folder → changed external deps
+	UpstreamTaint       map[string]map[string]bool            // import specifier → tainted export names
+	ExternalTaint       map[string]map[string]map[string]bool // project folder → narrowed external dep taint (specifier → names)
+	Truncated           map[string]bool                       // project folders beyond the propagation depth limits
+	ConstantSelected    map[string][]string                   // target name → constant exports selecting it
+	Contracts           map[string]*rush.ExportContract       // package name → export contract
+	Projects            map[string]rush.Project               // package name → rush project
+	ProjectConfigs      map[string]*rush.ProjectConfig        // project folder → config (nil if none)
+	Targets             []*DetectorTarget                     // every target being evaluated in this run
+}
+
+// upstreamTaintFor returns the upstream taint seen by a project: workspace taint plus
+// the project's narrowed external dependency taint. Projects beyond the propagation
+// depth limits see no workspace taint.
+func (ctx *DetectionContext) upstreamTaintFor(projectFolder string) map[string]map[string]bool {
+	external := ctx.ExternalTaint[projectFolder]
+	if ctx.Truncated[projectFolder] {
+		return external
+	}
+	if len(external) == 0 {
+		return ctx.UpstreamTaint
+	}
+	merged := make(map[string]map[string]bool, len(ctx.UpstreamTaint)+len(external))
+	maps.Copy(merged, ctx.UpstreamTaint)
+	maps.Copy(merged, external)
+	return merged
+}
+
+// targetUpstreamTaint returns the upstream taint a target's files are checked against:
+// its project's, minus the taint of the apps its appRoutes narrow (see appRoutesDetector)
+// and, with trustContracts, minus the exports covered by export contracts.
+func (ctx *DetectionContext) targetUpstreamTaint(t *DetectorTarget) map[string]map[string]bool {
+	taint := ctx.upstreamTaintFor(t.Project.ProjectFolder)
+	trustContracts := t.Def.TrustContracts && len(ctx.Contracts) > 0
+	if len(t.Def.AppRoutes) == 0 && !trustContracts {
+		return taint
+	}
+	filtered := make(map[string]map[string]bool, len(taint))
+	for specifier, names := range taint {
+		if isRoutedApp(t.Def.AppRoutes, specifier) {
+			continue
+		}
+		if trustContracts {
+			if names = ctx.uncoveredNames(t, specifier, names); len(names) == 0 {
+				continue
+			}
+		}
+		filtered[specifier] = names
+	}
+	return filtered
+}
+
+// uncoveredNames returns the tainted names of a specifier that its package's export
+// contract doesn't cover. A target is never spared its own package's changes, and CSS
+// and GraphQL taint isn't covered by contracts.
+func (ctx *DetectionContext) uncoveredNames(t *DetectorTarget, specifier string, names map[string]bool) map[string]bool {
+	pkg := analyzer.PackageOfSpecifier(specifier)
+	contract := ctx.Contracts[pkg]
+	if contract == nil || pkg == t.Project.PackageName {
+		return names
+	}
+	var uncovered map[string]bool
+	for name := range names {
+		if contract.Covers(specifier, name) {
+			log.Debugf("  %s: %s#%s is covered by the export contract of %s", t.Name, specifier, name, pkg)
+			continue
+		}
+		if uncovered == nil {
+			uncovered = make(map[string]bool, len(names))
+		}
+		uncovered[name] = true
+	}
+	return uncovered
+}
+
+// pruneUpstreamTaint returns the upstream taint without the entries of workspace
+// packages no target depends on, even transitively: no target file can import them,
+// so they only cost lookups, and a target project left with no taint skips the scan
+// of its files altogether.
+func pruneUpstreamTaint(taint map[string]map[string]bool, projectMap map[string]*rush.ProjectInfo, targets []*DetectorTarget) map[string]map[string]bool {
+	var projects []string
+	for _, t := range targets {
+		projects = append(projects, t.Project.PackageName)
+	}
+	reachable := rush.FindTransitiveDependencies(projectMap, projects)
+	pruned := make(map[string]map[string]bool, len(taint))
+	for specifier, names := range taint {
+		key := strings.TrimPrefix(strings.TrimPrefix(specifier, analyzer.CSSTaintPrefix), analyzer.GraphQLTaintPrefix)
+		pkg := analyzer.PackageOfSpecifier(key)
+		if projectMap[pkg] != nil && !reachable[pkg] {
+			continue
+		}
+		pruned[specifier] = names
+	}
+	return pruned
+}
+
+// changeDirTaint returns the part of a target's upstream taint the changeDir reacts
+// to (see rush.ChangeDir.OnlyPackages).
+func changeDirTaint(taint map[string]map[string]bool, cd rush.ChangeDir) map[string]map[string]bool {
+	if !cd.FiltersTaint() {
+		return taint
+	}
+	filtered := make(map[string]map[string]bool, len(taint))
+	for specifier, names := range taint {
+		key := strings.TrimPrefix(strings.TrimPrefix(specifier, analyzer.CSSTaintPrefix), analyzer.GraphQLTaintPrefix)
+		if cd.ReactsTo(analyzer.PackageOfSpecifier(key)) {
+			filtered[specifier] = names
+		}
+	}
+	return filtered
+}
+
+// isRoutedApp reports whether an import specifier belongs to an app of the routes.
+func isRoutedApp(routes []rush.AppRoute, specifier string) bool {
+	for _, r := range routes {
+		if specifier == r.App || strings.HasPrefix(specifier, r.App+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// DetectorTarget is a target as seen by detectors.
+type DetectorTarget struct {
+	Name          string // output name
+	Project       rush.Project
+	Def           rush.TargetDef
+	ProjectConfig *rush.ProjectConfig // the project's config
+	Config        *rush.ProjectConfig // the project's config with the target's ignores merged in
+	ChangeDirs    []rush.ChangeDir    // effective changeDirs (defaults and storybook filters applied)
+	// Root is set for virtual targets of the root config: their project is the
+	// repository root (".") and their changeDirs match any changed file.
+	Root bool
+}
+
+// Detection is a detector's verdict for a target. Full selects the whole target;
+// otherwise Files lists fine-grained detections (relative to the project folder).
+// The zero value means the detector did not trigger.
+type Detection struct {
+	Full   bool
+	Files  []string
+	Reason string // shown in the BASIC log
+}
+
+// Detector decides whether a target is affected by the change. Detectors run in
+// registry order; the first full detection wins, fine-grained files are merged.
+type Detector interface {
+	Name() string
+	Detect(ctx *DetectionContext, t *DetectorTarget) (Detection, error)
+}
+
+// builtinDetectors returns the built-in detectors in evaluation order. App, CSS and
+// GraphQL taint reach targets through the upstream taint map, so they are covered by
+// tainted-import and fine-grained.
+func builtinDetectors(rootCfg *rush.RootConfig) []Detector {
+	var contracts []rush.ContractConfig
+	if rootCfg != nil {
+		contracts = rootCfg.Contracts
+	}
+	return []Detector{
+		globalChangeDirsDetector{},
+		lockfileDetector{},
+		constantTargetsDetector{},
+		&contractDetector{contracts: contracts},
+		&submoduleDetector{root: rootCfg},
+		externalTriggerDetector{},
+		directChangeDetector{},
+		taintedImportDetector{},
+		&appRoutesDetector{},
+		fineGrainedDetector{},
+	}
+}
+
+// buildDetectors returns the built-in detectors followed by the custom detectors
+// declared in the root config.
+func buildDetectors(rootCfg *rush.RootConfig) ([]Detector, error) {
+	detectors := builtinDetectors(rootCfg)
+	if rootCfg == nil {
+		return detectors, nil
+	}
+	builtin := make(map[string]bool)
+	for _, d := range detectors {
+		builtin[d.Name()] = true
+	}
+	for _, dc := range rootCfg.Detectors {
+		if builtin[dc.Name] {
+			return nil, fmt.Errorf("custom detector %q clashes with a built-in detector", dc.Name)
+		}
+		detectors = append(detectors, &commandDetector{name: dc.Name, args: strings.Fields(dc.Command)})
+	}
+	return detectors, nil
+}
+
+// runDetectors evaluates a target with every detector and merges their verdicts.
+func runDetectors(detectors []Detector, ctx *DetectionContext, t *DetectorTarget) (Detection, error) {
+	var merged Detection
+	for _, d := range detectors {
+		det, err := d.Detect(ctx, t)
+		if err != nil {
+			return Detection{}, fmt.Errorf("detector %s: %w", d.Name(), err)
+		}
+		if det.Full {
+			det.Reason = d.Name() + ": " + det.Reason
+			return det, nil
+		}
+		if len(det.Files) > 0 {
+			merged.Files = append(merged.Files, det.Files...)
+			merged.Reason = strings.TrimPrefix(merged.Reason+", "+d.Name(), ", ")
+		}
+	}
+	sort.Strings(merged.Files)
+	merged.Files = compactStrings(merged.Files)
+	return merged, nil
+}
+
+func compactStrings(sorted []string) []string {
+	var result []string
+	for i, s := range sorted {
+		if i == 0 || s != sorted[i-1] {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// globalChangeDirsDetector selects every target of a project whose global changeDirs match a changed file.
+type globalChangeDirsDetector struct{}
+
+func (globalChangeDirsDetector) Name() string { return "global-changedirs" }
+
+func (globalChangeDirsDetector) Detect(ctx *DetectionContext, t *DetectorTarget) (Detection, error) {
+	cfg := t.ProjectConfig
+	if len(cfg.ChangeDirs) > 0 && globalChangeDirTriggered(cfg.ChangeDirs, ctx.ProjectChangedFiles[t.Project.ProjectFolder], t.Project.ProjectFolder, cfg) {
+		return Detection{Full: true, Reason: "global changeDirs matched"}, nil
+	}
+	return Detection{}, nil
+}
+
+// lockfileDetector selects targets whose project has external dependency changes in the lockfile.
+type lockfileDetector struct{}
+
+func (lockfileDetector) Name() string { return "lockfile" }
+
+func (lockfileDetector) Detect(ctx *DetectionContext, t *DetectorTarget) (Detection, error) {
+	deps := ctx.DepChangedDeps[t.Project.ProjectFolder]
+	if len(deps) == 0 {
+		return Detection{}, nil
+	}
+	names := make([]string, 0, len(deps))
+	for d := range deps {
+		names = append(names, d)
+	}
+	sort.Strings(names)
+	return Detection{Full: true, Reason: "changed deps " + strings.Join(names, ", ")}, nil
+}
+
+// constantTargetsDetector selects targets named by constantTargets of a changed constant.
+type constantTargetsDetector struct{}
+
+func (constantTargetsDetector) Name() string { return "constant-targets" }
+
+func (constantTargetsDetector) Detect(ctx *DetectionContext, t *DetectorTarget) (Detection, error) {
+	if exports := ctx.ConstantSelected[t.Name]; len(exports) > 0 {
+		return Detection{Full: true, Reason: strings.Join(exports, ", ")}, nil
+	}
+	return Detection{}, nil
+}
+
+// contractDetector selects the targets of backend contracts (root config "contracts")
+// whose files changed. For OpenAPI and proto files, only the targets of the contract's
+// endpoints entries matching a changed endpoint are selected; changed endpoints no entry
+// matches select the contract's targets. A change that can't be attributed to endpoints
+// (e.g. a migration) selects all of the contract's targets.
+type contractDetector struct {
+	contracts []rush.ContractConfig
+
+	once     sync.Once
+	selected map[string][]string // target name → reasons
+}
+
+func (*contractDetector) Name() string { return "backend-contract" }
+
+func (cd *contractDetector) Detect(ctx *DetectionContext, t *DetectorTarget) (Detection, error) {
+	cd.once.Do(func() { cd.selected = cd.run(ctx) })
+	if reasons := cd.selected[t.Name]; len(reasons) > 0 {
+		return Detection{Full: true, Reason: strings.Join(reasons, ", ")}, nil
+	}
+	return Detection{}, nil
+}
+
+func (cd *contractDetector) run(ctx *DetectionContext) map[string][]string {
+	selected := make(map[string][]string)
+	selectTargets := func(targets []string, reason string) {
+		for _, t := range targets {
+			if !slices.Contains(selected[t], reason) {
+				selected[t] = append(selected[t], reason)
+			}
+		}
+	}
+	for _, c := range cd.contracts {
+		for _, f := range ctx.ChangedFiles {
+			if !matchesAnyGlob(c.Paths, f) {
+				continue
+			}
+			endpoints, ok := analyzer.ChangedContractEndpoints(ctx.Context, f, ctx.MergeBase)
+			if !ok || len(c.Endpoints) == 0 {
+				selectTargets(c.Targets, c.Name+": "+f)
+				if !ok {
+					for _, e := range c.Endpoints {
+						selectTargets(e.Targets, c.Name+": "+f)
+					}
+				}
+				continue
+			}
+			for _, endpoint := range endpoints {
+				matched := false
+				for _, e := range c.Endpoints {
+					if m, _ := doublestar.Match(e.Match, endpoint); m {
+						selectTargets(e.Targets, c.Name+": "+endpoint)
+						matched = true
+					}
+				}
+				if !matched {
+					selectTargets(c.Targets, c.Name+": "+endpoint)
+				}
+			}
+		}
+	}
+	return selected
+}
+
+// submoduleDetector selects the targets of bumped submodules (root config
+// "submodules") and the targets of the packages wrapping them.
+type submoduleDetector struct {
+	root *rush.RootConfig
+
+	once    sync.Once
+	changed []rush.SubmoduleConfig
+}
+
+func (*submoduleDetector) Name() string { return "submodule" }
+
+func (sd *submoduleDetector) Detect(ctx *DetectionContext, t *DetectorTarget) (Detection, error) {
+	sd.once.Do(func() { sd.changed = sd.root.ChangedSubmodules(ctx.ChangedFiles) })
+	var paths []string
+	for _, sm := range sd.changed {
+		if slices.Contains(sm.Targets, t.Name) || slices.Contains(sm.Packages, t.Project.PackageName) {
+			paths = append(paths, sm.Path)
+		}
+	}
+	if len(paths) == 0 {
+		return Detection{}, nil
+	}
+	return Detection{Full: true, Reason: strings.Join(paths, ", ")}, nil
+}
+
+func matchesAnyGlob(globs []string, path string) bool {
+	for _, g := range globs {
+		if matched, _ := doublestar.Match(g, path); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// externalTriggerDetector selects targets with a changed file matching one of their
+// externalTriggers (repo-relative globs, typically outside the project folder).
+type externalTriggerDetector struct{}
+
+func (externalTriggerDetector) Name() string { return "external-trigger" }
+
+func (externalTriggerDetector) Detect(ctx *DetectionContext, t *DetectorTarget) (Detection, error) {
+	for _, f := range ctx.ChangedFiles {
+		if matchesAnyGlob(t.Def.ExternalTriggers, f) {
+			return Detection{Full: true, Reason: f}, nil
+		}
+	}
+	return Detection{}, nil
+}
+
+// directChangeDetector selects targets with a changed file matching a normal changeDir.
+type directChangeDetector struct{}
+
+func (directChangeDetector) Name() string { return "direct-change" }
+
+func (directChangeDetector) Detect(ctx *DetectionContext, t *DetectorTarget) (Detection, error) {
+	changed := ctx.ProjectChangedFiles[t.Project.ProjectFolder]
+	if t.Root {
+		changed = ctx.ChangedFiles
+	}
+	for _, cd := range t.ChangeDirs {
+		if cd.IsFineGrained() {
+			continue
+		}
+		for _, f := range changed {
+			relPath := strings.TrimPrefix(f, t.Project.ProjectFolder+"/")
+			if t.Config.IsIgnored(relPath) {
+				continue
+			}
+			if matched, _ := doublestar.Match(cd.Glob, relPath); matched {
+				return Detection{Full: true, Reason: relPath}, nil
+			}
+		}
+		// Changed binary assets count through the files importing them.
+		for _, f := range sortedKeys(analyzer.AssetFiles) {
+			asset, ok := strings.CutPrefix(f, t.Project.ProjectFolder+"/")
+			if !ok {
+				continue
+			}
+			for _, importer := range analyzer.AssetImporters(t.Project.ProjectFolder, asset) {
+				if t.Config.IsIgnored(importer) {
+					continue
+				}
+				if matched, _ := doublestar.Match(cd.Glob, importer); matched {
+					return Detection{Full: true, Reason: importer + " (imports " + asset + ")"}, nil
+				}
+			}
+		}
+	}
+	return Detection{}, nil
+}
+
+// taintedImportDetector selects targets with a file matching a normal changeDir that
+// imports tainted symbols (including app, CSS and GraphQL taint) from workspace packages.
+type taintedImportDetector struct{}
+
+func (taintedImportDetector) Name() string { return "tainted-import" }
+
+func (taintedImportDetector) Detect(ctx *DetectionContext, t *DetectorTarget) (Detection, error) {
+	if t.Root {
+		// Files outside every project aren't analyzed: there are no imports to follow.
+		return Detection{}, nil
+	}
+	for _, cd := range t.ChangeDirs {
+		if cd.IsFineGrained() {
+			continue
+		}
+		if analyzer.HasTaintedImportsForGlob(t.Project.ProjectFolder, cd.Glob, changeDirTaint(ctx.targetUpstreamTaint(t), cd), t.Config) {
+			return Detection{Full: true, Reason: cd.Glob}, nil
+		}
+	}
+	return Detection{}, nil
+}
+
+// fineGrainedDetector collects the affected files of fine-grained changeDirs.
+type fineGrainedDetector struct{}
+
+func (fineGrainedDetector) Name() string { return "fine-grained" }
+
+func (fineGrainedDetector) Detect(ctx *DetectionContext, t *DetectorTarget) (Detection, error) {
+	var files []string
+	for _, cd := range t.ChangeDirs {
+		if !cd.IsFineGrained() {
+			continue
+		}
+		filterPattern := ""
+		if cd.Filter != nil {
+			filterPattern = *cd.Filter
+		}
+		folder := t.Project.ProjectFolder
+		files = append(files, analyzer.FindAffectedFiles(ctx.Context, cd.Glob, filterPattern, changeDirTaint(ctx.targetUpstreamTaint(t), cd), ctx.ProjectChangedFiles[folder], folder, t.Config, ctx.DepChangedDeps[folder], ctx.MergeBase, flagIncludeTypes, t.ProjectConfig.ForceLibraryAnalysis)...)
+	}
+	return Detection{Files: files}, nil
+}
+
+// appRoutesDetector narrows the selection of targets with appRoutes: when an app the
+// target's files import is tainted, the app's changed and affected files are mapped to
+// the routes covering them, and only the specs of those routes are selected. A changed
+// file no route covers, or one importing upstream taint itself, selects the whole
+// target; uncovered files merely importing affected files (routers, barrels) relay
+// the taint of the areas they import and are skipped. With the app's bundleStats, a
+// file bundled in lazily loaded chunks only is also covered by the routes naming all
+// of its chunks.
+type appRoutesDetector struct {
+	affected map[string]appAffectedFiles     // app project folder → its affected files
+	stats    map[string]analyzer.BundleStats // app project folder → its bundleStats
+}
+
+type appAffectedFiles struct {
+	files   []string        // changed (not ignored) and affected files, relative to the app folder
+	origins map[string]bool // files tainted by themselves: changed or importing upstream taint
+}
+
+func (*appRoutesDetector) Name() string { return "app-routes" }
+
+func (d *appRoutesDetector) Detect(ctx *DetectionContext, t *DetectorTarget) (Detection, error) {
+	var apps []string
+	for _, r := range t.Def.AppRoutes {
+		if !slices.Contains(apps, r.App) {
+			apps = append(apps, r.App)
+		}
+	}
+	folder := t.Project.ProjectFolder
+	var specs []string
+	for _, app := range apps {
+		// As for tainted-import, the app's taint must reach one of the target's files.
+		appTaint := make(map[string]map[string]bool)
+		for specifier, names := range ctx.upstreamTaintFor(folder) {
+			if specifier == app || strings.HasPrefix(specifier, app+"/") {
+				appTaint[specifier] = names
+			}
+		}
+		reached := false
+		for _, cd := range t.ChangeDirs {
+			if len(appTaint) > 0 && analyzer.HasTaintedImportsForGlob(folder, cd.Glob, appTaint, t.Config) {
+				reached = true
+				break
+			}
+		}
+		if !reached {
+			continue
+		}
+		project, ok := ctx.Projects[app]
+		if !ok {
+			return Detection{Full: true, Reason: app + " is not a workspace project"}, nil
+		}
+		if len(ctx.DepChangedDeps[project.ProjectFolder]) > 0 {
+			return Detection{Full: true, Reason: app + " has changed external dependencies"}, nil
+		}
+		affected := d.affectedAppFiles(ctx, project.ProjectFolder)
+		if len(affected.origins) == 0 {
+			return Detection{Full: true, Reason: app + " affected outside its source files"}, nil
+		}
+		stats := d.bundleStats(ctx, project.ProjectFolder)
+		for _, f := range affected.files {
+			covered := false
+			for _, r := range t.Def.AppRoutes {
+				if r.App == app && matchesAnyGlob(r.Sources, f) {
+					specs = append(specs, r.Specs...)
+					covered = true
+				}
+			}
+			if !covered {
+				if chunkSpecs, ok := chunkRouteSpecs(t.Def.AppRoutes, app, stats.Lazy(f)); ok {
+					specs = append(specs, chunkSpecs...)
+					covered = true
+				}
+			}
+			if !covered && affected.origins[f] {
+				return Detection{Full: true, Reason: fmt.Sprintf("%s/%s is not covered by appRoutes", project.ProjectFolder, f)}, nil
+			}
+		}
+	}
+	if len(specs) == 0 {
+		return Detection{}, nil
+	}
+
+	var files []string
+	fsys := os.DirFS(folder)
+	for _, pattern := range specs {
+		matches, _ := doublestar.Glob(fsys, pattern, doublestar.WithFilesOnly())
+		for _, m := range matches {
+			if !t.Config.IsIgnored(m) {
+				files = append(files, m)
+			}
+		}
+	}
+	if len(files) == 0 {
+		return Detection{Full: true, Reason: "specs of affected appRoutes match no files"}, nil
+	}
+	return Detection{Files: files}, nil
+}
+
+// bundleStats returns the module → chunk mapping of the app's bundleStats, nil when it
+// has none or it can't be read (the build may not have run): files are then covered by
+// routes' sources only. Loaded once per app.
+func (d *appRoutesDetector) bundleStats(ctx *DetectionContext, appFolder string) analyzer.BundleStats {
+	if stats, ok := d.stats[appFolder]; ok {
+		return stats
+	}
+	var stats analyzer.BundleStats
+	if cfg := ctx.ProjectConfigs[appFolder]; cfg != nil && cfg.BundleStats != "" {
+		var err error
+		if stats, err = analyzer.LoadBundleStats(filepath.Join(appFolder, cfg.BundleStats)); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: reading bundleStats of %s: %v; appRoutes chunks don't apply\n", appFolder, err)
+		}
+	}
+	if d.stats == nil {
+		d.stats = make(map[string]analyzer.BundleStats)
+	}
+	d.stats[appFolder] = stats
+	return stats
+}
+
+// chunkRouteSpecs returns the specs of the app's routes whose chunks match the lazily
+// loaded chunks of a file. ok is set only when every chunk is matched: a file bundled
+// in an initial chunk, or in a chunk no route names, isn't covered.
+func chunkRouteSpecs(routes []rush.AppRoute, app string, chunks []analyzer.BundleChunk) (specs []string, ok bool) {
+	if len(chunks) == 0 {
+		return nil, false
+	}
+	for _, c := range chunks {
+		matched := false
+		for _, r := range routes {
+			if r.App == app && matchesAnyGlob(r.Chunks, c.Name) {
+				specs = append(specs, r.Specs...)
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, false
+		}
+	}
+	return specs, true
+}
+
+// affectedAppFiles returns the app's changed files (not ignored) and the source files
+// affected by them or by upstream taint. Computed once per app.
+func (d *appRoutesDetector) affectedAppFiles(ctx *DetectionContext, appFolder string) appAffectedFiles {
+	if affected, ok := d.affected[appFolder]; ok {
+		return affected
+	}
+	cfg := ctx.ProjectConfigs[appFolder]
+	upstream := ctx.upstreamTaintFor(appFolder)
+	affected := appAffectedFiles{origins: make(map[string]bool)}
+	for _, f := range ctx.ProjectChangedFiles[appFolder] {
+		rel := strings.TrimPrefix(f, appFolder+"/")
+		if !cfg.IsIgnored(rel) {
+			affected.files = append(affected.files, rel)
+			affected.origins[rel] = true
+		}
+	}
+	for _, f := range analyzer.FindAffectedFiles(ctx.Context, "**/*", "", upstream, ctx.ProjectChangedFiles[appFolder], appFolder, cfg, nil, ctx.MergeBase, flagIncludeTypes, false) {
+		affected.files = append(affected.files, f)
+		// The file's own path as the glob: does it import upstream taint itself?
+		if analyzer.HasTaintedImportsForGlob(appFolder, f, upstream, cfg) {
+			affected.origins[f] = true
+		}
+	}
+	sort.Strings(affected.files)
+	affected.files = compactStrings(affected.files)
+	if d.affected == nil {
+		d.affected = make(map[string]appAffectedFiles)
+	}
+	d.affected[appFolder] = affected
+	return affected
+}
+
+// commandDetector runs a custom detector process once per run. The process reads a
+// commandDetectorRequest from stdin and writes a commandDetectorResponse to stdout;
+// targets missing from the response are not triggered.
+type commandDetector struct {
+	name string
+	args []string
+
+	once     sync.Once
+	selected map[string]commandDetectorTarget
+	err      error
+}
+
+type commandDetectorRequest struct {
+	MergeBase    string                       `json:"mergeBase"`
+	ChangedFiles []string                     `json:"changedFiles"`
+	Targets      []commandDetectorRequestItem `json:"targets"`
+}
+
+type commandDetectorRequestItem struct {
+	Name          string `json:"name"`
+	Project       string `json:"project"`
+	ProjectFolder string `json:"projectFolder"`
+}
+
+type commandDetectorResponse struct {
+	Targets []commandDetectorTarget `json:"targets"`
+}
+
+type commandDetectorTarget struct {
+	Name       string   `json:"name"`
+	Detections []string `json:"detections,omitempty"` // fine-grained files; empty = full run
+	Reason     string   `json:"reason,omitempty"`
+}
+
+func (cd *commandDetector) Name() string { return cd.name }
+
+func (cd *commandDetector) Detect(ctx *DetectionContext, t *DetectorTarget) (Detection, error) {
+	cd.once.Do(func() { cd.selected, cd.err = cd.run(ctx) })
+	if cd.err != nil {
+		return Detection{}, cd.err
+	}
+	sel, ok := cd.selected[t.Name]
+	if !ok {
+		return Detection{}, nil
+	}
+	if len(sel.Detections) == 0 {
+		return Detection{Full: true, Reason: sel.Reason}, nil
+	}
+	return Detection{Files: sel.Detections, Reason: sel.Reason}, nil
+}
+
+func (cd *commandDetector) run(ctx *DetectionContext) (map[string]commandDetectorTarget, error) {
+	req := commandDetectorRequest{MergeBase: ctx.MergeBase, ChangedFiles: ctx.ChangedFiles}
+	for _, t := range ctx.Targets {
+		req.Targets = append(req.Targets, commandDetectorRequestItem{Name: t.Name, Project: t.Project.PackageName, ProjectFolder: t.Project.ProjectFolder})
+	}
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx.Context, cd.args[0], cd.args[1:]...)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stderr = os.Stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("running %q: %w", strings.Join(cd.args, " "), err)
+	}
+	var resp commandDetectorResponse
+	if err := json.Unmarshal(output, &resp); err != nil {
+		return nil, fmt.Errorf("invalid response from %q: %w", strings.Join(cd.args, " "), err)
+	}
+	selected := make(map[string]commandDetectorTarget, len(resp.Targets))
+	for _, t := range resp.Targets {
+		selected[t.Name] = t
+	}
+	return selected, nil
+}
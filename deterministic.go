@@ -0,0 +1,23 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// flagDeterministic (--deterministic) analyzes the packages of a level one at a time
+// in package order instead of concurrently, and reports a hash of the result, so two
+// runs on identical inputs can be compared in CI to catch nondeterminism.
+var flagDeterministic bool
+
+// resultHash returns the SHA-256 of the compact JSON output of a run, independent of
+// --pretty, as "sha256:<hex>".
+func resultHash(results []*TargetResult) string {
+	data, err := json.Marshal(results)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(append(data, '\n'))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
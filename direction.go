@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"goodchanges/internal/rush"
+)
+
+// flagDirection (--direction) is "up" (default) to select the targets affected by the
+// changes, or "down" to list the packages the selected targets, or those of --from,
+// depend on.
+var flagDirection = "up"
+
+// flagFrom (--from) lists target or package names (`*` wildcards) whose dependencies
+// --direction down lists, instead of those of the targets selected by the changes.
+var flagFrom []string
+
+// dependenciesDown returns the packages of the named targets or packages and every
+// workspace package they transitively depend on, dependencies first: the set
+// `rush build --only` needs to build them. Names match package names and target
+// output names; root targets have no package and contribute nothing. A pattern
+// matching neither is an error.
+func dependenciesDown(names []string) ([]string, error) {
+	rushConfig, err := rush.LoadConfig(".")
+	if err != nil {
+		return nil, fmt.Errorf("loading rush config: %w", err)
+	}
+	configMap, err := rush.LoadAllProjectConfigs(rushConfig)
+	if err != nil {
+		return nil, fmt.Errorf("loading project configs: %w", err)
+	}
+	rootCfg, err := rush.LoadRootConfig(".")
+	if err != nil {
+		return nil, fmt.Errorf("loading root config: %w", err)
+	}
+
+	seeds := make(map[string]bool)
+	matched := make([]bool, len(names))
+	match := func(name, pkg string) {
+		for i, pattern := range names {
+			if matchesTargetFilter(name, []string{pattern}) {
+				matched[i] = true
+				if pkg != "" {
+					seeds[pkg] = true
+				}
+			}
+		}
+	}
+	for _, rp := range rushConfig.Projects {
+		match(rp.PackageName, rp.PackageName)
+		if cfg := configMap[rp.ProjectFolder]; cfg != nil {
+			for _, td := range cfg.Targets {
+				match(td.OutputName(rp.PackageName), rp.PackageName)
+			}
+		}
+	}
+	if rootCfg != nil {
+		for _, rt := range rootCfg.Targets {
+			match(rt.TargetName, "")
+		}
+	}
+	var unknown []string
+	for i, ok := range matched {
+		if !ok {
+			unknown = append(unknown, names[i])
+		}
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("no package or target matches %s", strings.Join(unknown, ", "))
+	}
+
+	projectMap := rush.BuildProjectMapFor(rushConfig, sortedKeys(seeds))
+	deps := rush.FindTransitiveDependencies(projectMap, sortedKeys(seeds))
+	result := []string{}
+	for _, level := range rush.TopologicalSort(projectMap, deps) {
+		result = append(result, level...)
+	}
+	return result, nil
+}
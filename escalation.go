@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+
+	"goodchanges/internal/rush"
+)
+
+// flagMaxDetections (MAX_DETECTIONS) caps the fine-grained detections of a target:
+// with more, the whole target is selected instead; 0 means no cap.
+var flagMaxDetections int
+
+// detectionLimit returns the detection cap of a target: its maxDetections, otherwise
+// MAX_DETECTIONS, or 0 for none.
+func detectionLimit(td rush.TargetDef) int {
+	if td.MaxDetections != nil {
+		return *td.MaxDetections
+	}
+	return flagMaxDetections
+}
+
+// escalateDetections turns a fine-grained detection with more files than the target's
+// cap into a full one: running everything beats spawning runners for most of the suite.
+func escalateDetections(det Detection, td rush.TargetDef) Detection {
+	limit := detectionLimit(td)
+	if det.Full || limit <= 0 || len(det.Files) <= limit {
+		return det
+	}
+	return Detection{
+		Full:   true,
+		Reason: fmt.Sprintf("max-detections: %d files from %s exceed %d", len(det.Files), det.Reason, limit),
+	}
+}
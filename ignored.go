@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"goodchanges/internal/rush"
+)
+
+// flagShowIgnored (--show-ignored) lists the changed files excluded by ignore globs,
+// with the glob excluding each, on stderr.
+var flagShowIgnored bool
+
+// IgnoredFiles counts the changed files of a project excluded by ignore globs, so
+// overly broad ignores suppressing legitimate triggers show up in the run metadata.
+// Config and contract files, always ignored, aren't counted.
+type IgnoredFiles struct {
+	Package string `json:"package"`
+	Count   int    `json:"count"` // excluded by the project's ignores
+	// Targets counts, per target, the other changed files of the project its own
+	// ignores exclude.
+	Targets map[string]int `json:"targets,omitempty"`
+}
+
+// ignoredFile is a changed file excluded by pattern, from the ignores of target or,
+// when target is empty, of the project.
+type ignoredFile struct {
+	pkg, file, pattern, target string
+}
+
+// collectIgnoredFiles returns the per-project counts of changed files excluded by
+// ignore globs, sorted by package, and the files themselves.
+func collectIgnoredFiles(config *rush.Config, configMap map[string]*rush.ProjectConfig, projectChangedFiles map[string][]string, targets []*DetectorTarget) ([]IgnoredFiles, []ignoredFile) {
+	counts := make(map[string]*IgnoredFiles)
+	count := func(pkg string) *IgnoredFiles {
+		if counts[pkg] == nil {
+			counts[pkg] = &IgnoredFiles{Package: pkg}
+		}
+		return counts[pkg]
+	}
+	var files []ignoredFile
+	for _, rp := range config.Projects {
+		cfg := configMap[rp.ProjectFolder]
+		for _, f := range projectChangedFiles[rp.ProjectFolder] {
+			if pattern := cfg.IgnoringPattern(strings.TrimPrefix(f, rp.ProjectFolder+"/")); pattern != "" {
+				count(rp.PackageName).Count++
+				files = append(files, ignoredFile{pkg: rp.PackageName, file: f, pattern: pattern})
+			}
+		}
+	}
+	for _, t := range targets {
+		if t.Root || len(t.Def.Ignores) == 0 {
+			continue
+		}
+		own := &rush.ProjectConfig{Ignores: t.Def.Ignores}
+		for _, f := range projectChangedFiles[t.Project.ProjectFolder] {
+			relPath := strings.TrimPrefix(f, t.Project.ProjectFolder+"/")
+			if t.ProjectConfig.IgnoringPattern(relPath) != "" {
+				continue
+			}
+			if pattern := own.IgnoringPattern(relPath); pattern != "" {
+				c := count(t.Project.PackageName)
+				if c.Targets == nil {
+					c.Targets = make(map[string]int)
+				}
+				c.Targets[t.Name]++
+				files = append(files, ignoredFile{pkg: t.Project.PackageName, file: f, pattern: pattern, target: t.Name})
+			}
+		}
+	}
+
+	list := make([]IgnoredFiles, 0, len(counts))
+	for _, pkg := range sortedKeys(counts) {
+		list = append(list, *counts[pkg])
+	}
+	sort.SliceStable(files, func(i, j int) bool {
+		if files[i].pkg != files[j].pkg {
+			return files[i].pkg < files[j].pkg
+		}
+		return files[i].target < files[j].target
+	})
+	return list, files
+}
+
+// printIgnoredFiles writes the --show-ignored listing.
+func printIgnoredFiles(w io.Writer, files []ignoredFile) {
+	fmt.Fprintf(w, "Ignored changed files (%d):\n", len(files))
+	for _, f := range files {
+		if f.target != "" {
+			fmt.Fprintf(w, "  %s: %s (ignores %q of target %s)\n", f.pkg, f.file, f.pattern, f.target)
+		} else {
+			fmt.Fprintf(w, "  %s: %s (ignores %q)\n", f.pkg, f.file, f.pattern)
+		}
+	}
+}
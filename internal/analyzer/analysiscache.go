@@ -0,0 +1,89 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"goodchanges/internal/log"
+	"goodchanges/internal/metrics"
+)
+
+// AnalysisCacheDir is the directory of the on-disk AnalyzeLibraryPackage result cache
+// (ANALYSIS_CACHE_DIR). Entries are keyed by everything the analysis reads, so they
+// never expire. Empty disables the cache.
+var AnalysisCacheDir string
+
+// cachedAnalysis is one cache entry: the analysis result plus the side records
+// (change seeds, parse failures, guard skips) a fresh analysis would have made.
+type cachedAnalysis struct {
+	Affected      []AffectedExport `json:"affected"`
+	Seeds         []ChangeSeed     `json:"seeds,omitempty"`
+	ParseFailures []ParseFailure   `json:"parseFailures,omitempty"`
+	SkippedFiles  []SkippedFile    `json:"skippedFiles,omitempty"`
+}
+
+// LoadCachedAnalysis returns the cached result of a package analysis under key and
+// restores its change seeds, parse failures and guard skips. ok is false on a miss.
+func LoadCachedAnalysis(key string, projectFolder string) (affected []AffectedExport, ok bool) {
+	if AnalysisCacheDir == "" || key == "" {
+		return nil, false
+	}
+	path := filepath.Join(AnalysisCacheDir, key+".json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		metrics.Add("goodchanges_analysis_cache_lookups_total", 1, "result", "miss")
+		return nil, false
+	}
+	var entry cachedAnalysis
+	if err := json.Unmarshal(data, &entry); err != nil {
+		log.Debugf("analysis cache: ignoring unreadable %s", path)
+		metrics.Add("goodchanges_analysis_cache_lookups_total", 1, "result", "miss")
+		return nil, false
+	}
+	metrics.Add("goodchanges_analysis_cache_lookups_total", 1, "result", "hit")
+
+	changeSeeds.Lock()
+	changeSeeds.byProject[projectFolder] = append(changeSeeds.byProject[projectFolder], entry.Seeds...)
+	changeSeeds.Unlock()
+	parseFailures.Lock()
+	files := parseFailures.byProject[projectFolder]
+	if files == nil {
+		files = make(map[string][]string)
+		parseFailures.byProject[projectFolder] = files
+	}
+	for _, pf := range entry.ParseFailures {
+		if _, seen := files[pf.File]; !seen {
+			files[pf.File] = pf.Errors
+			metrics.Add("goodchanges_parse_failures_total", 1)
+		}
+	}
+	parseFailures.Unlock()
+	for _, sf := range entry.SkippedFiles {
+		recordSkippedFile(projectFolder, sf.File, sf.Reason)
+	}
+	return entry.Affected, true
+}
+
+// StoreCachedAnalysis caches the result of a package analysis under key, together with
+// the change seeds, parse failures and guard skips recorded for the package so far.
+func StoreCachedAnalysis(key string, projectFolder string, affected []AffectedExport) {
+	if AnalysisCacheDir == "" || key == "" {
+		return
+	}
+	path := filepath.Join(AnalysisCacheDir, key+".json")
+	data, err := json.Marshal(cachedAnalysis{
+		Affected:      affected,
+		Seeds:         ChangeSeeds(projectFolder),
+		ParseFailures: ParseFailures()[projectFolder],
+		SkippedFiles:  SkippedFiles()[projectFolder],
+	})
+	if err == nil {
+		if err = os.MkdirAll(AnalysisCacheDir, 0o755); err == nil {
+			err = os.WriteFile(path, data, 0o644)
+		}
+	}
+	if err != nil {
+		log.Debugf("analysis cache: writing %s: %v", path, err)
+	}
+}
@@ -1,16 +1,19 @@
 package analyzer
 
 import (
+	"context"
 	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
 
 	"github.com/bmatcuk/doublestar/v4"
 
-	"goodchanges/internal/git"
 	"goodchanges/internal/log"
+	"goodchanges/internal/metrics"
 	"goodchanges/internal/rush"
 	"goodchanges/internal/tsparse"
 )
@@ -21,6 +24,35 @@ var IncludeCSS bool
 // CSSTaintPrefix is the prefix used for CSS taint entries in the upstream taint map.
 const CSSTaintPrefix = "__css__:"
 
+// ProjectFolders is the set of all rush project folders (repo-relative). Source globbing
+// skips project folders nested inside the analyzed project.
+var ProjectFolders map[string]bool
+
+// ResetRunState forgets what earlier runs in this process collected or cached --
+// change seeds, parse failures, guard skips, asset importers, const enum lookups, import indexes and directory listings --
+// so a re-run (goodchanges tui) sees the current working tree.
+func ResetRunState() {
+	changeSeeds.Lock()
+	changeSeeds.byProject = make(map[string][]ChangeSeed)
+	changeSeeds.Unlock()
+	parseFailures.Lock()
+	parseFailures.byProject = make(map[string]map[string][]string)
+	parseFailures.Unlock()
+	skippedFiles.Lock()
+	skippedFiles.byProject = make(map[string]map[string]string)
+	skippedFiles.Unlock()
+	assetImporters.Lock()
+	assetImporters.byProject = make(map[string]map[string][]string)
+	assetImporters.Unlock()
+	constEnumPackages.Lock()
+	constEnumPackages.byDir = make(map[string]bool)
+	constEnumPackages.Unlock()
+	folderImports.Lock()
+	folderImports.byFolder = make(map[string]*folderImportIndex)
+	folderImports.Unlock()
+	dirEntryNames.Clear()
+}
+
 type Entrypoint struct {
 	ExportPath string // e.g. ".", "./utils/*"
 	SourceFile string // resolved source file path relative to project root
@@ -32,9 +64,12 @@ type AffectedExport struct {
 }
 
 // IsLibrary determines if a package is a library (transpiled) vs a bundled app.
-// When the project config sets an explicit `type`, that value wins; otherwise
-// the result is inferred from package.json fields.
+// forceLibraryAnalysis in the project config makes any package a library; otherwise
+// an explicit `type` wins, and the result is inferred from package.json fields.
 func IsLibrary(pc *rush.ProjectConfig, pkg rush.PackageJSON) bool {
+	if pc != nil && pc.ForceLibraryAnalysis {
+		return true
+	}
 	if pc != nil && pc.Type != nil {
 		return *pc.Type == "library"
 	}
@@ -50,8 +85,9 @@ func IsLibrary(pc *rush.ProjectConfig, pkg rush.PackageJSON) bool {
 	return false
 }
 
-// FindEntrypoints resolves all entrypoints from package.json to source files.
-func FindEntrypoints(projectFolder string, pkg rush.PackageJSON) []Entrypoint {
+// FindEntrypoints resolves all entrypoints from package.json to source files. cfg
+// (may be nil) supplies the project's sourceDirs mappings.
+func FindEntrypoints(projectFolder string, pkg rush.PackageJSON, cfg *rush.ProjectConfig) []Entrypoint {
 	log.Debugf("FindEntrypoints: %s", projectFolder)
 	var entrypoints []Entrypoint
 
@@ -59,7 +95,7 @@ func FindEntrypoints(projectFolder string, pkg rush.PackageJSON) []Entrypoint {
 		eps := parseExportsField(pkg.Exports)
 		log.Debugf("  parsed exports field: %d entries", len(eps))
 		for _, ep := range eps {
-			resolved := resolveToSource(projectFolder, ep.SourceFile)
+			resolved := resolveToSource(projectFolder, ep.SourceFile, cfg)
 			if resolved != "" {
 				entrypoints = append(entrypoints, Entrypoint{
 					ExportPath: ep.ExportPath,
@@ -73,9 +109,9 @@ func FindEntrypoints(projectFolder string, pkg rush.PackageJSON) []Entrypoint {
 	}
 
 	if len(entrypoints) == 0 {
-		for _, field := range []string{pkg.Main, pkg.Module, pkg.Browser, pkg.Types} {
+		for _, field := range []string{pkg.Main, pkg.Module, browserEntry(pkg), pkg.Types} {
 			if field != "" {
-				resolved := resolveToSource(projectFolder, field)
+				resolved := resolveToSource(projectFolder, field, cfg)
 				if resolved != "" {
 					entrypoints = append(entrypoints, Entrypoint{
 						ExportPath: ".",
@@ -88,10 +124,70 @@ func FindEntrypoints(projectFolder string, pkg rush.PackageJSON) []Entrypoint {
 		}
 	}
 
+	// Without an exports field, typesVersions subpaths (`{"*": {"sub": ["dist/sub.d.ts"]}}`)
+	// are the package's subpath entrypoints.
+	if pkg.Exports == nil {
+		for _, ep := range parseTypesVersions(pkg.TypesVersions) {
+			if slices.ContainsFunc(entrypoints, func(e Entrypoint) bool { return e.ExportPath == ep.ExportPath }) {
+				continue
+			}
+			if resolved := resolveToSource(projectFolder, ep.SourceFile, cfg); resolved != "" {
+				entrypoints = append(entrypoints, Entrypoint{ExportPath: ep.ExportPath, SourceFile: resolved})
+				log.Debugf("  typesVersions entrypoint: %s → %s (from %s)", ep.ExportPath, resolved, ep.SourceFile)
+			}
+		}
+	}
+
 	log.Debugf("  total entrypoints: %d", len(entrypoints))
 	return entrypoints
 }
 
+// FindUnresolvedEntrypoints returns the package.json entrypoints that cannot be
+// resolved to a source file, formatted as "exportPath → builtPath". When there is no
+// exports field, the main/module/browser/types fallback is only reported if none of
+// the set fields resolve, and a typesVersions subpath if none of its targets resolve.
+func FindUnresolvedEntrypoints(projectFolder string, pkg rush.PackageJSON, cfg *rush.ProjectConfig) []string {
+	var unresolved []string
+	if pkg.Exports != nil {
+		for _, ep := range parseExportsField(pkg.Exports) {
+			if resolveToSource(projectFolder, ep.SourceFile, cfg) == "" {
+				unresolved = append(unresolved, ep.ExportPath+" → "+ep.SourceFile)
+			}
+		}
+		sort.Strings(unresolved)
+		return unresolved
+	}
+	var fields []string
+	for _, field := range []string{pkg.Main, pkg.Module, browserEntry(pkg), pkg.Types} {
+		if field == "" {
+			continue
+		}
+		if resolveToSource(projectFolder, field, cfg) != "" {
+			fields = nil
+			break
+		}
+		fields = append(fields, field)
+	}
+	for _, field := range fields {
+		unresolved = append(unresolved, ". → "+field)
+	}
+	resolved := make(map[string]bool)
+	var targets []Entrypoint
+	for _, ep := range parseTypesVersions(pkg.TypesVersions) {
+		if resolveToSource(projectFolder, ep.SourceFile, cfg) != "" {
+			resolved[ep.ExportPath] = true
+		} else {
+			targets = append(targets, ep)
+		}
+	}
+	for _, ep := range targets {
+		if !resolved[ep.ExportPath] {
+			unresolved = append(unresolved, ep.ExportPath+" → "+ep.SourceFile)
+		}
+	}
+	return unresolved
+}
+
 // CollectEntrypointExports returns every export name reachable from an entrypoint,
 // recursively following `export * from "./local"` chains within the same project.
 // If an `export *` points at a source that cannot be enumerated (external package
@@ -148,35 +244,49 @@ func HasTaintedImportsForGlob(projectFolder, globPattern string, upstreamTaint m
 	if len(upstreamTaint) == 0 {
 		return false
 	}
-	allFiles, err := globSourceFiles(projectFolder)
+	idx, err := importIndexFor(projectFolder)
 	if err != nil {
 		return false
 	}
-	for _, relPath := range allFiles {
-		if matched, _ := doublestar.Match(globPattern, relPath); !matched {
+	inGlob := make([]bool, len(idx.files))
+	for i, f := range idx.files {
+		if matched, _ := doublestar.Match(globPattern, f.rel); !matched || ignoreCfg.IsIgnored(f.rel) {
 			continue
 		}
-		if ignoreCfg.IsIgnored(relPath) {
-			continue
+		inGlob[i] = true
+		if len(f.parseErrors) > 0 {
+			recordParseFailure(projectFolder, f.rel, &tsparse.FileAnalysis{ParseErrors: f.parseErrors})
 		}
-		fullPath := filepath.Join(projectFolder, relPath)
-		analysis, err := tsparse.ParseFile(fullPath)
-		if err != nil {
+	}
+	for _, specifier := range idx.specifiers {
+		var uses []scannedImport
+		for _, use := range idx.bySpecifier[specifier] {
+			if inGlob[use.file] {
+				uses = append(uses, use)
+			}
+		}
+		if len(uses) == 0 {
 			continue
 		}
-		for _, imp := range analysis.Imports {
-			if strings.HasPrefix(imp.Source, ".") {
-				continue
+		affectedNames, ok := upstreamTaint[specifier]
+		if !ok || len(affectedNames) == 0 {
+			if IncludeCSS && matchesCSSTaint(specifier, upstreamTaint) {
+				log.Debugf("  HasTaintedImportsForGlob: matched CSS taint via %s in %s", specifier, idx.files[uses[0].file].rel)
+				return true
 			}
-			affectedNames, ok := upstreamTaint[imp.Source]
-			if !ok || len(affectedNames) == 0 {
-				if IncludeCSS && matchesCSSTaint(imp.Source, upstreamTaint) {
-					log.Debugf("  HasTaintedImportsForGlob: matched CSS taint via %s in %s", imp.Source, relPath)
-					return true
-				}
-				continue
+			if IncludeGraphQL && matchesGraphQLTaint(specifier, upstreamTaint) {
+				log.Debugf("  HasTaintedImportsForGlob: matched GraphQL taint via %s in %s", specifier, idx.files[uses[0].file].rel)
+				return true
 			}
+			continue
+		}
+		for _, use := range uses {
+			imp, relPath := use.imp, idx.files[use.file].rel
 			if len(imp.Names) == 0 {
+				if deadBareImport(imp, false) {
+					log.Debugf("  HasTaintedImportsForGlob: skipping bare import of side-effect-free %s in %s", imp.Source, relPath)
+					continue
+				}
 				log.Debugf("  HasTaintedImportsForGlob: matched via unassigned import of %s in %s", imp.Source, relPath)
 				return true
 			}
@@ -192,6 +302,20 @@ func HasTaintedImportsForGlob(projectFolder, globPattern string, upstreamTaint m
 			}
 		}
 	}
+	if IncludeGraphQL {
+		taintedNames := taintedGraphQLNames(upstreamTaint)
+		for i, f := range idx.files {
+			if !inGlob[i] {
+				continue
+			}
+			for _, spread := range f.spreads {
+				if taintedNames[spread] {
+					log.Debugf("  HasTaintedImportsForGlob: matched via inline gql spread of %s in %s", spread, f.rel)
+					return true
+				}
+			}
+		}
+	}
 
 	if IncludeCSS {
 		scssFiles := globStyleFiles(projectFolder)
@@ -265,14 +389,40 @@ type importEdge struct {
 	isSideEffect bool // true for unassigned imports like import "./foo"
 }
 
+// addReExportEdge adds the names of a relative re-export to the import edges of a
+// file. A file both importing and re-exporting from a source, or re-exporting from it
+// in several statements (`export { default } from "./Button"` next to
+// `export type { ButtonProps } from "./Button"`), gets a single edge carrying all the
+// names, so taint on any of them reaches the file.
+func addReExportEdge(edges []importEdge, fromStem string, exp tsparse.Export) []importEdge {
+	localName, origName := exp.LocalName, exp.LocalName
+	if exp.IsStar {
+		// export * from "./foo" — treat as namespace-like (any taint propagates)
+		localName, origName = "*:__reexport__", "*"
+	}
+	for i := range edges {
+		if edges[i].fromStem == fromStem {
+			edges[i].localNames = append(edges[i].localNames, localName)
+			edges[i].origNames = append(edges[i].origNames, origName)
+			return edges
+		}
+	}
+	return append(edges, importEdge{
+		fromStem:   fromStem,
+		localNames: []string{localName},
+		origNames:  []string{origName},
+	})
+}
+
 // AnalyzeLibraryPackage builds a full internal file dependency graph,
 // then propagates taint from changed files and upstream dependencies through unlimited hops.
 // Instead of line-range heuristics, this version diffs OLD and NEW ASTs per symbol.
+// cfg is the project's config (may be nil); its ignoreSymbols seed no taint.
 // mergeBase is the git commit to compare against. changedFiles is the full list of changed
 // file paths (repo-relative) — only files within projectFolder are considered.
 // upstreamTaint maps import specifiers (e.g. "@gooddata/sdk-ui-kit") to sets of affected export names.
 // taintedExternalDeps is a set of external package names that changed in the lockfile.
-func AnalyzeLibraryPackage(projectFolder string, entrypoints []Entrypoint, mergeBase string, changedFiles []string, includeTypes bool, upstreamTaint map[string]map[string]bool, taintedExternalDeps map[string]bool) ([]AffectedExport, error) {
+func AnalyzeLibraryPackage(ctx context.Context, projectFolder string, entrypoints []Entrypoint, cfg *rush.ProjectConfig, mergeBase string, changedFiles []string, includeTypes bool, upstreamTaint map[string]map[string]bool, taintedExternalDeps map[string]bool) ([]AffectedExport, error) {
 	// Filter changed files to those within this project
 	var projectChangedFiles []string
 	for _, f := range changedFiles {
@@ -286,6 +436,17 @@ func AnalyzeLibraryPackage(projectFolder string, entrypoints []Entrypoint, merge
 	if err != nil {
 		return nil, fmt.Errorf("globbing source files: %w", err)
 	}
+	if MaxPackageFiles > 0 && len(allFiles) > MaxPackageFiles {
+		// Too large to analyze file by file in reasonable time: taint every export.
+		recordSkippedFile(projectFolder, ".", fmt.Sprintf("%d source files exceed MAX_PACKAGE_FILES (%d); all exports tainted", len(allFiles), MaxPackageFiles))
+		var all []AffectedExport
+		for _, ep := range entrypoints {
+			if names := CollectEntrypointExports(projectFolder, ep); len(names) > 0 {
+				all = append(all, AffectedExport{EntrypointPath: ep.ExportPath, ExportNames: names})
+			}
+		}
+		return all, nil
+	}
 
 	fileAnalyses := make(map[string]*tsparse.FileAnalysis)
 	for _, relPath := range allFiles {
@@ -294,6 +455,8 @@ func AnalyzeLibraryPackage(projectFolder string, entrypoints []Entrypoint, merge
 		if err != nil {
 			continue
 		}
+		recordParseFailure(projectFolder, relPath, analysis)
+		logSuppressedImports(relPath, analysis)
 		stem := stripTSExtension(relPath)
 		fileAnalyses[stem] = analysis
 	}
@@ -310,10 +473,12 @@ func AnalyzeLibraryPackage(projectFolder string, entrypoints []Entrypoint, merge
 
 	// Build import graph (relative imports only)
 	importGraph := make(map[string][]importEdge)
+	ownSideEffectFree := projectSideEffectFree(projectFolder)
 
-	for stem, analysis := range fileAnalyses {
+	for _, stem := range mapKeys(fileAnalyses) {
+		analysis := fileAnalyses[stem]
 		fileDir := filepath.Dir(stem + ".ts")
-		for _, imp := range analysis.Imports {
+		for _, imp := range runtimeImports(analysis, includeTypes) {
 			if !strings.HasPrefix(imp.Source, ".") {
 				continue
 			}
@@ -321,6 +486,10 @@ func AnalyzeLibraryPackage(projectFolder string, entrypoints []Entrypoint, merge
 			if resolvedStem == "" {
 				continue
 			}
+			if deadBareImport(imp, ownSideEffectFree) {
+				log.Debugf("  %s: skipping bare import of %s (package is side-effect free)", stem, imp.Source)
+				continue
+			}
 			var localNames, origNames []string
 			for i, name := range imp.Names {
 				local := importLocalName(imp, i)
@@ -344,40 +513,14 @@ func AnalyzeLibraryPackage(projectFolder string, entrypoints []Entrypoint, merge
 		// as import edges — barrel files have no import statements but still depend
 		// on the files they re-export from.
 		for _, exp := range analysis.Exports {
-			if exp.Source == "" || !strings.HasPrefix(exp.Source, ".") {
+			if exp.Source == "" || !strings.HasPrefix(exp.Source, ".") || (exp.IsTypeOnly && !includeTypes) {
 				continue
 			}
 			resolvedStem := resolveImportSource(fileDir, exp.Source, projectFolder)
 			if resolvedStem == "" {
 				continue
 			}
-			// Check if we already have an import edge to this source
-			// (to avoid duplicating edges when a file both imports and re-exports)
-			alreadyHasEdge := false
-			for _, edge := range importGraph[stem] {
-				if edge.fromStem == resolvedStem {
-					alreadyHasEdge = true
-					break
-				}
-			}
-			if alreadyHasEdge {
-				continue
-			}
-			// Create a synthetic import edge for the re-export
-			var localNames, origNames []string
-			if exp.IsStar {
-				// export * from "./foo" — treat as namespace-like (any taint propagates)
-				localNames = append(localNames, "*:__reexport__")
-				origNames = append(origNames, "*")
-			} else {
-				localNames = append(localNames, exp.LocalName)
-				origNames = append(origNames, exp.LocalName)
-			}
-			importGraph[stem] = append(importGraph[stem], importEdge{
-				fromStem:   resolvedStem,
-				localNames: localNames,
-				origNames:  origNames,
-			})
+			importGraph[stem] = addReExportEdge(importGraph[stem], resolvedStem, exp)
 		}
 	}
 
@@ -386,27 +529,43 @@ func AnalyzeLibraryPackage(projectFolder string, entrypoints []Entrypoint, merge
 	// compare each symbol's body text to determine which symbols actually changed.
 	// Distinguishes runtime changes from type-only changes (e.g. adding `as Type`).
 	tainted := make(map[string]map[string]bool)
+	// Renamed symbols (new name → old names). Consumers still importing an old name
+	// are broken, so old names are reported as affected wherever the new name is.
+	renamedFrom := make(map[string][]string)
+
+	var changedAugs []augmentation         // changed `declare global` / `declare module` blocks
+	seedChanges := make(map[string]string) // "stem#symbol" → changeProps / changeRender of changed components
 
 	log.Debugf("=== Seeding taint from AST diff for %s ===", projectFolder)
 	log.Debugf("  Changed files in project: %d", len(projectChangedFiles))
 	for _, changedFile := range projectChangedFiles {
 		relToProject := strings.TrimPrefix(changedFile, projectFolder+"/")
-		ext := strings.ToLower(filepath.Ext(relToProject))
-		if ext != ".ts" && ext != ".tsx" && ext != ".js" && ext != ".jsx" {
+		if !isSourceFile(relToProject) {
 			log.Debugf("  skipping non-TS file: %s", relToProject)
 			continue
 		}
 		stem := stripTSExtension(relToProject)
 		newAnalysis := fileAnalyses[stem]
+		if newAnalysis == nil && isSkippedFile(projectFolder, relToProject) {
+			// Not parsed (MAX_FILE_SIZE): everything importing the file is tainted.
+			log.Debugf("  %s: skipped by size guard, tainting all its imports", stem)
+			if tainted[stem] == nil {
+				tainted[stem] = make(map[string]bool)
+			}
+			tainted[stem]["*"] = true
+			continue
+		}
 		if newAnalysis == nil {
 			log.Debugf("  WARNING: no analysis found for stem %q", stem)
 			continue
 		}
 
 		// Get old file content from git
-		oldContent, err := git.ShowFile(mergeBase, changedFile)
+		oldContent, err := mergeBaseContent(ctx, mergeBase, changedFile)
 		if err != nil {
-			oldContent = ""
+			// Diffing against an empty base would miss removed exports; let the caller
+			// decide how to treat the package.
+			return nil, fmt.Errorf("reading %s at merge base: %w", changedFile, err)
 		}
 
 		var oldAnalysis *tsparse.FileAnalysis
@@ -414,10 +573,25 @@ func AnalyzeLibraryPackage(projectFolder string, entrypoints []Entrypoint, merge
 			oldAnalysis, _ = tsparse.ParseContent(oldContent, changedFile)
 		}
 
-		affected := findAffectedSymbolsByASTDiff(oldAnalysis, newAnalysis, oldContent, includeTypes)
+		affected, changes := findAffectedSymbolsByASTDiff(oldAnalysis, newAnalysis, oldContent, includeTypes, cfg.IgnoredSymbols(relToProject))
 		log.Debugf("  %s: affected symbols (AST diff): %v", stem, affected)
+		if includeTypes {
+			changedAugs = append(changedAugs, changedAugmentations(stem, oldAnalysis, newAnalysis)...)
+		}
+		renames := detectRenames(oldAnalysis, newAnalysis)
+		for _, newName := range mapKeys(renames) {
+			oldName := renames[newName]
+			log.Basicf("  Rename detected in %s: %s → %s", relToProject, oldName, newName)
+			renamedFrom[newName] = append(renamedFrom[newName], oldName)
+		}
 
 		if len(affected) > 0 {
+			recordChangeSeeds(projectFolder, changedFile, affected, changes, newAnalysis)
+			for _, s := range affected {
+				if changes[s] != "" {
+					seedChanges[stem+"#"+s] = changes[s]
+				}
+			}
 			if tainted[stem] == nil {
 				tainted[stem] = make(map[string]bool)
 			}
@@ -427,6 +601,11 @@ func AnalyzeLibraryPackage(projectFolder string, entrypoints []Entrypoint, merge
 		}
 	}
 
+	// Augmentations declare nothing exported; they change the types consumers see.
+	if len(changedAugs) > 0 {
+		seedAugmentationTaint(projectFolder, changedAugs, fileAnalyses, tainted)
+	}
+
 	// Determine the full set of tainted style files in this package. A style file is
 	// tainted if it was directly changed, or if it @use's the styles of a CSS-tainted
 	// upstream package (Pattern A — JS-bundled CSS). A TS file that side-effect-imports
@@ -455,7 +634,8 @@ func AnalyzeLibraryPackage(projectFolder string, entrypoints []Entrypoint, merge
 	// For CSS module imports (*.module.scss/css) with named bindings, only taint symbols
 	// that use the imported binding. For all other style imports, taint all symbols.
 	if len(taintedStyleFiles) > 0 {
-		for stem, analysis := range fileAnalyses {
+		for _, stem := range mapKeys(fileAnalyses) {
+			analysis := fileAnalyses[stem]
 			for _, imp := range analysis.Imports {
 				if !strings.HasPrefix(imp.Source, ".") {
 					continue
@@ -501,7 +681,8 @@ func AnalyzeLibraryPackage(projectFolder string, entrypoints []Entrypoint, merge
 		}
 	}
 	if len(changedJSONFiles) > 0 {
-		for stem, analysis := range fileAnalyses {
+		for _, stem := range mapKeys(fileAnalyses) {
+			analysis := fileAnalyses[stem]
 			for _, imp := range analysis.Imports {
 				if !strings.HasPrefix(imp.Source, ".") {
 					continue
@@ -533,10 +714,19 @@ func AnalyzeLibraryPackage(projectFolder string, entrypoints []Entrypoint, merge
 		}
 	}
 
+	// Seed taint from changed binary assets (the "asset" binaries policy).
+	seedAssetTaint(projectFolder, fileAnalyses, tainted)
+
+	// Seed taint from changed GraphQL documents and tainted fragments (opt-in).
+	if IncludeGraphQL {
+		seedGraphQLTaint(projectFolder, projectChangedFiles, fileAnalyses, upstreamTaint, tainted)
+	}
+
 	// Seed taint from upstream dependencies (cross-package propagation)
 	if len(upstreamTaint) > 0 {
-		for stem, analysis := range fileAnalyses {
-			for _, imp := range analysis.Imports {
+		for _, stem := range mapKeys(fileAnalyses) {
+			analysis := fileAnalyses[stem]
+			for _, imp := range runtimeImports(analysis, includeTypes) {
 				if strings.HasPrefix(imp.Source, ".") {
 					continue
 				}
@@ -556,6 +746,10 @@ func AnalyzeLibraryPackage(projectFolder string, entrypoints []Entrypoint, merge
 					continue
 				}
 				if len(imp.Names) == 0 {
+					if deadBareImport(imp, false) {
+						log.Debugf("    %s: skipping bare import of side-effect-free %s", stem, imp.Source)
+						continue
+					}
 					// Unassigned import from tainted upstream dep: taint all symbols
 					if tainted[stem] == nil {
 						tainted[stem] = make(map[string]bool)
@@ -609,15 +803,20 @@ func AnalyzeLibraryPackage(projectFolder string, entrypoints []Entrypoint, merge
 	// All imports from these deps are considered tainted since we can't know which
 	// specific exports of the external package changed.
 	if len(taintedExternalDeps) > 0 {
-		for stem, analysis := range fileAnalyses {
+		for _, stem := range mapKeys(fileAnalyses) {
+			analysis := fileAnalyses[stem]
 			// Check imports from tainted external deps
-			for _, imp := range analysis.Imports {
+			for _, imp := range runtimeImports(analysis, includeTypes) {
 				if strings.HasPrefix(imp.Source, ".") {
 					continue
 				}
 				if !isFromTaintedDep(imp.Source, taintedExternalDeps) {
 					continue
 				}
+				if deadBareImport(imp, false) {
+					log.Debugf("    %s: skipping bare import of side-effect-free %s", stem, imp.Source)
+					continue
+				}
 				if tainted[stem] == nil {
 					tainted[stem] = make(map[string]bool)
 				}
@@ -670,13 +869,17 @@ func AnalyzeLibraryPackage(projectFolder string, entrypoints []Entrypoint, merge
 		}
 	}
 
-	log.Debugf("=== Initial taint map (after diff seed) ===")
-	for stem, names := range tainted {
-		nameList := make([]string, 0, len(names))
-		for n := range names {
-			nameList = append(nameList, n)
+	if metrics.Enabled() {
+		seeds := 0
+		for _, names := range tainted {
+			seeds += len(names)
 		}
-		log.Debugf("  %s: %v", stem, nameList)
+		metrics.Add("goodchanges_taint_seeds_total", float64(seeds))
+	}
+
+	log.Debugf("=== Initial taint map (after diff seed) ===")
+	for _, stem := range mapKeys(tainted) {
+		log.Debugf("  %s: %v", stem, mapKeys(tainted[stem]))
 	}
 
 	if len(tainted) == 0 {
@@ -689,7 +892,8 @@ func AnalyzeLibraryPackage(projectFolder string, entrypoints []Entrypoint, merge
 	// in the same file that reference A should also be tainted.
 	// Example: if KdaDialogController taints KeyDriverAnalysisComponent, then
 	// KeyDriverAnalysis = connect(...)(KeyDriverAnalysisComponent) should also be tainted.
-	for stem, names := range tainted {
+	for _, stem := range mapKeys(tainted) {
+		names := tainted[stem]
 		analysis := fileAnalyses[stem]
 		if analysis == nil || analysis.SourceFile == nil {
 			continue
@@ -704,7 +908,7 @@ func AnalyzeLibraryPackage(projectFolder string, entrypoints []Entrypoint, merge
 					continue
 				}
 				bodyText := tsparse.ExtractTextForLines(sourceText, lineMap, sym.StartLine, sym.EndLine)
-				for tName := range names {
+				for _, tName := range mapKeys(names) {
 					if strings.Contains(bodyText, tName) {
 						names[sym.Name] = true
 						changed = true
@@ -714,22 +918,38 @@ func AnalyzeLibraryPackage(projectFolder string, entrypoints []Entrypoint, merge
 				}
 			}
 		}
+		for _, alias := range localExportAliases(analysis, names) {
+			names[alias] = true
+			log.Debugf("  %s: %s tainted as export of a tainted local binding (seed propagation)", stem, alias)
+		}
 	}
 
 	// Build reverse import graph
 	reverseImports := make(map[string][]string)
-	for stem, edges := range importGraph {
-		for _, edge := range edges {
+	for _, stem := range mapKeys(importGraph) {
+		for _, edge := range importGraph[stem] {
 			reverseImports[edge.fromStem] = append(reverseImports[edge.fromStem], stem)
 		}
 	}
 
+	// Machine-parseable trace of the analysis: seeds, BFS additions with the import edge
+	// that caused them, and affected entrypoint exports.
+	log.Recordf("begin", "pkg=%s", projectFolder)
+	defer log.Recordf("end", "pkg=%s", projectFolder)
+	for _, stem := range mapKeys(tainted) {
+		for _, sym := range mapKeys(tainted[stem]) {
+			if change := seedChanges[stem+"#"+sym]; change != "" {
+				log.Recordf("seed", "pkg=%s file=%s symbol=%s change=%s", projectFolder, stem, sym, change)
+			} else {
+				log.Recordf("seed", "pkg=%s file=%s symbol=%s", projectFolder, stem, sym)
+			}
+		}
+	}
+
 	// Propagate taint — BFS, unlimited hops
 	log.Debugf("=== Starting BFS taint propagation ===")
-	queue := make([]string, 0, len(tainted))
-	for stem := range tainted {
-		queue = append(queue, stem)
-	}
+	contextGroups := findContextGroups(projectFolder, fileAnalyses)
+	queue := mapKeys(tainted)
 
 	for len(queue) > 0 {
 		currentStem := queue[0]
@@ -775,23 +995,35 @@ func AnalyzeLibraryPackage(projectFolder string, entrypoints []Entrypoint, merge
 			log.Debugf("    → %s: sideEffect=%v taintedLocalNames=%v", importerStem, hasSideEffectImport, taintedLocalNames)
 
 			var newlyTainted []string
+			via := make(map[string]string) // newly tainted symbol → how (for @bfs records)
+			addTainted := func(name, how string) {
+				newlyTainted = append(newlyTainted, name)
+				if via[name] == "" {
+					via[name] = how
+				}
+			}
 
 			// Unassigned import from tainted file: all symbols in this file are tainted
 			if hasSideEffectImport && len(currentTainted) > 0 {
 				for _, sym := range importerAnalysis.Symbols {
-					newlyTainted = append(newlyTainted, sym.Name)
+					addTainted(sym.Name, "side-effect-import")
 				}
 			}
 
 			// Named imports: find symbols that use the tainted imports
 			if len(taintedLocalNames) > 0 {
-				usageTainted := findTaintedSymbolsByUsage(importerAnalysis, taintedLocalNames)
-				newlyTainted = append(newlyTainted, usageTainted...)
+				for _, name := range findTaintedSymbolsByUsage(importerAnalysis, taintedLocalNames) {
+					addTainted(name, "usage:"+strings.Join(taintedLocalNames, ","))
+				}
 			}
 
 			// Handle re-exports
+			// (type-only ones carry no taint unless includeTypes, at any hop)
 			importerDir := filepath.Dir(importerStem + ".ts")
 			for _, exp := range importerAnalysis.Exports {
+				if !includeTypes && (exp.IsTypeOnly || isTypeOnlyExport(importerAnalysis, exp.Name)) {
+					continue
+				}
 				if exp.Source == "" {
 					for _, tln := range taintedLocalNames {
 						cleanName := tln
@@ -799,18 +1031,22 @@ func AnalyzeLibraryPackage(projectFolder string, entrypoints []Entrypoint, merge
 							cleanName = strings.TrimPrefix(cleanName, "*:")
 						}
 						if exp.LocalName == cleanName {
-							newlyTainted = append(newlyTainted, exp.Name)
+							addTainted(exp.Name, "re-export:"+cleanName)
 						}
 					}
 				} else {
 					reExpStem := resolveImportSource(importerDir, exp.Source, projectFolder)
 					if reExpStem == currentStem {
 						if exp.IsStar {
-							for name := range currentTainted {
-								newlyTainted = append(newlyTainted, name)
+							for _, name := range mapKeys(currentTainted) {
+								if includeTypes || !isTypeOnlyExport(fileAnalyses[currentStem], name) {
+									addTainted(name, "re-export:*")
+								}
 							}
+						} else if !includeTypes && isTypeOnlyExport(fileAnalyses[currentStem], exp.LocalName) {
+							continue
 						} else if currentTainted[exp.LocalName] || currentTainted["*"] {
-							newlyTainted = append(newlyTainted, exp.Name)
+							addTainted(exp.Name, "re-export:"+exp.LocalName)
 						}
 					}
 				}
@@ -833,10 +1069,10 @@ func AnalyzeLibraryPackage(projectFolder string, entrypoints []Entrypoint, merge
 							continue
 						}
 						bodyText := tsparse.ExtractTextForLines(sourceText, lineMap, sym.StartLine, sym.EndLine)
-						for tName := range taintedSet {
+						for _, tName := range mapKeys(taintedSet) {
 							if strings.Contains(bodyText, tName) {
 								taintedSet[sym.Name] = true
-								newlyTainted = append(newlyTainted, sym.Name)
+								addTainted(sym.Name, "intra-file:"+tName)
 								changed = true
 								log.Debugf("    → %s: %s tainted via intra-file dep on %s", importerStem, sym.Name, tName)
 								break
@@ -846,6 +1082,17 @@ func AnalyzeLibraryPackage(projectFolder string, entrypoints []Entrypoint, merge
 				}
 			}
 
+			// Exports of newly tainted local bindings (`export default Button`).
+			if len(newlyTainted) > 0 {
+				taintedSet := make(map[string]bool)
+				for _, n := range newlyTainted {
+					taintedSet[n] = true
+				}
+				for _, alias := range localExportAliases(importerAnalysis, taintedSet) {
+					addTainted(alias, "local-export")
+				}
+			}
+
 			if len(newlyTainted) == 0 {
 				log.Debugf("    → %s: re-export/usage check found nothing new", importerStem)
 				continue
@@ -861,22 +1108,25 @@ func AnalyzeLibraryPackage(projectFolder string, entrypoints []Entrypoint, merge
 				if !tainted[importerStem][name] {
 					tainted[importerStem][name] = true
 					addedNew = true
+					log.Recordf("bfs", "pkg=%s file=%s symbol=%s from=%s via=%s", projectFolder, importerStem, name, currentStem, via[name])
 				}
 			}
 			if addedNew {
 				queue = append(queue, importerStem)
 			}
 		}
+
+		// Once the import graph is exhausted, spread taint through React context groups
+		// and continue from the files that gained it.
+		if len(queue) == 0 {
+			queue = taintContextGroups(projectFolder, contextGroups, fileAnalyses, tainted, true)
+		}
 	}
 
 	// Check entrypoints for tainted exports
 	log.Debugf("=== Final taint map (after BFS) ===")
-	for stem, names := range tainted {
-		nameList := make([]string, 0, len(names))
-		for n := range names {
-			nameList = append(nameList, n)
-		}
-		log.Debugf("  %s: %v", stem, nameList)
+	for _, stem := range mapKeys(tainted) {
+		log.Debugf("  %s: %v", stem, mapKeys(tainted[stem]))
 	}
 
 	var result []AffectedExport
@@ -916,6 +1166,9 @@ func AnalyzeLibraryPackage(projectFolder string, entrypoints []Entrypoint, merge
 			}
 
 			if exp.Source == "" {
+				if !includeTypes && isTypeOnlyExport(epAnalysis, exp.Name) {
+					continue
+				}
 				if tainted[epStem][exp.LocalName] || tainted[epStem]["*"] {
 					affectedNames = append(affectedNames, exp.Name)
 				}
@@ -949,10 +1202,17 @@ func AnalyzeLibraryPackage(projectFolder string, entrypoints []Entrypoint, merge
 			log.Debugf("    export %q from %q → stem %q: tainted=%v star=%v localName=%q",
 				exp.Name, exp.Source, resolvedStem, mapKeys(srcTainted), exp.IsStar, exp.LocalName)
 
+			// Per specifier: `export { Foo, type FooProps } from "./Foo"` reports Foo only,
+			// and a star re-export leaves out the source's type-only exports.
+			srcAnalysis := fileAnalyses[resolvedStem]
 			if exp.IsStar {
-				for name := range srcTainted {
-					affectedNames = append(affectedNames, name)
+				for _, name := range mapKeys(srcTainted) {
+					if includeTypes || !isTypeOnlyExport(srcAnalysis, name) {
+						affectedNames = append(affectedNames, name)
+					}
 				}
+			} else if !includeTypes && isTypeOnlyExport(srcAnalysis, exp.LocalName) {
+				continue
 			} else if srcTainted[exp.LocalName] || srcTainted["*"] {
 				affectedNames = append(affectedNames, exp.Name)
 			}
@@ -970,6 +1230,19 @@ func AnalyzeLibraryPackage(projectFolder string, entrypoints []Entrypoint, merge
 					deduped = append(deduped, n)
 				}
 			}
+			for _, n := range deduped {
+				for _, oldName := range renamedFrom[n] {
+					if !seen[oldName] {
+						seen[oldName] = true
+						deduped = append(deduped, oldName)
+						log.Debugf("  %s: old name of renamed %s — tainted for downstream importers", oldName, n)
+					}
+				}
+			}
+			sort.Strings(deduped)
+			for _, n := range deduped {
+				log.Recordf("export", "pkg=%s entrypoint=%s name=%s", projectFolder, ep.ExportPath, n)
+			}
 			result = append(result, AffectedExport{
 				EntrypointPath: ep.ExportPath,
 				ExportNames:    deduped,
@@ -980,6 +1253,37 @@ func AnalyzeLibraryPackage(projectFolder string, entrypoints []Entrypoint, merge
 	return result, nil
 }
 
+// runtimeImports returns the imports of a file that can carry taint. Unless
+// includeTypes is set, type-only imports (`import type { X }`) and type-only
+// specifiers (`import { type X }`) are dropped: they are erased from the emitted
+// JavaScript, so a change behind them cannot affect runtime behavior.
+func runtimeImports(analysis *tsparse.FileAnalysis, includeTypes bool) []tsparse.Import {
+	if includeTypes {
+		return analysis.Imports
+	}
+	var imports []tsparse.Import
+	for _, imp := range analysis.Imports {
+		if imp.IsTypeOnly {
+			continue
+		}
+		if imp.TypeOnlyNames == nil {
+			imports = append(imports, imp)
+			continue
+		}
+		kept := tsparse.Import{Source: imp.Source}
+		for i, name := range imp.Names {
+			if !imp.IsTypeOnlyName(i) {
+				kept.Names = append(kept.Names, name)
+				kept.LocalNames = append(kept.LocalNames, importLocalName(imp, i))
+			}
+		}
+		if len(kept.Names) > 0 {
+			imports = append(imports, kept)
+		}
+	}
+	return imports
+}
+
 // importLocalName returns the local binding name for the i-th imported name,
 // falling back to the source-side name when LocalNames is absent (e.g. older
 // Import entries). For `import { X as Y }`, imp.Names[i] is "X" and the local
@@ -1030,6 +1334,70 @@ func findTaintedSymbolsByUsage(analysis *tsparse.FileAnalysis, taintedNames []st
 	return result
 }
 
+// localExportAliases returns the names under which tainted local bindings of a file
+// are exported (`export default Button`, `export { Button as PrimaryButton }`) that
+// are not tainted yet. Within a file taint is tracked by local binding, across files
+// by export name, so both must be tainted for importers of either to see the change.
+func localExportAliases(analysis *tsparse.FileAnalysis, names map[string]bool) []string {
+	var aliases []string
+	for _, exp := range analysis.Exports {
+		if exp.Source != "" || exp.IsStar || exp.Name == exp.LocalName {
+			continue
+		}
+		if names[exp.LocalName] && !names[exp.Name] && !slices.Contains(aliases, exp.Name) {
+			aliases = append(aliases, exp.Name)
+		}
+	}
+	return aliases
+}
+
+// isTypeOnlyExport reports whether name, as exported or declared by the file, exists
+// only at the type level: exported with a `type` modifier (`export type { X }`,
+// `export { type X }`), or declared only as interfaces and type aliases. Such names
+// are erased from the emitted JavaScript.
+func isTypeOnlyExport(analysis *tsparse.FileAnalysis, name string) bool {
+	if analysis == nil {
+		return false
+	}
+	for _, exp := range analysis.Exports {
+		if exp.Name != name || exp.IsStar {
+			continue
+		}
+		if exp.IsTypeOnly {
+			return true
+		}
+		if exp.Source != "" {
+			return false
+		}
+		name = exp.LocalName
+		break
+	}
+	declared := false
+	for _, sym := range analysis.Symbols {
+		if sym.Name == name {
+			if !sym.IsTypeOnly {
+				return false // declaration merging: also a runtime value
+			}
+			declared = true
+		}
+	}
+	return declared
+}
+
+// defaultExportIdentity returns the local binding a file exports as default ("default"
+// for an anonymous default export), or "" when it has no local default export.
+func defaultExportIdentity(analysis *tsparse.FileAnalysis) string {
+	if analysis == nil {
+		return ""
+	}
+	for _, exp := range analysis.Exports {
+		if exp.Name == "default" && exp.Source == "" {
+			return exp.LocalName
+		}
+	}
+	return ""
+}
+
 // isFromTaintedDep checks if an import source matches any tainted external dep name.
 // Handles both exact matches (e.g. "react") and subpath imports (e.g. "react/jsx-runtime"),
 // as well as scoped packages (e.g. "@emotion/react", "@emotion/react/utils").
@@ -1042,11 +1410,13 @@ func isFromTaintedDep(importSource string, taintedDeps map[string]bool) bool {
 	return false
 }
 
-func mapKeys(m map[string]bool) []string {
+// mapKeys returns the keys of m sorted, so logs and results don't depend on map order.
+func mapKeys[V any](m map[string]V) []string {
 	keys := make([]string, 0, len(m))
 	for k := range m {
 		keys = append(keys, k)
 	}
+	sort.Strings(keys)
 	return keys
 }
 
@@ -1060,12 +1430,9 @@ func FindCSSTaintedPackages(changedFiles []string, rushConfig *rush.Config, proj
 		if ext != ".scss" && ext != ".css" {
 			continue
 		}
-		for _, rp := range rushConfig.Projects {
-			if strings.HasPrefix(f, rp.ProjectFolder+"/") {
-				result[rp.PackageName] = true
-				log.Debugf("FindCSSTaintedPackages: %s tainted via %s", rp.PackageName, f)
-				break
-			}
+		if rp := rushConfig.ProjectForFile(f); rp != nil {
+			result[rp.PackageName] = true
+			log.Debugf("FindCSSTaintedPackages: %s tainted via %s", rp.PackageName, f)
 		}
 	}
 	log.Debugf("FindCSSTaintedPackages: %d packages tainted", len(result))
@@ -1128,23 +1495,11 @@ func PropagateCSSTaint(rushConfig *rush.Config, projectMap map[string]*rush.Proj
 // globStyleFiles returns all .scss and .css files relative to projectFolder.
 func globStyleFiles(projectFolder string) []string {
 	var files []string
-	filepath.Walk(projectFolder, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil
-		}
-		if info.IsDir() {
-			base := filepath.Base(path)
-			if base == "node_modules" || base == ".git" || base == "dist" || base == "esm" {
-				return filepath.SkipDir
-			}
-			return nil
-		}
+	walkProjectFiles(projectFolder, func(path, rel string, _ fs.DirEntry) {
 		ext := strings.ToLower(filepath.Ext(path))
 		if ext == ".scss" || ext == ".css" {
-			rel, _ := filepath.Rel(projectFolder, path)
 			files = append(files, rel)
 		}
-		return nil
 	})
 	return files
 }
@@ -1190,7 +1545,10 @@ func parseScssUses(filePath string) []string {
 // Only TS/TSX source files are considered (fine-grained mode).
 // Ignores override glob matches.
 // If filterPattern is non-empty, only affected files matching it are returned.
-func FindAffectedFiles(globPattern string, filterPattern string, upstreamTaint map[string]map[string]bool, changedFiles []string, projectFolder string, ignoreCfg *rush.ProjectConfig, taintedExternalDeps map[string]bool, mergeBase string, includeTypes bool) []string {
+// With fullGraph, every source file of the project joins the import graph, so taint
+// reaches matching files through files outside the glob (e.g. tests importing app
+// modules); only matching files are returned.
+func FindAffectedFiles(ctx context.Context, globPattern string, filterPattern string, upstreamTaint map[string]map[string]bool, changedFiles []string, projectFolder string, ignoreCfg *rush.ProjectConfig, taintedExternalDeps map[string]bool, mergeBase string, includeTypes bool, fullGraph bool) []string {
 	allFiles, err := globSourceFiles(projectFolder)
 	if err != nil {
 		return nil
@@ -1202,8 +1560,10 @@ func FindAffectedFiles(globPattern string, filterPattern string, upstreamTaint m
 	// Filter to files matching the glob (and not ignored), keyed by stem
 	fileAnalyses := make(map[string]*tsparse.FileAnalysis) // keyed by stem
 	stemToRel := make(map[string]string)                   // stem -> original rel path
+	outsideGlob := make(map[string]bool)                   // fullGraph stems not matching the glob
 	for _, rel := range allFiles {
-		if matched, _ := doublestar.Match(globPattern, rel); !matched {
+		matched, _ := doublestar.Match(globPattern, rel)
+		if !matched && !fullGraph {
 			continue
 		}
 		if ignoreCfg.IsIgnored(rel) {
@@ -1214,18 +1574,25 @@ func FindAffectedFiles(globPattern string, filterPattern string, upstreamTaint m
 		if err != nil {
 			continue
 		}
+		recordParseFailure(projectFolder, rel, analysis)
+		logSuppressedImports(rel, analysis)
 		stem := stripTSExtension(rel)
 		fileAnalyses[stem] = analysis
 		stemToRel[stem] = rel
+		if !matched {
+			outsideGlob[stem] = true
+		}
 	}
 
-	log.Debugf("  files matching glob: %d", len(fileAnalyses))
+	log.Debugf("  files matching glob: %d (plus %d in the full graph)", len(fileAnalyses)-len(outsideGlob), len(outsideGlob))
 
 	// Build import graph (relative imports + re-exports)
 	localImportGraph := make(map[string][]importEdge)
-	for stem, analysis := range fileAnalyses {
+	ownSideEffectFree := projectSideEffectFree(projectFolder)
+	for _, stem := range mapKeys(fileAnalyses) {
+		analysis := fileAnalyses[stem]
 		fileDir := filepath.Dir(stem + ".ts")
-		for _, imp := range analysis.Imports {
+		for _, imp := range runtimeImports(analysis, includeTypes) {
 			if !strings.HasPrefix(imp.Source, ".") {
 				continue
 			}
@@ -1236,6 +1603,10 @@ func FindAffectedFiles(globPattern string, filterPattern string, upstreamTaint m
 			if _, ok := fileAnalyses[resolvedStem]; !ok {
 				continue
 			}
+			if deadBareImport(imp, ownSideEffectFree) {
+				log.Debugf("  %s: skipping bare import of %s (package is side-effect free)", stem, imp.Source)
+				continue
+			}
 			var localNames, origNames []string
 			for i, name := range imp.Names {
 				local := importLocalName(imp, i)
@@ -1256,7 +1627,7 @@ func FindAffectedFiles(globPattern string, filterPattern string, upstreamTaint m
 		}
 		// Re-exports as import edges
 		for _, exp := range analysis.Exports {
-			if exp.Source == "" || !strings.HasPrefix(exp.Source, ".") {
+			if exp.Source == "" || !strings.HasPrefix(exp.Source, ".") || (exp.IsTypeOnly && !includeTypes) {
 				continue
 			}
 			resolvedStem := resolveImportSource(fileDir, exp.Source, projectFolder)
@@ -1266,29 +1637,7 @@ func FindAffectedFiles(globPattern string, filterPattern string, upstreamTaint m
 			if _, ok := fileAnalyses[resolvedStem]; !ok {
 				continue
 			}
-			alreadyHasEdge := false
-			for _, edge := range localImportGraph[stem] {
-				if edge.fromStem == resolvedStem {
-					alreadyHasEdge = true
-					break
-				}
-			}
-			if alreadyHasEdge {
-				continue
-			}
-			var localNames, origNames []string
-			if exp.IsStar {
-				localNames = append(localNames, "*:__reexport__")
-				origNames = append(origNames, "*")
-			} else {
-				localNames = append(localNames, exp.LocalName)
-				origNames = append(origNames, exp.LocalName)
-			}
-			localImportGraph[stem] = append(localImportGraph[stem], importEdge{
-				fromStem:   resolvedStem,
-				localNames: localNames,
-				origNames:  origNames,
-			})
+			localImportGraph[stem] = addReExportEdge(localImportGraph[stem], resolvedStem, exp)
 		}
 	}
 
@@ -1296,8 +1645,8 @@ func FindAffectedFiles(globPattern string, filterPattern string, upstreamTaint m
 
 	// Build reverse import map for BFS traversal
 	reverseImports := make(map[string][]string)
-	for stem, edges := range localImportGraph {
-		for _, edge := range edges {
+	for _, stem := range mapKeys(localImportGraph) {
+		for _, edge := range localImportGraph[stem] {
 			reverseImports[edge.fromStem] = append(reverseImports[edge.fromStem], stem)
 		}
 	}
@@ -1307,6 +1656,7 @@ func FindAffectedFiles(globPattern string, filterPattern string, upstreamTaint m
 
 	log.Debugf("=== Seeding taint from AST diff (FindAffectedFiles) ===")
 	// Seed from AST diff of directly changed files
+	var changedAugs []augmentation
 	for _, f := range changedFiles {
 		if !strings.HasPrefix(f, projectFolder+"/") {
 			continue
@@ -1317,7 +1667,7 @@ func FindAffectedFiles(globPattern string, filterPattern string, upstreamTaint m
 		if !ok {
 			continue
 		}
-		oldContent, err := git.ShowFile(mergeBase, f)
+		oldContent, err := mergeBaseContent(ctx, mergeBase, f)
 		if err != nil {
 			oldContent = ""
 		}
@@ -1325,8 +1675,12 @@ func FindAffectedFiles(globPattern string, filterPattern string, upstreamTaint m
 		if oldContent != "" {
 			oldAnalysis, _ = tsparse.ParseContent(oldContent, f)
 		}
-		changedSymbols := findAffectedSymbolsByASTDiff(oldAnalysis, analysis, oldContent, includeTypes)
+		ignored := ignoreCfg.IgnoredSymbols(rel)
+		changedSymbols, _ := findAffectedSymbolsByASTDiff(oldAnalysis, analysis, oldContent, includeTypes, ignored)
 		log.Debugf("  %s: affected symbols (AST diff): %v", stem, changedSymbols)
+		if includeTypes {
+			changedAugs = append(changedAugs, changedAugmentations(stem, oldAnalysis, analysis)...)
+		}
 		if tainted[stem] == nil {
 			tainted[stem] = make(map[string]bool)
 		}
@@ -1341,18 +1695,23 @@ func FindAffectedFiles(globPattern string, filterPattern string, upstreamTaint m
 			for _, s := range changedSymbols {
 				tainted[stem][s] = true
 			}
-		} else {
+		} else if len(ignored) == 0 {
 			// File changed but no symbol-level diff detected (e.g. changes in
-			// test()/describe() blocks or other non-declaration code).
+			// test()/describe() blocks or other non-declaration code). Files with
+			// ignoreSymbols are assumed to have changed only in those.
 			tainted[stem]["*"] = true
 		}
 	}
+	if len(changedAugs) > 0 {
+		seedAugmentationTaint(projectFolder, changedAugs, fileAnalyses, tainted)
+	}
 
 	// Seed from upstream workspace taint
 	log.Debugf("=== Seeding taint from upstream workspace (FindAffectedFiles) ===")
 	if len(upstreamTaint) > 0 {
-		for stem, analysis := range fileAnalyses {
-			for _, imp := range analysis.Imports {
+		for _, stem := range mapKeys(fileAnalyses) {
+			analysis := fileAnalyses[stem]
+			for _, imp := range runtimeImports(analysis, includeTypes) {
 				if strings.HasPrefix(imp.Source, ".") {
 					continue
 				}
@@ -1370,6 +1729,10 @@ func FindAffectedFiles(globPattern string, filterPattern string, upstreamTaint m
 					continue
 				}
 				if len(imp.Names) == 0 {
+					if deadBareImport(imp, false) {
+						log.Debugf("    %s: skipping bare import of side-effect-free %s", stem, imp.Source)
+						continue
+					}
 					// Unassigned import: taint all symbols
 					if tainted[stem] == nil {
 						tainted[stem] = make(map[string]bool)
@@ -1407,14 +1770,19 @@ func FindAffectedFiles(globPattern string, filterPattern string, upstreamTaint m
 	// Seed from tainted external dependencies (lockfile changes)
 	log.Debugf("=== Seeding taint from external deps (FindAffectedFiles) ===")
 	if len(taintedExternalDeps) > 0 {
-		for stem, analysis := range fileAnalyses {
-			for _, imp := range analysis.Imports {
+		for _, stem := range mapKeys(fileAnalyses) {
+			analysis := fileAnalyses[stem]
+			for _, imp := range runtimeImports(analysis, includeTypes) {
 				if strings.HasPrefix(imp.Source, ".") {
 					continue
 				}
 				if !isFromTaintedDep(imp.Source, taintedExternalDeps) {
 					continue
 				}
+				if deadBareImport(imp, false) {
+					log.Debugf("    %s: skipping bare import of side-effect-free %s", stem, imp.Source)
+					continue
+				}
 				if tainted[stem] == nil {
 					tainted[stem] = make(map[string]bool)
 				}
@@ -1451,7 +1819,8 @@ func FindAffectedFiles(globPattern string, filterPattern string, upstreamTaint m
 	log.Debugf("=== Seeding taint from local style files (FindAffectedFiles) ===")
 	log.Debugf("  changed style files: %d", len(changedStyleFiles))
 	if len(changedStyleFiles) > 0 {
-		for stem, analysis := range fileAnalyses {
+		for _, stem := range mapKeys(fileAnalyses) {
+			analysis := fileAnalyses[stem]
 			for _, imp := range analysis.Imports {
 				if !strings.HasPrefix(imp.Source, ".") {
 					continue
@@ -1498,7 +1867,8 @@ func FindAffectedFiles(globPattern string, filterPattern string, upstreamTaint m
 	log.Debugf("=== Seeding taint from local JSON files (FindAffectedFiles) ===")
 	log.Debugf("  changed JSON files: %d", len(changedJSONFiles))
 	if len(changedJSONFiles) > 0 {
-		for stem, analysis := range fileAnalyses {
+		for _, stem := range mapKeys(fileAnalyses) {
+			analysis := fileAnalyses[stem]
 			for _, imp := range analysis.Imports {
 				if !strings.HasPrefix(imp.Source, ".") {
 					continue
@@ -1530,9 +1900,23 @@ func FindAffectedFiles(globPattern string, filterPattern string, upstreamTaint m
 		}
 	}
 
+	log.Debugf("=== Seeding taint from local binary assets (FindAffectedFiles) ===")
+	seedAssetTaint(projectFolder, fileAnalyses, tainted)
+
+	if IncludeGraphQL {
+		log.Debugf("=== Seeding taint from GraphQL documents (FindAffectedFiles) ===")
+		var projectChangedFiles []string
+		for _, f := range changedFiles {
+			if strings.HasPrefix(f, projectFolder+"/") {
+				projectChangedFiles = append(projectChangedFiles, f)
+			}
+		}
+		seedGraphQLTaint(projectFolder, projectChangedFiles, fileAnalyses, upstreamTaint, tainted)
+	}
+
 	log.Debugf("=== Initial taint map (FindAffectedFiles) ===")
-	for stem, names := range tainted {
-		log.Debugf("  %s: %v", stem, mapKeys(names))
+	for _, stem := range mapKeys(tainted) {
+		log.Debugf("  %s: %v", stem, mapKeys(tainted[stem]))
 	}
 
 	if len(tainted) == 0 {
@@ -1541,7 +1925,8 @@ func FindAffectedFiles(globPattern string, filterPattern string, upstreamTaint m
 	}
 
 	// Intra-file propagation for seeded taint (same as in AnalyzeLibraryPackage).
-	for stem, names := range tainted {
+	for _, stem := range mapKeys(tainted) {
+		names := tainted[stem]
 		analysis := fileAnalyses[stem]
 		if analysis == nil || analysis.SourceFile == nil {
 			continue
@@ -1556,7 +1941,7 @@ func FindAffectedFiles(globPattern string, filterPattern string, upstreamTaint m
 					continue
 				}
 				bodyText := tsparse.ExtractTextForLines(sourceText, lineMap, sym.StartLine, sym.EndLine)
-				for tName := range names {
+				for _, tName := range mapKeys(names) {
 					if strings.Contains(bodyText, tName) {
 						names[sym.Name] = true
 						changed = true
@@ -1566,14 +1951,16 @@ func FindAffectedFiles(globPattern string, filterPattern string, upstreamTaint m
 				}
 			}
 		}
+		for _, alias := range localExportAliases(analysis, names) {
+			names[alias] = true
+			log.Debugf("  %s: %s tainted as export of a tainted local binding (seed propagation)", stem, alias)
+		}
 	}
 
 	// Symbol-level BFS propagation (same engine as AnalyzeLibraryPackage)
 	log.Debugf("=== Starting BFS taint propagation (FindAffectedFiles) ===")
-	queue := make([]string, 0, len(tainted))
-	for stem := range tainted {
-		queue = append(queue, stem)
-	}
+	contextGroups := findContextGroups(projectFolder, fileAnalyses)
+	queue := mapKeys(tainted)
 	for len(queue) > 0 {
 		currentStem := queue[0]
 		queue = queue[1:]
@@ -1631,6 +2018,9 @@ func FindAffectedFiles(globPattern string, filterPattern string, upstreamTaint m
 			// Handle re-exports
 			importerDir := filepath.Dir(importerStem + ".ts")
 			for _, exp := range importerAnalysis.Exports {
+				if !includeTypes && (exp.IsTypeOnly || isTypeOnlyExport(importerAnalysis, exp.Name)) {
+					continue
+				}
 				if exp.Source == "" {
 					for _, tln := range taintedLocalNames {
 						cleanName := tln
@@ -1645,9 +2035,13 @@ func FindAffectedFiles(globPattern string, filterPattern string, upstreamTaint m
 					reExpStem := resolveImportSource(importerDir, exp.Source, projectFolder)
 					if reExpStem == currentStem {
 						if exp.IsStar {
-							for name := range currentTainted {
-								newlyTainted = append(newlyTainted, name)
+							for _, name := range mapKeys(currentTainted) {
+								if includeTypes || !isTypeOnlyExport(fileAnalyses[currentStem], name) {
+									newlyTainted = append(newlyTainted, name)
+								}
 							}
+						} else if !includeTypes && isTypeOnlyExport(fileAnalyses[currentStem], exp.LocalName) {
+							continue
 						} else if currentTainted[exp.LocalName] || currentTainted["*"] {
 							newlyTainted = append(newlyTainted, exp.Name)
 						}
@@ -1671,7 +2065,7 @@ func FindAffectedFiles(globPattern string, filterPattern string, upstreamTaint m
 							continue
 						}
 						bodyText := tsparse.ExtractTextForLines(sourceText, lineMap, sym.StartLine, sym.EndLine)
-						for tName := range taintedSet {
+						for _, tName := range mapKeys(taintedSet) {
 							if strings.Contains(bodyText, tName) {
 								taintedSet[sym.Name] = true
 								newlyTainted = append(newlyTainted, sym.Name)
@@ -1684,6 +2078,15 @@ func FindAffectedFiles(globPattern string, filterPattern string, upstreamTaint m
 				}
 			}
 
+			// Exports of newly tainted local bindings (`export default Button`).
+			if len(newlyTainted) > 0 {
+				taintedSet := make(map[string]bool)
+				for _, n := range newlyTainted {
+					taintedSet[n] = true
+				}
+				newlyTainted = append(newlyTainted, localExportAliases(importerAnalysis, taintedSet)...)
+			}
+
 			if len(newlyTainted) == 0 {
 				log.Debugf("    → %s: re-export/usage check found nothing new", importerStem)
 				continue
@@ -1705,6 +2108,10 @@ func FindAffectedFiles(globPattern string, filterPattern string, upstreamTaint m
 				queue = append(queue, importerStem)
 			}
 		}
+
+		if len(queue) == 0 {
+			queue = taintContextGroups(projectFolder, contextGroups, fileAnalyses, tainted, false)
+		}
 	}
 
 	// Mark leaf files (no tainted symbols yet) that import from tainted files.
@@ -1740,13 +2147,16 @@ func FindAffectedFiles(globPattern string, filterPattern string, upstreamTaint m
 	}
 
 	log.Debugf("=== Final taint map (FindAffectedFiles) ===")
-	for stem, names := range tainted {
-		log.Debugf("  %s: %v", stem, mapKeys(names))
+	for _, stem := range mapKeys(tainted) {
+		log.Debugf("  %s: %v", stem, mapKeys(tainted[stem]))
 	}
 
 	// Collect affected files (any file with tainted symbols)
 	var result []string
 	for stem := range tainted {
+		if outsideGlob[stem] {
+			continue
+		}
 		rel := stemToRel[stem]
 		if filterPattern != "" {
 			if matched, _ := doublestar.Match(filterPattern, rel); !matched {
@@ -1760,26 +2170,87 @@ func FindAffectedFiles(globPattern string, filterPattern string, upstreamTaint m
 	return result
 }
 
-func globSourceFiles(projectFolder string) ([]string, error) {
-	var files []string
-	err := filepath.Walk(projectFolder, func(path string, info os.FileInfo, err error) error {
+// skipDirNames are directories never globbed for sources: dependencies (including
+// pnpm's .pnpm virtual store), VCS metadata, build output and rush/heft temp folders.
+// TODO: use tsconfig.json outDir to determine build output directories instead of hardcoding
+var skipDirNames = map[string]bool{
+	"node_modules": true,
+	".pnpm":        true,
+	".git":         true,
+	".rush":        true,
+	".heft":        true,
+	"temp":         true,
+	"dist":         true,
+	"esm":          true,
+}
+
+// SkipDirs are additional directory name globs excluded from source globbing
+// (`skipDirs` in the root .goodchangesrc.json).
+var SkipDirs []string
+
+// skipWalkDir reports whether a directory below projectFolder is excluded from source
+// globbing: skipDirNames, SkipDirs, and nested rush projects (see ProjectFolders),
+// whose files belong to the nested project.
+func skipWalkDir(projectFolder string, path string) bool {
+	base := filepath.Base(path)
+	if skipDirNames[base] {
+		return true
+	}
+	for _, pattern := range SkipDirs {
+		if matched, _ := doublestar.Match(pattern, base); matched {
+			return true
+		}
+	}
+	return path != projectFolder && ProjectFolders[filepath.ToSlash(path)]
+}
+
+// walkProjectFiles calls visit for every regular file below projectFolder outside
+// skipped directories, with its path, its path relative to projectFolder and its entry.
+// Symlinks are skipped, so a symlinked node_modules or virtual store is never entered
+// and no file is visited twice.
+func walkProjectFiles(projectFolder string, visit func(path, rel string, d fs.DirEntry)) error {
+	return filepath.WalkDir(projectFolder, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return nil
 		}
-		if info.IsDir() {
-			base := filepath.Base(path)
-			// TODO: use tsconfig.json outDir to determine build output directories instead of hardcoding
-			if base == "node_modules" || base == ".git" || base == "dist" || base == "esm" {
+		if d.Type()&fs.ModeSymlink != 0 {
+			return nil
+		}
+		if d.IsDir() {
+			if skipWalkDir(projectFolder, path) {
 				return filepath.SkipDir
 			}
 			return nil
 		}
-		ext := strings.ToLower(filepath.Ext(path))
-		if ext == ".ts" || ext == ".tsx" || ext == ".js" || ext == ".jsx" {
-			rel, _ := filepath.Rel(projectFolder, path)
-			files = append(files, rel)
-		}
+		rel, _ := filepath.Rel(projectFolder, path)
+		visit(path, rel, d)
 		return nil
 	})
+}
+
+// globSourceFiles returns the TS/JS files of a project, relative to it. Files over
+// MaxFileSize are left out (and recorded as skipped).
+func globSourceFiles(projectFolder string) ([]string, error) {
+	var files []string
+	err := walkProjectFiles(projectFolder, func(path, rel string, d fs.DirEntry) {
+		if !isSourceFile(path) {
+			return
+		}
+		if info, err := d.Info(); err == nil && oversized(projectFolder, rel, info.Size()) {
+			return
+		}
+		files = append(files, rel)
+	})
 	return files, err
 }
+
+// logSuppressedImports lists import edges removed by goodchanges annotations in the file.
+func logSuppressedImports(relPath string, analysis *tsparse.FileAnalysis) {
+	for _, imp := range analysis.SuppressedImports {
+		if len(imp.Names) == 0 {
+			log.Debugf("  suppressed import edge: %s → %s (ignore-next-import)", relPath, imp.Source)
+		} else {
+			log.Debugf("  suppressed import edge: %s → %s {%s} (ignore-symbol)", relPath, imp.Source, strings.Join(imp.Names, ", "))
+		}
+	}
+}
@@ -0,0 +1,11 @@
+package analyzer_test
+
+import (
+	"testing"
+
+	"goodchanges/internal/analyzer/analyzertest"
+)
+
+func TestAnalyzerFixtures(t *testing.T) {
+	analyzertest.RunAll(t, "testdata/analyzer")
+}
@@ -0,0 +1,238 @@
+// Package analyzertest runs library analysis against golden fixtures: a mini package
+// at the merge base and at HEAD, plus the affected exports the analysis must report.
+// It lets regression fixtures for taint propagation bugs be added without a git
+// repository.
+//
+// Fixture layout:
+//
+//	<fixture>/changed-files.txt  changed paths relative to head/, one per line
+//	<fixture>/base/              contents of the changed files at the merge base
+//	                             (a missing file was added)
+//	<fixture>/head/              package tree at HEAD: package.json, sources and an
+//	                             optional .goodchangesrc.json (e.g. sourceDirs)
+//	<fixture>/expected.json      affected export names per entrypoint, e.g.
+//	                             {".": ["Button"]}; entrypoints without affected
+//	                             exports are left out
+//	<fixture>/options.json       optional analysis inputs (see Options)
+//
+// This mirrors the fixtures of `goodchanges replay`, for one package. Analysis uses global
+// state (git.FixtureDir, analyzer.ProjectFolders), so fixtures must not run in parallel.
+package analyzertest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"goodchanges/internal/analyzer"
+	"goodchanges/internal/git"
+	"goodchanges/internal/rush"
+)
+
+const (
+	changedList  = "changed-files.txt"
+	baseDir      = "base"
+	headDir      = "head"
+	expectedFile = "expected.json"
+	optionsFile  = "options.json"
+)
+
+// Options are the analysis inputs of a fixture besides its files (options.json).
+type Options struct {
+	IncludeTypes bool `json:"includeTypes,omitempty"`
+	// UpstreamTaint maps import specifiers of workspace dependencies to their affected
+	// export names ("*" for all).
+	UpstreamTaint map[string][]string `json:"upstreamTaint,omitempty"`
+	// ChangedExternalDeps are external packages whose lockfile version changed.
+	ChangedExternalDeps []string `json:"changedExternalDeps,omitempty"`
+}
+
+// Fixture is a loaded fixture directory.
+type Fixture struct {
+	Dir          string
+	ChangedFiles []string // relative to head/
+	Options      Options
+	Expected     map[string][]string // entrypoint export path → sorted affected export names
+}
+
+// Load reads the fixture in dir.
+func Load(dir string) (*Fixture, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	f := &Fixture{Dir: abs}
+	data, err := os.ReadFile(filepath.Join(abs, changedList))
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+			f.ChangedFiles = append(f.ChangedFiles, line)
+		}
+	}
+	data, err = os.ReadFile(filepath.Join(abs, expectedFile))
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &f.Expected); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", expectedFile, err)
+	}
+	for _, names := range f.Expected {
+		sort.Strings(names)
+	}
+	if data, err := os.ReadFile(filepath.Join(abs, optionsFile)); err == nil {
+		if err := json.Unmarshal(data, &f.Options); err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", optionsFile, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Analyze runs AnalyzeLibraryPackage on the fixture and returns the affected export
+// names per entrypoint, in the format of expected.json.
+func (f *Fixture) Analyze() (map[string][]string, error) {
+	head := filepath.Join(f.Dir, headDir)
+	base := filepath.Join(f.Dir, baseDir)
+	var changedFiles []string
+	for _, rel := range f.ChangedFiles {
+		changedFiles = append(changedFiles, filepath.ToSlash(filepath.Join(head, rel)))
+	}
+
+	// Base contents are served from git.FixtureDir joined with the changed file path,
+	// which is absolute here, so mirror base/ under that path in a scratch directory.
+	scratch, err := os.MkdirTemp("", "analyzertest")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(scratch)
+	if err := copyTree(base, filepath.Join(scratch, head)); err != nil {
+		return nil, err
+	}
+	prevFixtureDir, prevFolders := git.FixtureDir, analyzer.ProjectFolders
+	git.FixtureDir = scratch
+	analyzer.ProjectFolders = map[string]bool{filepath.ToSlash(head): true}
+	defer func() { git.FixtureDir, analyzer.ProjectFolders = prevFixtureDir, prevFolders }()
+
+	var pkg rush.PackageJSON
+	data, err := os.ReadFile(filepath.Join(head, "package.json"))
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, fmt.Errorf("parsing head/package.json: %w", err)
+	}
+	cfg, err := rush.LoadProjectConfig(head, pkg.Name)
+	if err != nil {
+		return nil, err
+	}
+	entrypoints := analyzer.FindEntrypoints(head, pkg, cfg)
+	if len(entrypoints) == 0 {
+		return nil, fmt.Errorf("no entrypoints of head/package.json resolve to source files")
+	}
+
+	upstreamTaint := make(map[string]map[string]bool)
+	for specifier, names := range f.Options.UpstreamTaint {
+		upstreamTaint[specifier] = make(map[string]bool)
+		for _, name := range names {
+			upstreamTaint[specifier][name] = true
+		}
+	}
+	changedDeps := make(map[string]bool)
+	for _, dep := range f.Options.ChangedExternalDeps {
+		changedDeps[dep] = true
+	}
+
+	affected, err := analyzer.AnalyzeLibraryPackage(context.Background(), head, entrypoints, cfg, "fixture", changedFiles, f.Options.IncludeTypes, upstreamTaint, changedDeps)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string][]string)
+	for _, ae := range affected {
+		for _, name := range ae.ExportNames {
+			if !slices.Contains(result[ae.EntrypointPath], name) {
+				result[ae.EntrypointPath] = append(result[ae.EntrypointPath], name)
+			}
+		}
+	}
+	for _, names := range result {
+		sort.Strings(names)
+	}
+	return result, nil
+}
+
+// Run analyzes the fixture in dir and reports a mismatch with expected.json as a
+// test error.
+func Run(t testing.TB, dir string) {
+	t.Helper()
+	f, err := Load(dir)
+	if err != nil {
+		t.Fatalf("loading fixture %s: %v", dir, err)
+	}
+	got, err := f.Analyze()
+	if err != nil {
+		t.Fatalf("analyzing fixture %s: %v", dir, err)
+	}
+	if !reflect.DeepEqual(got, f.Expected) {
+		gotJSON, _ := json.Marshal(got)
+		wantJSON, _ := json.Marshal(f.Expected)
+		t.Errorf("fixture %s: affected exports\n  got:  %s\n  want: %s", dir, gotJSON, wantJSON)
+	}
+}
+
+// RunAll runs every fixture directory (one containing expected.json) below root as
+// a subtest named after its path relative to root.
+func RunAll(t *testing.T, root string) {
+	t.Helper()
+	var dirs []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && d.Name() == expectedFile {
+			dirs = append(dirs, filepath.Dir(path))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("finding fixtures in %s: %v", root, err)
+	}
+	if len(dirs) == 0 {
+		t.Fatalf("no fixtures (directories with %s) in %s", expectedFile, root)
+	}
+	for _, dir := range dirs {
+		name, _ := filepath.Rel(root, dir)
+		t.Run(filepath.ToSlash(name), func(t *testing.T) { Run(t, dir) })
+	}
+}
+
+func copyTree(src, dst string) error {
+	if _, err := os.Stat(src); os.IsNotExist(err) {
+		return os.MkdirAll(dst, 0o755)
+	}
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		target := filepath.Join(dst, rel)
+		if d.IsDir() {
+			return os.MkdirAll(target, 0o755)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, 0o644)
+	})
+}
@@ -0,0 +1,165 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"goodchanges/internal/rush"
+)
+
+var (
+	apiReportDeclRe    = regexp.MustCompile(`^export\s+(?:declare\s+)?(?:abstract\s+)?(?:default\s+)?(?:function|class|const|let|var|interface|type|enum|namespace)\s+([A-Za-z_$][\w$]*)`)
+	apiReportExportsRe = regexp.MustCompile(`^export\s*\{([^}]*)\}`)
+	apiReportTagRe     = regexp.MustCompile(`^//\s*@(public|beta|alpha|internal)\b`)
+)
+
+// FindAPIReport returns the path of the package's api-extractor report (.api.md),
+// or "" if none exists. The location comes from apiReport.reportFolder/reportFileName
+// in the project's api-extractor.json; the api/ and etc/ folders are tried as fallbacks.
+func FindAPIReport(projectFolder, packageName string) string {
+	unscoped := packageName
+	if i := strings.LastIndex(packageName, "/"); i >= 0 {
+		unscoped = packageName[i+1:]
+	}
+	expand := func(s string) string {
+		s = strings.ReplaceAll(s, "<projectFolder>", projectFolder)
+		s = strings.ReplaceAll(s, "<unscopedPackageName>", unscoped)
+		return strings.ReplaceAll(s, "<packageName>", packageName)
+	}
+
+	fileName := unscoped + ".api.md"
+	candidates := []string{
+		filepath.Join(projectFolder, "api", fileName),
+		filepath.Join(projectFolder, "etc", fileName),
+	}
+	if data, err := os.ReadFile(filepath.Join(projectFolder, "api-extractor.json")); err == nil {
+		var cfg struct {
+			APIReport struct {
+				ReportFolder   string `json:"reportFolder"`
+				ReportFileName string `json:"reportFileName"`
+			} `json:"apiReport"`
+		}
+		if json.Unmarshal(rush.StripJSONCommentsAndTrailingCommas(data), &cfg) == nil {
+			folder := filepath.Join(projectFolder, "etc")
+			if f := cfg.APIReport.ReportFolder; f != "" {
+				// Paths without the <projectFolder> token are relative to the config file.
+				if strings.Contains(f, "<projectFolder>") {
+					folder = expand(f)
+				} else {
+					folder = filepath.Join(projectFolder, expand(f))
+				}
+			}
+			if cfg.APIReport.ReportFileName != "" {
+				fileName = expand(cfg.APIReport.ReportFileName)
+				if !strings.HasSuffix(fileName, ".md") {
+					fileName += ".api.md"
+				}
+			}
+			candidates = append([]string{filepath.Join(folder, fileName)}, candidates...)
+		}
+	}
+	for _, c := range candidates {
+		if _, err := os.Stat(c); err == nil {
+			return filepath.Clean(c)
+		}
+	}
+	return ""
+}
+
+// ParseAPIReport reads an api-extractor report and returns its top-level exported
+// names mapped to their release tag ("public", "beta", "alpha", "internal").
+// Names without a tag comment map to "public".
+func ParseAPIReport(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	exports := make(map[string]string)
+	tag := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		// Members of classes and namespaces are indented; only top-level declarations count.
+		if line == "" || line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+		if m := apiReportTagRe.FindStringSubmatch(line); m != nil {
+			tag = m[1]
+			continue
+		}
+		releaseTag := tag
+		if releaseTag == "" {
+			releaseTag = "public"
+		}
+		if m := apiReportDeclRe.FindStringSubmatch(line); m != nil {
+			exports[m[1]] = releaseTag
+			tag = ""
+		} else if m := apiReportExportsRe.FindStringSubmatch(line); m != nil {
+			for _, spec := range strings.Split(m[1], ",") {
+				fields := strings.Fields(spec)
+				if len(fields) > 0 {
+					exports[fields[len(fields)-1]] = releaseTag
+				}
+			}
+			tag = ""
+		}
+	}
+	return exports, nil
+}
+
+// apiReportDeclarations splits api-extractor report content into its top-level exported
+// declarations, keyed by name, with the declaration's text (release tag, signature and
+// indented members) as the value. Names exported by an "export { ... }" list share
+// the list's line.
+func apiReportDeclarations(content string) map[string]string {
+	decls := make(map[string]string)
+	var current []string // names the following member lines belong to
+	tag := ""
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' || line[0] == '}' || line[0] == ')' {
+			for _, name := range current {
+				decls[name] += "\n" + line
+			}
+			continue
+		}
+		if m := apiReportTagRe.FindStringSubmatch(line); m != nil {
+			tag = m[1]
+			continue
+		}
+		current = nil
+		if m := apiReportDeclRe.FindStringSubmatch(line); m != nil {
+			current = []string{m[1]}
+		} else if m := apiReportExportsRe.FindStringSubmatch(line); m != nil {
+			for _, spec := range strings.Split(m[1], ",") {
+				if fields := strings.Fields(spec); len(fields) > 0 {
+					current = append(current, fields[len(fields)-1])
+				}
+			}
+		} else {
+			continue
+		}
+		for _, name := range current {
+			decls[name] = "@" + tag + "\n" + line
+		}
+		tag = ""
+	}
+	return decls
+}
+
+// DiffAPIReports returns the sorted names of exports added, removed or changed between
+// two versions of an api-extractor report.
+func DiffAPIReports(oldContent, newContent string) []string {
+	oldDecls, newDecls := apiReportDeclarations(oldContent), apiReportDeclarations(newContent)
+	var changed []string
+	for _, name := range unionKeys(oldDecls, newDecls) {
+		if oldDecls[name] != newDecls[name] {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
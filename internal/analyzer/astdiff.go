@@ -2,6 +2,7 @@ package analyzer
 
 import (
 	"goodchanges/internal/log"
+	"slices"
 	"strings"
 
 	"goodchanges/internal/tsparse"
@@ -20,9 +21,13 @@ import (
 //   - interface/type declarations → always type-only
 //   - function/class/variable/enum → extract runtime-only text (strip type annotations,
 //     as/satisfies expressions), compare. If runtime texts match → type-only change.
-func findAffectedSymbolsByASTDiff(oldAnalysis *tsparse.FileAnalysis, newAnalysis *tsparse.FileAnalysis, oldContent string, includeTypes bool) []string {
+//
+// The second result classifies changed React components (and their `...Props` types)
+// as changeProps (only the prop signature changed) or changeRender (the render logic
+// changed).
+func findAffectedSymbolsByASTDiff(oldAnalysis *tsparse.FileAnalysis, newAnalysis *tsparse.FileAnalysis, oldContent string, includeTypes bool, ignored map[string]bool) ([]string, map[string]string) {
 	if newAnalysis == nil || newAnalysis.SourceFile == nil {
-		return nil
+		return nil, nil
 	}
 
 	newText := newAnalysis.SourceFile.Text()
@@ -30,15 +35,17 @@ func findAffectedSymbolsByASTDiff(oldAnalysis *tsparse.FileAnalysis, newAnalysis
 	// Build map of old symbol name → body text
 	oldSymbolTexts := make(map[string]string)
 	oldSymbolRuntimeTexts := make(map[string]string)
+	var oldStmtMap map[string]*ast.Node
+	oldText := ""
 	if oldAnalysis != nil && oldAnalysis.SourceFile != nil {
-		oldText := oldAnalysis.SourceFile.Text()
+		oldText = oldAnalysis.SourceFile.Text()
 		oldLineMap := oldAnalysis.SourceFile.ECMALineMap()
 		for _, sym := range oldAnalysis.Symbols {
 			body := tsparse.ExtractTextForLines(oldText, oldLineMap, sym.StartLine, sym.EndLine)
 			oldSymbolTexts[sym.Name] = normalizeWhitespace(body)
 		}
 		// Also extract runtime-only texts for old symbols using AST
-		oldStmtMap := buildStmtMap(oldAnalysis.SourceFile)
+		oldStmtMap = buildStmtMap(oldAnalysis.SourceFile)
 		for _, sym := range oldAnalysis.Symbols {
 			if sym.IsTypeOnly {
 				continue
@@ -54,11 +61,17 @@ func findAffectedSymbolsByASTDiff(oldAnalysis *tsparse.FileAnalysis, newAnalysis
 	newStmtMap := buildStmtMap(newAnalysis.SourceFile)
 
 	var affected []string
+	changes := make(map[string]string)
 	for _, sym := range newAnalysis.Symbols {
 		newBody := tsparse.ExtractTextForLines(newText, newLineMap, sym.StartLine, sym.EndLine)
 		newBodyNorm := normalizeWhitespace(newBody)
 
 		oldBodyNorm, existedBefore := oldSymbolTexts[sym.Name]
+		if ignored[sym.Name] && (!existedBefore || newBodyNorm != oldBodyNorm) {
+			// Neither affected nor propagated to the symbols referencing it.
+			log.Debugf("    %s: change ignored (ignoreSymbols)", sym.Name)
+			continue
+		}
 		if !existedBefore {
 			// New symbol — it's affected
 			if sym.IsTypeOnly && !includeTypes {
@@ -78,6 +91,9 @@ func findAffectedSymbolsByASTDiff(oldAnalysis *tsparse.FileAnalysis, newAnalysis
 		// Symbol text changed. Determine if it's type-only or runtime.
 		if sym.IsTypeOnly {
 			// interface/type alias — always type-only
+			if isPropsTypeName(sym.Name) {
+				changes[sym.Name] = changeProps
+			}
 			if includeTypes {
 				log.Debugf("    %s: type-only change (interface/type)", sym.Name)
 				affected = append(affected, sym.Name)
@@ -89,12 +105,19 @@ func findAffectedSymbolsByASTDiff(oldAnalysis *tsparse.FileAnalysis, newAnalysis
 		// by comparing runtime-stripped texts
 		oldRuntime := oldSymbolRuntimeTexts[sym.Name]
 		newRuntime := ""
+		isComponent := false
 		if stmt, ok := newStmtMap[sym.Name]; ok {
 			newRuntime = extractRuntimeText(stmt, newText)
+			isComponent = containsJSX(stmt)
 		}
 
 		if oldRuntime != "" && newRuntime != "" && oldRuntime == newRuntime {
 			// Only type annotations changed (e.g. `x = foo` → `x = foo as Bar`)
+			if isComponent {
+				// e.g. `forwardRef<HTMLDivElement, Props>` → `forwardRef<HTMLButtonElement, Props>`
+				log.Debugf("    %s: component prop-signature change", sym.Name)
+				changes[sym.Name] = changeProps
+			}
 			if includeTypes {
 				log.Debugf("    %s: type-only change (runtime text identical)", sym.Name)
 				affected = append(affected, sym.Name)
@@ -104,6 +127,16 @@ func findAffectedSymbolsByASTDiff(oldAnalysis *tsparse.FileAnalysis, newAnalysis
 
 		// Runtime change
 		log.Debugf("    %s: RUNTIME change", sym.Name)
+		if isComponent {
+			log.Debugf("    %s: component render change", sym.Name)
+			changes[sym.Name] = changeRender
+		}
+		if log.Debug {
+			// Enums and const literals: show which values changed (old → new)
+			for _, delta := range constantValueDeltas(sym.Name, oldStmtMap[sym.Name], newStmtMap[sym.Name], oldText, newText) {
+				log.Debugf("      value %s", delta)
+			}
+		}
 		affected = append(affected, sym.Name)
 	}
 
@@ -188,6 +221,16 @@ func findAffectedSymbolsByASTDiff(oldAnalysis *tsparse.FileAnalysis, newAnalysis
 		}
 	}
 
+	// Re-pointed or removed default export (`export default A` → `export default B`):
+	// no symbol body changed, but default importers get a different value.
+	if oldAnalysis != nil {
+		oldDefault, newDefault := defaultExportIdentity(oldAnalysis), defaultExportIdentity(newAnalysis)
+		if oldDefault != "" && oldDefault != newDefault && !slices.Contains(affected, "default") {
+			log.Debugf("    default: default export changed from %s to %q", oldDefault, newDefault)
+			affected = append(affected, "default")
+		}
+	}
+
 	// Fallback: if no symbols were detected but the file clearly changed,
 	// check if changes are outside any symbol (e.g. top-level side effects,
 	// copyright comments). If there are runtime side-effect changes, taint all symbols.
@@ -217,7 +260,36 @@ func findAffectedSymbolsByASTDiff(oldAnalysis *tsparse.FileAnalysis, newAnalysis
 		}
 	}
 
-	return affected
+	return affected, changes
+}
+
+// React component change classifications of findAffectedSymbolsByASTDiff.
+const (
+	changeProps  = "props"
+	changeRender = "render"
+)
+
+// isPropsTypeName reports whether an interface or type alias name follows the
+// component props convention (`ButtonProps`, `Props`).
+func isPropsTypeName(name string) bool {
+	return strings.HasSuffix(name, "Props")
+}
+
+// containsJSX reports whether a statement renders JSX, which marks the symbol it
+// declares as a React component.
+func containsJSX(node *ast.Node) bool {
+	found := false
+	var walk func(n *ast.Node) bool
+	walk = func(n *ast.Node) bool {
+		switch n.Kind {
+		case ast.KindJsxElement, ast.KindJsxSelfClosingElement, ast.KindJsxFragment:
+			found = true
+			return true
+		}
+		return n.ForEachChild(walk)
+	}
+	walk(node)
+	return found
 }
 
 // buildStmtMap maps symbol names to their AST statement nodes.
@@ -340,9 +412,9 @@ func collectTypeOnlyRanges(node *ast.Node) [][2]int {
 				// Include the angle brackets: one char before first, one char after last
 				ranges = append(ranges, [2]int{first.Pos() - 1, last.End() + 1})
 			}
-			// Strip return type
+			// Strip return type, from the colon after the parameter list
 			if fd.Type != nil {
-				ranges = append(ranges, [2]int{fd.Parameters.End(), fd.Type.End()})
+				ranges = append(ranges, [2]int{fd.Type.Pos() - 1, fd.Type.End()})
 			}
 
 		case ast.KindParameter:
@@ -359,7 +431,33 @@ func collectTypeOnlyRanges(node *ast.Node) [][2]int {
 				ranges = append(ranges, [2]int{first.Pos() - 1, last.End() + 1})
 			}
 			if af.Type != nil {
-				ranges = append(ranges, [2]int{af.Parameters.End(), af.Type.End()})
+				ranges = append(ranges, [2]int{af.Type.Pos() - 1, af.Type.End()})
+			}
+
+		case ast.KindFunctionExpression, ast.KindMethodDeclaration:
+			// `function <T>(props): JSX.Element`, class component `render(): ReactNode`
+			fl := n.FunctionLikeData()
+			if fl.TypeParameters != nil && len(fl.TypeParameters.Nodes) > 0 {
+				first := fl.TypeParameters.Nodes[0]
+				last := fl.TypeParameters.Nodes[len(fl.TypeParameters.Nodes)-1]
+				ranges = append(ranges, [2]int{first.Pos() - 1, last.End() + 1})
+			}
+			if fl.Type != nil {
+				ranges = append(ranges, [2]int{fl.Type.Pos() - 1, fl.Type.End()})
+			}
+
+		case ast.KindPropertyDeclaration:
+			// class component `state: State = {...}`
+			if pd := n.AsPropertyDeclaration(); pd.Type != nil {
+				ranges = append(ranges, [2]int{pd.Name().End(), pd.Type.End()})
+			}
+
+		case ast.KindCallExpression, ast.KindNewExpression, ast.KindTaggedTemplateExpression,
+			ast.KindExpressionWithTypeArguments, ast.KindJsxOpeningElement, ast.KindJsxSelfClosingElement:
+			// Type arguments: `forwardRef<HTMLDivElement, Props>(...)`, `memo<Props>(...)`,
+			// `extends Component<Props, State>`, `<List<Item> items={items} />`
+			if args := n.TypeArguments(); len(args) > 0 {
+				ranges = append(ranges, [2]int{args[0].Pos() - 1, args[len(args)-1].End() + 1})
 			}
 		}
 
@@ -492,3 +590,96 @@ func isSideEffectStatement(stmt *ast.Node) bool {
 		return true
 	}
 }
+
+// renameSimilarityThreshold is the minimum body similarity (0..1, after replacing the
+// symbol's own name) for a deleted/new symbol pair to be reported as a rename.
+const renameSimilarityThreshold = 0.9
+
+// renameMaxCompareLen caps the body length compared by edit distance; longer bodies
+// are only matched when identical.
+const renameMaxCompareLen = 4000
+
+// detectRenames pairs symbols deleted from the OLD file with symbols added in the NEW
+// file whose bodies are near-identical (same kind, body similarity above
+// renameSimilarityThreshold once the symbol names are blanked out).
+// Returns new name → old name.
+func detectRenames(oldAnalysis *tsparse.FileAnalysis, newAnalysis *tsparse.FileAnalysis) map[string]string {
+	if oldAnalysis == nil || oldAnalysis.SourceFile == nil || newAnalysis == nil || newAnalysis.SourceFile == nil {
+		return nil
+	}
+	oldNames := make(map[string]bool)
+	for _, sym := range oldAnalysis.Symbols {
+		oldNames[sym.Name] = true
+	}
+	newNames := make(map[string]bool)
+	for _, sym := range newAnalysis.Symbols {
+		newNames[sym.Name] = true
+	}
+
+	bodyWithoutName := func(analysis *tsparse.FileAnalysis, sym tsparse.SymbolDecl) string {
+		text := tsparse.ExtractTextForLines(analysis.SourceFile.Text(), analysis.SourceFile.ECMALineMap(), sym.StartLine, sym.EndLine)
+		return normalizeWhitespace(strings.ReplaceAll(text, sym.Name, "\x00"))
+	}
+
+	var deleted []tsparse.SymbolDecl
+	for _, sym := range oldAnalysis.Symbols {
+		if !newNames[sym.Name] {
+			deleted = append(deleted, sym)
+		}
+	}
+	if len(deleted) == 0 {
+		return nil
+	}
+
+	renames := make(map[string]string)
+	used := make(map[string]bool)
+	for _, sym := range newAnalysis.Symbols {
+		if oldNames[sym.Name] {
+			continue
+		}
+		newBody := bodyWithoutName(newAnalysis, sym)
+		bestName, bestScore := "", 0.0
+		for _, old := range deleted {
+			if used[old.Name] || old.Kind != sym.Kind {
+				continue
+			}
+			if score := textSimilarity(bodyWithoutName(oldAnalysis, old), newBody); score > bestScore {
+				bestName, bestScore = old.Name, score
+			}
+		}
+		if bestScore >= renameSimilarityThreshold {
+			renames[sym.Name] = bestName
+			used[bestName] = true
+			log.Debugf("    %s: RENAMED from %s (similarity %.2f)", sym.Name, bestName, bestScore)
+		}
+	}
+	return renames
+}
+
+// textSimilarity returns 1 - editDistance/maxLen for two strings (1 = identical).
+func textSimilarity(a, b string) float64 {
+	if a == b {
+		return 1
+	}
+	if len(a) > renameMaxCompareLen || len(b) > renameMaxCompareLen || len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return 1 - float64(prev[len(rb)])/float64(max(len(ra), len(rb)))
+}
@@ -0,0 +1,214 @@
+package analyzer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"goodchanges/internal/git"
+	"goodchanges/internal/log"
+	"goodchanges/internal/rush"
+	"goodchanges/internal/tsparse"
+	"goodchanges/tsgo-vendor/pkg/ast"
+	"goodchanges/tsgo-vendor/pkg/scanner"
+)
+
+// augmentation is a `declare global { ... }` block (module "") or a module
+// augmentation (`declare module "x" { ... }`) of a file. Such blocks declare no
+// exports of their own; they change the types every consumer of the augmented
+// module (or of the global scope) sees.
+type augmentation struct {
+	file   string   // stem of the declaring file, relative to the project folder
+	module string   // augmented module specifier, "" for the global scope
+	names  []string // names declared in the block, with interface members (e.g. "Window", "analytics")
+	text   string   // normalized block text, for diffing
+}
+
+// findAugmentations returns the top-level augmentation blocks of a file, merged per
+// augmented module.
+func findAugmentations(sf *ast.SourceFile) map[string]*augmentation {
+	if sf == nil {
+		return nil
+	}
+	text := sf.Text()
+	augs := make(map[string]*augmentation)
+	for _, stmt := range sf.Statements.Nodes {
+		if !ast.IsModuleDeclaration(stmt) {
+			continue
+		}
+		module := ""
+		if !ast.IsGlobalScopeAugmentation(stmt) {
+			name := stmt.Name()
+			if name == nil || name.Kind != ast.KindStringLiteral {
+				continue // namespace declaration, not an augmentation
+			}
+			module = name.Text()
+		}
+		aug := augs[module]
+		if aug == nil {
+			aug = &augmentation{module: module}
+			augs[module] = aug
+		}
+		start := scanner.SkipTrivia(text, stmt.Pos())
+		if start >= 0 && start < stmt.End() && stmt.End() <= len(text) {
+			aug.text += normalizeWhitespace(text[start:stmt.End()]) + "\n"
+		}
+		if body := stmt.Body(); body != nil && body.Kind == ast.KindModuleBlock {
+			for _, decl := range body.AsModuleBlock().Statements.Nodes {
+				aug.names = append(aug.names, augmentationDeclNames(decl)...)
+			}
+		}
+	}
+	return augs
+}
+
+// augmentationDeclNames returns the names a statement inside an augmentation block
+// declares.
+func augmentationDeclNames(stmt *ast.Node) []string {
+	if stmt.Kind == ast.KindVariableStatement {
+		var names []string
+		if dl := stmt.AsVariableStatement().DeclarationList; dl != nil && dl.AsVariableDeclarationList().Declarations != nil {
+			for _, decl := range dl.AsVariableDeclarationList().Declarations.Nodes {
+				if name := decl.Name(); name != nil && ast.IsIdentifier(name) {
+					names = append(names, name.Text())
+				}
+			}
+		}
+		return names
+	}
+	var names []string
+	if name := stmt.Name(); name != nil && ast.IsIdentifier(name) {
+		names = append(names, name.Text())
+	}
+	// `interface Window { analytics: Analytics }`: consumers reference the members
+	// (`window.analytics`), not the interface.
+	if stmt.Kind == ast.KindInterfaceDeclaration && stmt.AsInterfaceDeclaration().Members != nil {
+		for _, member := range stmt.AsInterfaceDeclaration().Members.Nodes {
+			if name := member.Name(); name != nil && ast.IsIdentifier(name) {
+				names = append(names, name.Text())
+			}
+		}
+	}
+	return names
+}
+
+// changedAugmentations returns the augmentation blocks added, removed or edited
+// between the OLD and NEW version of a file (declared names of both versions).
+func changedAugmentations(stem string, oldAnalysis *tsparse.FileAnalysis, newAnalysis *tsparse.FileAnalysis) []augmentation {
+	var oldAugs, newAugs map[string]*augmentation
+	if oldAnalysis != nil {
+		oldAugs = findAugmentations(oldAnalysis.SourceFile)
+	}
+	if newAnalysis != nil {
+		newAugs = findAugmentations(newAnalysis.SourceFile)
+	}
+	modules := make(map[string]bool)
+	for m := range oldAugs {
+		modules[m] = true
+	}
+	for m := range newAugs {
+		modules[m] = true
+	}
+	var changed []augmentation
+	for _, m := range mapKeys(modules) {
+		oldAug, newAug := oldAugs[m], newAugs[m]
+		if oldAug != nil && newAug != nil && oldAug.text == newAug.text {
+			continue
+		}
+		aug := augmentation{file: stem, module: m}
+		seen := make(map[string]bool)
+		for _, a := range []*augmentation{oldAug, newAug} {
+			if a == nil {
+				continue
+			}
+			for _, n := range a.names {
+				if !seen[n] {
+					seen[n] = true
+					aug.names = append(aug.names, n)
+				}
+			}
+		}
+		sort.Strings(aug.names)
+		changed = append(changed, aug)
+	}
+	return changed
+}
+
+// seedAugmentationTaint taints the consumers of changed augmentations within a
+// package: for `declare global`, symbols referencing a name declared in the block;
+// for `declare module "x"`, symbols using imports of x, or the whole augmented file
+// when x is relative.
+func seedAugmentationTaint(projectFolder string, augs []augmentation, fileAnalyses map[string]*tsparse.FileAnalysis, tainted map[string]map[string]bool) {
+	taint := func(stem string, names []string) {
+		if len(names) == 0 {
+			return
+		}
+		if tainted[stem] == nil {
+			tainted[stem] = make(map[string]bool)
+		}
+		for _, n := range names {
+			tainted[stem][n] = true
+		}
+	}
+	for _, aug := range augs {
+		if aug.module == "" {
+			log.Debugf("  %s: global augmentation changed (%s)", aug.file, strings.Join(aug.names, ", "))
+			if len(aug.names) == 0 {
+				continue
+			}
+			for _, stem := range mapKeys(fileAnalyses) {
+				taint(stem, findTaintedSymbolsByUsage(fileAnalyses[stem], aug.names))
+			}
+			continue
+		}
+		log.Debugf("  %s: augmentation of %q changed", aug.file, aug.module)
+		if strings.HasPrefix(aug.module, ".") {
+			if target := resolveImportSource(filepath.Dir(aug.file+".ts"), aug.module, projectFolder); target != "" {
+				taint(target, []string{"*"})
+			}
+			continue
+		}
+		for _, stem := range mapKeys(fileAnalyses) {
+			analysis := fileAnalyses[stem]
+			for _, imp := range analysis.Imports {
+				if imp.Source == aug.module {
+					taint(stem, findTaintedSymbolsByUsage(analysis, importLocalNames(imp)))
+				}
+			}
+		}
+	}
+}
+
+// AugmentationPackageTriggered reports whether the project uses the "package"
+// augmentations policy and a changed file edits a `declare global` or
+// `declare module` block. Such changes taint the whole package.
+func AugmentationPackageTriggered(ctx context.Context, changedFiles []string, projectFolder string, mergeBase string, cfg *rush.ProjectConfig) bool {
+	if cfg == nil || cfg.Augmentations == nil || *cfg.Augmentations != "package" {
+		return false
+	}
+	for _, f := range changedFiles {
+		if !strings.HasPrefix(f, projectFolder+"/") {
+			continue
+		}
+		if !isSourceFile(f) {
+			continue
+		}
+		relPath := strings.TrimPrefix(f, projectFolder+"/")
+		if cfg.IsIgnored(relPath) {
+			continue
+		}
+		var oldAnalysis, newAnalysis *tsparse.FileAnalysis
+		if content, err := os.ReadFile(f); err == nil {
+			newAnalysis, _ = tsparse.ParseContent(string(content), f)
+		}
+		if oldContent, err := git.ShowFile(ctx, mergeBase, BasePath(f)); err == nil && oldContent != "" {
+			oldAnalysis, _ = tsparse.ParseContent(oldContent, f)
+		}
+		if len(changedAugmentations(stripTSExtension(relPath), oldAnalysis, newAnalysis)) > 0 {
+			return true
+		}
+	}
+	return false
+}
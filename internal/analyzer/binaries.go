@@ -0,0 +1,188 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bmatcuk/doublestar/v4"
+
+	"goodchanges/internal/git"
+	"goodchanges/internal/log"
+	"goodchanges/internal/rush"
+	"goodchanges/internal/tsparse"
+)
+
+// AssetFiles are the changed binary files (repo-relative) of projects with the "asset"
+// binaries policy. They are no direct change of their project; the source files
+// importing them are tainted instead.
+var AssetFiles map[string]bool
+
+// BinaryChange is a changed binary file and the binaries policy applied to it.
+type BinaryChange struct {
+	File   string `json:"file"`   // repo-relative
+	Policy string `json:"policy"` // "trigger", "asset" or "ignore"
+}
+
+// lfsPointerPrefix starts every Git LFS pointer file.
+var lfsPointerPrefix = []byte("version https://git-lfs.github.com/spec/")
+
+// ClassifyBinaryChanges finds the changed binary files -- those git diffs as binary,
+// Git LFS pointers and files matching binaries.globs -- and applies their project's
+// binaries policy: "ignore" drops them from the returned changed files, "asset" records
+// them in AssetFiles, and "trigger" keeps them as ordinary changes. Files outside every
+// project are always "trigger".
+func ClassifyBinaryChanges(ctx context.Context, changedFiles []string, mergeBase string, rushConfig *rush.Config, configMap map[string]*rush.ProjectConfig) ([]string, []BinaryChange) {
+	gitBinary, err := git.BinaryFilesSince(ctx, mergeBase)
+	if err != nil {
+		log.Basicf("Listing binary changes failed: %v", err)
+	}
+	AssetFiles = make(map[string]bool)
+	kept := make([]string, 0, len(changedFiles))
+	var changes []BinaryChange
+	for _, f := range changedFiles {
+		var cfg *rush.ProjectConfig
+		relPath := f
+		if rp := rushConfig.ProjectForFile(f); rp != nil {
+			cfg = configMap[rp.ProjectFolder]
+			relPath = strings.TrimPrefix(f, rp.ProjectFolder+"/")
+		}
+		if !gitBinary[f] && !isLFSPointer(f) && !matchesBinaryGlob(relPath, cfg) {
+			kept = append(kept, f)
+			continue
+		}
+		policy := cfg.BinaryPolicy()
+		changes = append(changes, BinaryChange{File: f, Policy: policy})
+		switch policy {
+		case "ignore":
+			log.Basicf("Ignoring changed binary file %s", f)
+			continue
+		case "asset":
+			log.Basicf("Changed binary file %s taints its importers (asset)", f)
+			AssetFiles[f] = true
+		default:
+			log.Basicf("Changed binary file %s counts as a direct change", f)
+		}
+		kept = append(kept, f)
+	}
+	return kept, changes
+}
+
+// isLFSPointer reports whether the file in the working tree is a Git LFS pointer,
+// as checked out without the LFS smudge filter.
+func isLFSPointer(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	head := make([]byte, len(lfsPointerPrefix))
+	if _, err := io.ReadFull(f, head); err != nil {
+		return false
+	}
+	return bytes.Equal(head, lfsPointerPrefix)
+}
+
+func matchesBinaryGlob(relPath string, cfg *rush.ProjectConfig) bool {
+	if cfg == nil || cfg.Binaries == nil {
+		return false
+	}
+	for _, g := range cfg.Binaries.Globs {
+		if matched, _ := doublestar.Match(g, relPath); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// projectAssets returns the changed asset files of a project, relative to it.
+func projectAssets(projectFolder string) map[string]bool {
+	assets := make(map[string]bool)
+	for f := range AssetFiles {
+		if rel, ok := strings.CutPrefix(f, projectFolder+"/"); ok {
+			assets[rel] = true
+		}
+	}
+	return assets
+}
+
+// seedAssetTaint taints the symbols of files (keyed by stem) importing a changed asset
+// of the project: those using the imported binding, or all of them for a bare import.
+func seedAssetTaint(projectFolder string, fileAnalyses map[string]*tsparse.FileAnalysis, tainted map[string]map[string]bool) {
+	assets := projectAssets(projectFolder)
+	if len(assets) == 0 {
+		return
+	}
+	for _, stem := range mapKeys(fileAnalyses) {
+		analysis := fileAnalyses[stem]
+		for _, imp := range analysis.Imports {
+			if !strings.HasPrefix(imp.Source, ".") {
+				continue
+			}
+			resolved := filepath.ToSlash(filepath.Clean(filepath.Join(filepath.Dir(stem+".ts"), imp.Source)))
+			if !assets[resolved] {
+				continue
+			}
+			if tainted[stem] == nil {
+				tainted[stem] = make(map[string]bool)
+			}
+			if len(imp.Names) > 0 {
+				usageTainted := findTaintedSymbolsByUsage(analysis, importLocalNames(imp))
+				for _, s := range usageTainted {
+					tainted[stem][s] = true
+				}
+				log.Debugf("    %s: usage-tainted via asset import %s (names: %v)", stem, imp.Source, imp.Names)
+			} else {
+				for _, sym := range analysis.Symbols {
+					tainted[stem][sym.Name] = true
+				}
+				log.Debugf("    %s: all symbols tainted via asset import %s", stem, imp.Source)
+			}
+		}
+	}
+}
+
+// assetImporters caches AssetImporters per project folder.
+var assetImporters = struct {
+	sync.Mutex
+	byProject map[string]map[string][]string
+}{byProject: make(map[string]map[string][]string)}
+
+// AssetImporters returns the source files of a project (relative to it, sorted)
+// importing the changed asset file rel, relative to the project.
+func AssetImporters(projectFolder, rel string) []string {
+	assetImporters.Lock()
+	defer assetImporters.Unlock()
+	importers, ok := assetImporters.byProject[projectFolder]
+	if !ok {
+		importers = make(map[string][]string)
+		assets := projectAssets(projectFolder)
+		files, _ := globSourceFiles(projectFolder)
+		for _, file := range files {
+			analysis, err := tsparse.ParseFile(filepath.Join(projectFolder, file))
+			if err != nil {
+				continue
+			}
+			for _, imp := range analysis.Imports {
+				if !strings.HasPrefix(imp.Source, ".") {
+					continue
+				}
+				resolved := filepath.ToSlash(filepath.Clean(filepath.Join(filepath.Dir(file), imp.Source)))
+				if assets[resolved] && !slices.Contains(importers[resolved], file) {
+					importers[resolved] = append(importers[resolved], file)
+				}
+			}
+		}
+		for _, files := range importers {
+			sort.Strings(files)
+		}
+		assetImporters.byProject[projectFolder] = importers
+	}
+	return importers[rel]
+}
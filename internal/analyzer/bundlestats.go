@@ -0,0 +1,158 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// BundleChunk is an emitted chunk of an app's bundle.
+type BundleChunk struct {
+	Name string
+	// Initial is set for chunks loaded with the page (entry chunks and their
+	// synchronous dependencies), as opposed to lazily loaded ones.
+	Initial bool
+}
+
+// BundleStats maps the source modules of an app's bundle (paths relative to the app's
+// project folder) to the chunks emitted with them.
+type BundleStats map[string][]BundleChunk
+
+// Lazy returns the chunks of a module when it is bundled in lazily loaded chunks
+// only; nil when it is in an initial chunk or not in the bundle.
+func (s BundleStats) Lazy(file string) []BundleChunk {
+	chunks := s[file]
+	for _, c := range chunks {
+		if c.Initial {
+			return nil
+		}
+	}
+	return chunks
+}
+
+// LoadBundleStats reads a webpack stats file (`webpack --json`, with chunks and
+// modules) or a vite build manifest (`build.manifest`). Module paths of webpack stats
+// are relative to its context, and keys of a vite manifest to its root: both must be
+// the app's project folder. A vite manifest lists only the modules starting a chunk
+// (entries and dynamically imported modules), so only those are mapped.
+func LoadBundleStats(file string) (BundleStats, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", file, err)
+	}
+	if _, ok := probe["chunks"]; ok {
+		return parseWebpackStats(data)
+	}
+	return parseViteManifest(data)
+}
+
+type webpackStats struct {
+	Chunks []struct {
+		ID      any             `json:"id"`
+		Names   []string        `json:"names"`
+		Initial bool            `json:"initial"`
+		Modules []webpackModule `json:"modules"`
+	} `json:"chunks"`
+	Modules []webpackModule `json:"modules"`
+}
+
+type webpackModule struct {
+	Name    string          `json:"name"`
+	Chunks  []any           `json:"chunks"`
+	Modules []webpackModule `json:"modules"` // modules concatenated into this one
+}
+
+func parseWebpackStats(data []byte) (BundleStats, error) {
+	var raw webpackStats
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing webpack stats: %w", err)
+	}
+	stats := make(BundleStats)
+	byID := make(map[string]BundleChunk, len(raw.Chunks))
+	add := func(m webpackModule, chunk BundleChunk) {
+		var walk func(m webpackModule)
+		walk = func(m webpackModule) {
+			if len(m.Modules) > 0 {
+				for _, inner := range m.Modules {
+					walk(inner)
+				}
+				return
+			}
+			if file := webpackModulePath(m.Name); file != "" {
+				for _, c := range stats[file] {
+					if c == chunk {
+						return
+					}
+				}
+				stats[file] = append(stats[file], chunk)
+			}
+		}
+		walk(m)
+	}
+	for _, c := range raw.Chunks {
+		id := fmt.Sprint(c.ID)
+		chunk := BundleChunk{Name: id, Initial: c.Initial}
+		if len(c.Names) > 0 {
+			chunk.Name = c.Names[0]
+		}
+		byID[id] = chunk
+		for _, m := range c.Modules {
+			add(m, chunk)
+		}
+	}
+	// Stats written with chunkModules off list the chunks of each module instead.
+	for _, m := range raw.Modules {
+		for _, id := range m.Chunks {
+			if chunk, ok := byID[fmt.Sprint(id)]; ok {
+				add(m, chunk)
+			}
+		}
+	}
+	return stats, nil
+}
+
+// webpackModulePath returns the source path of a webpack module name ("./src/a.ts",
+// "./src/a.ts + 3 modules"), or "" for modules outside the app's sources (runtime,
+// node_modules, external or ignored modules).
+func webpackModulePath(name string) string {
+	name, _, _ = strings.Cut(name, " + ")
+	if i := strings.LastIndex(name, "!"); i >= 0 {
+		name = name[i+1:]
+	}
+	if !strings.HasPrefix(name, "./") || strings.Contains(name, "node_modules/") {
+		return ""
+	}
+	return path.Clean(name)
+}
+
+type viteManifestEntry struct {
+	Src            string `json:"src"`
+	Name           string `json:"name"`
+	IsEntry        bool   `json:"isEntry"`
+	IsDynamicEntry bool   `json:"isDynamicEntry"`
+}
+
+func parseViteManifest(data []byte) (BundleStats, error) {
+	var raw map[string]viteManifestEntry
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing vite manifest: %w", err)
+	}
+	stats := make(BundleStats)
+	for key, e := range raw {
+		if e.Src == "" || (!e.IsEntry && !e.IsDynamicEntry) {
+			continue // shared chunks and assets start with no source module
+		}
+		chunk := BundleChunk{Name: e.Name, Initial: e.IsEntry}
+		if chunk.Name == "" {
+			chunk.Name = key
+		}
+		stats[path.Clean(e.Src)] = append(stats[path.Clean(e.Src)], chunk)
+	}
+	return stats, nil
+}
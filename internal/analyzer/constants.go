@@ -0,0 +1,140 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+
+	"goodchanges/tsgo-vendor/pkg/ast"
+	"goodchanges/tsgo-vendor/pkg/scanner"
+)
+
+// constantValueDeltas describes how the values of an enum or a `const` literal changed
+// between the OLD and NEW declaration of the named symbol, one "path: old → new" entry
+// per changed value, sorted by path. Enum members are reported as "Enum.Member" and
+// properties of object literals (e.g. feature-flag maps) as "name.key.nested".
+// Returns nil when the declaration is not a constant, or no literal value changed
+// (e.g. a computed value or a function body changed instead).
+func constantValueDeltas(name string, oldStmt, newStmt *ast.Node, oldText, newText string) []string {
+	if oldStmt == nil || newStmt == nil || oldStmt.Kind != newStmt.Kind {
+		return nil
+	}
+	oldValues := make(map[string]string)
+	newValues := make(map[string]string)
+	switch newStmt.Kind {
+	case ast.KindEnumDeclaration:
+		collectEnumValues(name, oldStmt, oldText, oldValues)
+		collectEnumValues(name, newStmt, newText, newValues)
+	case ast.KindVariableStatement:
+		oldInit := constInitializer(name, oldStmt)
+		newInit := constInitializer(name, newStmt)
+		if oldInit == nil || newInit == nil {
+			return nil
+		}
+		collectLiteralValues(name, oldInit, oldText, oldValues)
+		collectLiteralValues(name, newInit, newText, newValues)
+	default:
+		return nil
+	}
+
+	var deltas []string
+	for path, newValue := range newValues {
+		oldValue, ok := oldValues[path]
+		if !ok {
+			deltas = append(deltas, fmt.Sprintf("%s: (added) → %s", path, newValue))
+		} else if oldValue != newValue {
+			deltas = append(deltas, fmt.Sprintf("%s: %s → %s", path, oldValue, newValue))
+		}
+	}
+	for path, oldValue := range oldValues {
+		if _, ok := newValues[path]; !ok {
+			deltas = append(deltas, fmt.Sprintf("%s: %s → (removed)", path, oldValue))
+		}
+	}
+	sort.Strings(deltas)
+	return deltas
+}
+
+// collectEnumValues records each enum member's initializer text under "Enum.Member".
+// Members without an initializer are recorded as "(auto)".
+func collectEnumValues(name string, stmt *ast.Node, sourceText string, values map[string]string) {
+	ed := stmt.AsEnumDeclaration()
+	if ed.Members == nil {
+		return
+	}
+	for _, member := range ed.Members.Nodes {
+		memberName := propertyNameText(member.Name())
+		if memberName == "" {
+			continue
+		}
+		value := "(auto)"
+		if init := member.AsEnumMember().Initializer; init != nil {
+			value = normalizeWhitespace(scanner.GetTextOfNodeFromSourceText(sourceText, init, false))
+		}
+		values[name+"."+memberName] = value
+	}
+}
+
+// constInitializer returns the initializer of the `const` declaration of name in a
+// variable statement, with parentheses, `as const` and `satisfies` wrappers removed.
+func constInitializer(name string, stmt *ast.Node) *ast.Node {
+	vs := stmt.AsVariableStatement()
+	if vs.DeclarationList == nil || vs.DeclarationList.Flags&ast.NodeFlagsBlockScoped != ast.NodeFlagsConst {
+		return nil
+	}
+	dl := vs.DeclarationList.AsVariableDeclarationList()
+	if dl.Declarations == nil {
+		return nil
+	}
+	for _, decl := range dl.Declarations.Nodes {
+		declName := decl.Name()
+		if declName == nil || !ast.IsIdentifier(declName) || declName.Text() != name {
+			continue
+		}
+		init := decl.AsVariableDeclaration().Initializer
+		if init == nil {
+			return nil
+		}
+		return ast.SkipOuterExpressions(init, ast.OEKParentheses|ast.OEKAssertions)
+	}
+	return nil
+}
+
+// collectLiteralValues records the literal values of expr under path, descending into
+// object literals. Non-literal values (calls, identifiers, functions, ...) are skipped.
+func collectLiteralValues(path string, expr *ast.Node, sourceText string, values map[string]string) {
+	expr = ast.SkipOuterExpressions(expr, ast.OEKParentheses|ast.OEKAssertions)
+	switch expr.Kind {
+	case ast.KindObjectLiteralExpression:
+		ole := expr.AsObjectLiteralExpression()
+		if ole.Properties == nil {
+			return
+		}
+		for _, prop := range ole.Properties.Nodes {
+			if prop.Kind != ast.KindPropertyAssignment {
+				continue
+			}
+			key := propertyNameText(prop.Name())
+			if key == "" {
+				continue
+			}
+			collectLiteralValues(path+"."+key, prop.AsPropertyAssignment().Initializer, sourceText, values)
+		}
+	case ast.KindStringLiteral, ast.KindNoSubstitutionTemplateLiteral, ast.KindNumericLiteral,
+		ast.KindBigIntLiteral, ast.KindTrueKeyword, ast.KindFalseKeyword, ast.KindNullKeyword,
+		ast.KindPrefixUnaryExpression, ast.KindArrayLiteralExpression:
+		values[path] = normalizeWhitespace(scanner.GetTextOfNodeFromSourceText(sourceText, expr, false))
+	}
+}
+
+// propertyNameText returns the text of an identifier, string or numeric property name.
+// Computed names are not supported and yield "".
+func propertyNameText(name *ast.Node) string {
+	if name == nil {
+		return ""
+	}
+	switch name.Kind {
+	case ast.KindIdentifier, ast.KindStringLiteral, ast.KindNumericLiteral:
+		return name.Text()
+	}
+	return ""
+}
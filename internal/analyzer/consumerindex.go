@@ -0,0 +1,177 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+
+	"goodchanges/internal/rush"
+)
+
+// ConsumerIndex maps the exports of workspace libraries to the workspace files
+// importing them and the targets watching those files. It answers "which targets
+// consume export X" without a diff analysis; taint through intermediate libraries
+// (a consumer that re-exports X) is not followed.
+type ConsumerIndex struct {
+	Version string            `json:"version"` // goodchanges version that built the index
+	Exports []ExportConsumers `json:"exports"` // sorted by specifier and name
+}
+
+// ExportConsumers is one entrypoint export and the files importing it.
+type ExportConsumers struct {
+	Specifier string         `json:"specifier"` // e.g. "@gooddata/sdk-ui" or "@gooddata/sdk-ui/internal"
+	Name      string         `json:"name"`
+	Consumers []ConsumerFile `json:"consumers,omitempty"` // sorted by file
+}
+
+// ConsumerFile is a workspace file importing an export, with the targets whose
+// changeDirs match it.
+type ConsumerFile struct {
+	File    string   `json:"file"` // repo-relative
+	Targets []string `json:"targets,omitempty"`
+}
+
+// IndexLibrary is a library whose entrypoint exports are indexed.
+type IndexLibrary struct {
+	PackageName   string
+	ProjectFolder string
+	Entrypoints   []Entrypoint
+}
+
+// IndexTarget is a target as seen by the index: the files of its project matching
+// its changeDirs (and not ignored by Config) belong to it.
+type IndexTarget struct {
+	Name          string
+	ProjectFolder string
+	ChangeDirs    []rush.ChangeDir
+	Config        *rush.ProjectConfig // the project's config with the target's ignores merged in
+}
+
+// BuildConsumerIndex indexes the entrypoint exports of the libraries against the
+// import index and assigns each consuming file the targets watching it. Imports
+// using every export of a specifier ("*") consume each of its exports.
+func BuildConsumerIndex(imports *ImportIndex, libraries []IndexLibrary, targets []IndexTarget, version string) *ConsumerIndex {
+	targetsByFolder := make(map[string][]IndexTarget)
+	for _, t := range targets {
+		targetsByFolder[t.ProjectFolder] = append(targetsByFolder[t.ProjectFolder], t)
+	}
+	fileTargets := make(map[string][]string) // repo-relative file → target names
+	targetsOf := func(use ImportUse) []string {
+		file := filepath.ToSlash(filepath.Join(use.ProjectFolder, use.File))
+		if names, ok := fileTargets[file]; ok {
+			return names
+		}
+		var names []string
+		for _, t := range targetsByFolder[use.ProjectFolder] {
+			if t.Config.IsIgnored(use.File) {
+				continue
+			}
+			for _, cd := range t.ChangeDirs {
+				if matched, _ := doublestar.Match(cd.Glob, filepath.ToSlash(use.File)); matched {
+					names = append(names, t.Name)
+					break
+				}
+			}
+		}
+		sort.Strings(names)
+		fileTargets[file] = names
+		return names
+	}
+
+	idx := &ConsumerIndex{Version: version}
+	for _, lib := range libraries {
+		for _, ep := range lib.Entrypoints {
+			// Wildcard entrypoints ("./utils/*") are imported through any matching subpath.
+			pattern := lib.PackageName + strings.TrimPrefix(ep.ExportPath, ".")
+			var specifiers []string
+			for _, s := range imports.Specifiers() {
+				if matched, _ := doublestar.Match(pattern, s); matched {
+					specifiers = append(specifiers, s)
+				}
+			}
+			for _, name := range CollectEntrypointExports(lib.ProjectFolder, ep) {
+				if name == "*" {
+					continue
+				}
+				for _, specifier := range specifiers {
+					ec := ExportConsumers{Specifier: specifier, Name: name}
+					seen := make(map[string]bool)
+					for _, use := range imports.Uses(specifier, name) {
+						file := filepath.ToSlash(filepath.Join(use.ProjectFolder, use.File))
+						if !seen[file] {
+							seen[file] = true
+							ec.Consumers = append(ec.Consumers, ConsumerFile{File: file, Targets: targetsOf(use)})
+						}
+					}
+					sort.Slice(ec.Consumers, func(i, j int) bool { return ec.Consumers[i].File < ec.Consumers[j].File })
+					idx.Exports = append(idx.Exports, ec)
+				}
+			}
+		}
+	}
+	sort.Slice(idx.Exports, func(i, j int) bool {
+		if idx.Exports[i].Specifier != idx.Exports[j].Specifier {
+			return idx.Exports[i].Specifier < idx.Exports[j].Specifier
+		}
+		return idx.Exports[i].Name < idx.Exports[j].Name
+	})
+	return idx
+}
+
+// WriteConsumerIndex writes the index as JSON, creating parent directories.
+func WriteConsumerIndex(path string, idx *ConsumerIndex) error {
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// LoadConsumerIndex reads an index written by WriteConsumerIndex.
+func LoadConsumerIndex(path string) (*ConsumerIndex, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var idx ConsumerIndex
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return &idx, nil
+}
+
+// Lookup returns the consumers of an export of a specifier, or nil if the export is
+// not indexed.
+func (idx *ConsumerIndex) Lookup(specifier, name string) *ExportConsumers {
+	i := sort.Search(len(idx.Exports), func(i int) bool {
+		e := idx.Exports[i]
+		return e.Specifier > specifier || (e.Specifier == specifier && e.Name >= name)
+	})
+	if i < len(idx.Exports) && idx.Exports[i].Specifier == specifier && idx.Exports[i].Name == name {
+		return &idx.Exports[i]
+	}
+	return nil
+}
+
+// Targets returns the targets consuming an export of a specifier, sorted.
+func (idx *ConsumerIndex) Targets(specifier, name string) []string {
+	ec := idx.Lookup(specifier, name)
+	if ec == nil {
+		return nil
+	}
+	set := make(map[string]bool)
+	for _, c := range ec.Consumers {
+		for _, t := range c.Targets {
+			set[t] = true
+		}
+	}
+	return mapKeys(set)
+}
@@ -0,0 +1,186 @@
+package analyzer
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"goodchanges/internal/log"
+	"goodchanges/internal/tsparse"
+	"goodchanges/tsgo-vendor/pkg/ast"
+)
+
+// contextMember is a symbol of a React context group.
+type contextMember struct {
+	stem     string
+	name     string
+	consumer bool // reads the context without being a hook (e.g. a component); receives taint only
+}
+
+// contextGroup links a React context object (`const ThemeContext = createContext(...)`)
+// with the symbols of its package that provide or consume it: providers rendering
+// `<ThemeContext.Provider>` and hooks calling `useContext(ThemeContext)`. A consumer
+// usually imports only the hook, so a change to the default value or to the provider
+// does not reach it through the import graph; the group is tainted as a whole instead.
+// Components reading the context directly are tainted with the group, but a change to
+// one of them does not taint the rest.
+type contextGroup struct {
+	context contextMember
+	members []contextMember // the context object first, then providers, hooks and consumers
+}
+
+// findContextGroups returns the context groups of a package with at least one
+// provider or hook besides the context object.
+func findContextGroups(projectFolder string, fileAnalyses map[string]*tsparse.FileAnalysis) []contextGroup {
+	var groups []contextGroup
+	for _, stem := range mapKeys(fileAnalyses) {
+		analysis := fileAnalyses[stem]
+		if analysis.SourceFile == nil {
+			continue
+		}
+		for _, name := range createdContexts(analysis.SourceFile) {
+			ctx := contextMember{stem: stem, name: name}
+			groups = append(groups, contextGroup{context: ctx, members: []contextMember{ctx}})
+		}
+	}
+	if len(groups) == 0 {
+		return nil
+	}
+
+	for _, stem := range mapKeys(fileAnalyses) {
+		analysis := fileAnalyses[stem]
+		if analysis.SourceFile == nil {
+			continue
+		}
+		sourceText := analysis.SourceFile.Text()
+		lineMap := analysis.SourceFile.ECMALineMap()
+		for gi := range groups {
+			local := contextLocalName(projectFolder, stem, analysis, groups[gi].context)
+			if local == "" {
+				continue
+			}
+			re := contextUseRe(local)
+			for _, sym := range analysis.Symbols {
+				if stem == groups[gi].context.stem && sym.Name == groups[gi].context.name {
+					continue
+				}
+				body := tsparse.ExtractTextForLines(sourceText, lineMap, sym.StartLine, sym.EndLine)
+				if !re.MatchString(body) {
+					continue
+				}
+				consumer := !isHookName(sym.Name) && !strings.Contains(body, local+".Provider")
+				groups[gi].members = append(groups[gi].members, contextMember{stem, sym.Name, consumer})
+			}
+		}
+	}
+
+	var result []contextGroup
+	for _, g := range groups {
+		if len(g.members) > 1 {
+			result = append(result, g)
+		}
+	}
+	return result
+}
+
+// createdContexts returns the names of top-level variables initialized with
+// `createContext(...)` or `React.createContext(...)`.
+func createdContexts(sf *ast.SourceFile) []string {
+	var names []string
+	for _, stmt := range sf.Statements.Nodes {
+		if stmt.Kind != ast.KindVariableStatement {
+			continue
+		}
+		dl := stmt.AsVariableStatement().DeclarationList
+		if dl == nil || dl.AsVariableDeclarationList().Declarations == nil {
+			continue
+		}
+		for _, decl := range dl.AsVariableDeclarationList().Declarations.Nodes {
+			name := decl.Name()
+			init := decl.Initializer()
+			if name == nil || !ast.IsIdentifier(name) || init == nil || !ast.IsCallExpression(init) {
+				continue
+			}
+			callee := init.AsCallExpression().Expression
+			if ast.IsPropertyAccessExpression(callee) {
+				callee = callee.AsPropertyAccessExpression().Name()
+			}
+			if ast.IsIdentifier(callee) && callee.Text() == "createContext" {
+				names = append(names, name.Text())
+			}
+		}
+	}
+	return names
+}
+
+// contextLocalName returns the name a file refers to a context object by: its own
+// name in the declaring file, the local binding of a relative import of it elsewhere.
+func contextLocalName(projectFolder string, stem string, analysis *tsparse.FileAnalysis, ctx contextMember) string {
+	if stem == ctx.stem {
+		return ctx.name
+	}
+	dir := filepath.Dir(stem + ".ts")
+	for _, imp := range analysis.Imports {
+		if !strings.HasPrefix(imp.Source, ".") || resolveImportSource(dir, imp.Source, projectFolder) != ctx.stem {
+			continue
+		}
+		for i, name := range imp.Names {
+			if name == ctx.name {
+				return importLocalName(imp, i)
+			}
+		}
+	}
+	return ""
+}
+
+// contextUseRe matches providing or consuming a context by its local name:
+// `useContext(X)`, `use(X)`, `X.Provider` and `X.Consumer`.
+func contextUseRe(local string) *regexp.Regexp {
+	q := regexp.QuoteMeta(local)
+	return regexp.MustCompile(`\b(?:useContext|use)\(\s*` + q + `\s*[,)]|\b` + q + `\.(?:Provider|Consumer)\b`)
+}
+
+// isHookName reports whether a symbol name follows the React hook convention (`useTheme`).
+func isHookName(name string) bool {
+	return len(name) > 3 && strings.HasPrefix(name, "use") && name[3] >= 'A' && name[3] <= 'Z'
+}
+
+// taintContextGroups taints every member of a context group that has a tainted
+// member, and returns the files that gained taint. record writes @bfs debug records.
+func taintContextGroups(projectFolder string, groups []contextGroup, fileAnalyses map[string]*tsparse.FileAnalysis, tainted map[string]map[string]bool, record bool) []string {
+	var stems []string
+	for _, g := range groups {
+		var from *contextMember
+		for i, m := range g.members {
+			if !m.consumer && (tainted[m.stem][m.name] || tainted[m.stem]["*"]) {
+				from = &g.members[i]
+				break
+			}
+		}
+		if from == nil {
+			continue
+		}
+		for _, m := range g.members {
+			if tainted[m.stem][m.name] || tainted[m.stem]["*"] {
+				continue
+			}
+			if tainted[m.stem] == nil {
+				tainted[m.stem] = make(map[string]bool)
+			}
+			names := []string{m.name}
+			names = append(names, localExportAliases(fileAnalyses[m.stem], map[string]bool{m.name: true})...)
+			for _, n := range names {
+				if tainted[m.stem][n] {
+					continue
+				}
+				tainted[m.stem][n] = true
+				log.Debugf("    → %s: %s tainted via context %s (%s changed)", m.stem, n, g.context.name, from.name)
+				if record {
+					log.Recordf("bfs", "pkg=%s file=%s symbol=%s from=%s via=context:%s", projectFolder, m.stem, n, from.stem, g.context.name)
+				}
+			}
+			stems = append(stems, m.stem)
+		}
+	}
+	return stems
+}
@@ -0,0 +1,531 @@
+package analyzer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"goodchanges/internal/git"
+	"goodchanges/internal/log"
+)
+
+// specDiff is the outcome of structurally diffing an API spec.
+type specDiff struct {
+	endpoints  []string // OpenAPI paths or "pkg.Service/Method"
+	operations []string // operationIds or proto method names of changed operations
+	types      []string // names of changed schemas or messages/enums, including dependents
+	// anonymous is true when a changed OpenAPI operation has no operationId.
+	anonymous bool
+}
+
+// ChangedContractEndpoints diffs a changed backend-contract file (repo-relative) against
+// the merge base and returns the endpoints whose behavior may have changed:
+//   - OpenAPI/Swagger specs (.yaml, .yml, .json): paths ("/users/{id}") whose path item
+//     changed or references (via $ref, transitively) a changed component
+//   - proto files: methods ("pkg.Service/Method") whose rpc changed or whose request or
+//     response type references a changed message or enum
+//
+// ok is false when the change can't be attributed to endpoints: the file is not an
+// API spec (e.g. a migration), can't be parsed, or changed outside endpoints and their
+// types (e.g. servers, security schemes, the proto package). Changes to documentation-only
+// OpenAPI sections (info, tags, externalDocs) are ignored.
+func ChangedContractEndpoints(ctx context.Context, file string, mergeBase string) (endpoints []string, ok bool) {
+	diff, ok := diffSpecFile(ctx, file, mergeBase)
+	if ok {
+		log.Debugf("  contract %s: changed endpoints %v", file, diff.endpoints)
+	} else {
+		log.Debugf("  contract %s: change not attributable to endpoints", file)
+	}
+	return diff.endpoints, ok
+}
+
+// diffSpecFile diffs a changed OpenAPI or proto file against the merge base.
+// ok is false as described in ChangedContractEndpoints.
+func diffSpecFile(ctx context.Context, file string, mergeBase string) (specDiff, bool) {
+	oldContent, _ := git.ShowFile(ctx, mergeBase, BasePath(file))
+	newData, _ := os.ReadFile(file)
+	newContent := string(newData)
+	// Added and deleted files change all of their endpoints; only compare those.
+	wholeFile := oldContent == "" || newContent == ""
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".proto":
+		return diffProto(parseProto(oldContent), parseProto(newContent), wholeFile)
+	case ".yaml", ".yml", ".json":
+		oldDoc, oldOK := parseOpenAPI(oldContent)
+		newDoc, newOK := parseOpenAPI(newContent)
+		if !oldOK || !newOK || oldDoc == nil && newDoc == nil {
+			log.Debugf("  spec %s: not an OpenAPI document", file)
+			return specDiff{}, false
+		}
+		return diffOpenAPI(oldDoc, newDoc, wholeFile)
+	}
+	return specDiff{}, false
+}
+
+// CorrelateSpecChanges maps the changes of the API specs a library's generated client is
+// produced from (generated.specs) to the client's exports: an export is affected when
+// its name, lowercased and stripped of non-alphanumerics, contains the name of a changed
+// operation (operationId or proto method, e.g. "getUserById" → getUserById,
+// useGetUserById, UsersApiGetUserByIdRequest) or changed schema/message type.
+//
+// whole is true when the changes can't be correlated symbol by symbol -- a spec change
+// not attributable to endpoints, a changed operation without an operationId, or one no
+// export is named after (e.g. clients generating one class per API tag) -- and the
+// whole package must be treated as tainted instead.
+func CorrelateSpecChanges(ctx context.Context, projectFolder string, entrypoints []Entrypoint, specFiles []string, mergeBase string) (affected []AffectedExport, whole bool) {
+	var operations, types []string
+	for _, f := range specFiles {
+		diff, ok := diffSpecFile(ctx, f, mergeBase)
+		if !ok || diff.anonymous {
+			log.Debugf("  spec %s: change can't be correlated to operations", f)
+			return nil, true
+		}
+		log.Debugf("  spec %s: changed operations %v, types %v", f, diff.operations, diff.types)
+		operations = append(operations, diff.operations...)
+		types = append(types, diff.types...)
+	}
+
+	matchedOperations := make(map[string]bool)
+	for _, ep := range entrypoints {
+		var names []string
+		for _, name := range CollectEntrypointExports(projectFolder, ep) {
+			normalized := normalizeSymbolName(name)
+			hit := false
+			for _, op := range operations {
+				if strings.Contains(normalized, normalizeSymbolName(op)) {
+					matchedOperations[op] = true
+					hit = true
+				}
+			}
+			for _, t := range types {
+				if strings.Contains(normalized, normalizeSymbolName(t)) {
+					hit = true
+				}
+			}
+			if hit {
+				names = append(names, name)
+			}
+		}
+		if len(names) > 0 {
+			affected = append(affected, AffectedExport{EntrypointPath: ep.ExportPath, ExportNames: names})
+		}
+	}
+	for _, op := range operations {
+		if !matchedOperations[op] {
+			log.Debugf("  spec operation %s matches no export of %s", op, projectFolder)
+			return nil, true
+		}
+	}
+	return affected, false
+}
+
+// normalizeSymbolName lowercases name and drops everything but letters and digits, so
+// operationIds in any casing convention compare equal to generated identifiers.
+func normalizeSymbolName(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// taintedUnits returns the changed units plus every unit that references one of them,
+// directly or transitively.
+func taintedUnits(changed map[string]bool, refs map[string]map[string]bool) map[string]bool {
+	tainted := make(map[string]bool, len(changed))
+	for u := range changed {
+		tainted[u] = true
+	}
+	for grew := true; grew; {
+		grew = false
+		for u, targets := range refs {
+			if tainted[u] {
+				continue
+			}
+			for t := range targets {
+				if tainted[t] {
+					tainted[u] = true
+					grew = true
+					break
+				}
+			}
+		}
+	}
+	return tainted
+}
+
+// --- OpenAPI ---
+
+// openAPIDocKeys are top-level OpenAPI keys whose changes never affect endpoint behavior.
+var openAPIDocKeys = map[string]bool{"info": true, "tags": true, "externalDocs": true}
+
+// parseOpenAPI parses an OpenAPI 3 or Swagger 2 document (YAML or JSON). Empty content
+// yields a nil document; ok is false for anything else that isn't a spec.
+func parseOpenAPI(content string) (doc map[string]any, ok bool) {
+	if strings.TrimSpace(content) == "" {
+		return nil, true
+	}
+	if err := yaml.Unmarshal([]byte(content), &doc); err != nil {
+		return nil, false
+	}
+	return doc, doc["openapi"] != nil || doc["swagger"] != nil
+}
+
+// openAPIUnits returns the $ref-able definitions of a document keyed by JSON pointer:
+// "#/components/<section>/<name>" (OpenAPI 3) and "#/definitions/<name>",
+// "#/parameters/<name>", "#/responses/<name>" (Swagger 2).
+func openAPIUnits(doc map[string]any) map[string]any {
+	units := make(map[string]any)
+	add := func(prefix string, section any) {
+		m, _ := section.(map[string]any)
+		for name, v := range m {
+			units[prefix+"/"+escapeJSONPointer(name)] = v
+		}
+	}
+	if components, ok := doc["components"].(map[string]any); ok {
+		for section, v := range components {
+			add("#/components/"+section, v)
+		}
+	}
+	for _, section := range []string{"definitions", "parameters", "responses"} {
+		add("#/"+section, doc[section])
+	}
+	return units
+}
+
+func escapeJSONPointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
+
+// openAPIRefUnit returns the unit a local $ref points into ("#/components/schemas/A/properties/b"
+// → "#/components/schemas/A"), or "" for external refs.
+func openAPIRefUnit(ref string) string {
+	parts := strings.Split(ref, "/")
+	if parts[0] != "#" || len(parts) < 3 {
+		return ""
+	}
+	n := 3
+	if parts[1] == "components" {
+		n = 4
+	}
+	if len(parts) < n {
+		return ""
+	}
+	return strings.Join(parts[:n], "/")
+}
+
+// collectOpenAPIRefs adds the units referenced by local $refs anywhere in node to refs.
+func collectOpenAPIRefs(node any, refs map[string]bool) {
+	switch v := node.(type) {
+	case map[string]any:
+		for key, child := range v {
+			if ref, ok := child.(string); ok && key == "$ref" {
+				if unit := openAPIRefUnit(ref); unit != "" {
+					refs[unit] = true
+				}
+				continue
+			}
+			collectOpenAPIRefs(child, refs)
+		}
+	case []any:
+		for _, child := range v {
+			collectOpenAPIRefs(child, refs)
+		}
+	}
+}
+
+// openAPIMethods are the operation keys of an OpenAPI path item.
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+func diffOpenAPI(oldDoc, newDoc map[string]any, wholeFile bool) (specDiff, bool) {
+	var diff specDiff
+	if !wholeFile {
+		for _, key := range unionKeys(oldDoc, newDoc) {
+			switch key {
+			case "paths", "components", "definitions", "parameters", "responses":
+				continue
+			}
+			if !openAPIDocKeys[key] && !reflect.DeepEqual(oldDoc[key], newDoc[key]) {
+				log.Debugf("  OpenAPI: top-level %q changed", key)
+				return diff, false
+			}
+		}
+	}
+
+	oldUnits, newUnits := openAPIUnits(oldDoc), openAPIUnits(newDoc)
+	changed := make(map[string]bool)
+	refs := make(map[string]map[string]bool)
+	for _, unit := range unionKeys(oldUnits, newUnits) {
+		if !reflect.DeepEqual(oldUnits[unit], newUnits[unit]) {
+			changed[unit] = true
+		}
+		refs[unit] = make(map[string]bool)
+		collectOpenAPIRefs(oldUnits[unit], refs[unit])
+		collectOpenAPIRefs(newUnits[unit], refs[unit])
+	}
+	tainted := taintedUnits(changed, refs)
+	for _, unit := range unionKeys(tainted, nil) {
+		if strings.HasPrefix(unit, "#/components/schemas/") || strings.HasPrefix(unit, "#/definitions/") {
+			diff.types = append(diff.types, unit[strings.LastIndex(unit, "/")+1:])
+		}
+	}
+	// referencesTainted reports whether node references a tainted unit.
+	referencesTainted := func(node any) bool {
+		nodeRefs := make(map[string]bool)
+		collectOpenAPIRefs(node, nodeRefs)
+		for unit := range nodeRefs {
+			if tainted[unit] {
+				return true
+			}
+		}
+		return false
+	}
+
+	oldPaths, _ := oldDoc["paths"].(map[string]any)
+	newPaths, _ := newDoc["paths"].(map[string]any)
+	for _, path := range unionKeys(oldPaths, newPaths) {
+		oldItem, _ := oldPaths[path].(map[string]any)
+		newItem, _ := newPaths[path].(map[string]any)
+		// Path-level fields (shared parameters, servers, $ref) affect every operation.
+		itemChanged := false
+		for _, key := range unionKeys(oldItem, newItem) {
+			if !slices.Contains(openAPIMethods, key) && !reflect.DeepEqual(oldItem[key], newItem[key]) {
+				itemChanged = true
+			}
+		}
+		itemChanged = itemChanged || referencesTainted(withoutKeys(newItem, openAPIMethods))
+		pathChanged := false
+		for _, method := range openAPIMethods {
+			oldOp, newOp := oldItem[method], newItem[method]
+			if oldOp == nil && newOp == nil {
+				continue
+			}
+			if !itemChanged && reflect.DeepEqual(oldOp, newOp) && !referencesTainted(newOp) {
+				continue
+			}
+			pathChanged = true
+			op, _ := newOp.(map[string]any)
+			if op == nil {
+				op, _ = oldOp.(map[string]any)
+			}
+			if id, _ := op["operationId"].(string); id != "" {
+				diff.operations = append(diff.operations, id)
+			} else {
+				diff.anonymous = true
+			}
+		}
+		if pathChanged || itemChanged {
+			diff.endpoints = append(diff.endpoints, path)
+		}
+	}
+	return diff, true
+}
+
+// withoutKeys returns a copy of m without the given keys.
+func withoutKeys(m map[string]any, keys []string) map[string]any {
+	result := make(map[string]any, len(m))
+	for k, v := range m {
+		if !slices.Contains(keys, k) {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// unionKeys returns the sorted keys present in either map.
+func unionKeys[V any](a, b map[string]V) []string {
+	seen := make(map[string]bool, len(a)+len(b))
+	var keys []string
+	for _, m := range []map[string]V{a, b} {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// --- proto ---
+
+// protoTokenRe matches proto tokens; comments are matched (and dropped) so that comment
+// markers inside string literals are left alone.
+var protoTokenRe = regexp.MustCompile(`"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'|/\*[\s\S]*?\*/|//[^\n]*|\.?[A-Za-z_][A-Za-z0-9_.]*|[0-9][A-Za-z0-9_.+-]*|\S`)
+
+// protoFile is the endpoint-relevant structure of a .proto file.
+type protoFile struct {
+	pkg      string
+	units    map[string]protoUnit // top-level message/enum name → definition
+	rpcs     map[string]protoUnit // "Service/Method" → rpc statement (refs: request/response types)
+	services map[string]string    // service name → service body outside rpcs (options)
+	rest     string               // everything outside messages, enums, services and imports
+}
+
+type protoUnit struct {
+	text string   // whitespace-normalized definition
+	refs []string // referenced type names, last component only
+}
+
+func protoTokens(content string) []string {
+	var tokens []string
+	for _, t := range protoTokenRe.FindAllString(content, -1) {
+		if !strings.HasPrefix(t, "//") && !strings.HasPrefix(t, "/*") {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
+
+// matchBrace returns the index just past the "}" closing the "{" at tokens[open].
+func matchBrace(tokens []string, open int) int {
+	depth := 0
+	for i := open; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "{":
+			depth++
+		case "}":
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return len(tokens)
+}
+
+// protoTypeName returns the last component of a (possibly qualified) type name.
+func protoTypeName(t string) string {
+	return t[strings.LastIndex(t, ".")+1:]
+}
+
+func parseProto(content string) protoFile {
+	f := protoFile{units: make(map[string]protoUnit), rpcs: make(map[string]protoUnit), services: make(map[string]string)}
+	tokens := protoTokens(content)
+	var rest []string
+	for i := 0; i < len(tokens); {
+		tok := tokens[i]
+		switch {
+		case (tok == "message" || tok == "enum") && i+2 < len(tokens) && tokens[i+2] == "{":
+			end := matchBrace(tokens, i+2)
+			var refs []string
+			for _, t := range tokens[i+3 : end] {
+				refs = append(refs, protoTypeName(t))
+			}
+			f.units[tokens[i+1]] = protoUnit{text: strings.Join(tokens[i:end], " "), refs: refs}
+			i = end
+		case tok == "service" && i+2 < len(tokens) && tokens[i+2] == "{":
+			end := matchBrace(tokens, i+2)
+			parseProtoService(&f, tokens[i+1], tokens[i+3:max(end-1, i+3)])
+			i = end
+		case tok == "import":
+			for i < len(tokens) && tokens[i] != ";" {
+				i++
+			}
+			i++
+		default:
+			if tok == "package" && i+1 < len(tokens) {
+				f.pkg = tokens[i+1]
+			}
+			rest = append(rest, tok)
+			i++
+		}
+	}
+	f.rest = strings.Join(rest, " ")
+	return f
+}
+
+func parseProtoService(f *protoFile, service string, body []string) {
+	var rest []string
+	for i := 0; i < len(body); {
+		if body[i] != "rpc" || i+1 >= len(body) {
+			rest = append(rest, body[i])
+			i++
+			continue
+		}
+		end := i + 1
+		for end < len(body) && body[end] != ";" && body[end] != "{" {
+			end++
+		}
+		signature := body[i:min(end, len(body))]
+		if end < len(body) && body[end] == "{" {
+			end = matchBrace(body, end) // rpc options block
+		} else {
+			end++
+		}
+		var refs []string
+		for k, t := range signature {
+			if t != "(" || k+1 >= len(signature) {
+				continue
+			}
+			typ := signature[k+1]
+			if typ == "stream" && k+2 < len(signature) {
+				typ = signature[k+2]
+			}
+			refs = append(refs, protoTypeName(typ))
+		}
+		f.rpcs[service+"/"+body[i+1]] = protoUnit{text: strings.Join(body[i:min(end, len(body))], " "), refs: refs}
+		i = end
+	}
+	f.services[service] = strings.Join(rest, " ")
+}
+
+func diffProto(oldFile, newFile protoFile, wholeFile bool) (specDiff, bool) {
+	var diff specDiff
+	if !wholeFile && oldFile.rest != newFile.rest {
+		log.Debugf("  proto: file-level declarations changed")
+		return diff, false
+	}
+
+	changed := make(map[string]bool)
+	refs := make(map[string]map[string]bool)
+	for _, name := range unionKeys(oldFile.units, newFile.units) {
+		oldUnit, newUnit := oldFile.units[name], newFile.units[name]
+		if oldUnit.text != newUnit.text {
+			changed[name] = true
+		}
+		refs[name] = make(map[string]bool)
+		for _, r := range append(oldUnit.refs, newUnit.refs...) {
+			if r != name {
+				refs[name][r] = true
+			}
+		}
+	}
+	tainted := taintedUnits(changed, refs)
+	diff.types = unionKeys(tainted, nil)
+
+	pkg := newFile.pkg
+	if pkg == "" {
+		pkg = oldFile.pkg
+	}
+	for _, key := range unionKeys(oldFile.rpcs, newFile.rpcs) {
+		oldRPC, newRPC := oldFile.rpcs[key], newFile.rpcs[key]
+		service, method, _ := strings.Cut(key, "/")
+		affected := oldRPC.text != newRPC.text || oldFile.services[service] != newFile.services[service]
+		for _, r := range append(oldRPC.refs, newRPC.refs...) {
+			if tainted[r] {
+				affected = true
+			}
+		}
+		if !affected {
+			continue
+		}
+		diff.operations = append(diff.operations, method)
+		if pkg != "" {
+			key = pkg + "." + key
+		}
+		diff.endpoints = append(diff.endpoints, key)
+	}
+	return diff, true
+}
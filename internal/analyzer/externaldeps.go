@@ -0,0 +1,251 @@
+package analyzer
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"goodchanges/internal/lockfile"
+	"goodchanges/internal/log"
+	"goodchanges/internal/registry"
+	"goodchanges/internal/tsparse"
+)
+
+// DropTypeOnlyDeps removes from depChangedDeps the version bumps that can't change a
+// project's runtime behavior, classified by the npm registry metadata of the old and
+// new version:
+//   - types-only packages (@types/*, declaration-only entries), for every project,
+//     unless the project value-imports the typed package and the installed version
+//     declares a const enum, whose members are inlined into the importer's output;
+//   - packages declaring "sideEffects": false, for projects that import them only
+//     with type-only imports (`import type`, or all specifiers `type`), which are
+//     erased from the emitted JavaScript.
+//
+// Both versions must qualify. Dependencies whose metadata can't be loaded are kept
+// and reported in warnings. Call it only when type changes don't select targets
+// (INCLUDE_TYPES unset).
+func DropTypeOnlyDeps(versionChanges map[string]map[string]lockfile.VersionChange, depChangedDeps map[string]map[string]bool) (dropped []string, warnings []string) {
+	type class struct {
+		format         string // "" when the two versions are classified differently
+		sideEffectFree bool   // both versions declare "sideEffects": false
+		ok             bool
+	}
+	classes := make(map[string]class) // "dep@old→new" → classification
+	classify := func(dep string, vc lockfile.VersionChange) class {
+		key := dep + "@" + vc.Old + "→" + vc.New
+		if c, ok := classes[key]; ok {
+			return c
+		}
+		var metas [2]*registry.VersionMetadata
+		for i, version := range []string{vc.Old, vc.New} {
+			meta, err := registry.Version(dep, version)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("registry metadata of %s@%s: %v (treating the bump as a runtime change)", dep, version, err))
+				classes[key] = class{}
+				return class{}
+			}
+			metas[i] = meta
+		}
+		c := class{
+			sideEffectFree: metas[0].SideEffectFree() && metas[1].SideEffectFree(),
+			ok:             true,
+		}
+		if metas[0].Format() == metas[1].Format() {
+			c.format = metas[1].Format()
+		}
+		log.Debugf("registry: %s %s → %s: format %q, side-effect free: %v", dep, vc.Old, vc.New, c.format, c.sideEffectFree)
+		classes[key] = c
+		return c
+	}
+
+	seen := make(map[string]bool)
+	for folder, deps := range depChangedDeps {
+		if deps["*"] {
+			continue // lockfileVersion changed: nothing can be narrowed
+		}
+		var typeImports map[string]bool
+		scanned := false
+		for dep := range deps {
+			vc, ok := versionChanges[folder][dep]
+			if !ok {
+				continue // only transitive dependencies changed
+			}
+			c := classify(dep, vc)
+			if !c.ok {
+				continue
+			}
+			drop := c.format == registry.FormatTypes
+			if drop || c.sideEffectFree {
+				if !scanned {
+					typeImports, scanned = typeOnlyImportedPackages(folder), true
+				}
+			}
+			if drop {
+				// Members of a const enum are inlined by value imports at compile time, so
+				// declarations alone can change the emitted JavaScript.
+				runtimePkg := typesPackageTarget(dep)
+				if typeOnly, imported := typeImports[runtimePkg]; imported && !typeOnly && declaresConstEnum(filepath.Join(folder, "node_modules", dep)) {
+					log.Debugf("registry: %s bump of %s declares const enums imported as values — tainted", folder, dep)
+					drop = false
+				}
+			} else if c.sideEffectFree {
+				drop = typeImports[dep]
+			}
+			if !drop {
+				continue
+			}
+			log.Debugf("registry: %s bump of %s doesn't affect runtime — not tainted", folder, dep)
+			delete(deps, dep)
+			if !seen[dep] {
+				seen[dep] = true
+				dropped = append(dropped, dep)
+			}
+		}
+		if len(deps) == 0 {
+			delete(depChangedDeps, folder)
+		}
+	}
+	return dropped, warnings
+}
+
+// typeOnlyImportedPackages returns the external packages the project imports or
+// re-exports, mapped to whether every such import is type-only.
+func typeOnlyImportedPackages(projectFolder string) map[string]bool {
+	result := make(map[string]bool)
+	files, err := globSourceFiles(projectFolder)
+	if err != nil {
+		return nil
+	}
+	record := func(source string, typeOnly bool) {
+		if source == "" || strings.HasPrefix(source, ".") || strings.HasPrefix(source, "/") {
+			return
+		}
+		pkg := PackageOfSpecifier(source)
+		prev, seen := result[pkg]
+		result[pkg] = typeOnly && (!seen || prev)
+	}
+	for _, relPath := range files {
+		analysis, err := tsparse.ParseFile(filepath.Join(projectFolder, relPath))
+		if err != nil {
+			return nil // unknown imports may use any package at runtime
+		}
+		for _, imp := range analysis.Imports {
+			record(imp.Source, imp.IsTypeOnly)
+		}
+		for _, exp := range analysis.Exports {
+			record(exp.Source, exp.IsTypeOnly)
+		}
+	}
+	return result
+}
+
+// typesPackageTarget returns the package a DefinitelyTyped package declares types
+// for ("@types/react" → "react", "@types/babel__core" → "@babel/core"); other packages
+// declare their own types.
+func typesPackageTarget(dep string) string {
+	name, ok := strings.CutPrefix(dep, "@types/")
+	if !ok {
+		return dep
+	}
+	if scope, pkg, scoped := strings.Cut(name, "__"); scoped {
+		return "@" + scope + "/" + pkg
+	}
+	return name
+}
+
+var constEnumRe = regexp.MustCompile(`\bconst\s+enum\s`)
+
+// constEnumPackages caches declaresConstEnum by resolved package directory.
+var constEnumPackages = struct {
+	sync.Mutex
+	byDir map[string]bool
+}{byDir: make(map[string]bool)}
+
+// declaresConstEnum reports whether the installed package in dir has a declaration
+// file declaring a const enum. A package that isn't installed is assumed not to.
+func declaresConstEnum(dir string) bool {
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+	constEnumPackages.Lock()
+	defer constEnumPackages.Unlock()
+	if found, ok := constEnumPackages.byDir[real]; ok {
+		return found
+	}
+	found := false
+	filepath.WalkDir(real, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || found {
+			return filepath.SkipAll
+		}
+		if d.IsDir() {
+			if d.Name() == "node_modules" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		name := d.Name()
+		if !strings.HasSuffix(name, ".d.ts") && !strings.HasSuffix(name, ".d.mts") && !strings.HasSuffix(name, ".d.cts") {
+			return nil
+		}
+		if info, err := d.Info(); err != nil || (MaxFileSize > 0 && info.Size() > MaxFileSize) {
+			return nil
+		}
+		if content, err := os.ReadFile(path); err == nil && constEnumRe.Match(content) {
+			found = true
+		}
+		return nil
+	})
+	constEnumPackages.byDir[real] = found
+	return found
+}
+
+// PackageOfSpecifier returns the package name of a bare import specifier:
+// "@scope/name/sub" → "@scope/name", "name/sub" → "name".
+func PackageOfSpecifier(specifier string) string {
+	parts := strings.SplitN(specifier, "/", 3)
+	if strings.HasPrefix(specifier, "@") && len(parts) >= 2 {
+		return parts[0] + "/" + parts[1]
+	}
+	return parts[0]
+}
+
+// SideEffectFreeDeps returns the bumped external dependencies whose old and new
+// versions both declare "sideEffects": false in every project bumping them, for
+// RespectSideEffects. Dependencies whose metadata can't be loaded are left out and
+// reported in warnings.
+func SideEffectFreeDeps(versionChanges map[string]map[string]lockfile.VersionChange, depChangedDeps map[string]map[string]bool) (free map[string]bool, warnings []string) {
+	free = make(map[string]bool)
+	notFree := make(map[string]bool)
+	for folder, deps := range depChangedDeps {
+		for dep := range deps {
+			vc, ok := versionChanges[folder][dep]
+			if !ok || notFree[dep] {
+				continue
+			}
+			for _, version := range []string{vc.Old, vc.New} {
+				meta, err := registry.Version(dep, version)
+				if err != nil {
+					warnings = append(warnings, fmt.Sprintf("registry metadata of %s@%s: %v (bare imports of it keep tainting)", dep, version, err))
+					notFree[dep] = true
+					break
+				}
+				if !meta.SideEffectFree() {
+					notFree[dep] = true
+					break
+				}
+			}
+			if !notFree[dep] {
+				free[dep] = true
+			}
+		}
+	}
+	for dep := range notFree {
+		delete(free, dep)
+	}
+	return free, warnings
+}
@@ -0,0 +1,148 @@
+package analyzer
+
+import (
+	"context"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+
+	"goodchanges/internal/git"
+	"goodchanges/internal/log"
+	"goodchanges/internal/rush"
+)
+
+// GeneratedMarker in the header of a file marks it as generated code.
+const GeneratedMarker = "@generated"
+
+// generatedMarkerWindow is how many leading bytes are searched for GeneratedMarker.
+const generatedMarkerWindow = 2048
+
+var (
+	// Volatile tokens that code generators embed in header comments.
+	volatileTimestampRe = regexp.MustCompile(`\d{4}-\d{2}-\d{2}([T ]\d{2}:\d{2}(:\d{2}(\.\d+)?)?(Z|[+-]\d{2}:?\d{2})?)?`)
+	volatileHashRe      = regexp.MustCompile(`\b[0-9a-fA-F]{16,}\b`)
+)
+
+// IsGeneratedFile reports whether a file (path relative to the project root) is
+// generated code: it matches the project's generated.globs or its header contains
+// the @generated marker.
+func IsGeneratedFile(relPath string, content string, cfg *rush.ProjectConfig) bool {
+	if cfg != nil && cfg.Generated != nil {
+		for _, pattern := range cfg.Generated.Globs {
+			if matched, _ := doublestar.Match(pattern, relPath); matched {
+				return true
+			}
+		}
+	}
+	if len(content) > generatedMarkerWindow {
+		content = content[:generatedMarkerWindow]
+	}
+	return strings.Contains(content, GeneratedMarker)
+}
+
+// NormalizeGeneratedContent replaces timestamps and hashes in comments with
+// placeholders, so two generator runs over the same input compare equal.
+// Only comment trivia is normalized: lines starting with "//" and text inside
+// "/* ... */" blocks. Code is left untouched — a hash in a string literal or a
+// private field initializer may be meaningful.
+func NormalizeGeneratedContent(content string) string {
+	lines := strings.Split(content, "\n")
+	inBlock := false
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		searchFrom := 0
+		if !inBlock {
+			if strings.HasPrefix(trimmed, "//") {
+				lines[i] = normalizeVolatileTokens(line)
+				continue
+			}
+			if !strings.HasPrefix(trimmed, "/*") {
+				continue
+			}
+			inBlock = true
+			searchFrom = strings.Index(line, "/*") + 2
+		}
+		// Inside a block comment: normalize up to its end, keep any code after it.
+		end := strings.Index(line[searchFrom:], "*/")
+		if end < 0 {
+			lines[i] = normalizeVolatileTokens(line)
+			continue
+		}
+		end += searchFrom + 2
+		lines[i] = normalizeVolatileTokens(line[:end]) + line[end:]
+		inBlock = false
+	}
+	return strings.Join(lines, "\n")
+}
+
+func normalizeVolatileTokens(s string) string {
+	s = volatileTimestampRe.ReplaceAllString(s, "<timestamp>")
+	return volatileHashRe.ReplaceAllString(s, "<hash>")
+}
+
+// FilterRegenerationOnlyChanges drops changed generated files whose content differs
+// from the merge base only in volatile header tokens (see NormalizeGeneratedContent),
+// so a commit that merely re-runs a code generator taints nothing.
+// Added and deleted files are always kept.
+func FilterRegenerationOnlyChanges(ctx context.Context, changedFiles []string, mergeBase string, rushConfig *rush.Config, configMap map[string]*rush.ProjectConfig) []string {
+	kept := make([]string, 0, len(changedFiles))
+	for _, f := range changedFiles {
+		if isRegenerationOnlyChange(ctx, f, mergeBase, rushConfig, configMap) {
+			log.Basicf("Ignoring regeneration-only change to generated file %s", f)
+			continue
+		}
+		kept = append(kept, f)
+	}
+	return kept
+}
+
+func isRegenerationOnlyChange(ctx context.Context, file string, mergeBase string, rushConfig *rush.Config, configMap map[string]*rush.ProjectConfig) bool {
+	newData, err := os.ReadFile(file)
+	if err != nil {
+		return false
+	}
+	newContent := string(newData)
+	relPath := file
+	var cfg *rush.ProjectConfig
+	if rp := rushConfig.ProjectForFile(file); rp != nil {
+		relPath = strings.TrimPrefix(file, rp.ProjectFolder+"/")
+		cfg = configMap[rp.ProjectFolder]
+	}
+	if !IsGeneratedFile(relPath, newContent, cfg) {
+		return false
+	}
+	oldContent, err := git.ShowFile(ctx, mergeBase, BasePath(file))
+	if err != nil || oldContent == "" {
+		return false
+	}
+	return NormalizeGeneratedContent(oldContent) == NormalizeGeneratedContent(newContent)
+}
+
+// GeneratedPackageTriggered reports whether the project uses the "package" generated
+// policy and any of its generated files changed. Such changes taint the whole package
+// instead of going through per-symbol AST analysis.
+func GeneratedPackageTriggered(changedFiles []string, projectFolder string, cfg *rush.ProjectConfig) bool {
+	if cfg == nil || cfg.Generated == nil || cfg.Generated.Policy == nil || *cfg.Generated.Policy != "package" {
+		return false
+	}
+	for _, f := range changedFiles {
+		if !strings.HasPrefix(f, projectFolder+"/") {
+			continue
+		}
+		relPath := strings.TrimPrefix(f, projectFolder+"/")
+		if cfg.IsIgnored(relPath) {
+			continue
+		}
+		content, err := os.ReadFile(f)
+		if err != nil {
+			// Deleted: fall back to the glob check only.
+			content = nil
+		}
+		if IsGeneratedFile(relPath, string(content), cfg) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package analyzer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"goodchanges/internal/git"
+	"goodchanges/internal/rush"
+)
+
+func TestNormalizeGeneratedContentHeaderOnly(t *testing.T) {
+	old := "// @generated by openapi-generator at 2024-01-01T10:00:00Z\n" +
+		"/*\n * spec hash: 0123456789abcdef0123\n */\n" +
+		"export const x = 1;\n"
+	regenerated := "// @generated by openapi-generator at 2024-03-05T08:30:12Z\n" +
+		"/*\n * spec hash: fedcba9876543210fedc\n */\n" +
+		"export const x = 1;\n"
+	if NormalizeGeneratedContent(old) != NormalizeGeneratedContent(regenerated) {
+		t.Errorf("header-only regeneration should normalize equal:\n%s\n---\n%s",
+			NormalizeGeneratedContent(old), NormalizeGeneratedContent(regenerated))
+	}
+}
+
+func TestNormalizeGeneratedContentKeepsCode(t *testing.T) {
+	cases := []struct{ name, old, new string }{
+		{
+			name: "private field",
+			old:  "class A {\n    #createdAt = \"2024-01-01T10:00:00Z\";\n}\n",
+			new:  "class A {\n    #createdAt = \"2025-06-01T10:00:00Z\";\n}\n",
+		},
+		{
+			name: "multiplication continuation",
+			old:  "const n = a\n    * 0x0123456789abcdef0123;\n",
+			new:  "const n = a\n    * 0x0123456789abcdef9999;\n",
+		},
+		{
+			name: "code after block comment",
+			old:  "/* v1 */ export const id = \"0123456789abcdef0123\";\n",
+			new:  "/* v1 */ export const id = \"fedcba9876543210fedc\";\n",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if NormalizeGeneratedContent(tc.old) == NormalizeGeneratedContent(tc.new) {
+				t.Errorf("code change was normalized away:\n%s", NormalizeGeneratedContent(tc.new))
+			}
+		})
+	}
+}
+
+func TestRegenerationOnlyChangeNestedProject(t *testing.T) {
+	root := t.TempDir()
+	base := t.TempDir()
+	t.Chdir(root)
+	git.FixtureDir = base
+	t.Cleanup(func() { git.FixtureDir = "" })
+
+	const file = "libs/api/client/src/gen.ts"
+	writeFile(t, filepath.Join(base, file), "// built 2024-01-01\nexport const x = 1;\n")
+	writeFile(t, filepath.Join(root, file), "// built 2024-02-02\nexport const x = 1;\n")
+
+	rushConfig := &rush.Config{Projects: []rush.Project{
+		{PackageName: "api", ProjectFolder: "libs/api"},
+		{PackageName: "client", ProjectFolder: "libs/api/client"},
+	}}
+	// Only the nested project declares src/gen.ts as generated; the outer
+	// project's config must not be consulted.
+	configMap := map[string]*rush.ProjectConfig{
+		"libs/api":        {},
+		"libs/api/client": {Generated: &rush.GeneratedConfig{Globs: []string{"src/gen.ts"}}},
+	}
+	if !isRegenerationOnlyChange(context.Background(), file, "base", rushConfig, configMap) {
+		t.Errorf("expected %s to be a regeneration-only change in the nested project", file)
+	}
+
+	configMap = map[string]*rush.ProjectConfig{
+		"libs/api":        {Generated: &rush.GeneratedConfig{Globs: []string{"client/src/gen.ts"}}},
+		"libs/api/client": {},
+	}
+	if isRegenerationOnlyChange(context.Background(), file, "base", rushConfig, configMap) {
+		t.Errorf("outer project's generated globs must not apply to %s", file)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,382 @@
+package analyzer
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"goodchanges/internal/git"
+	"goodchanges/internal/log"
+	"goodchanges/internal/rush"
+	"goodchanges/internal/tsparse"
+)
+
+// IncludeGraphQL enables GraphQL document taint tracking when set to true (via INCLUDE_GRAPHQL).
+var IncludeGraphQL bool
+
+// GraphQLTaintPrefix is the prefix used for GraphQL taint entries in the upstream taint map.
+// The value set holds the names of changed operations and fragments defined in the package,
+// or "*" when a changed document defines no named operation (e.g. a schema file).
+const GraphQLTaintPrefix = "__graphql__:"
+
+var (
+	graphqlDefinitionRe = regexp.MustCompile(`\b(?:query|mutation|subscription|fragment)\s+([A-Za-z_][A-Za-z0-9_]*)`)
+	graphqlSpreadRe     = regexp.MustCompile(`\.\.\.\s*([A-Za-z_][A-Za-z0-9_]*)`)
+	graphqlImportRe     = regexp.MustCompile(`(?m)^\s*#import\s+["']([^"']+)["']`)
+	gqlTemplateRe       = regexp.MustCompile("\\b(?:gql|graphql)\\s*(?:<[^>]*>)?\\s*`([^`]*)`")
+)
+
+// isGraphQLFile returns true for .graphql/.gql documents (also used for import sources).
+func isGraphQLFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".graphql" || ext == ".gql"
+}
+
+// graphqlDefinitions splits a GraphQL document into its named operations and fragments,
+// keyed by name, with whitespace-normalized definition text as the value.
+func graphqlDefinitions(doc string) map[string]string {
+	defs := make(map[string]string)
+	matches := graphqlDefinitionRe.FindAllStringSubmatchIndex(doc, -1)
+	for i, m := range matches {
+		end := len(doc)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		defs[doc[m[2]:m[3]]] = normalizeWhitespace(doc[m[0]:end])
+	}
+	return defs
+}
+
+// graphqlSpreads returns the fragment names spread (`...Name`) in a GraphQL document.
+// Inline fragments (`... on Type`) are skipped.
+func graphqlSpreads(doc string) []string {
+	var names []string
+	for _, m := range graphqlSpreadRe.FindAllStringSubmatch(doc, -1) {
+		if m[1] != "on" {
+			names = append(names, m[1])
+		}
+	}
+	return names
+}
+
+// inlineGraphQL returns the concatenated bodies of gql/graphql tagged template literals.
+func inlineGraphQL(source string) string {
+	var b strings.Builder
+	for _, m := range gqlTemplateRe.FindAllStringSubmatch(source, -1) {
+		b.WriteString(m[1])
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// graphqlDocument returns the GraphQL content of a file: the whole file for
+// .graphql/.gql documents, the inline gql literals for TS/JS sources.
+func graphqlDocument(path string, content string) string {
+	if isGraphQLFile(path) {
+		return content
+	}
+	return inlineGraphQL(content)
+}
+
+// changedGraphQLDefinitions compares two versions of a GraphQL document and returns
+// the names of operations/fragments that were added, removed or modified.
+// A changed document without named definitions yields "*".
+func changedGraphQLDefinitions(oldDoc, newDoc string) []string {
+	if oldDoc == newDoc {
+		return nil
+	}
+	oldDefs := graphqlDefinitions(oldDoc)
+	newDefs := graphqlDefinitions(newDoc)
+	var names []string
+	for name, text := range newDefs {
+		if oldDefs[name] != text {
+			names = append(names, name)
+		}
+	}
+	for name := range oldDefs {
+		if _, ok := newDefs[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 && len(oldDefs) == 0 && len(newDefs) == 0 && normalizeWhitespace(oldDoc) != normalizeWhitespace(newDoc) {
+		names = append(names, "*")
+	}
+	return names
+}
+
+// FindGraphQLTaintedPackages diffs the GraphQL content of changed files (.graphql/.gql
+// documents and inline gql literals in TS/JS sources) against the merge base and
+// returns the changed definition names per package.
+//
+// TS/JS sources are only diffed when their new content mentions gql or graphql, so
+// most changed sources cost no git call. A source whose literals were all removed
+// is skipped; its changed symbols are still caught by the TS diff.
+func FindGraphQLTaintedPackages(ctx context.Context, changedFiles []string, mergeBase string, rushConfig *rush.Config) map[string]map[string]bool {
+	result := make(map[string]map[string]bool)
+	for _, f := range changedFiles {
+		if !isGraphQLFile(f) && !isSourceFile(f) {
+			continue
+		}
+		rp := rushConfig.ProjectForFile(f)
+		if rp == nil {
+			continue
+		}
+		newData, _ := os.ReadFile(f)
+		if !isGraphQLFile(f) && !bytes.Contains(newData, []byte("gql")) && !bytes.Contains(newData, []byte("graphql")) {
+			continue
+		}
+		oldContent, _ := git.ShowFile(ctx, mergeBase, BasePath(f))
+		names := changedGraphQLDefinitions(graphqlDocument(f, oldContent), graphqlDocument(f, string(newData)))
+		if len(names) == 0 {
+			continue
+		}
+		if result[rp.PackageName] == nil {
+			result[rp.PackageName] = make(map[string]bool)
+		}
+		for _, name := range names {
+			result[rp.PackageName][name] = true
+		}
+		log.Debugf("FindGraphQLTaintedPackages: %s tainted via %s (%v)", rp.PackageName, f, names)
+	}
+	log.Debugf("FindGraphQLTaintedPackages: %d packages tainted", len(result))
+	return result
+}
+
+// PropagateGraphQLTaint extends GraphQL taint through fragment spreads: a definition
+// (in a .graphql document or an inline gql literal) that spreads a tainted fragment of
+// its own package or of a workspace dependency becomes tainted too. Repeats until stable.
+func PropagateGraphQLTaint(rushConfig *rush.Config, projectMap map[string]*rush.ProjectInfo, upstreamTaint map[string]map[string]bool) {
+	hasTaint := false
+	for key := range upstreamTaint {
+		if strings.HasPrefix(key, GraphQLTaintPrefix) {
+			hasTaint = true
+			break
+		}
+	}
+	if !hasTaint {
+		return
+	}
+
+	// Definition name → spread fragment names, per package (scanned once).
+	pkgSpreads := make(map[string]map[string][]string)
+	for _, rp := range rushConfig.Projects {
+		spreads := make(map[string][]string)
+		for _, f := range globGraphQLSources(rp.ProjectFolder) {
+			content, err := os.ReadFile(filepath.Join(rp.ProjectFolder, f))
+			if err != nil {
+				continue
+			}
+			for name, text := range graphqlDefinitions(graphqlDocument(f, string(content))) {
+				spreads[name] = append(spreads[name], graphqlSpreads(text)...)
+			}
+		}
+		if len(spreads) > 0 {
+			pkgSpreads[rp.PackageName] = spreads
+		}
+	}
+
+	changed := true
+	for changed {
+		changed = false
+		for pkgName, spreads := range pkgSpreads {
+			visible := []string{pkgName}
+			if info := projectMap[pkgName]; info != nil {
+				visible = append(visible, info.DependsOn...)
+			}
+			key := GraphQLTaintPrefix + pkgName
+			for name, spreadNames := range spreads {
+				if upstreamTaint[key][name] {
+					continue
+				}
+				for _, spread := range spreadNames {
+					if !graphqlNameTainted(spread, visible, upstreamTaint) {
+						continue
+					}
+					if upstreamTaint[key] == nil {
+						upstreamTaint[key] = make(map[string]bool)
+					}
+					upstreamTaint[key][name] = true
+					changed = true
+					log.Debugf("GraphQL taint propagated: %s#%s (spreads %s)", pkgName, name, spread)
+					break
+				}
+			}
+		}
+	}
+}
+
+// graphqlNameTainted reports whether a definition name is tainted in any of the packages.
+func graphqlNameTainted(name string, pkgNames []string, upstreamTaint map[string]map[string]bool) bool {
+	for _, pkg := range pkgNames {
+		if upstreamTaint[GraphQLTaintPrefix+pkg][name] {
+			return true
+		}
+	}
+	return false
+}
+
+// taintedGraphQLNames collects all tainted GraphQL definition names in the taint map.
+func taintedGraphQLNames(upstreamTaint map[string]map[string]bool) map[string]bool {
+	names := make(map[string]bool)
+	for key, set := range upstreamTaint {
+		if !strings.HasPrefix(key, GraphQLTaintPrefix) {
+			continue
+		}
+		for name := range set {
+			names[name] = true
+		}
+	}
+	return names
+}
+
+// matchesGraphQLTaint checks if a non-relative .graphql import comes from a package
+// with GraphQL taint.
+func matchesGraphQLTaint(importSource string, upstreamTaint map[string]map[string]bool) bool {
+	if !isGraphQLFile(importSource) {
+		return false
+	}
+	for key, set := range upstreamTaint {
+		if !strings.HasPrefix(key, GraphQLTaintPrefix) || len(set) == 0 {
+			continue
+		}
+		pkgName := strings.TrimPrefix(key, GraphQLTaintPrefix)
+		if strings.HasPrefix(importSource, pkgName+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// seedGraphQLTaint taints TS symbols that depend on changed GraphQL documents:
+//   - files importing a tainted .graphql document (changed, defining a tainted
+//     definition, or #import-ing a tainted document) — usage-tainted for named
+//     imports, all symbols for unassigned imports
+//   - files importing a .graphql document from a GraphQL-tainted package
+//   - symbols whose inline gql literals spread a tainted fragment
+func seedGraphQLTaint(projectFolder string, projectChangedFiles []string, fileAnalyses map[string]*tsparse.FileAnalysis, upstreamTaint map[string]map[string]bool, tainted map[string]map[string]bool) {
+	taintedNames := taintedGraphQLNames(upstreamTaint)
+
+	// Tainted documents within this package.
+	taintedDocs := make(map[string]bool)
+	for _, f := range projectChangedFiles {
+		if isGraphQLFile(f) {
+			taintedDocs[strings.TrimPrefix(f, projectFolder+"/")] = true
+		}
+	}
+	docImports := make(map[string][]string)
+	for _, doc := range globGraphQLSources(projectFolder) {
+		if !isGraphQLFile(doc) {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(projectFolder, doc))
+		if err != nil {
+			continue
+		}
+		for _, m := range graphqlImportRe.FindAllStringSubmatch(string(content), -1) {
+			docImports[doc] = append(docImports[doc], filepath.Clean(filepath.Join(filepath.Dir(doc), m[1])))
+		}
+		for name := range graphqlDefinitions(string(content)) {
+			if taintedNames[name] {
+				taintedDocs[doc] = true
+			}
+		}
+	}
+	for changed := true; changed; {
+		changed = false
+		for doc, imports := range docImports {
+			if taintedDocs[doc] {
+				continue
+			}
+			for _, imp := range imports {
+				if taintedDocs[imp] {
+					taintedDocs[doc] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	for _, stem := range mapKeys(fileAnalyses) {
+		analysis := fileAnalyses[stem]
+		taint := func(symbols []string) {
+			if len(symbols) == 0 {
+				return
+			}
+			if tainted[stem] == nil {
+				tainted[stem] = make(map[string]bool)
+			}
+			for _, s := range symbols {
+				tainted[stem][s] = true
+			}
+		}
+		allSymbols := func() []string {
+			names := make([]string, 0, len(analysis.Symbols))
+			for _, sym := range analysis.Symbols {
+				names = append(names, sym.Name)
+			}
+			return names
+		}
+
+		for _, imp := range analysis.Imports {
+			if !isGraphQLFile(imp.Source) {
+				continue
+			}
+			if strings.HasPrefix(imp.Source, ".") {
+				resolved := filepath.Clean(filepath.Join(filepath.Dir(stem+".ts"), imp.Source))
+				if !taintedDocs[resolved] {
+					continue
+				}
+			} else if !matchesGraphQLTaint(imp.Source, upstreamTaint) {
+				continue
+			}
+			if len(imp.Names) > 0 {
+				taint(findTaintedSymbolsByUsage(analysis, importLocalNames(imp)))
+				log.Debugf("    %s: usage-tainted via GraphQL import %s (names: %v)", stem, imp.Source, imp.Names)
+			} else {
+				taint(allSymbols())
+				log.Debugf("    %s: all symbols tainted via GraphQL import %s", stem, imp.Source)
+			}
+		}
+
+		if len(taintedNames) == 0 || analysis.SourceFile == nil {
+			continue
+		}
+		sourceText := analysis.SourceFile.Text()
+		lineMap := analysis.SourceFile.ECMALineMap()
+		for _, sym := range analysis.Symbols {
+			body := inlineGraphQL(tsparse.ExtractTextForLines(sourceText, lineMap, sym.StartLine, sym.EndLine))
+			for _, spread := range graphqlSpreads(body) {
+				if taintedNames[spread] {
+					taint([]string{sym.Name})
+					log.Debugf("    %s: %s tainted via inline gql spread of %s", stem, sym.Name, spread)
+					break
+				}
+			}
+		}
+	}
+}
+
+// globGraphQLSources returns .graphql/.gql documents and TS/JS sources containing
+// inline gql literals, relative to projectFolder.
+func globGraphQLSources(projectFolder string) []string {
+	var files []string
+	walkProjectFiles(projectFolder, func(path, rel string, _ fs.DirEntry) {
+		if isGraphQLFile(path) {
+			files = append(files, rel)
+			return
+		}
+		if !isSourceFile(path) {
+			return
+		}
+		content, err := os.ReadFile(path)
+		if err == nil && gqlTemplateRe.Match(content) {
+			files = append(files, rel)
+		}
+	})
+	return files
+}
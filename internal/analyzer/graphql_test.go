@@ -0,0 +1,37 @@
+package analyzer
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"goodchanges/internal/git"
+	"goodchanges/internal/rush"
+)
+
+func TestFindGraphQLTaintedPackages(t *testing.T) {
+	root := t.TempDir()
+	base := t.TempDir()
+	t.Chdir(root)
+	git.FixtureDir = base
+	t.Cleanup(func() { git.FixtureDir = "" })
+
+	const query = "libs/app/widgets/src/query.ts"
+	writeFile(t, filepath.Join(base, query), "export const Q = gql`query Widgets { widgets { id } }`;\n")
+	writeFile(t, filepath.Join(root, query), "export const Q = gql`query Widgets { widgets { id name } }`;\n")
+	// No gql literal at HEAD: skipped without reading the merge base version.
+	const plain = "libs/app/src/plain.ts"
+	writeFile(t, filepath.Join(base, plain), "export const P = gql`query Plain { p }`;\n")
+	writeFile(t, filepath.Join(root, plain), "export const P = 1;\n")
+
+	rushConfig := &rush.Config{Projects: []rush.Project{
+		{PackageName: "app", ProjectFolder: "libs/app"},
+		{PackageName: "widgets", ProjectFolder: "libs/app/widgets"},
+	}}
+	got := FindGraphQLTaintedPackages(context.Background(), []string{query, plain}, "base", rushConfig)
+	want := map[string]map[string]bool{"widgets": {"Widgets": true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
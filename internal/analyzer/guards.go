@@ -0,0 +1,87 @@
+package analyzer
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"goodchanges/internal/log"
+	"goodchanges/internal/metrics"
+)
+
+// MaxFileSize (MAX_FILE_SIZE) is the size in bytes above which a source file is not
+// parsed. Such files are usually bundled vendor blobs or huge generated modules; a
+// changed one taints everything importing it. 0 disables the guard.
+var MaxFileSize int64 = 5 << 20
+
+// MaxPackageFiles (MAX_PACKAGE_FILES) is the number of source files above which a
+// library is not analyzed file by file; all its exports are tainted instead.
+// 0 disables the guard.
+var MaxPackageFiles = 20000
+
+// SkippedFile is a source file (or, with File ".", a whole package) a guard excluded
+// from parsing.
+type SkippedFile struct {
+	File   string // relative to the project folder
+	Reason string
+}
+
+// skippedFiles collects guard skips per project folder. Analysis runs concurrently,
+// hence the mutex.
+var skippedFiles = struct {
+	sync.Mutex
+	byProject map[string]map[string]string // project folder → relPath → reason
+}{byProject: make(map[string]map[string]string)}
+
+// recordSkippedFile remembers a file skipped by a guard. Each file is recorded once,
+// however many times it is globbed.
+func recordSkippedFile(projectFolder string, relPath string, reason string) {
+	skippedFiles.Lock()
+	defer skippedFiles.Unlock()
+	files := skippedFiles.byProject[projectFolder]
+	if files == nil {
+		files = make(map[string]string)
+		skippedFiles.byProject[projectFolder] = files
+	}
+	if _, seen := files[relPath]; seen {
+		return
+	}
+	files[relPath] = reason
+	metrics.Add("goodchanges_skipped_files_total", 1)
+	log.Debugf("  skipped %s/%s: %s", projectFolder, relPath, reason)
+}
+
+// oversized reports whether a file of the given size exceeds MaxFileSize, recording
+// the skip.
+func oversized(projectFolder string, relPath string, size int64) bool {
+	if MaxFileSize <= 0 || size <= MaxFileSize {
+		return false
+	}
+	recordSkippedFile(projectFolder, relPath, fmt.Sprintf("%d bytes exceed MAX_FILE_SIZE (%d)", size, MaxFileSize))
+	return true
+}
+
+// isSkippedFile reports whether a guard excluded the file from parsing.
+func isSkippedFile(projectFolder string, relPath string) bool {
+	skippedFiles.Lock()
+	defer skippedFiles.Unlock()
+	_, ok := skippedFiles.byProject[projectFolder][relPath]
+	return ok
+}
+
+// SkippedFiles returns the files skipped by guards so far, keyed by project folder
+// and sorted by file.
+func SkippedFiles() map[string][]SkippedFile {
+	skippedFiles.Lock()
+	defer skippedFiles.Unlock()
+	result := make(map[string][]SkippedFile, len(skippedFiles.byProject))
+	for folder, files := range skippedFiles.byProject {
+		for file, reason := range files {
+			result[folder] = append(result[folder], SkippedFile{File: file, Reason: reason})
+		}
+		sort.Slice(result[folder], func(i, j int) bool {
+			return result[folder][i].File < result[folder][j].File
+		})
+	}
+	return result
+}
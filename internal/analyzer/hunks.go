@@ -0,0 +1,232 @@
+package analyzer
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"goodchanges/internal/git"
+	"goodchanges/internal/log"
+	"goodchanges/internal/rush"
+)
+
+// hunkBases holds, for changed files with some ignored hunks, the merge-base content
+// with those hunks applied: diffing it against the working tree sees only the hunks
+// that count.
+var hunkBases map[string]string
+
+// hunkHeaderRe matches a unified diff hunk header, capturing the old start line.
+var hunkHeaderRe = regexp.MustCompile(`^@@ -(\d+)(?:,\d+)? \+\d+(?:,\d+)? @@`)
+
+// diffHunk is one -U0 hunk: the removed lines starting at line oldStart of the old
+// file (or inserting after it when none are removed) and the added lines.
+type diffHunk struct {
+	oldStart int
+	removed  []string
+	added    []string
+}
+
+// FilterIgnoredHunks applies the ignoreHunks patterns of the repository root and of
+// each file's project to the git diff: a hunk whose removed and added lines all match
+// a pattern is ignored. Files with only ignored hunks are dropped from the changed
+// files, so e.g. a copyright year bump taints nothing, and counted in the returned
+// number; files with some are diffed against a merge base with the ignored hunks
+// already applied. Added, deleted and binary files are always kept.
+func FilterIgnoredHunks(ctx context.Context, changedFiles []string, mergeBase string, rushConfig *rush.Config, configMap map[string]*rush.ProjectConfig, rootCfg *rush.RootConfig) ([]string, int) {
+	hunkBases = make(map[string]string)
+	var rootPatterns []string
+	if rootCfg != nil {
+		rootPatterns = rootCfg.IgnoreHunks
+	}
+	// All diffs come from one git call: a license-header PR touches thousands of files.
+	var diffs map[string]string
+	kept := make([]string, 0, len(changedFiles))
+	dropped := 0
+	for _, f := range changedFiles {
+		patterns := rootPatterns
+		if rp := rushConfig.ProjectForFile(f); rp != nil {
+			if cfg := configMap[rp.ProjectFolder]; cfg != nil {
+				patterns = append(patterns[:len(patterns):len(patterns)], cfg.IgnoreHunks...)
+			}
+		}
+		if len(patterns) == 0 {
+			kept = append(kept, f)
+			continue
+		}
+		if diffs == nil {
+			var err error
+			if diffs, err = git.DiffsSince(ctx, mergeBase); err != nil {
+				log.Basicf("Diffing for ignoreHunks failed: %v", err)
+			}
+			if diffs == nil {
+				diffs = make(map[string]string)
+			}
+		}
+		diff, ok := diffs[f]
+		if !ok {
+			var err error
+			if diff, err = git.DiffFile(ctx, mergeBase, f); err != nil {
+				log.Basicf("Diffing %s for ignoreHunks failed: %v", f, err)
+				kept = append(kept, f)
+				continue
+			}
+		}
+		res := compileHunkPatterns(patterns)
+		hunks := parseHunks(diff)
+		var ignored []diffHunk
+		for _, h := range hunks {
+			if isIgnoredHunk(h, res) {
+				ignored = append(ignored, h)
+			}
+		}
+		switch {
+		case len(ignored) == 0:
+			kept = append(kept, f)
+		case len(ignored) == len(hunks):
+			log.Basicf("Ignoring change to %s: all %d hunks match ignoreHunks", f, len(hunks))
+			dropped++
+		default:
+			oldContent, err := git.ShowFile(ctx, mergeBase, BasePath(f))
+			if err != nil {
+				kept = append(kept, f)
+				continue
+			}
+			log.Basicf("Ignoring %d of %d hunks of %s matching ignoreHunks", len(ignored), len(hunks), f)
+			hunkBases[f] = applyHunks(oldContent, ignored)
+			kept = append(kept, f)
+		}
+	}
+	return kept, dropped
+}
+
+// mergeBaseContent returns the content of a changed file at the merge base, with the
+// hunks ignored by ignoreHunks applied.
+func mergeBaseContent(ctx context.Context, mergeBase string, file string) (string, error) {
+	if content, ok := hunkBases[file]; ok {
+		return content, nil
+	}
+	return git.ShowFile(ctx, mergeBase, BasePath(file))
+}
+
+// MovedFolders maps the folder of each project moved in rush.json since the merge base
+// to its folder there, so the files of a moved project diff against their old paths.
+var MovedFolders map[string]string
+
+// BasePath returns the path a file had at the merge base: under the old folder for the
+// files of moved projects (the most nested one when moved folders nest).
+func BasePath(file string) string {
+	best := ""
+	for folder := range MovedFolders {
+		if strings.HasPrefix(file, folder+"/") && len(folder) > len(best) {
+			best = folder
+		}
+	}
+	if best == "" {
+		return file
+	}
+	return MovedFolders[best] + strings.TrimPrefix(file, best)
+}
+
+// HunkBaseDigests identifies the ignored hunks applied to the project's changed files,
+// as sorted "file:sha256" entries of the merge-base content they produce.
+func HunkBaseDigests(projectFolder string) []string {
+	var digests []string
+	for _, f := range mapKeys(hunkBases) {
+		if strings.HasPrefix(f, projectFolder+"/") {
+			sum := sha256.Sum256([]byte(hunkBases[f]))
+			digests = append(digests, f+":"+hex.EncodeToString(sum[:]))
+		}
+	}
+	return digests
+}
+
+// compiledHunkPatterns caches compiled ignoreHunks patterns.
+var compiledHunkPatterns = make(map[string]*regexp.Regexp)
+
+// compileHunkPatterns compiles ignoreHunks patterns; invalid ones are rejected by
+// config validation and skipped here.
+func compileHunkPatterns(patterns []string) []*regexp.Regexp {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, ok := compiledHunkPatterns[p]
+		if !ok {
+			re, _ = regexp.Compile(p)
+			compiledHunkPatterns[p] = re
+		}
+		if re != nil {
+			res = append(res, re)
+		}
+	}
+	return res
+}
+
+// parseHunks parses `git diff -U0` output of one file. Added, deleted and binary
+// files yield no hunks, so they are never ignored.
+func parseHunks(diff string) []diffHunk {
+	var hunks []diffHunk
+	var cur *diffHunk
+	for _, line := range strings.Split(diff, "\n") {
+		if strings.HasPrefix(line, "new file mode") || strings.HasPrefix(line, "deleted file mode") {
+			return nil
+		}
+		if m := hunkHeaderRe.FindStringSubmatch(line); m != nil {
+			start, _ := strconv.Atoi(m[1])
+			hunks = append(hunks, diffHunk{oldStart: start})
+			cur = &hunks[len(hunks)-1]
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, "-"):
+			cur.removed = append(cur.removed, line[1:])
+		case strings.HasPrefix(line, "+"):
+			cur.added = append(cur.added, line[1:])
+		}
+	}
+	return hunks
+}
+
+func isIgnoredHunk(h diffHunk, res []*regexp.Regexp) bool {
+	for _, lines := range [][]string{h.removed, h.added} {
+		for _, line := range lines {
+			if !matchesAny(line, res) {
+				return false
+			}
+		}
+	}
+	return len(h.removed)+len(h.added) > 0
+}
+
+func matchesAny(line string, res []*regexp.Regexp) bool {
+	for _, re := range res {
+		if re.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyHunks applies hunks (in diff order) to content, from the last one backwards so
+// the line numbers of earlier hunks stay valid.
+func applyHunks(content string, hunks []diffHunk) string {
+	lines := strings.Split(content, "\n")
+	for i := len(hunks) - 1; i >= 0; i-- {
+		h := hunks[i]
+		// With no removed lines, oldStart is the line the insertion follows.
+		at := h.oldStart
+		if len(h.removed) > 0 {
+			at--
+		}
+		end := at + len(h.removed)
+		if at < 0 || end > len(lines) {
+			continue
+		}
+		lines = append(lines[:at], append(append([]string(nil), h.added...), lines[end:]...)...)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,191 @@
+package analyzer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"goodchanges/internal/log"
+	"goodchanges/internal/tsparse"
+)
+
+// ImportUse is one import (or re-export) of a package specifier by a project file.
+type ImportUse struct {
+	ProjectFolder string   `json:"projectFolder"`
+	File          string   `json:"file"`      // relative to ProjectFolder
+	Specifier     string   `json:"specifier"` // e.g. "@gooddata/sdk-ui" or "@gooddata/sdk-ui/internal"
+	Names         []string `json:"names"`     // source-side names; "*" uses every export
+}
+
+// ImportIndex holds the package imports of a set of projects: static and dynamic
+// imports and re-exports with a non-relative source.
+type ImportIndex struct {
+	bySpecifier map[string][]ImportUse
+}
+
+// importIndexCache is the on-disk cache of per-file import uses, keyed by path
+// and content hash, so that unchanged files are not parsed again.
+type importIndexCache struct {
+	Key   string                    `json:"key"` // cache format and parser; a different key discards the cache
+	Files map[string]cachedFileUses `json:"files"`
+}
+
+type cachedFileUses struct {
+	Hash string      `json:"hash"` // sha256 of the file content
+	Uses []ImportUse `json:"uses"`
+}
+
+// BuildImportIndex parses the source files of the given project folders and indexes
+// their package imports. Namespace imports, `export *`, bare imports and opaque
+// dynamic imports use every export of the specifier and are recorded as "*".
+//
+// When cachePath is set, per-file results are read from and written back to that
+// file; entries are reused while the file content is unchanged and cacheKey matches.
+func BuildImportIndex(projectFolders []string, cachePath, cacheKey string) *ImportIndex {
+	cache := importIndexCache{Key: cacheKey, Files: make(map[string]cachedFileUses)}
+	if cachePath != "" {
+		if data, err := os.ReadFile(cachePath); err == nil {
+			var loaded importIndexCache
+			if json.Unmarshal(data, &loaded) == nil && loaded.Key == cacheKey && loaded.Files != nil {
+				cache = loaded
+			}
+		}
+	}
+	fresh := make(map[string]cachedFileUses)
+	parsed := 0
+
+	idx := &ImportIndex{bySpecifier: make(map[string][]ImportUse)}
+	for _, folder := range projectFolders {
+		files, err := globSourceFiles(folder)
+		if err != nil {
+			continue
+		}
+		for _, relPath := range files {
+			path := filepath.Join(folder, relPath)
+			content, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			sum := sha256.Sum256(content)
+			hash := hex.EncodeToString(sum[:])
+			entry, ok := cache.Files[path]
+			if !ok || entry.Hash != hash {
+				analysis, err := tsparse.ParseContent(string(content), path)
+				if err != nil {
+					continue
+				}
+				entry = cachedFileUses{Hash: hash, Uses: fileImportUses(analysis)}
+				parsed++
+			}
+			fresh[path] = entry
+			for _, use := range entry.Uses {
+				use.ProjectFolder = folder
+				use.File = relPath
+				idx.bySpecifier[use.Specifier] = append(idx.bySpecifier[use.Specifier], use)
+			}
+		}
+	}
+	log.Debugf("import index: %d files, %d parsed, %d from cache", len(fresh), parsed, len(fresh)-parsed)
+
+	if cachePath != "" && parsed > 0 {
+		// Only the scanned files are kept, so entries of deleted files don't accumulate.
+		data, err := json.Marshal(importIndexCache{Key: cacheKey, Files: fresh})
+		if err == nil {
+			if err := os.MkdirAll(filepath.Dir(cachePath), 0o755); err == nil {
+				err = os.WriteFile(cachePath, data, 0o644)
+			}
+		}
+		if err != nil {
+			log.Debugf("import index: writing cache %s: %v", cachePath, err)
+		}
+	}
+	return idx
+}
+
+// fileImportUses returns the package imports of one file, merged per specifier.
+func fileImportUses(analysis *tsparse.FileAnalysis) []ImportUse {
+	names := make(map[string]map[string]bool) // specifier → names
+	add := func(specifier, name string) {
+		if specifier == "" || strings.HasPrefix(specifier, ".") || strings.HasPrefix(specifier, "/") {
+			return
+		}
+		if names[specifier] == nil {
+			names[specifier] = make(map[string]bool)
+		}
+		names[specifier][name] = true
+	}
+	for _, imp := range analysis.Imports {
+		if len(imp.Names) == 0 {
+			add(imp.Source, "*")
+		}
+		for _, name := range imp.Names {
+			if strings.HasPrefix(name, "*:") {
+				name = "*"
+			}
+			add(imp.Source, name)
+		}
+	}
+	for _, exp := range analysis.Exports {
+		if exp.IsStar {
+			add(exp.Source, "*")
+		} else {
+			add(exp.Source, exp.LocalName)
+		}
+	}
+
+	var uses []ImportUse
+	for specifier, set := range names {
+		use := ImportUse{Specifier: specifier}
+		for name := range set {
+			use.Names = append(use.Names, name)
+		}
+		sort.Strings(use.Names)
+		uses = append(uses, use)
+	}
+	return uses
+}
+
+// Specifiers returns the indexed import specifiers, sorted.
+func (idx *ImportIndex) Specifiers() []string {
+	specifiers := make([]string, 0, len(idx.bySpecifier))
+	for s := range idx.bySpecifier {
+		specifiers = append(specifiers, s)
+	}
+	sort.Strings(specifiers)
+	return specifiers
+}
+
+// Uses returns the imports of a specifier, optionally narrowed to those using the
+// export name (including "*" uses). An empty name matches every import.
+func (idx *ImportIndex) Uses(specifier, name string) []ImportUse {
+	var result []ImportUse
+	for _, use := range idx.bySpecifier[specifier] {
+		if name == "" || importsName(use, name) {
+			result = append(result, use)
+		}
+	}
+	return result
+}
+
+// Consumed reports whether any indexed file uses the export name of the specifier.
+func (idx *ImportIndex) Consumed(specifier, name string) bool {
+	for _, use := range idx.bySpecifier[specifier] {
+		if importsName(use, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func importsName(use ImportUse, name string) bool {
+	for _, n := range use.Names {
+		if n == name || n == "*" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,91 @@
+package analyzer
+
+import (
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"goodchanges/internal/log"
+	"goodchanges/internal/metrics"
+	"goodchanges/internal/tsparse"
+)
+
+// ParseFailure is a source file the parser reported syntax errors for. Its AST is
+// error-recovered, so imports and symbols around the errors may be missing from the graph.
+type ParseFailure struct {
+	File   string   // relative to the project folder
+	Errors []string // "line:col: message"
+}
+
+// parseFailures collects parse failures per project folder during analysis.
+// Analysis runs concurrently, hence the mutex.
+var parseFailures = struct {
+	sync.Mutex
+	byProject map[string]map[string][]string // project folder → relPath → errors
+}{byProject: make(map[string]map[string][]string)}
+
+// recordParseFailure remembers the parse errors of a parsed source file, if any.
+// Each file is recorded once, however many times it is parsed.
+func recordParseFailure(projectFolder string, relPath string, analysis *tsparse.FileAnalysis) {
+	if len(analysis.ParseErrors) == 0 {
+		return
+	}
+	parseFailures.Lock()
+	defer parseFailures.Unlock()
+	files := parseFailures.byProject[projectFolder]
+	if files == nil {
+		files = make(map[string][]string)
+		parseFailures.byProject[projectFolder] = files
+	}
+	if _, seen := files[relPath]; seen {
+		return
+	}
+	files[relPath] = analysis.ParseErrors
+	metrics.Add("goodchanges_parse_failures_total", 1)
+	log.Debugf("  parse errors in %s/%s: %s", projectFolder, relPath, strings.Join(analysis.ParseErrors, "; "))
+}
+
+// ParseFailures returns the parse failures recorded so far, keyed by project folder
+// and sorted by file.
+func ParseFailures() map[string][]ParseFailure {
+	parseFailures.Lock()
+	defer parseFailures.Unlock()
+	result := make(map[string][]ParseFailure, len(parseFailures.byProject))
+	for folder, files := range parseFailures.byProject {
+		for file, errs := range files {
+			result[folder] = append(result[folder], ParseFailure{File: file, Errors: errs})
+		}
+		sort.Slice(result[folder], func(i, j int) bool {
+			return result[folder][i].File < result[folder][j].File
+		})
+	}
+	return result
+}
+
+// UnparseableChangedFiles returns the changed TS/JS files of the project (relative to
+// it) that have parse errors. Their per-symbol diff cannot be trusted.
+func UnparseableChangedFiles(changedFiles []string, projectFolder string) []string {
+	var result []string
+	for _, f := range changedFiles {
+		if !strings.HasPrefix(f, projectFolder+"/") {
+			continue
+		}
+		if !isSourceFile(f) {
+			continue
+		}
+		relPath := strings.TrimPrefix(f, projectFolder+"/")
+		if info, err := os.Stat(f); err == nil && oversized(projectFolder, relPath, info.Size()) {
+			continue // not parsed at all; analysis taints its importers
+		}
+		analysis, err := tsparse.ParseFile(f)
+		if err != nil {
+			continue // deleted
+		}
+		recordParseFailure(projectFolder, relPath, analysis)
+		if len(analysis.ParseErrors) > 0 {
+			result = append(result, relPath)
+		}
+	}
+	return result
+}
@@ -1,11 +1,20 @@
 package analyzer
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"goodchanges/internal/log"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
+
+	"goodchanges/internal/rush"
+	"goodchanges/internal/tsparse"
 )
 
 func parseExportsField(exports json.RawMessage) []Entrypoint {
@@ -53,10 +62,78 @@ func resolveExportValue(raw json.RawMessage) string {
 	return ""
 }
 
-func resolveToSource(projectFolder string, builtPath string) string {
+// browserEntry returns the browser build of the package's main entry: the "browser"
+// field when it is a path, or the replacement of main (or module) in its object form
+// (`{"./lib/index.js": "./lib/browser.js"}`). Returns "" if there is none.
+func browserEntry(pkg rush.PackageJSON) string {
+	var str string
+	if json.Unmarshal(pkg.Browser, &str) == nil {
+		return str
+	}
+	var replacements map[string]json.RawMessage
+	if json.Unmarshal(pkg.Browser, &replacements) != nil {
+		return ""
+	}
+	normalize := func(p string) string { return strings.TrimSuffix(path.Clean(p), ".js") }
+	for _, field := range []string{pkg.Main, pkg.Module} {
+		if field == "" {
+			continue
+		}
+		for key, val := range replacements {
+			// `false` replacements exclude a file from the browser build
+			if normalize(key) == normalize(field) && json.Unmarshal(val, &str) == nil {
+				return str
+			}
+		}
+	}
+	return ""
+}
+
+// parseTypesVersions returns the subpath entrypoints of a "typesVersions" map
+// (`{">=4.2": {"sub": ["dist/sub.d.ts"]}}`), one per target, in order. TypeScript
+// version ranges are not evaluated: the subpaths of every range are returned, ranges
+// sorted by key. Wildcard subpaths are skipped, as in exports.
+func parseTypesVersions(raw json.RawMessage) []Entrypoint {
+	var ranges map[string]map[string][]string
+	if len(raw) == 0 || json.Unmarshal(raw, &ranges) != nil {
+		return nil
+	}
+	var result []Entrypoint
+	for _, r := range mapKeys(ranges) {
+		for _, subpath := range mapKeys(ranges[r]) {
+			if strings.Contains(subpath, "*") {
+				continue
+			}
+			exportPath := "./" + strings.TrimPrefix(subpath, "./")
+			if subpath == "." {
+				exportPath = "."
+			}
+			for _, target := range ranges[r][subpath] {
+				result = append(result, Entrypoint{ExportPath: exportPath, SourceFile: target})
+			}
+		}
+	}
+	return result
+}
+
+// resolveToSource maps a built package.json entrypoint path to its source file,
+// relative to the project folder: through the project's sourceDirs mappings, then
+// esm/, dist/, lib/ and build/ → src/, then the path itself or its source map.
+func resolveToSource(projectFolder string, builtPath string, cfg *rush.ProjectConfig) string {
 	builtPath = strings.TrimPrefix(builtPath, "./")
 
 	var candidates []string
+	if cfg != nil {
+		for _, sd := range cfg.SourceDirs {
+			prefix := strings.TrimSuffix(strings.TrimPrefix(sd.Build, "./"), "/") + "/"
+			if !strings.HasPrefix(builtPath, prefix) {
+				continue
+			}
+			for _, src := range sd.Sources {
+				candidates = append(candidates, path.Join(src, strings.TrimPrefix(builtPath, prefix)))
+			}
+		}
+	}
 	for _, prefix := range []string{"esm/", "dist/", "lib/", "build/"} {
 		if strings.HasPrefix(builtPath, prefix) {
 			candidates = append(candidates, "src/"+strings.TrimPrefix(builtPath, prefix))
@@ -65,9 +142,19 @@ func resolveToSource(projectFolder string, builtPath string) string {
 	candidates = append(candidates, builtPath)
 
 	for _, candidate := range candidates {
+		if candidate == builtPath {
+			// No source mirror (e.g. a generated barrel emitted only to esm/): before
+			// settling for the built file itself, ask its source map where it came from.
+			if source := resolveViaSourceMap(projectFolder, builtPath); source != "" {
+				log.Debugf("  resolveToSource: %s → %s (source map)", builtPath, source)
+				return source
+			}
+		}
 		base := candidate
 		if strings.HasSuffix(base, ".d.mts") {
 			base = strings.TrimSuffix(base, ".d.mts")
+		} else if strings.HasSuffix(base, ".d.cts") {
+			base = strings.TrimSuffix(base, ".d.cts")
 		} else if strings.HasSuffix(base, ".d.ts") {
 			base = strings.TrimSuffix(base, ".d.ts")
 		} else if strings.HasSuffix(base, ".mjs") {
@@ -78,7 +165,7 @@ func resolveToSource(projectFolder string, builtPath string) string {
 			base = strings.TrimSuffix(base, ".js")
 		}
 
-		for _, ext := range []string{".ts", ".tsx", ".js", ".jsx"} {
+		for _, ext := range []string{".ts", ".tsx", ".js", ".jsx", ".mts", ".cts", ".mjs", ".cjs"} {
 			tryPath := filepath.Join(projectFolder, base+ext)
 			if _, err := os.Stat(tryPath); err == nil {
 				log.Debugf("  resolveToSource: %s → %s", builtPath, base+ext)
@@ -104,6 +191,51 @@ func resolveToSource(projectFolder string, builtPath string) string {
 	return ""
 }
 
+// sourceMappingURLRe matches the source map reference comment at the end of a built file.
+var sourceMappingURLRe = regexp.MustCompile(`//[#@] sourceMappingURL=(\S+)\s*$`)
+
+// resolveViaSourceMap locates the source of a built file (relative to the project root)
+// through its source map: the file's sourceMappingURL comment, or "<file>.map" next to
+// it (declaration maps included). Returns the first TS/JS source listed in the map that
+// exists inside the project, or "" if there is none. Inline (data:) maps are not supported.
+func resolveViaSourceMap(projectFolder string, builtPath string) string {
+	builtFile := filepath.Join(projectFolder, builtPath)
+	mapFile := builtFile + ".map"
+	if content, err := os.ReadFile(builtFile); err == nil {
+		if m := sourceMappingURLRe.FindSubmatch(bytes.TrimSpace(content)); m != nil && !bytes.HasPrefix(m[1], []byte("data:")) {
+			mapFile = filepath.Join(filepath.Dir(builtFile), string(m[1]))
+		}
+	}
+	data, err := os.ReadFile(mapFile)
+	if err != nil {
+		return ""
+	}
+	var sourceMap struct {
+		SourceRoot string   `json:"sourceRoot"`
+		Sources    []string `json:"sources"`
+	}
+	if err := json.Unmarshal(data, &sourceMap); err != nil {
+		log.Debugf("  resolveViaSourceMap: invalid source map %s: %v", mapFile, err)
+		return ""
+	}
+	for _, source := range sourceMap.Sources {
+		switch filepath.Ext(source) {
+		case ".ts", ".tsx", ".js", ".jsx", ".mts", ".cts":
+		default:
+			continue
+		}
+		path := filepath.Join(filepath.Dir(mapFile), sourceMap.SourceRoot, source)
+		rel, err := filepath.Rel(projectFolder, path)
+		if err != nil || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return ""
+}
+
 func resolveImportSource(fromDir string, source string, projectFolder string) string {
 	if !strings.HasPrefix(source, ".") {
 		return ""
@@ -116,31 +248,158 @@ func resolveImportSource(fromDir string, source string, projectFolder string) st
 }
 
 func resolveImportToFile(fromDir string, source string, projectFolder string) string {
-	base := strings.TrimSuffix(source, ".js")
-	base = strings.TrimSuffix(base, ".jsx")
+	resolved, caseMismatch := resolveImportCase(fromDir, source, projectFolder)
+	if caseMismatch {
+		log.Debugf("  resolveImportToFile: %s (from %s) → %s (case mismatch)", source, fromDir, resolved)
+	} else if resolved != "" {
+		log.Debugf("  resolveImportToFile: %s (from %s) → %s", source, fromDir, resolved)
+	} else {
+		log.Debugf("  resolveImportToFile: %s (from %s) → (not found)", source, fromDir)
+	}
+	return resolved
+}
+
+// resolveImportCase resolves a relative import to a file path relative to projectFolder,
+// spelled as on disk. Candidates are first matched case-exactly (like git and
+// case-sensitive filesystems), then case-insensitively, so an import whose case differs
+// from the file (which works on macOS) still resolves to the path git reports as
+// changed. caseMismatch is true when only the case-insensitive match succeeded.
+func resolveImportCase(fromDir string, source string, projectFolder string) (resolved string, caseMismatch bool) {
+	// Candidate extensions per import extension, following TypeScript's resolution:
+	// `./x.js` may name x.ts, `./x.mjs` x.mts and `./x.cjs` x.cts.
+	ext := filepath.Ext(source)
+	base := strings.TrimSuffix(source, ext)
+	var exts []string
+	index := false // directory imports resolve to index files
+	switch strings.ToLower(ext) {
+	case ".js", ".jsx":
+		exts, index = []string{".ts", ".tsx", ".js", ".jsx"}, true
+	case ".mjs":
+		exts = []string{".mts", ".mjs"}
+	case ".cjs":
+		exts = []string{".cts", ".cjs"}
+	case ".ts", ".tsx", ".mts", ".cts":
+		// allowImportingTsExtensions: the import names the source file itself
+		exts = []string{ext}
+	default:
+		base = source
+		exts, index = []string{".ts", ".tsx", ".js", ".jsx"}, true
+	}
 	relPath := filepath.Join(fromDir, base)
 
-	for _, ext := range []string{".ts", ".tsx", ".js", ".jsx"} {
-		tryPath := filepath.Join(projectFolder, relPath+ext)
-		if _, err := os.Stat(tryPath); err == nil {
-			log.Debugf("  resolveImportToFile: %s (from %s) → %s", source, fromDir, relPath+ext)
-			return relPath + ext
+	var candidates []string
+	for _, ext := range exts {
+		candidates = append(candidates, relPath+ext)
+	}
+	if index {
+		for _, ext := range []string{".ts", ".tsx"} {
+			candidates = append(candidates, filepath.Join(relPath, "index"+ext))
 		}
 	}
-	for _, ext := range []string{".ts", ".tsx"} {
-		tryPath := filepath.Join(projectFolder, relPath, "index"+ext)
-		if _, err := os.Stat(tryPath); err == nil {
-			result := filepath.Join(relPath, "index"+ext)
-			log.Debugf("  resolveImportToFile: %s (from %s) → %s", source, fromDir, result)
-			return result
+	for _, fold := range []bool{false, true} {
+		for _, candidate := range candidates {
+			if found := lookupPath(projectFolder, candidate, fold); found != "" {
+				return found, fold
+			}
 		}
 	}
-	log.Debugf("  resolveImportToFile: %s (from %s) → (not found)", source, fromDir)
-	return ""
+	return "", false
+}
+
+// dirEntryNames caches directory listings (dir → entry names) for lookupPath.
+var dirEntryNames sync.Map
+
+// lookupPath returns relPath (relative to projectFolder) spelled as on disk, or "" if it
+// does not exist. Components are compared by name rather than with os.Stat, which
+// matches case-insensitively on macOS and Windows. With fold, a component matches
+// case-insensitively when no exact match exists.
+func lookupPath(projectFolder string, relPath string, fold bool) string {
+	parts := strings.Split(filepath.ToSlash(relPath), "/")
+	dir := projectFolder
+	for i, part := range parts {
+		if part == "." || part == ".." {
+			dir = filepath.Join(dir, part)
+			continue
+		}
+		cached, ok := dirEntryNames.Load(dir)
+		if !ok {
+			entries, _ := os.ReadDir(dir)
+			names := make([]string, len(entries))
+			for j, e := range entries {
+				names[j] = e.Name()
+			}
+			cached, _ = dirEntryNames.LoadOrStore(dir, names)
+		}
+		names := cached.([]string)
+		match := ""
+		for _, name := range names {
+			if name == part {
+				match = name
+				break
+			}
+			if fold && match == "" && strings.EqualFold(name, part) {
+				match = name
+			}
+		}
+		if match == "" {
+			return ""
+		}
+		parts[i] = match
+		dir = filepath.Join(dir, match)
+	}
+	return filepath.Join(parts...)
+}
+
+// FindCaseMismatchedImports returns the relative imports and re-exports in the
+// project's source files whose path differs in case from the file they resolve to,
+// formatted as `file: "source" → resolved`. Such imports resolve on case-insensitive
+// filesystems (macOS) but not on Linux.
+func FindCaseMismatchedImports(projectFolder string) []string {
+	files, err := globSourceFiles(projectFolder)
+	if err != nil {
+		return nil
+	}
+	var mismatches []string
+	for _, relPath := range files {
+		analysis, err := tsparse.ParseFile(filepath.Join(projectFolder, relPath))
+		if err != nil {
+			continue
+		}
+		fileDir := filepath.Dir(relPath)
+		var sources []string
+		for _, imp := range analysis.Imports {
+			sources = append(sources, imp.Source)
+		}
+		for _, exp := range analysis.Exports {
+			sources = append(sources, exp.Source)
+		}
+		seen := make(map[string]bool)
+		for _, source := range sources {
+			if !strings.HasPrefix(source, ".") || seen[source] {
+				continue
+			}
+			seen[source] = true
+			if resolved, caseMismatch := resolveImportCase(fileDir, source, projectFolder); caseMismatch {
+				mismatches = append(mismatches, fmt.Sprintf("%s: %q → %s", filepath.ToSlash(relPath), source, filepath.ToSlash(resolved)))
+			}
+		}
+	}
+	sort.Strings(mismatches)
+	return mismatches
+}
+
+// isSourceFile reports whether a path is a TS/JS module: .ts/.tsx/.js/.jsx and the
+// ES module and CommonJS variants .mts/.cts/.mjs/.cjs.
+func isSourceFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ts", ".tsx", ".js", ".jsx", ".mts", ".cts", ".mjs", ".cjs":
+		return true
+	}
+	return false
 }
 
 func stripTSExtension(path string) string {
-	for _, ext := range []string{".tsx", ".ts", ".jsx", ".js", ".d.ts", ".d.mts"} {
+	for _, ext := range []string{".tsx", ".ts", ".jsx", ".js", ".d.ts", ".d.mts", ".d.cts", ".mts", ".cts", ".mjs", ".cjs"} {
 		if strings.HasSuffix(path, ext) {
 			return strings.TrimSuffix(path, ext)
 		}
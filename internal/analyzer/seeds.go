@@ -0,0 +1,64 @@
+package analyzer
+
+import (
+	"sort"
+	"sync"
+
+	"goodchanges/internal/tsparse"
+)
+
+// ChangeSeed is a symbol of a changed library file whose AST diff seeded taint.
+type ChangeSeed struct {
+	File       string // repo-relative
+	Symbol     string
+	Line       int    // 1-based declaration line; 0 when the symbol was removed
+	EndLine    int    // last line of the declaration
+	ExportName string // name the file exports the symbol under, if any
+	Change     string // React components: "props" (prop signature only) or "render"
+}
+
+// changeSeeds collects the seeds of each library during analysis, which runs
+// concurrently, hence the mutex.
+var changeSeeds = struct {
+	sync.Mutex
+	byProject map[string][]ChangeSeed // project folder → seeds
+}{byProject: make(map[string][]ChangeSeed)}
+
+// recordChangeSeeds remembers the affected symbols of a changed file, located in its
+// new version, with their component change classification.
+func recordChangeSeeds(projectFolder string, file string, symbols []string, changes map[string]string, analysis *tsparse.FileAnalysis) {
+	decls := make(map[string]tsparse.SymbolDecl)
+	for _, sym := range analysis.Symbols {
+		if _, ok := decls[sym.Name]; !ok {
+			decls[sym.Name] = sym
+		}
+	}
+	changeSeeds.Lock()
+	defer changeSeeds.Unlock()
+	for _, s := range symbols {
+		d := decls[s]
+		seed := ChangeSeed{File: file, Symbol: s, Line: d.StartLine, EndLine: d.EndLine, Change: changes[s]}
+		if d.IsExported {
+			seed.ExportName = d.ExportName
+		}
+		changeSeeds.byProject[projectFolder] = append(changeSeeds.byProject[projectFolder], seed)
+	}
+}
+
+// ChangeSeeds returns the seeds recorded so far for a project folder, sorted by file,
+// line and symbol.
+func ChangeSeeds(projectFolder string) []ChangeSeed {
+	changeSeeds.Lock()
+	defer changeSeeds.Unlock()
+	seeds := append([]ChangeSeed(nil), changeSeeds.byProject[projectFolder]...)
+	sort.Slice(seeds, func(i, j int) bool {
+		if seeds[i].File != seeds[j].File {
+			return seeds[i].File < seeds[j].File
+		}
+		if seeds[i].Line != seeds[j].Line {
+			return seeds[i].Line < seeds[j].Line
+		}
+		return seeds[i].Symbol < seeds[j].Symbol
+	})
+	return seeds
+}
@@ -0,0 +1,173 @@
+package analyzer
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"goodchanges/internal/lockfile"
+	"goodchanges/internal/log"
+	"goodchanges/internal/registry"
+	"goodchanges/internal/rush"
+	"goodchanges/internal/tsparse"
+)
+
+// NarrowSiblingDeps narrows lockfile bumps of sibling packages (root config
+// siblingPackages) from "every import of the package is tainted" to the exports that
+// changed between the old and new version, by diffing the versions' api-extractor
+// reports fetched from the configured URLs.
+//
+// A narrowed dependency is removed from depChangedDeps of the projects bumping it, and
+// the changed exports are returned as upstream taint per project folder (folder →
+// import specifier → export names). A dependency is left untouched (whole-package taint)
+// when its bump differs between projects, a report can't be fetched (reported in
+// warnings), only its transitive dependencies changed, or a project imports an
+// entrypoint without a configured report.
+func NarrowSiblingDeps(rootCfg *rush.RootConfig, versionChanges map[string]map[string]lockfile.VersionChange, depChangedDeps map[string]map[string]bool) (taint map[string]map[string]map[string]bool, warnings []string) {
+	if rootCfg == nil || len(rootCfg.SiblingPackages) == 0 {
+		return nil, nil
+	}
+
+	// dep → its version change, when all projects bump it the same way.
+	bumps := make(map[string]lockfile.VersionChange)
+	ambiguous := make(map[string]bool)
+	for folder, deps := range depChangedDeps {
+		for dep := range deps {
+			vc, ok := versionChanges[folder][dep]
+			if !ok || rootCfg.SiblingFor(dep) == nil {
+				continue
+			}
+			if prev, seen := bumps[dep]; seen && prev != vc {
+				ambiguous[dep] = true
+			}
+			bumps[dep] = vc
+		}
+	}
+
+	changedExports := make(map[string]map[string]map[string]bool) // dep → specifier → names
+	for dep, vc := range bumps {
+		if ambiguous[dep] {
+			log.Debugf("sibling %s: bumped to different versions across projects — not narrowed", dep)
+			continue
+		}
+		specifiers, err := siblingChangedExports(dep, vc, rootCfg.SiblingFor(dep))
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("sibling package %s %s → %s: %v (treating the whole package as changed)", dep, vc.Old, vc.New, err))
+			continue
+		}
+		changedExports[dep] = specifiers
+	}
+
+	taint = make(map[string]map[string]map[string]bool)
+	for folder, deps := range depChangedDeps {
+		if deps["*"] {
+			continue // lockfileVersion changed: nothing can be narrowed
+		}
+		for dep := range deps {
+			specifiers, ok := changedExports[dep]
+			if !ok {
+				continue
+			}
+			if _, ok := versionChanges[folder][dep]; !ok {
+				continue
+			}
+			if other := importedOtherEntrypoint(folder, dep, specifiers); other != "" {
+				log.Debugf("sibling %s: %s imports %s, which has no API report — not narrowed", dep, folder, other)
+				continue
+			}
+			delete(deps, dep)
+			for specifier, names := range specifiers {
+				if len(names) == 0 {
+					continue
+				}
+				if taint[folder] == nil {
+					taint[folder] = make(map[string]map[string]bool)
+				}
+				taint[folder][specifier] = names
+			}
+		}
+		if len(deps) == 0 {
+			delete(depChangedDeps, folder)
+		}
+	}
+	return taint, warnings
+}
+
+// siblingChangedExports fetches the old and new API reports of every configured
+// entrypoint of a sibling package and returns the changed exports per import specifier.
+func siblingChangedExports(dep string, vc lockfile.VersionChange, sc *rush.SiblingConfig) (map[string]map[string]bool, error) {
+	gitHeads := make(map[string]string) // version → gitHead
+	expand := func(template, version string) (string, error) {
+		if strings.Contains(template, "{gitHead}") {
+			if _, ok := gitHeads[version]; !ok {
+				meta, err := registry.Version(dep, version)
+				if err != nil {
+					return "", err
+				}
+				if meta.GitHead == "" {
+					return "", fmt.Errorf("registry metadata of %s@%s has no gitHead", dep, version)
+				}
+				gitHeads[version] = meta.GitHead
+			}
+		}
+		unscoped := dep[strings.LastIndex(dep, "/")+1:]
+		return strings.NewReplacer(
+			"{package}", dep,
+			"{unscopedPackageName}", unscoped,
+			"{version}", version,
+			"{gitHead}", gitHeads[version],
+		).Replace(template), nil
+	}
+
+	result := make(map[string]map[string]bool)
+	for _, ar := range sc.APIReports {
+		var reports [2]string
+		for i, version := range []string{vc.Old, vc.New} {
+			url, err := expand(ar.URL, version)
+			if err != nil {
+				return nil, err
+			}
+			data, err := registry.Fetch(url)
+			if err != nil {
+				return nil, err
+			}
+			reports[i] = string(data)
+		}
+		specifier := dep + strings.TrimPrefix(ar.Entrypoint, ".")
+		names := make(map[string]bool)
+		for _, name := range DiffAPIReports(reports[0], reports[1]) {
+			names[name] = true
+		}
+		log.Basicf("Sibling %s %s → %s: %d changed exports in %s", dep, vc.Old, vc.New, len(names), specifier)
+		result[specifier] = names
+	}
+	return result, nil
+}
+
+// importedOtherEntrypoint returns an import source of the project that refers to the
+// package dep but none of the given specifiers, or "".
+func importedOtherEntrypoint(projectFolder, dep string, specifiers map[string]map[string]bool) string {
+	files, err := globSourceFiles(projectFolder)
+	if err != nil {
+		return ""
+	}
+	for _, relPath := range files {
+		analysis, err := tsparse.ParseFile(filepath.Join(projectFolder, relPath))
+		if err != nil {
+			continue
+		}
+		var sources []string
+		for _, imp := range analysis.Imports {
+			sources = append(sources, imp.Source)
+		}
+		for _, exp := range analysis.Exports {
+			sources = append(sources, exp.Source)
+		}
+		for _, source := range sources {
+			if (source == dep || strings.HasPrefix(source, dep+"/")) && specifiers[source] == nil {
+				return source
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,50 @@
+package analyzer
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"goodchanges/internal/rush"
+	"goodchanges/internal/tsparse"
+)
+
+// RespectSideEffects enables skipping bare imports (`import "./x"`, `import "pkg"`)
+// of modules whose package declares "sideEffects": false (RESPECT_SIDE_EFFECTS).
+// Bundlers drop such imports, so a change behind them can't reach the importer.
+var RespectSideEffects bool
+
+// SideEffectFreePackages holds the names of workspace and external packages that
+// declare "sideEffects": false. Set by main when RespectSideEffects is enabled.
+var SideEffectFreePackages map[string]bool
+
+// deadBareImport reports whether imp is a bare import dropped by bundlers: a relative
+// import in a project declaring "sideEffects": false (ownSideEffectFree), or a
+// package import of a side-effect-free package.
+func deadBareImport(imp tsparse.Import, ownSideEffectFree bool) bool {
+	if !RespectSideEffects || !imp.IsBare {
+		return false
+	}
+	if strings.HasPrefix(imp.Source, ".") {
+		return ownSideEffectFree
+	}
+	return SideEffectFreePackages[PackageOfSpecifier(imp.Source)]
+}
+
+// projectSideEffectFree reports whether the project's package.json declares
+// "sideEffects": false. Always false unless RespectSideEffects is set.
+func projectSideEffectFree(projectFolder string) bool {
+	if !RespectSideEffects {
+		return false
+	}
+	data, err := os.ReadFile(filepath.Join(projectFolder, "package.json"))
+	if err != nil {
+		return false
+	}
+	var pkg rush.PackageJSON
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return false
+	}
+	return pkg.SideEffectFree()
+}
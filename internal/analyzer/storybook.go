@@ -0,0 +1,78 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"goodchanges/internal/tsparse"
+)
+
+// StoryFilePattern matches Storybook CSF story files. It is the default output
+// filter of "storybook" targets.
+const StoryFilePattern = "**/*.stories.{ts,tsx,js,jsx}"
+
+var (
+	storyTitleRe     = regexp.MustCompile(`\btitle\s*:\s*["'` + "`" + `]([^"'` + "`" + `]+)["'` + "`" + `]`)
+	storyIDInvalidRe = regexp.MustCompile(`[^a-z0-9]+`)
+)
+
+// StoryIDs returns the Storybook story IDs (e.g. "components-button--primary") defined
+// in a CSF story file (path relative to projectFolder). IDs are derived the way
+// Storybook derives them: the meta title from the default export plus each named
+// export. Returns nil when the file has no literal `title` (auto-titled stories),
+// since the title then depends on the Storybook configuration.
+func StoryIDs(projectFolder, relPath string) []string {
+	fullPath := filepath.Join(projectFolder, relPath)
+	content, err := os.ReadFile(fullPath)
+	if err != nil {
+		return nil
+	}
+	m := storyTitleRe.FindSubmatch(content)
+	if m == nil {
+		return nil
+	}
+	analysis, err := tsparse.ParseFile(fullPath)
+	if err != nil {
+		return nil
+	}
+	titleID := sanitizeStoryID(string(m[1]))
+	var ids []string
+	for _, exp := range analysis.Exports {
+		if exp.Name == "default" || exp.IsStar || exp.IsTypeOnly || strings.HasPrefix(exp.Name, "__") {
+			continue
+		}
+		ids = append(ids, titleID+"--"+sanitizeStoryID(storyNameFromExport(exp.Name)))
+	}
+	return ids
+}
+
+// storyNameFromExport converts an export name to a story name like Storybook's
+// startCase: "PrimaryButton2" → "Primary Button 2".
+func storyNameFromExport(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if r == '_' || r == '$' {
+			b.WriteByte(' ')
+			continue
+		}
+		if i > 0 {
+			prev := runes[i-1]
+			if (unicode.IsUpper(r) && unicode.IsLower(prev)) ||
+				(unicode.IsDigit(r) && unicode.IsLetter(prev)) ||
+				(unicode.IsLetter(r) && unicode.IsDigit(prev)) {
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// sanitizeStoryID lowercases a title or story name and joins its words with "-".
+func sanitizeStoryID(s string) string {
+	return strings.Trim(storyIDInvalidRe.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
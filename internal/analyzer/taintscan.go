@@ -0,0 +1,93 @@
+package analyzer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"goodchanges/internal/log"
+	"goodchanges/internal/tsparse"
+)
+
+// folderImportIndex is what HasTaintedImportsForGlob needs of a project folder's
+// source files: their package imports by specifier and, with IncludeGraphQL, their
+// inline gql fragment spreads.
+type folderImportIndex struct {
+	files       []scannedFile
+	bySpecifier map[string][]scannedImport
+	specifiers  []string // keys of bySpecifier, sorted
+}
+
+type scannedFile struct {
+	rel         string
+	parseErrors []string // not known for prescanned files
+	spreads     []string
+}
+
+// PrescanImports enables the lexer-level import prescan (PRESCAN_IMPORTS): target
+// files' imports are read without parsing when the prescan can vouch for them. Syntax
+// errors in prescanned files then go unreported, so it is off by default.
+var PrescanImports bool
+
+type scannedImport struct {
+	file int // index into files
+	imp  tsparse.Import
+}
+
+// folderImports caches the import index of each scanned project folder, so the
+// folder's files are parsed once per run however many targets, change dirs and app
+// routes check them.
+var folderImports = struct {
+	sync.Mutex
+	byFolder map[string]*folderImportIndex
+}{byFolder: make(map[string]*folderImportIndex)}
+
+// importIndexFor returns the import index of a project folder, building it on first use.
+func importIndexFor(projectFolder string) (*folderImportIndex, error) {
+	folderImports.Lock()
+	defer folderImports.Unlock()
+	if idx, ok := folderImports.byFolder[projectFolder]; ok {
+		return idx, nil
+	}
+	allFiles, err := globSourceFiles(projectFolder)
+	if err != nil {
+		return nil, err
+	}
+	idx := &folderImportIndex{bySpecifier: make(map[string][]scannedImport)}
+	for _, relPath := range allFiles {
+		path := filepath.Join(projectFolder, relPath)
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		// Only the imports matter here: the prescan spares the full parse of most files.
+		f := scannedFile{rel: relPath}
+		var imports []tsparse.Import
+		ok := false
+		if PrescanImports {
+			imports, ok = tsparse.ScanImports(string(content))
+		}
+		if !ok {
+			analysis, err := tsparse.ParseContent(string(content), path)
+			if err != nil {
+				continue
+			}
+			logSuppressedImports(relPath, analysis)
+			imports, f.parseErrors = analysis.Imports, analysis.ParseErrors
+		}
+		if IncludeGraphQL {
+			f.spreads = graphqlSpreads(inlineGraphQL(string(content)))
+		}
+		idx.files = append(idx.files, f)
+		for _, imp := range imports {
+			if !strings.HasPrefix(imp.Source, ".") {
+				idx.bySpecifier[imp.Source] = append(idx.bySpecifier[imp.Source], scannedImport{file: len(idx.files) - 1, imp: imp})
+			}
+		}
+	}
+	idx.specifiers = mapKeys(idx.bySpecifier)
+	log.Debugf("import index of %s: %d files, %d package specifiers", projectFolder, len(idx.files), len(idx.bySpecifier))
+	folderImports.byFolder[projectFolder] = idx
+	return idx, nil
+}
@@ -0,0 +1,31 @@
+package analyzer
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestImportIndexParseErrors(t *testing.T) {
+	folder := t.TempDir()
+	t.Cleanup(func() {
+		PrescanImports = false
+		folderImports.byFolder = make(map[string]*folderImportIndex)
+	})
+	writeFile(t, filepath.Join(folder, "src/broken.test.ts"), "import { a } from \"dep\";\nit(\"x\", () => { a( });\n")
+
+	for _, prescan := range []bool{false, true} {
+		PrescanImports = prescan
+		folderImports.byFolder = make(map[string]*folderImportIndex)
+		idx, err := importIndexFor(folder)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(idx.files) != 1 || len(idx.bySpecifier["dep"]) != 1 {
+			t.Fatalf("prescan=%v: unexpected index %+v", prescan, idx)
+		}
+		// Prescanned files aren't parsed, so their syntax errors are only known without it.
+		if got := len(idx.files[0].parseErrors) > 0; got != !prescan {
+			t.Errorf("prescan=%v: parse errors reported = %v", prescan, got)
+		}
+	}
+}
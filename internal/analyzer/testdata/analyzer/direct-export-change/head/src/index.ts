@@ -0,0 +1 @@
+export { add, subtract } from "./math";
@@ -0,0 +1,7 @@
+export function add(a: number, b: number): number {
+    return a + b + 0;
+}
+
+export function subtract(a: number, b: number): number {
+    return a - b;
+}
@@ -0,0 +1,7 @@
+export function formatNumber(value: number): string {
+    return String(value);
+}
+
+export function parseNumber(value: string): number {
+    return Number(value);
+}
@@ -0,0 +1,2 @@
+export * from "./internal/index";
+export { VERSION } from "./version";
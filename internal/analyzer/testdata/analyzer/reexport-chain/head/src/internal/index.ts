@@ -0,0 +1 @@
+export { formatNumber as format, parseNumber } from "./format";
@@ -0,0 +1,7 @@
+export interface ButtonProps {
+    label: string;
+}
+
+export function renderButton(label: string): string {
+    return `<button>${label}</button>`;
+}
@@ -0,0 +1,8 @@
+export interface ButtonProps {
+    label: string;
+    disabled?: boolean;
+}
+
+export function renderButton(label: string): string {
+    return `<button>${label}</button>`;
+}
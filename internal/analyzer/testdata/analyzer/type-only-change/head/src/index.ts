@@ -0,0 +1,2 @@
+export type { ButtonProps } from "./button";
+export { renderButton } from "./button";
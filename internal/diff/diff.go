@@ -0,0 +1,88 @@
+package diff
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Hunk is a changed line range of a unified diff. Lines are 1-based; a zero count
+// means the hunk only adds (OldLines) or only removes (NewLines) lines, and the start
+// is then the line after which the change happened.
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+}
+
+// NewEnd returns the last line of the hunk in the new file, or NewStart when it only
+// removes lines.
+func (h Hunk) NewEnd() int {
+	if h.NewLines == 0 {
+		return h.NewStart
+	}
+	return h.NewStart + h.NewLines - 1
+}
+
+// FileDiff is the hunks of one file; Path is the new path ("b/" side).
+type FileDiff struct {
+	Path  string
+	Hunks []Hunk
+}
+
+// Parse extracts file paths and hunk line ranges from unified diff output (such as
+// `git diff -U0`). Hunk contents are ignored.
+func Parse(patch string) []FileDiff {
+	var files []FileDiff
+	for _, line := range strings.Split(patch, "\n") {
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			files = append(files, FileDiff{})
+		case strings.HasPrefix(line, "+++ "):
+			if len(files) == 0 {
+				files = append(files, FileDiff{})
+			}
+			path := strings.TrimPrefix(line, "+++ ")
+			if path != "/dev/null" {
+				files[len(files)-1].Path = strings.TrimPrefix(path, "b/")
+			}
+		case strings.HasPrefix(line, "@@ "):
+			if len(files) == 0 {
+				continue
+			}
+			if h, ok := parseHunkHeader(line); ok {
+				files[len(files)-1].Hunks = append(files[len(files)-1].Hunks, h)
+			}
+		}
+	}
+	return files
+}
+
+// parseHunkHeader parses "@@ -a,b +c,d @@ ..." (counts default to 1 when omitted).
+func parseHunkHeader(line string) (Hunk, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 3 || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return Hunk{}, false
+	}
+	oldStart, oldLines, ok1 := parseRange(fields[1][1:])
+	newStart, newLines, ok2 := parseRange(fields[2][1:])
+	if !ok1 || !ok2 {
+		return Hunk{}, false
+	}
+	return Hunk{OldStart: oldStart, OldLines: oldLines, NewStart: newStart, NewLines: newLines}, true
+}
+
+func parseRange(s string) (start, count int, ok bool) {
+	startStr, countStr, hasCount := strings.Cut(s, ",")
+	start, err := strconv.Atoi(startStr)
+	if err != nil {
+		return 0, 0, false
+	}
+	count = 1
+	if hasCount {
+		if count, err = strconv.Atoi(countStr); err != nil {
+			return 0, 0, false
+		}
+	}
+	return start, count, true
+}
@@ -1,16 +1,124 @@
 package git
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
+
+	"goodchanges/internal/log"
+	"goodchanges/internal/metrics"
+	"goodchanges/internal/tracing"
+)
+
+// FixtureDir, when set, makes ShowFile read file contents at the comparison commit
+// from this directory instead of git. Used by `goodchanges replay`.
+var FixtureDir string
+
+// Timeout bounds a single git invocation (GIT_TIMEOUT); 0 disables it. A timed-out
+// invocation counts as a transient failure.
+var Timeout = 2 * time.Minute
+
+// Retries is how many times a git invocation failing transiently (timeouts, network
+// errors, lock contention) is retried, with exponential backoff (GIT_RETRIES).
+var Retries = 2
+
+// MaxConcurrent bounds the number of git processes running at once; library analysis
+// runs in parallel and would otherwise spawn one `git show` per changed file at once.
+var MaxConcurrent = 8
+
+// ErrTimeout is returned (wrapped) when a git invocation exceeds Timeout on every attempt.
+var ErrTimeout = errors.New("git timed out")
+
+var (
+	slotsOnce sync.Once
+	slots     chan struct{}
 )
 
-func Cmd(args ...string) (string, error) {
-	cmd := exec.Command("git", args...)
-	out, err := cmd.CombinedOutput()
+// transientMarkers are substrings of git output that indicate a failure worth retrying.
+var transientMarkers = []string{
+	"Could not resolve host",
+	"Connection timed out",
+	"Connection reset",
+	"Connection refused",
+	"Operation timed out",
+	"early EOF",
+	"RPC failed",
+	"the remote end hung up unexpectedly",
+	"index.lock",
+	"Unable to create",
+	"cannot lock ref",
+}
+
+func isTransient(output string) bool {
+	for _, m := range transientMarkers {
+		if strings.Contains(output, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// run executes git with the per-invocation timeout, retrying transient failures.
+// It returns the combined output; on failure the output of the last attempt.
+func run(ctx context.Context, args ...string) ([]byte, error) {
+	slotsOnce.Do(func() { slots = make(chan struct{}, max(MaxConcurrent, 1)) })
+	select {
+	case slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	defer func() { <-slots }()
+
+	for attempt := 0; ; attempt++ {
+		cmdCtx, cancel := ctx, context.CancelFunc(func() {})
+		if Timeout > 0 {
+			cmdCtx, cancel = context.WithTimeout(ctx, Timeout)
+		}
+		out, err := exec.CommandContext(cmdCtx, "git", args...).CombinedOutput()
+		timedOut := cmdCtx.Err() == context.DeadlineExceeded
+		cancel()
+		if err == nil {
+			return out, nil
+		}
+		if ctx.Err() != nil {
+			return out, ctx.Err()
+		}
+		if timedOut {
+			err = fmt.Errorf("%w after %s", ErrTimeout, Timeout)
+		}
+		if (!timedOut && !isTransient(string(out))) || attempt >= Retries {
+			return out, err
+		}
+		backoff := time.Duration(1<<attempt) * time.Second
+		log.Basicf("git %s failed transiently (%v), retrying in %s", args[0], err, backoff)
+		metrics.Add("goodchanges_git_retries_total", 1)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return out, ctx.Err()
+		}
+	}
+}
+
+// Cmd runs git with the given arguments and returns its trimmed output.
+func Cmd(ctx context.Context, args ...string) (string, error) {
+	span := tracing.Start(nil, "git "+args[0], "git.args", strings.Join(args, " "))
+	defer span.End()
+	out, err := run(ctx, args...)
 	if err != nil {
-		return "", fmt.Errorf("%s: %s", err, strings.TrimSpace(string(out)))
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			err = fmt.Errorf("%w: %s", err, msg)
+		}
+		span.SetError(err)
+		return "", err
 	}
 	return strings.TrimSpace(string(out)), nil
 }
@@ -19,14 +127,14 @@ func Cmd(args ...string) (string, error) {
 // If HEAD is already merged into the branch (e.g. testing an already-merged PR),
 // it finds the merge commit on the branch's first-parent line and uses its
 // first parent to compute the correct divergence point.
-func MergeBase(branch string) (string, error) {
+func MergeBase(ctx context.Context, branch string) (string, error) {
 	ref := branch
-	base, err := Cmd("merge-base", "HEAD", ref)
+	base, err := Cmd(ctx, "merge-base", "HEAD", ref)
 	if err != nil {
 		return "", err
 	}
 
-	head, err := Cmd("rev-parse", "HEAD")
+	head, err := Cmd(ctx, "rev-parse", "HEAD")
 	if err != nil {
 		return base, nil
 	}
@@ -38,17 +146,17 @@ func MergeBase(branch string) (string, error) {
 		// List all merge commits in ancestry order (oldest first with --reverse).
 		// NOTE: -1 cannot be combined with --reverse (git applies -1 before reversing),
 		// so we get all results and take the first line.
-		mergeList, err := Cmd("log", "--ancestry-path", head+".."+ref,
+		mergeList, err := Cmd(ctx, "log", "--ancestry-path", head+".."+ref,
 			"--merges", "--first-parent", "--reverse", "--pretty=%H")
 		if err != nil || mergeList == "" {
 			return base, nil
 		}
 		mergeCommit := strings.SplitN(mergeList, "\n", 2)[0]
-		firstParent, err := Cmd("rev-parse", mergeCommit+"^1")
+		firstParent, err := Cmd(ctx, "rev-parse", mergeCommit+"^1")
 		if err != nil {
 			return base, nil
 		}
-		realBase, err := Cmd("merge-base", head, firstParent)
+		realBase, err := Cmd(ctx, "merge-base", head, firstParent)
 		if err != nil {
 			return base, nil
 		}
@@ -58,12 +166,111 @@ func MergeBase(branch string) (string, error) {
 	return base, nil
 }
 
+// RefExists reports whether ref resolves to a commit.
+func RefExists(ctx context.Context, ref string) (bool, error) {
+	if _, err := Cmd(ctx, "rev-parse", "--verify", "--quiet", ref+"^{commit}"); err != nil {
+		if ctx.Err() != nil || errors.Is(err, ErrTimeout) {
+			return false, err
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
+// IsShallow reports whether the repository is a shallow clone, whose history may end
+// before the merge base.
+func IsShallow(ctx context.Context) bool {
+	out, err := Cmd(ctx, "rev-parse", "--is-shallow-repository")
+	return err == nil && out == "true"
+}
+
+// FetchBranch fetches a remote-tracking branch such as "origin/master" from its
+// remote into refs/remotes/. A positive depth limits the fetched history to that many
+// commits.
+func FetchBranch(ctx context.Context, ref string, depth int) error {
+	ref = strings.TrimPrefix(ref, "refs/remotes/")
+	remote, branch, ok := strings.Cut(ref, "/")
+	if ok {
+		remotes, err := Cmd(ctx, "remote")
+		if err != nil {
+			return err
+		}
+		ok = slices.Contains(strings.Split(remotes, "\n"), remote)
+	}
+	if !ok || branch == "" {
+		return fmt.Errorf("%s is not a remote-tracking branch (<remote>/<branch>)", ref)
+	}
+	args := []string{"fetch", "--no-tags", remote, "+refs/heads/" + branch + ":refs/remotes/" + remote + "/" + branch}
+	if depth > 0 {
+		args = append(args, "--depth="+strconv.Itoa(depth))
+	}
+	_, err := Cmd(ctx, args...)
+	return err
+}
+
+// ResolveAncestor resolves rev (a commit, branch or "refs/tags/<tag>") to a commit
+// hash and verifies that it is an ancestor of HEAD, so that diffing against it covers
+// exactly the commits since it, as for a release delta.
+func ResolveAncestor(ctx context.Context, rev string) (string, error) {
+	commit, err := Cmd(ctx, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		if ctx.Err() != nil || errors.Is(err, ErrTimeout) {
+			return "", err
+		}
+		return "", errors.New("unknown revision")
+	}
+	if _, err := Cmd(ctx, "merge-base", "--is-ancestor", commit, "HEAD"); err != nil {
+		if ctx.Err() != nil || errors.Is(err, ErrTimeout) {
+			return "", err
+		}
+		return "", fmt.Errorf("%s is not an ancestor of HEAD", commit)
+	}
+	return commit, nil
+}
+
+// Parents returns the parent commits of rev, first parent first. A root commit, or
+// the boundary commit of a shallow clone, has none.
+func Parents(ctx context.Context, rev string) ([]string, error) {
+	out, err := Cmd(ctx, "rev-list", "--parents", "-n", "1", rev)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(out)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("unknown revision %s", rev)
+	}
+	return fields[1:], nil
+}
+
+// CommitCount returns the number of commits reachable from HEAD but not from commit.
+func CommitCount(ctx context.Context, commit string) (int, error) {
+	out, err := Cmd(ctx, "rev-list", "--count", commit+"..HEAD")
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(out)
+}
+
 // ShowFile returns the content of a file at a specific commit.
-// Returns empty string and no error if the file didn't exist at that commit.
-func ShowFile(commit string, path string) (string, error) {
-	cmd := exec.Command("git", "show", commit+":"+path)
-	out, err := cmd.CombinedOutput()
+// Returns empty string and no error if the file didn't exist at that commit; an error
+// means git could not be asked (cancelled, timed out).
+func ShowFile(ctx context.Context, commit string, path string) (string, error) {
+	if FixtureDir != "" {
+		data, err := os.ReadFile(filepath.Join(FixtureDir, path))
+		if err != nil {
+			// File didn't exist at the comparison commit
+			return "", nil
+		}
+		return string(data), nil
+	}
+	span := tracing.Start(nil, "git show", "git.path", path)
+	defer span.End()
+	out, err := run(ctx, "show", commit+":"+path)
 	if err != nil {
+		if ctx.Err() != nil || errors.Is(err, ErrTimeout) {
+			span.SetError(err)
+			return "", err
+		}
 		// File might not exist at this commit — that's fine
 		return "", nil
 	}
@@ -71,8 +278,98 @@ func ShowFile(commit string, path string) (string, error) {
 }
 
 // ChangedFilesSince returns the list of changed file paths since the given commit.
-func ChangedFilesSince(commit string) ([]string, error) {
-	raw, err := Cmd("diff", "--name-only", commit)
+func ChangedFilesSince(ctx context.Context, commit string) ([]string, error) {
+	raw, err := Cmd(ctx, "diff", "--name-only", commit)
+	if err != nil {
+		return nil, err
+	}
+	if raw == "" {
+		return nil, nil
+	}
+	return strings.Split(raw, "\n"), nil
+}
+
+// BinaryFilesSince returns the changed files since the given commit that git diffs as
+// binary (numstat "-"). Replay fixtures have no history, so it is empty for them.
+func BinaryFilesSince(ctx context.Context, commit string) (map[string]bool, error) {
+	if FixtureDir != "" {
+		return nil, nil
+	}
+	raw, err := Cmd(ctx, "diff", "--numstat", "--no-renames", "-z", commit)
+	if err != nil {
+		return nil, err
+	}
+	binary := make(map[string]bool)
+	for _, line := range strings.Split(raw, "\x00") {
+		if path, ok := strings.CutPrefix(line, "-\t-\t"); ok {
+			binary[path] = true
+		}
+	}
+	return binary, nil
+}
+
+// DiffFile returns the zero-context unified diff of a file between commit and the
+// working tree. Replay fixtures have no history, so it is empty for them.
+func DiffFile(ctx context.Context, commit string, path string) (string, error) {
+	if FixtureDir != "" {
+		return "", nil
+	}
+	return Cmd(ctx, "diff", "-U0", commit, "--", path)
+}
+
+// DiffsSince returns the zero-context unified diffs of all files changed between commit
+// and the working tree in a single git call, keyed by path, as DiffFile would return
+// them. Files whose path git quotes are left out. Replay fixtures have no history, so
+// it is empty for them.
+func DiffsSince(ctx context.Context, commit string) (map[string]string, error) {
+	if FixtureDir != "" {
+		return nil, nil
+	}
+	raw, err := Cmd(ctx, "diff", "-U0", "--no-renames", commit)
+	if err != nil {
+		return nil, err
+	}
+	diffs := make(map[string]string)
+	for _, part := range strings.Split("\n"+raw, "\ndiff --git ")[1:] {
+		header, _, _ := strings.Cut(part, "\n")
+		// "a/<path> b/<path>"; both halves are the same path without renames.
+		n := (len(header) - len("a/ b/")) / 2
+		if n <= 0 || !strings.HasPrefix(header, "a/") || header[2+n:5+n] != " b/" || header[2:2+n] != header[5+n:] {
+			continue
+		}
+		diffs[header[2:2+n]] = "diff --git " + part
+	}
+	return diffs, nil
+}
+
+// TrackedFiles returns every file path tracked in the index (repo-relative).
+func TrackedFiles(ctx context.Context) ([]string, error) {
+	raw, err := Cmd(ctx, "ls-files")
+	if err != nil {
+		return nil, err
+	}
+	if raw == "" {
+		return nil, nil
+	}
+	return strings.Split(raw, "\n"), nil
+}
+
+// FilesAt returns every file path in the tree of a commit (repo-relative); for replay
+// fixtures, the files of the comparison tree.
+func FilesAt(ctx context.Context, commit string) ([]string, error) {
+	if FixtureDir != "" {
+		var files []string
+		err := filepath.WalkDir(FixtureDir, func(path string, d os.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+			rel, err := filepath.Rel(FixtureDir, path)
+			files = append(files, filepath.ToSlash(rel))
+			return err
+		})
+		return files, err
+	}
+	raw, err := Cmd(ctx, "ls-tree", "-r", "--name-only", commit)
 	if err != nil {
 		return nil, err
 	}
@@ -81,3 +378,28 @@ func ChangedFilesSince(commit string) ([]string, error) {
 	}
 	return strings.Split(raw, "\n"), nil
 }
+
+// TreeHash returns the hash of the tree (or blob) at path in commit, or an empty
+// string if the path does not exist there. Replay fixtures have no history, so it is
+// always empty for them.
+func TreeHash(ctx context.Context, commit string, path string) (string, error) {
+	if FixtureDir != "" {
+		return "", nil
+	}
+	if _, err := Cmd(ctx, "cat-file", "-e", commit+":"+path); err != nil {
+		if ctx.Err() != nil || errors.Is(err, ErrTimeout) {
+			return "", err
+		}
+		return "", nil
+	}
+	return Cmd(ctx, "rev-parse", commit+":"+path)
+}
+
+// IsClean reports whether path has no uncommitted or untracked changes in the working tree.
+func IsClean(ctx context.Context, path string) (bool, error) {
+	out, err := Cmd(ctx, "status", "--porcelain", "--", path)
+	if err != nil {
+		return false, err
+	}
+	return out == "", nil
+}
@@ -118,6 +118,53 @@ func FindDepChanges(oldLf, newLf *PnpmLockfile, subspace string) map[string]map[
 	return result
 }
 
+// VersionChange is a direct dependency's resolved version before and after a change,
+// without pnpm's peer-dependency suffix (e.g. "1.2.3", not "1.2.3(react@18.2.0)").
+type VersionChange struct {
+	Old string
+	New string
+}
+
+// FindDirectVersionChanges returns, per project folder, the external dependencies whose
+// resolved version changed between the lockfiles (added and removed deps excluded).
+// Importer paths resolve as in FindDepChanges.
+func FindDirectVersionChanges(oldLf, newLf *PnpmLockfile, subspace string) map[string]map[string]VersionChange {
+	if oldLf == nil || newLf == nil {
+		return nil
+	}
+	importerBase := filepath.Join("common", "temp", subspace)
+	result := make(map[string]map[string]VersionChange)
+	for importerPath, newImporter := range newLf.Importers {
+		projectFolder := resolveImporterPath(importerPath, importerBase)
+		if projectFolder == "" {
+			continue
+		}
+		oldDeps := mergeImporterDeps(oldLf.Importers[importerPath])
+		for depName, newRef := range mergeImporterDeps(newImporter) {
+			oldRef, ok := oldDeps[depName]
+			if !ok || strings.HasPrefix(newRef.Version, "link:") || strings.HasPrefix(oldRef.Version, "link:") {
+				continue
+			}
+			oldVersion, newVersion := stripPeerSuffix(oldRef.Version), stripPeerSuffix(newRef.Version)
+			if oldVersion == newVersion {
+				continue
+			}
+			if result[projectFolder] == nil {
+				result[projectFolder] = make(map[string]VersionChange)
+			}
+			result[projectFolder][depName] = VersionChange{Old: oldVersion, New: newVersion}
+		}
+	}
+	return result
+}
+
+func stripPeerSuffix(version string) string {
+	if i := strings.Index(version, "("); i >= 0 {
+		return version[:i]
+	}
+	return version
+}
+
 func resolveImporterPath(importerPath, importerBase string) string {
 	importerPath = strings.Trim(importerPath, "'\"")
 	if importerPath == "." {
@@ -2,20 +2,34 @@ package log
 
 import (
 	"fmt"
+	"io"
 	"os"
 )
 
 var Basic bool
 var Debug bool
 
+// Output receives all log lines. It is stderr unless --log-file is given, so stdout
+// only ever carries the JSON result.
+var Output io.Writer = os.Stderr
+
 func Basicf(format string, args ...interface{}) {
 	if Basic {
-		fmt.Fprintf(os.Stderr, "[BASIC] "+format+"\n", args...)
+		fmt.Fprintf(Output, "[BASIC] "+format+"\n", args...)
 	}
 }
 
 func Debugf(format string, args ...interface{}) {
 	if Debug {
-		fmt.Fprintf(os.Stderr, "[DEBUG] "+format+"\n", args...)
+		fmt.Fprintf(Output, "[DEBUG] "+format+"\n", args...)
+	}
+}
+
+// Recordf writes a machine-parseable debug record, "[DEBUG] @<kind> key=value ...",
+// when debug logging is on. Records carry the package they belong to, so they can be
+// filtered out of interleaved output with grep.
+func Recordf(kind string, format string, args ...interface{}) {
+	if Debug {
+		fmt.Fprintf(Output, "[DEBUG] @"+kind+" "+format+"\n", args...)
 	}
 }
@@ -0,0 +1,250 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PushgatewayURL is the Prometheus Pushgateway base URL (e.g. "http://pushgateway:9091").
+// Metrics are pushed there at the end of a run when set.
+var PushgatewayURL string
+
+// StatsDAddr is the StatsD UDP address (e.g. "127.0.0.1:8125").
+// Metrics are sent there at the end of a run when set.
+var StatsDAddr string
+
+// Job is the Pushgateway job name and the StatsD metric prefix.
+var Job = "goodchanges"
+
+// Enabled reports whether any metrics sink is configured. Callers can skip
+// expensive metric-only bookkeeping when it is false.
+func Enabled() bool {
+	return PushgatewayURL != "" || StatsDAddr != ""
+}
+
+// histogramBuckets are the upper bounds (seconds) used for all duration histograms.
+var histogramBuckets = []float64{0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60, 300}
+
+type histogram struct {
+	counts []uint64 // per bucket in histogramBuckets, non-cumulative
+	sum    float64
+	count  uint64
+}
+
+var (
+	mu         sync.Mutex
+	counters   = make(map[string]float64)
+	gauges     = make(map[string]float64)
+	histograms = make(map[string]*histogram)
+)
+
+// key builds the series identifier from a metric name and label pairs
+// ("phase", "analysis") → `name{phase="analysis"}`.
+func key(name string, labels []string) string {
+	if len(labels) < 2 {
+		return name
+	}
+	var b strings.Builder
+	b.WriteString(name)
+	b.WriteByte('{')
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=%q", labels[i], labels[i+1])
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+// Add increments a counter. labels are name/value pairs.
+func Add(name string, v float64, labels ...string) {
+	mu.Lock()
+	counters[key(name, labels)] += v
+	mu.Unlock()
+}
+
+// Set sets a gauge. labels are name/value pairs.
+func Set(name string, v float64, labels ...string) {
+	mu.Lock()
+	gauges[key(name, labels)] = v
+	mu.Unlock()
+}
+
+// Observe records a duration in seconds into a histogram. labels are name/value pairs.
+func Observe(name string, seconds float64, labels ...string) {
+	mu.Lock()
+	defer mu.Unlock()
+	k := key(name, labels)
+	h := histograms[k]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(histogramBuckets))}
+		histograms[k] = h
+	}
+	for i, le := range histogramBuckets {
+		if seconds <= le {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// Since observes the time elapsed since start into a histogram.
+func Since(name string, start time.Time, labels ...string) {
+	Observe(name, time.Since(start).Seconds(), labels...)
+}
+
+// Push sends all recorded metrics to the configured sinks.
+func Push() error {
+	var errs []string
+	if PushgatewayURL != "" {
+		if err := pushPrometheus(); err != nil {
+			errs = append(errs, fmt.Sprintf("pushgateway: %v", err))
+		}
+	}
+	if StatsDAddr != "" {
+		if err := sendStatsD(); err != nil {
+			errs = append(errs, fmt.Sprintf("statsd: %v", err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// splitKey splits `name{labels}` into name and the label body (without braces).
+func splitKey(k string) (string, string) {
+	if i := strings.IndexByte(k, '{'); i >= 0 {
+		return k[:i], k[i+1 : len(k)-1]
+	}
+	return k, ""
+}
+
+// prometheusText renders all metrics in the Prometheus text exposition format.
+func prometheusText() []byte {
+	mu.Lock()
+	defer mu.Unlock()
+	var b bytes.Buffer
+	typed := make(map[string]bool)
+	writeType := func(name, kind string) {
+		if !typed[name] {
+			fmt.Fprintf(&b, "# TYPE %s %s\n", name, kind)
+			typed[name] = true
+		}
+	}
+	for _, k := range sortedKeys(counters) {
+		name, _ := splitKey(k)
+		writeType(name, "counter")
+		fmt.Fprintf(&b, "%s %g\n", k, counters[k])
+	}
+	for _, k := range sortedKeys(gauges) {
+		name, _ := splitKey(k)
+		writeType(name, "gauge")
+		fmt.Fprintf(&b, "%s %g\n", k, gauges[k])
+	}
+	for _, k := range sortedKeys(histograms) {
+		name, labels := splitKey(k)
+		writeType(name, "histogram")
+		h := histograms[k]
+		prefix := ""
+		if labels != "" {
+			prefix = labels + ","
+		}
+		var cumulative uint64
+		for i, le := range histogramBuckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(&b, "%s_bucket{%sle=\"%g\"} %d\n", name, prefix, le, cumulative)
+		}
+		fmt.Fprintf(&b, "%s_bucket{%sle=\"+Inf\"} %d\n", name, prefix, h.count)
+		suffix := ""
+		if labels != "" {
+			suffix = "{" + labels + "}"
+		}
+		fmt.Fprintf(&b, "%s_sum%s %g\n", name, suffix, h.sum)
+		fmt.Fprintf(&b, "%s_count%s %d\n", name, suffix, h.count)
+	}
+	return b.Bytes()
+}
+
+func pushPrometheus() error {
+	endpoint := strings.TrimSuffix(PushgatewayURL, "/") + "/metrics/job/" + url.PathEscape(Job)
+	req, err := http.NewRequest(http.MethodPut, endpoint, bytes.NewReader(prometheusText()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; version=0.0.4")
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// statsDName converts `goodchanges_name{phase="x"}` to "job.name.x": the Prometheus
+// namespace is replaced by the job prefix and label values become path segments.
+func statsDName(k string) string {
+	name, labels := splitKey(k)
+	parts := []string{Job, strings.TrimPrefix(name, "goodchanges_")}
+	if labels != "" {
+		for _, pair := range strings.Split(labels, ",") {
+			if eq := strings.IndexByte(pair, '='); eq >= 0 {
+				parts = append(parts, strings.Trim(pair[eq+1:], "\""))
+			}
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+func sendStatsD() error {
+	conn, err := net.Dial("udp", StatsDAddr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	mu.Lock()
+	var lines []string
+	for _, k := range sortedKeys(counters) {
+		lines = append(lines, fmt.Sprintf("%s:%g|c", statsDName(k), counters[k]))
+	}
+	for _, k := range sortedKeys(gauges) {
+		lines = append(lines, fmt.Sprintf("%s:%g|g", statsDName(k), gauges[k]))
+	}
+	for _, k := range sortedKeys(histograms) {
+		// StatsD has no histogram type; report the total time as a timer in ms.
+		lines = append(lines, fmt.Sprintf("%s:%g|ms", statsDName(k), histograms[k].sum*1000))
+	}
+	mu.Unlock()
+
+	// One metric per packet keeps every datagram well below typical MTU limits.
+	for _, line := range lines {
+		if _, err := conn.Write([]byte(line)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"goodchanges/internal/log"
+	"goodchanges/internal/metrics"
+)
+
+// URL is the npm registry base URL (NPM_REGISTRY), e.g. a private mirror.
+var URL = "https://registry.npmjs.org"
+
+// CacheDir is the directory of the on-disk version metadata cache
+// (REGISTRY_CACHE_DIR). Published versions are immutable, so entries never expire.
+// Empty disables the disk cache.
+var CacheDir string
+
+// Offline (REGISTRY_OFFLINE) disables network access: version metadata is served
+// from the cache only, and misses and Fetch calls are errors.
+var Offline bool
+
+// VersionMetadata is the subset of a published package version's registry
+// metadata (its package.json plus registry fields) goodchanges uses.
+type VersionMetadata struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+	GitHead string `json:"gitHead,omitempty"` // commit the version was published from, if recorded
+
+	Type        string          `json:"type,omitempty"` // "module" for ESM packages
+	Main        string          `json:"main,omitempty"`
+	Module      string          `json:"module,omitempty"`
+	Types       string          `json:"types,omitempty"`
+	Typings     string          `json:"typings,omitempty"`
+	Exports     json.RawMessage `json:"exports,omitempty"`
+	SideEffects json.RawMessage `json:"sideEffects,omitempty"` // false, true, or a list of globs
+}
+
+// Package formats returned by VersionMetadata.Format.
+const (
+	FormatTypes = "types" // declarations only, nothing to load at runtime
+	FormatESM   = "esm"
+	FormatCJS   = "cjs"
+)
+
+// Format classifies the version as types-only, ESM ("type": "module", a "module"
+// field, or an "import" export condition) or CommonJS.
+//
+// A package is types-only when it is a DefinitelyTyped package (@types/*), its
+// main entry is a declaration file, or every target of its exports map is one.
+// A package without main and exports may still ship an index.js, so "types" alone
+// does not make it types-only.
+func (m *VersionMetadata) Format() string {
+	switch {
+	case strings.HasPrefix(m.Name, "@types/"),
+		isDeclarationFile(m.Main),
+		m.Main == "" && m.Module == "" && exportsOnlyDeclarations(m.Exports):
+		return FormatTypes
+	case m.Type == "module", m.Module != "", exportsHasImportCondition(m.Exports):
+		return FormatESM
+	}
+	return FormatCJS
+}
+
+// SideEffectFree reports whether the version declares "sideEffects": false. A
+// list of globs means some files have side effects and counts as not free.
+func (m *VersionMetadata) SideEffectFree() bool {
+	var v bool
+	return json.Unmarshal(m.SideEffects, &v) == nil && !v
+}
+
+func isDeclarationFile(path string) bool {
+	for _, ext := range []string{".d.ts", ".d.mts", ".d.cts"} {
+		if strings.HasSuffix(path, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// exportsOnlyDeclarations reports whether a package.json exports map is present and
+// all its target paths are declaration files. Null targets (blocked subpaths) are
+// ignored.
+func exportsOnlyDeclarations(exports json.RawMessage) bool {
+	if len(exports) == 0 {
+		return false
+	}
+	var v any
+	if err := json.Unmarshal(exports, &v); err != nil {
+		return false
+	}
+	found := false
+	var walk func(v any) bool
+	walk = func(v any) bool {
+		switch t := v.(type) {
+		case string:
+			found = true
+			return isDeclarationFile(t)
+		case []any:
+			for _, e := range t {
+				if !walk(e) {
+					return false
+				}
+			}
+		case map[string]any:
+			for _, e := range t {
+				if !walk(e) {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return walk(v) && found
+}
+
+// exportsHasImportCondition reports whether a package.json exports map has an
+// "import" condition, i.e. an ESM entry next to (or instead of) a CommonJS one.
+func exportsHasImportCondition(exports json.RawMessage) bool {
+	var v any
+	if len(exports) == 0 || json.Unmarshal(exports, &v) != nil {
+		return false
+	}
+	var walk func(v any) bool
+	walk = func(v any) bool {
+		if m, ok := v.(map[string]any); ok {
+			for k, e := range m {
+				if k == "import" || walk(e) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	return walk(v)
+}
+
+var (
+	memMu    sync.Mutex
+	memCache = make(map[string]*VersionMetadata) // "pkg@version" → metadata
+)
+
+// Version returns the registry metadata of one published version of a package,
+// from the in-memory cache, the disk cache or the registry, in that order.
+func Version(pkg, version string) (*VersionMetadata, error) {
+	key := pkg + "@" + version
+	memMu.Lock()
+	meta, ok := memCache[key]
+	memMu.Unlock()
+	if ok {
+		metrics.Add("goodchanges_registry_lookups_total", 1, "source", "memory")
+		return meta, nil
+	}
+
+	cachePath := ""
+	if CacheDir != "" {
+		// Scoped names keep their "@" but escape the "/", which keeps entries flat.
+		cachePath = filepath.Join(CacheDir, url.PathEscape(pkg)+"@"+url.PathEscape(version)+".json")
+		if data, err := os.ReadFile(cachePath); err == nil {
+			var cached VersionMetadata
+			if err := json.Unmarshal(data, &cached); err == nil {
+				metrics.Add("goodchanges_registry_lookups_total", 1, "source", "disk")
+				return remember(key, &cached), nil
+			}
+			log.Debugf("registry cache: ignoring unreadable %s", cachePath)
+		}
+	}
+	if Offline {
+		return nil, fmt.Errorf("%s is not in the registry cache and REGISTRY_OFFLINE is set", key)
+	}
+
+	data, err := Fetch(strings.TrimSuffix(URL, "/") + "/" + url.PathEscape(pkg) + "/" + url.PathEscape(version))
+	if err != nil {
+		return nil, err
+	}
+	metrics.Add("goodchanges_registry_lookups_total", 1, "source", "network")
+	meta = &VersionMetadata{}
+	if err := json.Unmarshal(data, meta); err != nil {
+		return nil, fmt.Errorf("parsing registry metadata of %s: %w", key, err)
+	}
+	if cachePath != "" {
+		// Store only the fields used, not the full document. A failed write just
+		// means the next run fetches again.
+		if data, err := json.Marshal(meta); err == nil {
+			if err := os.MkdirAll(CacheDir, 0o755); err == nil {
+				err = os.WriteFile(cachePath, data, 0o644)
+			}
+			if err != nil {
+				log.Debugf("registry cache: writing %s: %v", cachePath, err)
+			}
+		}
+	}
+	return remember(key, meta), nil
+}
+
+func remember(key string, meta *VersionMetadata) *VersionMetadata {
+	memMu.Lock()
+	defer memMu.Unlock()
+	memCache[key] = meta
+	return meta
+}
+
+// unreachable holds the first connection error of Fetch per host. Later requests
+// to the host fail with it immediately instead of waiting for another timeout.
+var (
+	unreachableMu sync.Mutex
+	unreachable   = make(map[string]error)
+)
+
+// Fetch GETs a URL and returns the response body. Non-2xx responses are errors.
+func Fetch(rawURL string) ([]byte, error) {
+	if Offline {
+		return nil, errors.New("network access disabled by REGISTRY_OFFLINE")
+	}
+	host := ""
+	if u, err := url.Parse(rawURL); err == nil {
+		host = u.Host
+	}
+	unreachableMu.Lock()
+	err := unreachable[host]
+	unreachableMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Get(rawURL)
+	if err != nil {
+		unreachableMu.Lock()
+		unreachable[host] = err
+		unreachableMu.Unlock()
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("GET %s: unexpected status %s", rawURL, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,191 @@
+package rush
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFileNames are the accepted .goodchangesrc file names, in lookup order. Both
+// JSON variants may contain comments and trailing commas.
+var ConfigFileNames = []string{ConfigFileName, ".goodchangesrc.jsonc", ".goodchangesrc.yaml"}
+
+// IsConfigFileName reports whether name is one of ConfigFileNames.
+func IsConfigFileName(name string) bool {
+	return slices.Contains(ConfigFileNames, name)
+}
+
+// FindConfigFile returns the path of the .goodchangesrc file in dir, or "" if there is
+// none. More than one of ConfigFileNames in the same folder is an error.
+func FindConfigFile(dir string) (string, error) {
+	var found []string
+	for _, name := range ConfigFileNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			found = append(found, path)
+		} else if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	switch len(found) {
+	case 0:
+		return "", nil
+	case 1:
+		return found[0], nil
+	}
+	return "", fmt.Errorf("%s: conflicting config files %s: keep only one", dir, strings.Join(found, ", "))
+}
+
+// configPosition is a 1-based line and column in a config file: the source's own
+// file, or file when the value is inherited from another one.
+type configPosition struct {
+	line, column int
+	file         string
+}
+
+// configSource is the content of a config file as plain JSON, with the position of
+// every value (or object key) in the original file, keyed by JSON path.
+type configSource struct {
+	file string
+	data []byte
+	// byPath is set when data isn't the file's own text (YAML, inherited or nested
+	// configs), so errors are located by JSON path rather than by offset.
+	byPath    bool
+	positions map[string]configPosition
+	// field maps a JSON path of data to the one reported; nil for the identity.
+	field func(path string) string
+}
+
+// errorAt returns a *ConfigError for the JSON path, located via the source positions.
+func (s *configSource) errorAt(path, msg string) *ConfigError {
+	ce := &ConfigError{File: s.file, Field: path, Msg: msg}
+	if s.field != nil {
+		ce.Field = s.field(path)
+	}
+	if pos, ok := s.positions[path]; ok {
+		ce.Line, ce.Column = pos.line, pos.column
+		if pos.file != "" && pos.file != s.file {
+			ce.File = pos.file
+			ce.Msg += " (inherited by " + s.file + ")"
+		}
+	}
+	return ce
+}
+
+// jsonPointerUnescaper undoes the "~0"/"~1" escaping of map keys in the field paths
+// encoding/json reports.
+var jsonPointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// knownPath returns the JSON path, or that of its closest enclosing value when the
+// path has no known position.
+func (s *configSource) knownPath(path string) string {
+	path = jsonPointerUnescaper.Replace(path)
+	for {
+		if _, ok := s.positions[path]; ok {
+			return path
+		}
+		i := strings.LastIndexAny(path, ".[")
+		if i < 0 {
+			return ""
+		}
+		path = path[:i]
+	}
+}
+
+var yamlErrorLineRe = regexp.MustCompile(`^yaml: line (\d+): `)
+
+// newConfigSource converts a JSON, JSONC or YAML config file (by extension) into a
+// configSource. Only YAML syntax errors are returned here; JSON syntax errors are left
+// to decodeConfig.
+func newConfigSource(file string, raw []byte) (*configSource, error) {
+	src := &configSource{file: file, positions: make(map[string]configPosition)}
+	if filepath.Ext(file) != ".yaml" {
+		// Comments and trailing commas are blanked out in place, so offsets into the
+		// stripped data are offsets into the file.
+		src.data = StripJSONCommentsAndTrailingCommas(raw)
+		for path, off := range jsonPathOffsets(src.data) {
+			line, col := offsetToLineCol(src.data, off)
+			src.positions[path] = configPosition{line: line, column: col}
+		}
+		return src, nil
+	}
+
+	src.byPath = true
+	var doc yaml.Node
+	if err := yaml.Unmarshal(raw, &doc); err != nil {
+		ce := &ConfigError{File: file, Msg: err.Error()}
+		if m := yamlErrorLineRe.FindStringSubmatch(ce.Msg); m != nil {
+			ce.Line, _ = strconv.Atoi(m[1])
+			ce.Column = 1
+			ce.Msg = strings.TrimPrefix(ce.Msg, m[0])
+		}
+		return nil, ce
+	}
+	var value any
+	if len(doc.Content) > 0 {
+		var err error
+		if value, err = src.yamlValue(doc.Content[0], ""); err != nil {
+			return nil, err
+		}
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, &ConfigError{File: file, Msg: err.Error()}
+	}
+	src.data = data
+	return src, nil
+}
+
+// yamlValue converts a YAML node into its JSON-compatible value, recording the
+// position of it and its descendants.
+func (s *configSource) yamlValue(n *yaml.Node, path string) (any, error) {
+	if _, ok := s.positions[path]; !ok {
+		s.positions[path] = configPosition{line: n.Line, column: n.Column}
+	}
+	switch n.Kind {
+	case yaml.AliasNode:
+		return s.yamlValue(n.Alias, path)
+	case yaml.MappingNode:
+		m := make(map[string]any, len(n.Content)/2)
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, value := n.Content[i], n.Content[i+1]
+			if key.Kind != yaml.ScalarNode {
+				return nil, &ConfigError{File: s.file, Line: key.Line, Column: key.Column, Field: path, Msg: "object keys must be strings"}
+			}
+			child := key.Value
+			if path != "" {
+				child = path + "." + key.Value
+			}
+			s.positions[child] = configPosition{line: key.Line, column: key.Column}
+			v, err := s.yamlValue(value, child)
+			if err != nil {
+				return nil, err
+			}
+			m[key.Value] = v
+		}
+		return m, nil
+	case yaml.SequenceNode:
+		list := make([]any, 0, len(n.Content))
+		for i, item := range n.Content {
+			v, err := s.yamlValue(item, path+"["+strconv.Itoa(i)+"]")
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	default:
+		var v any
+		if err := n.Decode(&v); err != nil {
+			return nil, &ConfigError{File: s.file, Line: n.Line, Column: n.Column, Field: path, Msg: err.Error()}
+		}
+		return v, nil
+	}
+}
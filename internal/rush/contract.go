@@ -0,0 +1,98 @@
+package rush
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ContractFileName is the per-library export contract file name.
+const ContractFileName = "goodchanges-contract.json"
+
+// ExportContract is a library's goodchanges-contract.json: exports whose behavior the
+// library's own suites test, so that downstream targets trusting contracts
+// (trustContracts) aren't selected for their changes.
+type ExportContract struct {
+	Schema string `json:"$schema,omitempty"`
+	// Stable lists the covered exports: export names (any entrypoint) or
+	// "specifier#name" pairs, as in noisyExports.
+	Stable []string `json:"stable"`
+	// Suites are the output names of the library's targets covering the stable exports.
+	Suites []string `json:"suites,omitempty"`
+}
+
+var knownContractPaths = map[string]bool{
+	"":         true,
+	"$schema":  true,
+	"stable":   true,
+	"stable[]": true,
+	"suites":   true,
+	"suites[]": true,
+}
+
+// Covers reports whether the export name of the given entrypoint specifier of the
+// library is one of its stable exports.
+func (c *ExportContract) Covers(specifier, name string) bool {
+	return c != nil && IsNoisyExport(c.Stable, specifier, name)
+}
+
+// LoadContract reads and validates goodchanges-contract.json from the project folder.
+// Returns nil and no error if the file doesn't exist.
+func LoadContract(projectFolder string) (*ExportContract, error) {
+	path := filepath.Join(projectFolder, ContractFileName)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return parseContract(path, data)
+}
+
+// LoadAllContracts reads goodchanges-contract.json for every project in the config.
+// Returns a map keyed by package name, without the projects that have none. Errors
+// from all invalid contracts are joined into the returned error.
+func LoadAllContracts(config *Config) (map[string]*ExportContract, error) {
+	result := make(map[string]*ExportContract)
+	var errs []error
+	for _, rp := range config.Projects {
+		contract, err := LoadContract(rp.ProjectFolder)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if contract != nil {
+			result[rp.PackageName] = contract
+		}
+	}
+	return result, errors.Join(errs...)
+}
+
+func parseContract(file string, data []byte) (*ExportContract, error) {
+	src, err := newConfigSource(file, data)
+	if err != nil {
+		return nil, err
+	}
+	var contract ExportContract
+	if err := decodeConfig(src, &contract); err != nil {
+		return nil, err
+	}
+	var errs []error
+	report := newConfigReporter(src, &errs)
+	for path := range src.positions {
+		if !knownContractPaths[arrayIndexRe.ReplaceAllString(path, "[]")] {
+			report(path, "unknown field")
+		}
+	}
+	if len(contract.Stable) == 0 {
+		report("", "missing required field \"stable\"")
+	}
+	validateNoisyExports("stable", contract.Stable, report)
+	validateTargetNames("suites", contract.Suites, report)
+	if len(errs) > 0 {
+		return nil, joinConfigErrors(errs)
+	}
+	return &contract, nil
+}
@@ -0,0 +1,263 @@
+package rush
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// ProjectDefaults is an entry of the root projectDefaults: configuration inherited by
+// the projects whose folder matches one of Folders. Inheritance is per field: a field
+// the project's own .goodchangesrc file sets (even to null) replaces the inherited
+// value whole, and of several matching entries, later ones override earlier ones.
+type ProjectDefaults struct {
+	Folders []string `json:"folders"` // project folder globs, e.g. "tools/e2e/*"
+	// Config holds .goodchangesrc fields inherited by the matching projects.
+	Config json.RawMessage `json:"config,omitempty"`
+	// Target holds target fields inherited by each target of the matching projects
+	// that doesn't set them. A project without "targets" gets one target made of it.
+	Target json.RawMessage `json:"target,omitempty"`
+}
+
+// Matches reports whether the project folder matches one of the entry's globs.
+func (d ProjectDefaults) Matches(projectFolder string) bool {
+	for _, g := range d.Folders {
+		if matched, _ := doublestar.Match(g, projectFolder); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// validateProjectDefaults checks the projectDefaults entries of the root config,
+// validating each config as a .goodchangesrc file and each target as one of its targets.
+func validateProjectDefaults(src *configSource, defaults []ProjectDefaults, report func(path, format string, args ...any), errs *[]error) {
+	for i, d := range defaults {
+		prefix := fmt.Sprintf("projectDefaults[%d]", i)
+		if len(d.Folders) == 0 {
+			report(prefix, "missing required field \"folders\"")
+		}
+		validateGlobs(prefix+".folders", d.Folders, report)
+		if d.Config == nil && d.Target == nil {
+			report(prefix, "missing required field \"config\" (or \"target\")")
+		}
+		if sub := nestedConfigSource(src, prefix+".config", d.Config); sub != nil {
+			_, err := parseProjectConfig(sub, "")
+			*errs = append(*errs, configErrors(err)...)
+		}
+		if d.Target != nil {
+			// The target is validated as the only target of a config.
+			sub := nestedConfigSource(src, prefix+".target", d.Target)
+			sub.data = []byte(`{"targets":[` + string(d.Target) + `]}`)
+			positions := map[string]configPosition{"": src.positions[prefix+".target"], "targets": src.positions[prefix+".target"]}
+			for path, pos := range sub.positions {
+				positions["targets[0]"+pathSuffix(path)] = pos
+			}
+			sub.positions = positions
+			sub.field = func(path string) string {
+				if path == "" || path == "targets" {
+					return prefix + ".target"
+				}
+				return prefix + ".target" + strings.TrimPrefix(path, "targets[0]")
+			}
+			_, err := parseProjectConfig(sub, "")
+			*errs = append(*errs, configErrors(err)...)
+		}
+	}
+}
+
+// nestedConfigSource returns the value at the JSON path of src as a source of its
+// own, with paths relative to it, or nil when the value is missing or null.
+func nestedConfigSource(src *configSource, prefix string, data json.RawMessage) *configSource {
+	if data == nil || string(data) == "null" {
+		return nil
+	}
+	sub := &configSource{
+		file:      src.file,
+		data:      data,
+		byPath:    true,
+		positions: make(map[string]configPosition),
+		field: func(path string) string {
+			return prefix + pathSuffix(path)
+		},
+	}
+	for path, pos := range src.positions {
+		if rest, ok := strings.CutPrefix(path, prefix); ok && (rest == "" || rest[0] == '.' || rest[0] == '[') {
+			sub.positions[strings.TrimPrefix(rest, ".")] = pos
+		}
+	}
+	return sub
+}
+
+// pathSuffix returns the JSON path for appending to a parent path: "" for the root,
+// "[0]..." for array items and ".key..." otherwise.
+func pathSuffix(path string) string {
+	if path == "" || path[0] == '[' {
+		return path
+	}
+	return "." + path
+}
+
+// configErrors returns the *ConfigError values of a parse error.
+func configErrors(err error) []error {
+	if err == nil {
+		return nil
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
+
+// inheritDefaults merges the projectDefaults matching the project folder into the
+// project's own config source (nil when the project has no .goodchangesrc file).
+// Returns own unchanged when no entry matches, and nil when there is nothing at all.
+// Inherited values keep the positions of the root config, so errors point there.
+func inheritDefaults(own *configSource, projectFolder string, root *RootConfig) (*configSource, error) {
+	var matched []int
+	if root != nil {
+		for i, d := range root.ProjectDefaults {
+			if d.Matches(projectFolder) {
+				matched = append(matched, i)
+			}
+		}
+	}
+	if len(matched) == 0 {
+		return own, nil
+	}
+	if own != nil {
+		// Configs that aren't a JSON object are left to parseProjectConfig to report.
+		var obj map[string]json.RawMessage
+		if json.Unmarshal(own.data, &obj) != nil {
+			return own, nil
+		}
+	}
+
+	file := filepath.ToSlash(projectFolder)
+	if own != nil {
+		file = own.file
+	}
+	merged := &configSource{file: file, byPath: true, positions: make(map[string]configPosition)}
+	fields := make(map[string]json.RawMessage)
+	// layer sets the fields of a config object, with the positions under prefix of from.
+	layer := func(data []byte, from *configSource, prefix string) error {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(data, &obj); err != nil {
+			return err
+		}
+		if _, ok := merged.positions[""]; !ok || from == own {
+			merged.positions[""] = from.positionOf(prefix)
+		}
+		for key, value := range obj {
+			fields[key] = value
+			dropPositions(merged.positions, key)
+			copyPositions(merged.positions, from, joinPath(prefix, key), key)
+		}
+		return nil
+	}
+	var base map[string]json.RawMessage
+	baseFrom := make(map[string]string) // base target field → its path in the root config
+	for _, i := range matched {
+		d := root.ProjectDefaults[i]
+		prefix := fmt.Sprintf("projectDefaults[%d]", i)
+		if d.Config != nil && string(d.Config) != "null" {
+			if err := layer(d.Config, root.src, prefix+".config"); err != nil {
+				return nil, err
+			}
+		}
+		if d.Target != nil && string(d.Target) != "null" {
+			var target map[string]json.RawMessage
+			if err := json.Unmarshal(d.Target, &target); err != nil {
+				return nil, err
+			}
+			if base == nil {
+				base = make(map[string]json.RawMessage)
+			}
+			for key, value := range target {
+				base[key] = value
+				baseFrom[key] = prefix + ".target." + key
+			}
+			baseFrom[""] = prefix + ".target"
+		}
+	}
+	if own != nil {
+		if err := layer(own.data, own, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	if base != nil {
+		var targets []map[string]json.RawMessage
+		if raw, ok := fields["targets"]; ok && string(raw) != "null" {
+			if err := json.Unmarshal(raw, &targets); err != nil {
+				// Not a list of objects: left to parseProjectConfig to report.
+				targets = nil
+				base = nil
+			}
+		} else {
+			targets = []map[string]json.RawMessage{{}}
+			merged.positions["targets"] = root.src.positionOf(baseFrom[""])
+			merged.positions["targets[0]"] = root.src.positionOf(baseFrom[""])
+		}
+		for j, t := range targets {
+			for key, value := range base {
+				if _, ok := t[key]; ok {
+					continue
+				}
+				t[key] = value
+				copyPositions(merged.positions, root.src, baseFrom[key], fmt.Sprintf("targets[%d].%s", j, key))
+			}
+		}
+		if base != nil {
+			data, err := json.Marshal(targets)
+			if err != nil {
+				return nil, err
+			}
+			fields["targets"] = data
+		}
+	}
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+	merged.data = data
+	return merged, nil
+}
+
+// positionOf returns the position of the JSON path, tagged with the source's file.
+func (s *configSource) positionOf(path string) configPosition {
+	pos := s.positions[path]
+	pos.file = s.file
+	return pos
+}
+
+// copyPositions copies the positions of the value at path from in from, and of its
+// descendants, into positions under the path to.
+func copyPositions(positions map[string]configPosition, from *configSource, path, to string) {
+	for p := range from.positions {
+		if rest, ok := strings.CutPrefix(p, path); ok && (rest == "" || rest[0] == '.' || rest[0] == '[') {
+			positions[to+rest] = from.positionOf(p)
+		}
+	}
+}
+
+// dropPositions removes the positions of the top-level field key and its descendants.
+func dropPositions(positions map[string]configPosition, key string) {
+	for p := range positions {
+		if rest, ok := strings.CutPrefix(p, key); ok && (rest == "" || rest[0] == '.' || rest[0] == '[') {
+			delete(positions, p)
+		}
+	}
+}
+
+// joinPath appends a field to a JSON path.
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
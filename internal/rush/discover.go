@@ -0,0 +1,121 @@
+package rush
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// isProjectGlob reports whether a rush.json project entry stands for generated
+// projects: its projectFolder is a glob, or it sets autoDiscover.
+func isProjectGlob(p Project) bool {
+	return p.AutoDiscover || strings.ContainsAny(p.ProjectFolder, "*?[{")
+}
+
+// HasProjectGlobs reports whether any of the rush.json project entries is a glob.
+func HasProjectGlobs(projects []Project) bool {
+	return slices.ContainsFunc(projects, isProjectGlob)
+}
+
+// expandProjectGlobs replaces the project entries of rush.json whose projectFolder is
+// a glob (e.g. "examples/*"), or that set autoDiscover (standing for "<folder>/*"), with
+// a project for each matching folder under dir containing a package.json, named after
+// it. Expanded projects inherit the entry's shouldPublish, subspaceName and tags;
+// folders listed explicitly keep their own entry. Folders are expanded in path order,
+// and node_modules is never matched.
+func expandProjectGlobs(dir string, projects []Project) ([]Project, error) {
+	fsys := os.DirFS(dir)
+	glob := func(pattern string) ([]string, error) { return doublestar.Glob(fsys, pattern) }
+	read := func(name string) ([]byte, error) { return fs.ReadFile(fsys, name) }
+	return expandProjects(projects, glob, read)
+}
+
+// ExpandProjectGlobsIn expands project globs like LoadConfig, against a list of
+// repo-relative files (e.g. the tree of a commit) whose content read returns.
+func ExpandProjectGlobsIn(projects []Project, files []string, read func(name string) ([]byte, error)) ([]Project, error) {
+	glob := func(pattern string) ([]string, error) {
+		var matches []string
+		for _, f := range files {
+			if matched, _ := doublestar.Match(pattern, f); matched {
+				matches = append(matches, f)
+			}
+		}
+		return matches, nil
+	}
+	return expandProjects(projects, glob, read)
+}
+
+// expandProjects implements expandProjectGlobs with glob listing the files matching a
+// pattern and read returning a file's content.
+func expandProjects(projects []Project, glob func(pattern string) ([]string, error), read func(name string) ([]byte, error)) ([]Project, error) {
+	if !HasProjectGlobs(projects) {
+		return projects, nil
+	}
+	seen := make(map[string]bool, len(projects)) // listed or expanded project folders
+	names := make(map[string]string, len(projects))
+	for _, p := range projects {
+		if !isProjectGlob(p) {
+			seen[p.ProjectFolder] = true
+			names[p.PackageName] = p.ProjectFolder
+		}
+	}
+
+	result := make([]Project, 0, len(projects))
+	for _, p := range projects {
+		if !isProjectGlob(p) {
+			result = append(result, p)
+			continue
+		}
+		pattern := strings.TrimSuffix(p.ProjectFolder, "/")
+		if p.AutoDiscover {
+			pattern = path.Join(pattern, "*")
+		}
+		if !doublestar.ValidatePattern(pattern) {
+			return nil, fmt.Errorf("projectFolder %q: invalid glob", p.ProjectFolder)
+		}
+		if p.PackageName != "" {
+			return nil, fmt.Errorf("projectFolder %q: packageName is not allowed on a project glob, names come from package.json", p.ProjectFolder)
+		}
+		matches, err := glob(pattern + "/package.json")
+		if err != nil {
+			return nil, fmt.Errorf("projectFolder %q: %w", p.ProjectFolder, err)
+		}
+		slices.Sort(matches)
+		for _, m := range matches {
+			folder := path.Dir(m)
+			if seen[folder] || slices.Contains(strings.Split(folder, "/"), "node_modules") {
+				continue
+			}
+			data, err := read(m)
+			if err != nil {
+				return nil, err
+			}
+			var pkg PackageJSON
+			if err := json.Unmarshal(data, &pkg); err != nil {
+				return nil, fmt.Errorf("%s: %w", m, err)
+			}
+			if pkg.Name == "" {
+				return nil, fmt.Errorf("%s: missing \"name\" for a project matched by %q", m, p.ProjectFolder)
+			}
+			if other, ok := names[pkg.Name]; ok {
+				return nil, fmt.Errorf("%s: package %q is already the project in %s", m, pkg.Name, other)
+			}
+			seen[folder] = true
+			names[pkg.Name] = folder
+			result = append(result, Project{
+				PackageName:   pkg.Name,
+				ProjectFolder: folder,
+				ShouldPublish: p.ShouldPublish,
+				SubspaceName:  p.SubspaceName,
+				Tags:          p.Tags,
+			})
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,58 @@
+package rush
+
+import "bytes"
+
+// StripJSONCommentsAndTrailingCommas converts JSON-with-comments (as used by rush.json,
+// api-extractor.json and .goodchangesrc.jsonc) into plain JSON. Comments and trailing
+// commas are blanked out rather than removed, so byte offsets, and thus the line and
+// column of every value, stay those of the original file. Strings are left untouched:
+// "//", "/*" and ",]" inside them are not comments or trailing commas.
+func StripJSONCommentsAndTrailingCommas(data []byte) []byte {
+	out := bytes.Clone(data)
+	blank := func(from, to int) {
+		for i := from; i < to; i++ {
+			if out[i] != '\n' && out[i] != '\r' {
+				out[i] = ' '
+			}
+		}
+	}
+	comma := -1 // offset of the last comma not yet followed by a value
+	for i := 0; i < len(data); i++ {
+		switch c := data[i]; {
+		case c == '"':
+			for i++; i < len(data) && data[i] != '"'; i++ {
+				if data[i] == '\\' {
+					i++
+				}
+			}
+			comma = -1
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			end := len(data)
+			if idx := bytes.IndexByte(data[i:], '\n'); idx >= 0 {
+				end = i + idx
+			}
+			blank(i, end)
+			i = end - 1
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			// An unterminated block comment runs to the end of the file, which then
+			// fails to parse as JSON.
+			end := len(data)
+			if idx := bytes.Index(data[i+2:], []byte("*/")); idx >= 0 {
+				end = i + 2 + idx + 2
+			}
+			blank(i, end)
+			i = end - 1
+		case c == ',':
+			comma = i
+		case c == '}' || c == ']':
+			if comma >= 0 {
+				out[comma] = ' '
+			}
+			comma = -1
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		default:
+			comma = -1
+		}
+	}
+	return out
+}
@@ -0,0 +1,61 @@
+package rush
+
+// ProjectChanges are the structural differences between the projects of two
+// rush.json versions, matched by package name.
+type ProjectChanges struct {
+	Added   []Project // projects only in the new version
+	Removed []Project // projects only in the old version
+	// Moved maps the new folder of each project whose projectFolder changed to its
+	// old folder.
+	Moved map[string]string
+	// SubspaceChanged are the projects (new version) whose subspaceName changed.
+	SubspaceChanged []Project
+}
+
+// Empty reports whether the project lists are the same.
+func (c ProjectChanges) Empty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0 && len(c.Moved) == 0 && len(c.SubspaceChanged) == 0
+}
+
+// DiffProjects compares the projects of an old and a new rush.json. A project keeping
+// its package name is the same project, even in another folder; a renamed package is
+// a removed and an added project.
+func DiffProjects(old, new []Project) ProjectChanges {
+	var changes ProjectChanges
+	oldByName := make(map[string]Project, len(old))
+	for _, p := range old {
+		oldByName[p.PackageName] = p
+	}
+	newNames := make(map[string]bool, len(new))
+	for _, p := range new {
+		newNames[p.PackageName] = true
+		before, ok := oldByName[p.PackageName]
+		if !ok {
+			changes.Added = append(changes.Added, p)
+			continue
+		}
+		if before.ProjectFolder != p.ProjectFolder {
+			if changes.Moved == nil {
+				changes.Moved = make(map[string]string)
+			}
+			changes.Moved[p.ProjectFolder] = before.ProjectFolder
+		}
+		if subspaceOf(before) != subspaceOf(p) {
+			changes.SubspaceChanged = append(changes.SubspaceChanged, p)
+		}
+	}
+	for _, p := range old {
+		if !newNames[p.PackageName] {
+			changes.Removed = append(changes.Removed, p)
+		}
+	}
+	return changes
+}
+
+// subspaceOf returns the project's subspace, "default" when unset.
+func subspaceOf(p Project) string {
+	if p.SubspaceName == "" {
+		return "default"
+	}
+	return p.SubspaceName
+}
@@ -2,11 +2,14 @@ package rush
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
-	"regexp"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/bmatcuk/doublestar/v4"
 )
@@ -17,21 +20,37 @@ type Project struct {
 	ShouldPublish bool     `json:"shouldPublish"`
 	SubspaceName  string   `json:"subspaceName"`
 	Tags          []string `json:"tags"`
+	// AutoDiscover makes every subfolder of ProjectFolder with a package.json a project
+	// (see expandProjectGlobs).
+	AutoDiscover bool `json:"autoDiscover,omitempty"`
 }
 
 type Config struct {
 	Projects []Project `json:"projects"`
+
+	folderIndex     map[string]*Project // project folder → project, built on first ProjectForFile
+	folderIndexOnce sync.Once
 }
 
 type PackageJSON struct {
 	Name            string            `json:"name"`
 	Main            string            `json:"main"`
 	Module          string            `json:"module"`
-	Browser         string            `json:"browser"`
+	Browser         json.RawMessage   `json:"browser"` // a path, or an object of file replacements
 	Types           string            `json:"types"`
+	TypesVersions   json.RawMessage   `json:"typesVersions"`
 	Exports         json.RawMessage   `json:"exports"`
+	SideEffects     json.RawMessage   `json:"sideEffects"`
 	Dependencies    map[string]string `json:"dependencies"`
 	DevDependencies map[string]string `json:"devDependencies"`
+	Scripts         map[string]string `json:"scripts"`
+}
+
+// SideEffectFree reports whether the package declares "sideEffects": false. A list
+// of globs means some files have side effects and counts as not free.
+func (p PackageJSON) SideEffectFree() bool {
+	var v bool
+	return json.Unmarshal(p.SideEffects, &v) == nil && !v
 }
 
 type ProjectInfo struct {
@@ -47,52 +66,111 @@ func LoadConfig(dir string) (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("reading rush.json: %w", err)
 	}
-	cleaned := stripJSONCommentsAndTrailingCommas(data)
+	config, err := ParseConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	projects, err := expandProjectGlobs(dir, config.Projects)
+	if err != nil {
+		return nil, fmt.Errorf("rush.json: %w", err)
+	}
+	config.Projects = projects
+	return config, nil
+}
+
+// ParseConfig parses the content of a rush.json file, leaving project globs unexpanded
+// (see ExpandProjectGlobsIn).
+func ParseConfig(data []byte) (*Config, error) {
 	var config Config
-	if err := json.Unmarshal(cleaned, &config); err != nil {
+	if err := json.Unmarshal(StripJSONCommentsAndTrailingCommas(data), &config); err != nil {
 		return nil, fmt.Errorf("parsing rush.json: %w", err)
 	}
 	return &config, nil
 }
 
+// ProjectForFile returns the project owning a repo-relative file path, or nil if the
+// file is outside every project. When project folders are nested (or one is a prefix
+// of another), the project with the longest matching folder wins, so a file in
+// "libs/a/nested/src" belongs to "libs/a/nested", not "libs/a".
+//
+// Lookups walk up the file's parent directories in a folder index, so mapping a
+// large diff costs O(files × path depth) rather than O(files × projects).
+func (c *Config) ProjectForFile(file string) *Project {
+	c.folderIndexOnce.Do(func() {
+		c.folderIndex = make(map[string]*Project, len(c.Projects))
+		for i := range c.Projects {
+			c.folderIndex[c.Projects[i].ProjectFolder] = &c.Projects[i]
+		}
+	})
+	for dir := path.Dir(file); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		if rp := c.folderIndex[dir]; rp != nil {
+			return rp
+		}
+	}
+	return nil
+}
+
+// ProjectFolders returns the set of all project folders.
+func (c *Config) ProjectFolders() map[string]bool {
+	result := make(map[string]bool, len(c.Projects))
+	for _, rp := range c.Projects {
+		result[rp.ProjectFolder] = true
+	}
+	return result
+}
+
+// FilesByProject groups repo-relative file paths by their owning project folder
+// (see ProjectForFile). Files outside every project are dropped.
+func (c *Config) FilesByProject(files []string) map[string][]string {
+	result := make(map[string][]string)
+	for _, f := range files {
+		if rp := c.ProjectForFile(f); rp != nil {
+			result[rp.ProjectFolder] = append(result[rp.ProjectFolder], f)
+		}
+	}
+	return result
+}
+
 // BuildProjectMap parses each project's package.json and builds the dependency graph.
 func BuildProjectMap(config *Config) map[string]*ProjectInfo {
-	rushPackageSet := make(map[string]bool)
+	names := make([]string, len(config.Projects))
+	for i, p := range config.Projects {
+		names[i] = p.PackageName
+	}
+	return BuildProjectMapFor(config, names)
+}
+
+// BuildProjectMapFor is BuildProjectMap restricted to the seed packages and their
+// transitive workspace dependencies, whose package.json files are read on demand while
+// following dependency edges. Other projects get an entry with only their rush.json
+// data: no package.json fields and no dependency edges. Dependents are therefore only
+// known within the loaded subgraph, which suffices when nothing outside it matters
+// (TARGETS, --scope).
+func BuildProjectMapFor(config *Config, seeds []string) map[string]*ProjectInfo {
+	rushProjects := make(map[string]Project, len(config.Projects)) // built from rush.json only
 	for _, p := range config.Projects {
-		rushPackageSet[p.PackageName] = true
+		rushProjects[p.PackageName] = p
 	}
 
-	projectMap := make(map[string]*ProjectInfo)
-	for _, rp := range config.Projects {
-		pkgPath := filepath.Join(rp.ProjectFolder, "package.json")
-		pkgData, err := os.ReadFile(pkgPath)
-		if err != nil {
-			projectMap[rp.PackageName] = &ProjectInfo{Project: rp}
+	projectMap := make(map[string]*ProjectInfo, len(config.Projects))
+	loaded := make(map[string]bool)
+	queue := append([]string(nil), seeds...)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		rp, ok := rushProjects[name]
+		if !ok || loaded[name] {
 			continue
 		}
-		var pkg PackageJSON
-		if err := json.Unmarshal(pkgData, &pkg); err != nil {
+		loaded[name] = true
+		info := loadProjectInfo(rp, rushProjects)
+		projectMap[name] = info
+		queue = append(queue, info.DependsOn...)
+	}
+	for _, rp := range config.Projects {
+		if projectMap[rp.PackageName] == nil {
 			projectMap[rp.PackageName] = &ProjectInfo{Project: rp}
-			continue
-		}
-
-		info := &ProjectInfo{
-			Project: rp,
-			Package: pkg,
-		}
-
-		for depName, depVersion := range pkg.Dependencies {
-			if strings.HasPrefix(depVersion, "workspace:") && rushPackageSet[depName] {
-				info.DependsOn = append(info.DependsOn, depName)
-			}
 		}
-		for depName, depVersion := range pkg.DevDependencies {
-			if strings.HasPrefix(depVersion, "workspace:") && rushPackageSet[depName] {
-				info.DependsOn = append(info.DependsOn, depName)
-			}
-		}
-
-		projectMap[rp.PackageName] = info
 	}
 
 	// Build reverse edges
@@ -107,10 +185,67 @@ func BuildProjectMap(config *Config) map[string]*ProjectInfo {
 	return projectMap
 }
 
+// loadProjectInfo reads a project's package.json and its workspace dependency edges.
+// An unreadable package.json yields a project without package data or edges.
+func loadProjectInfo(rp Project, rushProjects map[string]Project) *ProjectInfo {
+	pkgPath := filepath.Join(rp.ProjectFolder, "package.json")
+	pkgData, err := os.ReadFile(pkgPath)
+	if err != nil {
+		return &ProjectInfo{Project: rp}
+	}
+	var pkg PackageJSON
+	if err := json.Unmarshal(pkgData, &pkg); err != nil {
+		return &ProjectInfo{Project: rp}
+	}
+
+	info := &ProjectInfo{
+		Project: rp,
+		Package: pkg,
+	}
+
+	for depName, depVersion := range pkg.Dependencies {
+		if _, ok := rushProjects[depName]; ok && strings.HasPrefix(depVersion, "workspace:") {
+			info.DependsOn = append(info.DependsOn, depName)
+		}
+	}
+	for depName, depVersion := range pkg.DevDependencies {
+		if _, ok := rushProjects[depName]; ok && strings.HasPrefix(depVersion, "workspace:") {
+			info.DependsOn = append(info.DependsOn, depName)
+		}
+	}
+	return info
+}
+
 type ChangeDir struct {
 	Glob   string  `json:"glob"`
 	Filter *string `json:"filter,omitempty"` // optional output filter glob (fine-grained only)
 	Type   *string `json:"type,omitempty"`   // nil = normal, "fine-grained"
+	// OnlyPackages and ExceptPackages restrict the upstream taint a target's changeDir
+	// reacts to by package name globs (e.g. "@gooddata/sdk-backend-*"): only taint of
+	// packages matching OnlyPackages (when set) and none of ExceptPackages counts.
+	OnlyPackages   []string `json:"onlyPackages,omitempty"`
+	ExceptPackages []string `json:"exceptPackages,omitempty"`
+}
+
+// FiltersTaint returns true if the changeDir reacts to the taint of some packages only.
+func (cd ChangeDir) FiltersTaint() bool {
+	return len(cd.OnlyPackages) > 0 || len(cd.ExceptPackages) > 0
+}
+
+// ReactsTo reports whether the changeDir reacts to upstream taint of the package.
+func (cd ChangeDir) ReactsTo(pkg string) bool {
+	matches := func(globs []string) bool {
+		for _, g := range globs {
+			if matched, _ := doublestar.Match(g, pkg); matched {
+				return true
+			}
+		}
+		return false
+	}
+	if len(cd.OnlyPackages) > 0 && !matches(cd.OnlyPackages) {
+		return false
+	}
+	return !matches(cd.ExceptPackages)
 }
 
 // IsFineGrained returns true if this changeDir is configured for fine-grained detection.
@@ -119,9 +254,63 @@ func (cd ChangeDir) IsFineGrained() bool {
 }
 
 type TargetDef struct {
-	TargetName *string     `json:"targetName,omitempty"` // custom output name (defaults to package name)
-	ChangeDirs []ChangeDir `json:"changeDirs,omitempty"` // globs to watch (defaults to **/* if empty)
-	Ignores    []string    `json:"ignores,omitempty"`    // per-target ignore globs (additive with global)
+	TargetName       *string     `json:"targetName,omitempty"`       // custom output name (defaults to package name)
+	ChangeDirs       []ChangeDir `json:"changeDirs,omitempty"`       // globs to watch (defaults to **/* if empty)
+	Ignores          []string    `json:"ignores,omitempty"`          // per-target ignore globs (additive with global)
+	Type             *string     `json:"type,omitempty"`             // nil = normal, "storybook", "unit"
+	Command          *string     `json:"command,omitempty"`          // unit-test command of a "unit" target
+	ExternalTriggers []string    `json:"externalTriggers,omitempty"` // repo-relative globs (e.g. Dockerfiles, helm charts) selecting the target
+	AppRoutes        []AppRoute  `json:"appRoutes,omitempty"`        // narrows a tainted app to the specs of its affected areas
+	SpecTags         []SpecTag   `json:"specTags,omitempty"`         // runner tags of tainted upstream packages or exports
+	MinimumRun       *MinimumRun `json:"minimumRun,omitempty"`       // runs the target in a share of runs even when unaffected
+	TrustContracts   bool        `json:"trustContracts,omitempty"`   // ignores taint of exports upstream export contracts cover
+	MaxDetections    *int        `json:"maxDetections,omitempty"`    // more fine-grained detections select the whole target
+}
+
+// MinimumRun selects an unaffected target in at least Percent of runs, to catch
+// detection blind spots. Sample is "rotating" (default): targets are spread over a
+// cycle of 100/Percent days by UTC date, each running on its day; or "commit": each
+// run selects the target with Percent probability, seeded by the HEAD commit.
+type MinimumRun struct {
+	Percent int     `json:"percent"`
+	Sample  *string `json:"sample,omitempty"`
+}
+
+// IsCommitSample reports whether the target is sampled by commit rather than rotated by date.
+func (mr MinimumRun) IsCommitSample() bool {
+	return mr.Sample != nil && *mr.Sample == "commit"
+}
+
+// SpecTag maps upstream taint to the runner tags (Cypress grepTags, Playwright --grep)
+// of the specs covering it. Match is a package, covering all its entrypoints, or a
+// "specifier#name" export.
+type SpecTag struct {
+	Match string   `json:"match"`
+	Tags  []string `json:"tags"`
+}
+
+// AppRoute maps an area of an app the target tests (Sources, globs relative to the
+// app's project folder) to the specs covering it (Specs, globs relative to the
+// target's project folder). App is the app's package name.
+type AppRoute struct {
+	App     string   `json:"app"`
+	Sources []string `json:"sources,omitempty"`
+	// Chunks are globs of the app's lazily loaded chunk names (see
+	// ProjectConfig.BundleStats) covering the area, alternatively or in addition to
+	// Sources.
+	Chunks []string `json:"chunks,omitempty"`
+	Specs  []string `json:"specs"`
+}
+
+// IsStorybook returns true if this target selects affected Storybook stories.
+func (td TargetDef) IsStorybook() bool {
+	return td.Type != nil && *td.Type == "storybook"
+}
+
+// IsUnit returns true if this target runs the library's own unit tests: it is listed
+// in the unit section of the output instead of being selected.
+func (td TargetDef) IsUnit() bool {
+	return td.Type != nil && *td.Type == "unit"
 }
 
 // OutputName returns the target's output name: targetName if set, otherwise the package name.
@@ -133,51 +322,388 @@ func (td TargetDef) OutputName(packageName string) string {
 }
 
 type ProjectConfig struct {
-	Type       *string     `json:"type,omitempty"`       // "library" or "app". When set, overrides automatic inference.
+	Schema     string      `json:"$schema,omitempty"` // optional JSON schema reference for editors; ignored by the tool
+	Type       *string     `json:"type,omitempty"`    // "library" or "app". When set, overrides automatic inference.
 	Targets    []TargetDef `json:"targets,omitempty"`
 	Ignores    []string    `json:"ignores,omitempty"`
 	ChangeDirs []ChangeDir `json:"changeDirs,omitempty"` // global changeDirs: triggers all exports (library) or all targets (app)
+	// NoisyExports lists this library's exports whose taint is not propagated to
+	// downstream packages: export names (any entrypoint) or "specifier#name" pairs.
+	NoisyExports []string `json:"noisyExports,omitempty"`
+	// Generated configures handling of generated code (see GeneratedConfig).
+	Generated *GeneratedConfig `json:"generated,omitempty"`
+	// ConstantTargets routes changes of specific constant exports (e.g. feature-flag
+	// maps) to a dedicated set of targets instead of propagating them downstream.
+	ConstantTargets []ConstantTarget `json:"constantTargets,omitempty"`
+	// Augmentations is how changed `declare global` / `declare module` blocks are
+	// treated with INCLUDE_TYPES: "consumers" (default) or "package".
+	Augmentations *string `json:"augmentations,omitempty"`
+	// SourceDirs map build output directories to the source directories mirroring them,
+	// for resolving package.json entrypoints. They are tried before the built-in
+	// esm/, dist/, lib/ and build/ → src/ mappings.
+	SourceDirs []SourceDirMapping `json:"sourceDirs,omitempty"`
+	// ForceLibraryAnalysis gives an app symbol-level analysis like a library, and makes
+	// fine-grained changeDirs follow imports through all of its source files.
+	ForceLibraryAnalysis bool `json:"forceLibraryAnalysis,omitempty"`
+	// Binaries controls how changed binary files (images, PDF fixtures, Git LFS
+	// objects) are treated (see BinariesConfig).
+	Binaries *BinariesConfig `json:"binaries,omitempty"`
+	// IgnoreHunks are regular expressions for changed lines to disregard (e.g. license
+	// headers), in addition to the repository root's: see RootConfig.IgnoreHunks.
+	IgnoreHunks []string `json:"ignoreHunks,omitempty"`
+	// IgnoreSymbols maps file globs (relative to the project) to top-level symbols whose
+	// changes seed no taint, e.g. version constants bumped on every release commit.
+	IgnoreSymbols map[string][]string `json:"ignoreSymbols,omitempty"`
+	// BundleStats is the app's webpack stats file or vite build manifest (relative to
+	// the project folder), mapping its source modules to emitted chunks for the chunks
+	// of appRoutes.
+	BundleStats string `json:"bundleStats,omitempty"`
+	// MaxPropagationDepth caps how many dependency hops the taint of this package's
+	// changes travels (MAX_PROPAGATION_DEPTH caps it for every package); packages
+	// beyond it are left out of the run and reported as truncated.
+	MaxPropagationDepth *int `json:"maxPropagationDepth,omitempty"`
+}
+
+// IgnoredSymbols returns the ignoreSymbols names of a file (relative to the project
+// root), or nil when none are listed.
+func (pc *ProjectConfig) IgnoredSymbols(relPath string) map[string]bool {
+	if pc == nil {
+		return nil
+	}
+	var ignored map[string]bool
+	for glob, names := range pc.IgnoreSymbols {
+		if matched, _ := doublestar.Match(glob, relPath); !matched {
+			continue
+		}
+		if ignored == nil {
+			ignored = make(map[string]bool)
+		}
+		for _, name := range names {
+			ignored[name] = true
+		}
+	}
+	return ignored
+}
+
+// BinariesConfig controls how changed binary files of a project are treated. Files git
+// diffs as binary and Git LFS pointers are always considered binary.
+type BinariesConfig struct {
+	Globs []string `json:"globs,omitempty"` // additional files to treat as binary
+	// Policy is "trigger" (default: an ordinary changed file, matched by changeDirs),
+	// "asset" (taints the source files importing it) or "ignore".
+	Policy *string `json:"policy,omitempty"`
+}
+
+// BinaryPolicy returns the project's binaries policy, "trigger" when unset.
+func (c *ProjectConfig) BinaryPolicy() string {
+	if c == nil || c.Binaries == nil || c.Binaries.Policy == nil {
+		return "trigger"
+	}
+	return *c.Binaries.Policy
+}
+
+// SourceDirMapping resolves a built file under Build (e.g. "out") to the same path
+// under each of Sources, in order (e.g. ["source", "generated"]).
+type SourceDirMapping struct {
+	Build   string   `json:"build"`
+	Sources []string `json:"sources"`
+}
+
+// ConstantTarget selects Targets (output names, from any project) whenever Export
+// is affected. Export is an export name or a "specifier#name" pair, as in noisyExports.
+type ConstantTarget struct {
+	Export  string   `json:"export"`
+	Targets []string `json:"targets"`
+}
+
+// GeneratedConfig controls how changes to generated files are treated. Files with an
+// @generated marker in their header are always considered generated.
+type GeneratedConfig struct {
+	Globs  []string `json:"globs,omitempty"`  // additional files to treat as generated
+	Policy *string  `json:"policy,omitempty"` // "normalize" (default) or "package"
+	// Specs are repo-relative globs of the OpenAPI/proto specs the package's generated
+	// client is produced from; their changes taint the correlated client exports.
+	Specs []string `json:"specs,omitempty"`
+}
+
+// RootConfig is the optional repository-root .goodchangesrc.json (next to rush.json).
+type RootConfig struct {
+	Schema string `json:"$schema,omitempty"`
+	// NoisyExports applies to every library: bare export names match in any package,
+	// "specifier#name" pairs match one export of one entrypoint.
+	NoisyExports []string `json:"noisyExports,omitempty"`
+	// Detectors declares custom target detectors run as external processes.
+	Detectors []DetectorConfig `json:"detectors,omitempty"`
+	// Contracts maps backend-contract files (API specs, migrations) to targets.
+	Contracts []ContractConfig `json:"contracts,omitempty"`
+	// SiblingPackages narrows lockfile bumps of packages published from sibling
+	// repositories to the exports changed between the versions.
+	SiblingPackages []SiblingConfig `json:"siblingPackages,omitempty"`
+	// Policies post-process the target selection, run as external processes in order.
+	Policies []PolicyConfig `json:"policies,omitempty"`
+	// SkipDirs are directory name globs excluded from source globbing, in addition to
+	// the built-in node_modules, .pnpm, .git, .rush, .heft, temp, dist and esm.
+	SkipDirs []string `json:"skipDirs,omitempty"`
+	// Submodules map git submodule paths, whose pointer bumps have no analyzable
+	// content, to the targets and wrapper packages they affect.
+	Submodules []SubmoduleConfig `json:"submodules,omitempty"`
+	// IgnoreHunks are regular expressions for changed lines to disregard everywhere,
+	// such as copyright headers: a diff hunk whose removed and added lines all match
+	// one of them is ignored before any analysis.
+	IgnoreHunks []string `json:"ignoreHunks,omitempty"`
+	// ProjectDefaults are project configs inherited by the projects in matching
+	// folders (see ProjectDefaults).
+	ProjectDefaults []ProjectDefaults `json:"projectDefaults,omitempty"`
+	// Targets are virtual targets without a project (see RootTarget).
+	Targets []RootTarget `json:"targets,omitempty"`
+
+	src *configSource // positions of inherited projectDefaults values
+}
+
+// SubmoduleConfig selects Targets (output names, from any project) when the submodule
+// at Path (a repo-relative path or glob) is bumped. Packages are the workspace packages
+// wrapping it: they count as changed with all exports tainted, and their targets are
+// selected, as if the submodule were their source.
+type SubmoduleConfig struct {
+	Path     string   `json:"path"`
+	Targets  []string `json:"targets,omitempty"`
+	Packages []string `json:"packages,omitempty"`
+}
+
+// ChangedSubmodules returns the submodules whose path matches a changed file.
+func (rc *RootConfig) ChangedSubmodules(changedFiles []string) []SubmoduleConfig {
+	if rc == nil {
+		return nil
+	}
+	var changed []SubmoduleConfig
+	for _, sm := range rc.Submodules {
+		for _, f := range changedFiles {
+			if matched, _ := doublestar.Match(sm.Path, f); matched {
+				changed = append(changed, sm)
+				break
+			}
+		}
+	}
+	return changed
+}
+
+// SiblingConfig declares external packages (matched by name globs) whose lockfile
+// version bumps taint only the exports changed between the old and new version, as
+// found by diffing their published api-extractor reports.
+type SiblingConfig struct {
+	Packages   []string           `json:"packages"`
+	APIReports []SiblingAPIReport `json:"apiReports"`
+}
+
+// SiblingAPIReport locates the api-extractor report of one entrypoint of a sibling
+// package version. URL is a template; see SiblingURLTokens.
+type SiblingAPIReport struct {
+	Entrypoint string `json:"entrypoint"` // "." or "./subpath"
+	URL        string `json:"url"`
+}
+
+// SiblingURLTokens are the placeholders allowed in SiblingAPIReport.URL. {gitHead} is
+// the commit the version was published from, taken from the npm registry metadata.
+var SiblingURLTokens = []string{"{package}", "{unscopedPackageName}", "{version}", "{gitHead}"}
+
+// SiblingFor returns the sibling config whose package globs match the package name, or nil.
+func (rc *RootConfig) SiblingFor(pkg string) *SiblingConfig {
+	if rc == nil {
+		return nil
+	}
+	for i, sc := range rc.SiblingPackages {
+		for _, g := range sc.Packages {
+			if matched, _ := doublestar.Match(g, pkg); matched {
+				return &rc.SiblingPackages[i]
+			}
+		}
+	}
+	return nil
+}
+
+// ContractConfig declares a backend contract: files matching Paths (repo-relative
+// globs, inside or outside rush projects) select Targets when they change. For OpenAPI
+// and proto files, Endpoints narrows the selection to the targets of changed endpoints.
+type ContractConfig struct {
+	Name      string             `json:"name"`
+	Paths     []string           `json:"paths"`
+	Targets   []string           `json:"targets,omitempty"`
+	Endpoints []ContractEndpoint `json:"endpoints,omitempty"`
+}
+
+// ContractEndpoint selects Targets when a changed endpoint matches the Match glob.
+// Endpoints are OpenAPI paths ("/api/v1/users/{id}") or proto methods
+// ("pkg.Service/Method").
+type ContractEndpoint struct {
+	Match   string   `json:"match"`
+	Targets []string `json:"targets"`
+}
+
+// DetectorConfig declares a custom detector: Command is started once per run and
+// answers which targets it selects (see the "Custom detectors" README section).
+type DetectorConfig struct {
+	Name    string `json:"name"`
+	Command string `json:"command"`
+}
+
+// RootTarget is a virtual target defined in the root config instead of a project's:
+// it is selected when a changed file matches one of its changeDirs, which are
+// repo-relative globs, and by its name (constantTargets, contracts, submodules,
+// minimumRun). As it has no project, import-based detection doesn't apply to it.
+type RootTarget struct {
+	TargetName string      `json:"targetName"`
+	ChangeDirs []ChangeDir `json:"changeDirs"`
+	Ignores    []string    `json:"ignores,omitempty"` // repo-relative ignore globs
+	MinimumRun *MinimumRun `json:"minimumRun,omitempty"`
 }
 
-// LoadProjectConfig reads .goodchangesrc.json from the project folder.
-// Returns nil if the file doesn't exist.
-func LoadProjectConfig(projectFolder string) *ProjectConfig {
-	data, err := os.ReadFile(filepath.Join(projectFolder, ".goodchangesrc.json"))
+// TargetDef returns the root target as a project target definition.
+func (rt RootTarget) TargetDef() TargetDef {
+	return TargetDef{TargetName: &rt.TargetName, ChangeDirs: rt.ChangeDirs, Ignores: rt.Ignores, MinimumRun: rt.MinimumRun}
+}
+
+// PolicyConfig declares a target selection policy: Command is started once per run
+// with the selected targets and answers the final selection (see the "Selection
+// policies" README section).
+type PolicyConfig struct {
+	Name    string `json:"name"`
+	Command string `json:"command"`
+}
+
+// LoadRootConfig reads and validates the .goodchangesrc file (any of ConfigFileNames)
+// from the repository root. Returns nil and no error if there is none.
+func LoadRootConfig(dir string) (*RootConfig, error) {
+	path, err := FindConfigFile(dir)
+	if err != nil || path == "" {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
 	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return parseRootConfig(path, data)
+}
+
+// IsNoisyExport reports whether the export name of the given entrypoint specifier
+// (e.g. "@gooddata/sdk-ui/internal") matches any of the noisyExports entries.
+func IsNoisyExport(entries []string, specifier, name string) bool {
+	for _, e := range entries {
+		if e == name || e == specifier+"#"+name {
+			return true
+		}
+	}
+	return false
+}
+
+// ChangedSpecs returns the changed files (repo-relative) matching generated.specs.
+func (pc *ProjectConfig) ChangedSpecs(changedFiles []string) []string {
+	if pc == nil || pc.Generated == nil {
 		return nil
 	}
-	var cfg ProjectConfig
-	if err := json.Unmarshal(data, &cfg); err != nil {
+	var result []string
+	for _, f := range changedFiles {
+		for _, g := range pc.Generated.Specs {
+			if matched, _ := doublestar.Match(g, f); matched {
+				result = append(result, f)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// ConstantTargetsFor returns the dedicated targets configured for the export name of
+// the given entrypoint specifier, or nil if it has no constantTargets entry.
+func (pc *ProjectConfig) ConstantTargetsFor(specifier, name string) []string {
+	if pc == nil {
 		return nil
 	}
-	return &cfg
+	var targets []string
+	for _, ct := range pc.ConstantTargets {
+		if IsNoisyExport([]string{ct.Export}, specifier, name) {
+			targets = append(targets, ct.Targets...)
+		}
+	}
+	return targets
+}
+
+// LoadProjectConfig reads and validates the .goodchangesrc file (any of ConfigFileNames)
+// from the project folder, merged with the root projectDefaults it inherits. Returns nil
+// and no error if there is neither. Invalid configs return an error listing every
+// problem with file/line context (see ConfigError).
+func LoadProjectConfig(projectFolder string, packageName string) (*ProjectConfig, error) {
+	root, err := LoadRootConfig(".")
+	if err != nil {
+		return nil, err
+	}
+	return loadProjectConfig(projectFolder, packageName, root)
 }
 
-// LoadAllProjectConfigs reads .goodchangesrc.json for every project in the config.
-// Returns a map keyed by project folder. Entries are nil for projects without a config file.
-func LoadAllProjectConfigs(config *Config) map[string]*ProjectConfig {
+func loadProjectConfig(projectFolder string, packageName string, root *RootConfig) (*ProjectConfig, error) {
+	path, err := FindConfigFile(projectFolder)
+	if err != nil {
+		return nil, err
+	}
+	var own *configSource
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		if own, err = newConfigSource(path, data); err != nil {
+			return nil, err
+		}
+	}
+	src, err := inheritDefaults(own, projectFolder, root)
+	if err != nil || src == nil {
+		return nil, err
+	}
+	return parseProjectConfig(src, packageName)
+}
+
+// LoadAllProjectConfigs reads the .goodchangesrc file of every project in the config,
+// merged with the root projectDefaults it inherits. Returns a map keyed by project
+// folder. Entries are nil for projects without a config. Errors from the root config
+// and all invalid configs are joined into the returned error.
+func LoadAllProjectConfigs(config *Config) (map[string]*ProjectConfig, error) {
 	result := make(map[string]*ProjectConfig, len(config.Projects))
+	var errs []error
+	root, err := LoadRootConfig(".")
+	if err != nil {
+		errs = append(errs, err)
+	}
 	for _, rp := range config.Projects {
-		result[rp.ProjectFolder] = LoadProjectConfig(rp.ProjectFolder)
+		cfg, err := loadProjectConfig(rp.ProjectFolder, rp.PackageName, root)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		result[rp.ProjectFolder] = cfg
 	}
-	return result
+	return result, errors.Join(errs...)
 }
 
 // IsIgnored checks if a file path (relative to project root) matches any ignore glob.
-// The config file itself (.goodchangesrc.json) is always ignored.
+// The config file itself (any of ConfigFileNames) and the export contract are always ignored.
 func (pc *ProjectConfig) IsIgnored(relPath string) bool {
-	if relPath == ".goodchangesrc.json" {
+	if IsConfigFileName(relPath) || relPath == ContractFileName {
 		return true
 	}
+	return pc.IgnoringPattern(relPath) != ""
+}
+
+// IgnoringPattern returns the first ignore glob matching a file path (relative to
+// project root), or "" if none does. Unlike IsIgnored, it doesn't cover config files.
+func (pc *ProjectConfig) IgnoringPattern(relPath string) string {
 	if pc == nil {
-		return false
+		return ""
 	}
 	for _, pattern := range pc.Ignores {
 		if matched, _ := doublestar.Match(pattern, relPath); matched {
-			return true
+			return pattern
 		}
 	}
-	return false
+	return ""
 }
 
 // WithTargetIgnores returns a new ProjectConfig with the target's ignores merged in.
@@ -187,8 +713,9 @@ func (pc *ProjectConfig) WithTargetIgnores(td TargetDef) *ProjectConfig {
 		return pc
 	}
 	merged := &ProjectConfig{
-		Targets: pc.Targets,
-		Ignores: make([]string, 0, len(pc.Ignores)+len(td.Ignores)),
+		Targets:       pc.Targets,
+		Ignores:       make([]string, 0, len(pc.Ignores)+len(td.Ignores)),
+		IgnoreSymbols: pc.IgnoreSymbols,
 	}
 	merged.Ignores = append(merged.Ignores, pc.Ignores...)
 	merged.Ignores = append(merged.Ignores, td.Ignores...)
@@ -196,6 +723,7 @@ func (pc *ProjectConfig) WithTargetIgnores(td TargetDef) *ProjectConfig {
 }
 
 // FindChangedProjects determines which projects have files in the changed file list.
+// Each file is attributed to its owning project only (see ProjectForFile).
 // Files matching ignore globs in .goodchangesrc.json are excluded.
 // If relevantPackages is non-nil, only projects in that set are considered.
 func FindChangedProjects(config *Config, projectMap map[string]*ProjectInfo, changedFiles []string, configMap map[string]*ProjectConfig, relevantPackages map[string]bool) map[string]*ProjectInfo {
@@ -204,21 +732,20 @@ func FindChangedProjects(config *Config, projectMap map[string]*ProjectInfo, cha
 		if file == "" {
 			continue
 		}
-		for _, rp := range config.Projects {
-			if strings.HasPrefix(file, rp.ProjectFolder+"/") {
-				if relevantPackages != nil && !relevantPackages[rp.PackageName] {
-					break
-				}
-				relPath := strings.TrimPrefix(file, rp.ProjectFolder+"/")
-				cfg := configMap[rp.ProjectFolder]
-				if cfg.IsIgnored(relPath) {
-					break
-				}
-				if _, exists := result[rp.PackageName]; !exists {
-					result[rp.PackageName] = projectMap[rp.PackageName]
-				}
-				break
-			}
+		rp := config.ProjectForFile(file)
+		if rp == nil {
+			continue
+		}
+		if relevantPackages != nil && !relevantPackages[rp.PackageName] {
+			continue
+		}
+		relPath := strings.TrimPrefix(file, rp.ProjectFolder+"/")
+		cfg := configMap[rp.ProjectFolder]
+		if cfg.IsIgnored(relPath) {
+			continue
+		}
+		if _, exists := result[rp.PackageName]; !exists {
+			result[rp.PackageName] = projectMap[rp.PackageName]
 		}
 	}
 	return result
@@ -278,7 +805,7 @@ func FindTransitiveDependents(projectMap map[string]*ProjectInfo, seeds []string
 
 // TopologicalSort returns packages grouped by level (dependencies first).
 // Level 0 = packages with no dependencies on other packages in the set.
-// Only considers dependencies within the given set.
+// Only considers dependencies within the given set. Packages within a level are sorted by name.
 func TopologicalSort(projectMap map[string]*ProjectInfo, packages map[string]bool) [][]string {
 	inDegree := make(map[string]int)
 	for p := range packages {
@@ -315,6 +842,7 @@ func TopologicalSort(projectMap map[string]*ProjectInfo, packages map[string]boo
 				level = append(level, p)
 			}
 		}
+		sort.Strings(level)
 		levels = append(levels, level)
 		for _, p := range level {
 			delete(remaining, p)
@@ -332,70 +860,3 @@ func TopologicalSort(projectMap map[string]*ProjectInfo, packages map[string]boo
 
 	return levels
 }
-
-func stripJSONCommentsAndTrailingCommas(data []byte) []byte {
-	s := string(data)
-	lines := strings.Split(s, "\n")
-	var result []string
-	inBlockComment := false
-	for _, line := range lines {
-		if inBlockComment {
-			if idx := strings.Index(line, "*/"); idx != -1 {
-				line = line[idx+2:]
-				inBlockComment = false
-			} else {
-				continue
-			}
-		}
-		var cleaned strings.Builder
-		inString := false
-		escaped := false
-		i := 0
-		for i < len(line) {
-			ch := line[i]
-			if escaped {
-				cleaned.WriteByte(ch)
-				escaped = false
-				i++
-				continue
-			}
-			if inString {
-				cleaned.WriteByte(ch)
-				if ch == '\\' {
-					escaped = true
-				} else if ch == '"' {
-					inString = false
-				}
-				i++
-				continue
-			}
-			if ch == '"' {
-				inString = true
-				cleaned.WriteByte(ch)
-				i++
-				continue
-			}
-			if ch == '/' && i+1 < len(line) {
-				if line[i+1] == '/' {
-					break
-				}
-				if line[i+1] == '*' {
-					end := strings.Index(line[i+2:], "*/")
-					if end != -1 {
-						i = i + 2 + end + 2
-						continue
-					}
-					inBlockComment = true
-					break
-				}
-			}
-			cleaned.WriteByte(ch)
-			i++
-		}
-		result = append(result, cleaned.String())
-	}
-	joined := strings.Join(result, "\n")
-	re := regexp.MustCompile(",\\s*([\\]})])")
-	joined = re.ReplaceAllString(joined, "$1")
-	return []byte(joined)
-}
@@ -0,0 +1,46 @@
+package rush
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// TestScripts are the package.json scripts tried, in order, for a project's test
+// command: CI variants first, as "test" often runs in watch mode.
+var TestScripts = []string{"test-ci", "test-once", "test"}
+
+// jestConfigFiles are the Jest config files marking a project testable with plain jest.
+var jestConfigFiles = []string{"jest.config.js", "jest.config.ts", "jest.config.cjs", "jest.config.mjs", "jest.config.json"}
+
+// DiscoverTestCommand returns the command running a project's tests, to be run in its
+// folder with its node_modules/.bin on the PATH like an npm script: the first of
+// TestScripts its package.json defines, otherwise "heft test" for a project with a
+// config/heft.json, or "jest" for one with a Jest config file. Returns "" when none
+// is found.
+func DiscoverTestCommand(projectFolder string) string {
+	if data, err := os.ReadFile(filepath.Join(projectFolder, "package.json")); err == nil {
+		var pkg PackageJSON
+		if json.Unmarshal(data, &pkg) == nil {
+			for _, name := range TestScripts {
+				if script := pkg.Scripts[name]; script != "" {
+					return script
+				}
+			}
+		}
+	}
+	if fileExists(filepath.Join(projectFolder, "config", "heft.json")) {
+		return "heft test"
+	}
+	for _, name := range jestConfigFiles {
+		if fileExists(filepath.Join(projectFolder, name)) {
+			return "jest"
+		}
+	}
+	return ""
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
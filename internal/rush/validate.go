@@ -0,0 +1,751 @@
+package rush
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+)
+
+// ConfigFileName is the default per-project configuration file name; see
+// ConfigFileNames for the accepted variants.
+const ConfigFileName = ".goodchangesrc.json"
+
+// ConfigError is a problem found in a .goodchangesrc.json file.
+// Line and Column are 1-based; zero when the location is unknown.
+type ConfigError struct {
+	File   string
+	Line   int
+	Column int
+	Field  string // JSON path of the offending value (e.g. "targets[0].changeDirs[1].type")
+	Msg    string
+}
+
+func (e *ConfigError) Error() string {
+	var b strings.Builder
+	b.WriteString(e.File)
+	if e.Line > 0 {
+		fmt.Fprintf(&b, ":%d:%d", e.Line, e.Column)
+	}
+	b.WriteString(": ")
+	if e.Field != "" {
+		b.WriteString(e.Field)
+		b.WriteString(": ")
+	}
+	b.WriteString(e.Msg)
+	return b.String()
+}
+
+// knownConfigPaths lists every JSON path allowed by the .goodchangesrc.json schema
+// (see goodchangesrc.schema.json), with array indices normalized to "[]".
+var knownConfigPaths = map[string]bool{
+	"":                                      true,
+	"$schema":                               true,
+	"type":                                  true,
+	"forceLibraryAnalysis":                  true,
+	"bundleStats":                           true,
+	"ignores":                               true,
+	"ignores[]":                             true,
+	"changeDirs":                            true,
+	"changeDirs[]":                          true,
+	"changeDirs[].glob":                     true,
+	"changeDirs[].filter":                   true,
+	"changeDirs[].type":                     true,
+	"targets":                               true,
+	"targets[]":                             true,
+	"targets[].targetName":                  true,
+	"targets[].ignores":                     true,
+	"targets[].ignores[]":                   true,
+	"targets[].changeDirs":                  true,
+	"targets[].changeDirs[]":                true,
+	"targets[].changeDirs[].glob":           true,
+	"targets[].changeDirs[].filter":         true,
+	"targets[].changeDirs[].type":           true,
+	"targets[].changeDirs[].onlyPackages":   true,
+	"targets[].changeDirs[].onlyPackages[]": true,
+	"targets[].changeDirs[].exceptPackages": true,
+	"targets[].changeDirs[].exceptPackages[]": true,
+	"targets[].type":                  true,
+	"targets[].externalTriggers":      true,
+	"targets[].externalTriggers[]":    true,
+	"targets[].appRoutes":             true,
+	"targets[].appRoutes[]":           true,
+	"targets[].appRoutes[].app":       true,
+	"targets[].appRoutes[].sources":   true,
+	"targets[].appRoutes[].sources[]": true,
+	"targets[].appRoutes[].chunks":    true,
+	"targets[].appRoutes[].chunks[]":  true,
+	"targets[].appRoutes[].specs":     true,
+	"targets[].appRoutes[].specs[]":   true,
+	"targets[].specTags":              true,
+	"targets[].specTags[]":            true,
+	"targets[].specTags[].match":      true,
+	"targets[].specTags[].tags":       true,
+	"targets[].specTags[].tags[]":     true,
+	"targets[].trustContracts":        true,
+	"targets[].maxDetections":         true,
+	"targets[].command":               true,
+	"targets[].minimumRun":            true,
+	"targets[].minimumRun.percent":    true,
+	"targets[].minimumRun.sample":     true,
+	"noisyExports":                    true,
+	"noisyExports[]":                  true,
+	"generated":                       true,
+	"generated.globs":                 true,
+	"generated.globs[]":               true,
+	"generated.policy":                true,
+	"generated.specs":                 true,
+	"generated.specs[]":               true,
+	"binaries":                        true,
+	"binaries.globs":                  true,
+	"binaries.globs[]":                true,
+	"binaries.policy":                 true,
+	"ignoreHunks":                     true,
+	"ignoreHunks[]":                   true,
+	"ignoreSymbols":                   true,
+	"maxPropagationDepth":             true,
+	"constantTargets":                 true,
+	"constantTargets[]":               true,
+	"constantTargets[].export":        true,
+	"constantTargets[].targets":       true,
+	"constantTargets[].targets[]":     true,
+	"augmentations":                   true,
+	"sourceDirs":                      true,
+	"sourceDirs[]":                    true,
+	"sourceDirs[].build":              true,
+	"sourceDirs[].sources":            true,
+	"sourceDirs[].sources[]":          true,
+}
+
+// knownRootConfigPaths lists every JSON path allowed in the repository-root
+// .goodchangesrc.json (next to rush.json).
+var knownRootConfigPaths = map[string]bool{
+	"":                                          true,
+	"$schema":                                   true,
+	"noisyExports":                              true,
+	"noisyExports[]":                            true,
+	"detectors":                                 true,
+	"detectors[]":                               true,
+	"detectors[].name":                          true,
+	"detectors[].command":                       true,
+	"policies":                                  true,
+	"policies[]":                                true,
+	"policies[].name":                           true,
+	"policies[].command":                        true,
+	"contracts":                                 true,
+	"contracts[]":                               true,
+	"contracts[].name":                          true,
+	"contracts[].paths":                         true,
+	"contracts[].paths[]":                       true,
+	"contracts[].targets":                       true,
+	"contracts[].targets[]":                     true,
+	"contracts[].endpoints":                     true,
+	"contracts[].endpoints[]":                   true,
+	"contracts[].endpoints[].match":             true,
+	"contracts[].endpoints[].targets":           true,
+	"contracts[].endpoints[].targets[]":         true,
+	"siblingPackages":                           true,
+	"siblingPackages[]":                         true,
+	"siblingPackages[].packages":                true,
+	"siblingPackages[].packages[]":              true,
+	"siblingPackages[].apiReports":              true,
+	"siblingPackages[].apiReports[]":            true,
+	"siblingPackages[].apiReports[].entrypoint": true,
+	"siblingPackages[].apiReports[].url":        true,
+	"skipDirs":                                  true,
+	"skipDirs[]":                                true,
+	"submodules":                                true,
+	"submodules[]":                              true,
+	"submodules[].path":                         true,
+	"submodules[].targets":                      true,
+	"submodules[].targets[]":                    true,
+	"submodules[].packages":                     true,
+	"submodules[].packages[]":                   true,
+	"ignoreHunks":                               true,
+	"ignoreHunks[]":                             true,
+	"targets":                                   true,
+	"targets[]":                                 true,
+	"targets[].targetName":                      true,
+	"targets[].changeDirs":                      true,
+	"targets[].changeDirs[]":                    true,
+	"targets[].changeDirs[].glob":               true,
+	"targets[].ignores":                         true,
+	"targets[].ignores[]":                       true,
+	"targets[].minimumRun":                      true,
+	"targets[].minimumRun.percent":              true,
+	"targets[].minimumRun.sample":               true,
+	"projectDefaults":                           true,
+	"projectDefaults[]":                         true,
+	"projectDefaults[].folders":                 true,
+	"projectDefaults[].folders[]":               true,
+	"projectDefaults[].config":                  true,
+	"projectDefaults[].target":                  true,
+}
+
+var arrayIndexRe = regexp.MustCompile(`\[\d+\]`)
+var goFieldIndexRe = regexp.MustCompile(`\.(\d+)`)
+var urlTokenRe = regexp.MustCompile(`\{[^{}]*\}`)
+
+// parseProjectConfig decodes and validates a project config: a .goodchangesrc file,
+// merged with the projectDefaults it inherits. All problems are returned joined, each
+// as a *ConfigError with file/line context. packageName resolves default target names
+// for the duplicate-name check.
+func parseProjectConfig(src *configSource, packageName string) (*ProjectConfig, error) {
+	var cfg ProjectConfig
+	if err := decodeConfig(src, &cfg); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	report := newConfigReporter(src, &errs)
+
+	for path := range src.positions {
+		normalized := arrayIndexRe.ReplaceAllString(path, "[]")
+		// The removed "app" field (0.23.0) is still tolerated and ignored.
+		if normalized == "app" || strings.HasPrefix(normalized, "app.") || strings.HasPrefix(normalized, "app[") {
+			continue
+		}
+		// ignoreSymbols is keyed by file globs; its entries are checked below.
+		if strings.HasPrefix(normalized, "ignoreSymbols.") {
+			continue
+		}
+		if !knownConfigPaths[normalized] {
+			report(path, "unknown field")
+		}
+	}
+
+	if cfg.Type != nil && *cfg.Type != "library" && *cfg.Type != "app" {
+		report("type", "invalid value %q: must be \"library\" or \"app\"", *cfg.Type)
+	}
+	validateGlobs("ignores", cfg.Ignores, report)
+	validateChangeDirs("changeDirs", cfg.ChangeDirs, report)
+	seenTargets := make(map[string]int)
+	for i, td := range cfg.Targets {
+		prefix := fmt.Sprintf("targets[%d]", i)
+		if td.TargetName != nil && *td.TargetName == "" {
+			report(prefix+".targetName", "must not be empty")
+		}
+		if td.Type != nil && *td.Type != "storybook" && *td.Type != "unit" {
+			report(prefix+".type", "invalid value %q: must be \"storybook\", \"unit\" or omitted", *td.Type)
+		}
+		if td.IsUnit() && (td.Command == nil || strings.TrimSpace(*td.Command) == "") {
+			report(prefix, "missing required field \"command\" of a unit target")
+		} else if !td.IsUnit() && td.Command != nil {
+			report(prefix+".command", "only allowed on unit targets")
+		}
+		// Two targets without targetName both default to the package name.
+		name := td.OutputName(packageName)
+		if first, dup := seenTargets[name]; dup {
+			report(prefix, "duplicate target name %q (also defined by targets[%d])", name, first)
+		} else {
+			seenTargets[name] = i
+		}
+		validateGlobs(prefix+".ignores", td.Ignores, report)
+		validateChangeDirs(prefix+".changeDirs", td.ChangeDirs, report)
+		validateGlobs(prefix+".externalTriggers", td.ExternalTriggers, report)
+		for j, r := range td.AppRoutes {
+			routePrefix := fmt.Sprintf("%s.appRoutes[%d]", prefix, j)
+			if r.App == "" {
+				report(routePrefix, "missing required field \"app\"")
+			}
+			if len(r.Sources) == 0 && len(r.Chunks) == 0 {
+				report(routePrefix, "missing required field \"sources\" or \"chunks\"")
+			}
+			if len(r.Specs) == 0 {
+				report(routePrefix, "missing required field \"specs\"")
+			}
+			validateGlobs(routePrefix+".sources", r.Sources, report)
+			validateGlobs(routePrefix+".chunks", r.Chunks, report)
+			validateGlobs(routePrefix+".specs", r.Specs, report)
+		}
+		validateMinimumRun(prefix+".minimumRun", td.MinimumRun, report)
+		if m := td.MaxDetections; m != nil && *m < 1 {
+			report(prefix+".maxDetections", "invalid value %d: must be at least 1", *m)
+		}
+		for j, st := range td.SpecTags {
+			tagPrefix := fmt.Sprintf("%s.specTags[%d]", prefix, j)
+			if st.Match == "" {
+				report(tagPrefix, "missing required field \"match\"")
+			} else if pkg, name, found := strings.Cut(st.Match, "#"); pkg == "" || (found && (name == "" || strings.Contains(name, "#"))) {
+				report(tagPrefix+".match", "invalid value %q: must be a package or \"specifier#name\"", st.Match)
+			}
+			if len(st.Tags) == 0 {
+				report(tagPrefix, "missing required field \"tags\"")
+			}
+			for k, tag := range st.Tags {
+				if tag == "" {
+					report(fmt.Sprintf("%s.tags[%d]", tagPrefix, k), "must not be empty")
+				}
+			}
+		}
+	}
+	validateNoisyExports("noisyExports", cfg.NoisyExports, report)
+	if cfg.Generated != nil {
+		validateGlobs("generated.globs", cfg.Generated.Globs, report)
+		validateGlobs("generated.specs", cfg.Generated.Specs, report)
+		if p := cfg.Generated.Policy; p != nil && *p != "normalize" && *p != "package" {
+			report("generated.policy", "invalid value %q: must be \"normalize\" or \"package\"", *p)
+		}
+	}
+	if cfg.Binaries != nil {
+		validateGlobs("binaries.globs", cfg.Binaries.Globs, report)
+		if p := cfg.Binaries.Policy; p != nil && *p != "trigger" && *p != "asset" && *p != "ignore" {
+			report("binaries.policy", "invalid value %q: must be \"trigger\", \"asset\" or \"ignore\"", *p)
+		}
+	}
+	validateRegexps("ignoreHunks", cfg.IgnoreHunks, report)
+	for glob, names := range cfg.IgnoreSymbols {
+		path := "ignoreSymbols." + glob
+		if !doublestar.ValidatePattern(glob) {
+			report(path, "invalid glob %q", glob)
+		}
+		if len(names) == 0 {
+			report(path, "must list at least one symbol")
+		}
+		for i, name := range names {
+			if name == "" {
+				report(fmt.Sprintf("%s[%d]", path, i), "must not be empty")
+			}
+		}
+	}
+	if d := cfg.MaxPropagationDepth; d != nil && *d < 0 {
+		report("maxPropagationDepth", "invalid value %d: must not be negative", *d)
+	}
+	if a := cfg.Augmentations; a != nil && *a != "consumers" && *a != "package" {
+		report("augmentations", "invalid value %q: must be \"consumers\" or \"package\"", *a)
+	}
+	for i, sd := range cfg.SourceDirs {
+		prefix := fmt.Sprintf("sourceDirs[%d]", i)
+		if sd.Build == "" {
+			report(prefix, "missing required field \"build\"")
+		} else if !isRelativeDir(sd.Build) {
+			report(prefix+".build", "invalid value %q: must be a directory relative to the project folder", sd.Build)
+		}
+		if len(sd.Sources) == 0 {
+			report(prefix, "missing required field \"sources\"")
+		}
+		for j, src := range sd.Sources {
+			if src == "" || !isRelativeDir(src) {
+				report(fmt.Sprintf("%s.sources[%d]", prefix, j), "invalid value %q: must be a directory relative to the project folder", src)
+			}
+		}
+	}
+	for i, ct := range cfg.ConstantTargets {
+		prefix := fmt.Sprintf("constantTargets[%d]", i)
+		if ct.Export == "" {
+			report(prefix, "missing required field \"export\"")
+		} else if !isValidExportRef(ct.Export) {
+			report(prefix+".export", "invalid value %q: must be an export name or \"specifier#name\"", ct.Export)
+		}
+		if len(ct.Targets) == 0 {
+			report(prefix, "missing required field \"targets\"")
+		}
+		validateTargetNames(prefix+".targets", ct.Targets, report)
+	}
+
+	if len(errs) > 0 {
+		return nil, joinConfigErrors(errs)
+	}
+	return &cfg, nil
+}
+
+// parseRootConfig decodes and validates the repository-root .goodchangesrc.json.
+func parseRootConfig(file string, data []byte) (*RootConfig, error) {
+	src, err := newConfigSource(file, data)
+	if err != nil {
+		return nil, err
+	}
+	var cfg RootConfig
+	if err := decodeConfig(src, &cfg); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	report := newConfigReporter(src, &errs)
+	for path := range src.positions {
+		normalized := arrayIndexRe.ReplaceAllString(path, "[]")
+		// projectDefaults configs and targets are checked below.
+		if strings.HasPrefix(normalized, "projectDefaults[].config.") || strings.HasPrefix(normalized, "projectDefaults[].target.") {
+			continue
+		}
+		if !knownRootConfigPaths[normalized] {
+			report(path, "unknown field")
+		}
+	}
+	validateNoisyExports("noisyExports", cfg.NoisyExports, report)
+	validateGlobs("skipDirs", cfg.SkipDirs, report)
+	validateRegexps("ignoreHunks", cfg.IgnoreHunks, report)
+	for i, dir := range cfg.SkipDirs {
+		if strings.Contains(dir, "/") {
+			report(fmt.Sprintf("skipDirs[%d]", i), "invalid value %q: must be a directory name, not a path", dir)
+		}
+	}
+	seenDetectors := make(map[string]int)
+	for i, d := range cfg.Detectors {
+		prefix := fmt.Sprintf("detectors[%d]", i)
+		if d.Name == "" {
+			report(prefix, "missing required field \"name\"")
+		} else if first, dup := seenDetectors[d.Name]; dup {
+			report(prefix+".name", "duplicate detector name %q (also defined by detectors[%d])", d.Name, first)
+		} else {
+			seenDetectors[d.Name] = i
+		}
+		if strings.TrimSpace(d.Command) == "" {
+			report(prefix, "missing required field \"command\"")
+		}
+	}
+	seenPolicies := make(map[string]int)
+	for i, p := range cfg.Policies {
+		prefix := fmt.Sprintf("policies[%d]", i)
+		if p.Name == "" {
+			report(prefix, "missing required field \"name\"")
+		} else if first, dup := seenPolicies[p.Name]; dup {
+			report(prefix+".name", "duplicate policy name %q (also defined by policies[%d])", p.Name, first)
+		} else {
+			seenPolicies[p.Name] = i
+		}
+		if strings.TrimSpace(p.Command) == "" {
+			report(prefix, "missing required field \"command\"")
+		}
+	}
+	seenContracts := make(map[string]int)
+	for i, c := range cfg.Contracts {
+		prefix := fmt.Sprintf("contracts[%d]", i)
+		if c.Name == "" {
+			report(prefix, "missing required field \"name\"")
+		} else if first, dup := seenContracts[c.Name]; dup {
+			report(prefix+".name", "duplicate contract name %q (also defined by contracts[%d])", c.Name, first)
+		} else {
+			seenContracts[c.Name] = i
+		}
+		if len(c.Paths) == 0 {
+			report(prefix, "missing required field \"paths\"")
+		}
+		validateGlobs(prefix+".paths", c.Paths, report)
+		if len(c.Targets) == 0 && len(c.Endpoints) == 0 {
+			report(prefix, "missing required field \"targets\" (or \"endpoints\")")
+		}
+		validateTargetNames(prefix+".targets", c.Targets, report)
+		for j, e := range c.Endpoints {
+			endpointPrefix := fmt.Sprintf("%s.endpoints[%d]", prefix, j)
+			if e.Match == "" {
+				report(endpointPrefix, "missing required field \"match\"")
+			} else if !doublestar.ValidatePattern(e.Match) {
+				report(endpointPrefix+".match", "invalid glob %q", e.Match)
+			}
+			if len(e.Targets) == 0 {
+				report(endpointPrefix, "missing required field \"targets\"")
+			}
+			validateTargetNames(endpointPrefix+".targets", e.Targets, report)
+		}
+	}
+	for i, sm := range cfg.Submodules {
+		prefix := fmt.Sprintf("submodules[%d]", i)
+		if sm.Path == "" {
+			report(prefix, "missing required field \"path\"")
+		} else if !doublestar.ValidatePattern(sm.Path) {
+			report(prefix+".path", "invalid glob %q", sm.Path)
+		}
+		if len(sm.Targets) == 0 && len(sm.Packages) == 0 {
+			report(prefix, "missing required field \"targets\" (or \"packages\")")
+		}
+		validateTargetNames(prefix+".targets", sm.Targets, report)
+		for j, p := range sm.Packages {
+			if p == "" {
+				report(fmt.Sprintf("%s.packages[%d]", prefix, j), "must not be empty")
+			}
+		}
+	}
+	for i, sc := range cfg.SiblingPackages {
+		prefix := fmt.Sprintf("siblingPackages[%d]", i)
+		if len(sc.Packages) == 0 {
+			report(prefix, "missing required field \"packages\"")
+		}
+		validateGlobs(prefix+".packages", sc.Packages, report)
+		if len(sc.APIReports) == 0 {
+			report(prefix, "missing required field \"apiReports\"")
+		}
+		seenEntrypoints := make(map[string]int)
+		for j, ar := range sc.APIReports {
+			reportPrefix := fmt.Sprintf("%s.apiReports[%d]", prefix, j)
+			if ar.Entrypoint == "" {
+				report(reportPrefix, "missing required field \"entrypoint\"")
+			} else if ar.Entrypoint != "." && !strings.HasPrefix(ar.Entrypoint, "./") {
+				report(reportPrefix+".entrypoint", "invalid value %q: must be \".\" or start with \"./\"", ar.Entrypoint)
+			} else if first, dup := seenEntrypoints[ar.Entrypoint]; dup {
+				report(reportPrefix+".entrypoint", "duplicate entrypoint %q (also defined by apiReports[%d])", ar.Entrypoint, first)
+			} else {
+				seenEntrypoints[ar.Entrypoint] = j
+			}
+			if ar.URL == "" {
+				report(reportPrefix, "missing required field \"url\"")
+			}
+			for _, token := range urlTokenRe.FindAllString(ar.URL, -1) {
+				if !slices.Contains(SiblingURLTokens, token) {
+					report(reportPrefix+".url", "unknown placeholder %s: must be one of %s", token, strings.Join(SiblingURLTokens, ", "))
+				}
+			}
+		}
+	}
+
+	seenTargets := make(map[string]int)
+	for i, rt := range cfg.Targets {
+		prefix := fmt.Sprintf("targets[%d]", i)
+		if rt.TargetName == "" {
+			report(prefix, "missing required field \"targetName\"")
+		} else if first, dup := seenTargets[rt.TargetName]; dup {
+			report(prefix+".targetName", "duplicate target name %q (also defined by targets[%d])", rt.TargetName, first)
+		} else {
+			seenTargets[rt.TargetName] = i
+		}
+		if len(rt.ChangeDirs) == 0 {
+			report(prefix, "missing required field \"changeDirs\"")
+		}
+		validateChangeDirs(prefix+".changeDirs", rt.ChangeDirs, report)
+		validateGlobs(prefix+".ignores", rt.Ignores, report)
+		validateMinimumRun(prefix+".minimumRun", rt.MinimumRun, report)
+	}
+	validateProjectDefaults(src, cfg.ProjectDefaults, report, &errs)
+
+	if len(errs) > 0 {
+		return nil, joinConfigErrors(errs)
+	}
+	cfg.src = src
+	return &cfg, nil
+}
+
+// decodeConfig unmarshals a config source into v, converting syntax and type errors
+// into a *ConfigError with file/line context.
+func decodeConfig(src *configSource, v any) error {
+	err := json.Unmarshal(src.data, v)
+	if err == nil {
+		return nil
+	}
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		line, col := offsetToLineCol(src.data, int(syntaxErr.Offset))
+		return &ConfigError{File: src.file, Line: line, Column: col, Msg: syntaxErr.Error()}
+	case errors.As(err, &typeErr):
+		// encoding/json reports "targets.0.targetName"; use the "targets[0].targetName" form.
+		field := goFieldIndexRe.ReplaceAllString(typeErr.Field, "[$1]")
+		msg := fmt.Sprintf("expected %s, got %s", typeErr.Type, typeErr.Value)
+		if src.byPath {
+			// Offsets are into the converted JSON; locate the value by its path instead.
+			ce := src.errorAt(src.knownPath(field), msg)
+			ce.Field = field
+			if src.field != nil {
+				ce.Field = src.field(field)
+			}
+			return ce
+		}
+		line, col := offsetToLineCol(src.data, int(typeErr.Offset))
+		return &ConfigError{File: src.file, Line: line, Column: col, Field: field, Msg: msg}
+	default:
+		return &ConfigError{File: src.file, Msg: err.Error()}
+	}
+}
+
+// newConfigReporter returns a function that appends a *ConfigError for the given
+// JSON path to errs, located via the source positions.
+func newConfigReporter(src *configSource, errs *[]error) func(path, format string, args ...any) {
+	return func(path, format string, args ...any) {
+		*errs = append(*errs, src.errorAt(path, fmt.Sprintf(format, args...)))
+	}
+}
+
+// joinConfigErrors sorts config errors by position and joins them.
+func joinConfigErrors(errs []error) error {
+	sort.SliceStable(errs, func(i, j int) bool {
+		a, b := errs[i].(*ConfigError), errs[j].(*ConfigError)
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return errors.Join(errs...)
+}
+
+// validateNoisyExports checks that entries are either an export name or a
+// "specifier#name" pair with both parts non-empty.
+func validateNoisyExports(path string, entries []string, report func(path, format string, args ...any)) {
+	for i, e := range entries {
+		if !isValidExportRef(e) {
+			report(fmt.Sprintf("%s[%d]", path, i), "invalid entry %q: must be an export name or \"specifier#name\"", e)
+		}
+	}
+}
+
+// isValidExportRef reports whether e is an export name or a "specifier#name" pair
+// with both parts non-empty.
+func isValidExportRef(e string) bool {
+	specifier, name, qualified := strings.Cut(e, "#")
+	if name == "" && !qualified {
+		name = specifier
+	}
+	return name != "" && !(qualified && specifier == "") && !strings.Contains(name, "#")
+}
+
+// isRelativeDir reports whether dir is a path below the project folder ("out",
+// "src/main"): not absolute and without ".." components.
+func isRelativeDir(dir string) bool {
+	if path.IsAbs(dir) {
+		return false
+	}
+	for _, part := range strings.Split(dir, "/") {
+		if part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+func validateGlobs(path string, globs []string, report func(path, format string, args ...any)) {
+	for i, g := range globs {
+		if !doublestar.ValidatePattern(g) {
+			report(fmt.Sprintf("%s[%d]", path, i), "invalid glob %q", g)
+		}
+	}
+}
+
+// validateRegexps reports entries of a list of regular expressions that don't compile.
+func validateRegexps(path string, patterns []string, report func(path, format string, args ...any)) {
+	for i, p := range patterns {
+		if p == "" {
+			report(fmt.Sprintf("%s[%d]", path, i), "must not be empty")
+		} else if _, err := regexp.Compile(p); err != nil {
+			report(fmt.Sprintf("%s[%d]", path, i), "invalid regular expression %q: %v", p, err)
+		}
+	}
+}
+
+// validateTargetNames reports empty entries of a list of target output names.
+func validateTargetNames(path string, names []string, report func(path, format string, args ...any)) {
+	for i, name := range names {
+		if name == "" {
+			report(fmt.Sprintf("%s[%d]", path, i), "must not be empty")
+		}
+	}
+}
+
+func validateMinimumRun(path string, mr *MinimumRun, report func(path, format string, args ...any)) {
+	if mr == nil {
+		return
+	}
+	if mr.Percent < 1 || mr.Percent > 100 {
+		report(path+".percent", "invalid value %d: must be between 1 and 100", mr.Percent)
+	}
+	if mr.Sample != nil && *mr.Sample != "rotating" && *mr.Sample != "commit" {
+		report(path+".sample", "invalid value %q: must be \"rotating\" or \"commit\"", *mr.Sample)
+	}
+}
+
+func validateChangeDirs(path string, changeDirs []ChangeDir, report func(path, format string, args ...any)) {
+	for i, cd := range changeDirs {
+		prefix := fmt.Sprintf("%s[%d]", path, i)
+		if cd.Glob == "" {
+			report(prefix, "missing required field \"glob\"")
+		} else if !doublestar.ValidatePattern(cd.Glob) {
+			report(prefix+".glob", "invalid glob %q", cd.Glob)
+		}
+		if cd.Type != nil && *cd.Type != "fine-grained" {
+			report(prefix+".type", "invalid value %q: must be \"fine-grained\" or omitted", *cd.Type)
+		}
+		validateGlobs(prefix+".onlyPackages", cd.OnlyPackages, report)
+		validateGlobs(prefix+".exceptPackages", cd.ExceptPackages, report)
+		if cd.Filter != nil {
+			if !cd.IsFineGrained() {
+				report(prefix+".filter", "only allowed on fine-grained changeDirs")
+			} else if !doublestar.ValidatePattern(*cd.Filter) {
+				report(prefix+".filter", "invalid glob %q", *cd.Filter)
+			}
+		}
+	}
+}
+
+// jsonPathOffsets walks a JSON document and returns the byte offset at which each
+// value (or object key) starts, keyed by its JSON path (e.g. "targets[0].glob").
+// Returns whatever was indexed before the first syntax error.
+func jsonPathOffsets(data []byte) map[string]int {
+	offsets := make(map[string]int)
+	dec := json.NewDecoder(bytes.NewReader(data))
+
+	var walk func(path string) error
+	walk = func(path string) error {
+		if _, ok := offsets[path]; !ok {
+			offsets[path] = skipJSONSeparators(data, int(dec.InputOffset()))
+		}
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'):
+			for dec.More() {
+				keyStart := skipJSONSeparators(data, int(dec.InputOffset()))
+				keyTok, err := dec.Token()
+				if err != nil {
+					return err
+				}
+				key, _ := keyTok.(string)
+				child := key
+				if path != "" {
+					child = path + "." + key
+				}
+				offsets[child] = keyStart
+				if err := walk(child); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+			return err
+		case json.Delim('['):
+			for i := 0; dec.More(); i++ {
+				if err := walk(path + "[" + strconv.Itoa(i) + "]"); err != nil {
+					return err
+				}
+			}
+			_, err = dec.Token()
+			return err
+		}
+		return nil
+	}
+	_ = walk("")
+	return offsets
+}
+
+// skipJSONSeparators advances past whitespace and the ':' / ',' separators that
+// json.Decoder leaves unconsumed before the next token.
+func skipJSONSeparators(data []byte, off int) int {
+	for off < len(data) {
+		switch data[off] {
+		case ' ', '\t', '\n', '\r', ':', ',':
+			off++
+		default:
+			return off
+		}
+	}
+	return off
+}
+
+// offsetToLineCol converts a byte offset into 1-based line and column numbers.
+func offsetToLineCol(data []byte, off int) (int, int) {
+	if off > len(data) {
+		off = len(data)
+	}
+	line := 1 + bytes.Count(data[:off], []byte("\n"))
+	col := off - bytes.LastIndexByte(data[:off], '\n')
+	return line, col
+}
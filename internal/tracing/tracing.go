@@ -0,0 +1,238 @@
+package tracing
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Endpoint is the OTLP/HTTP traces URL (e.g. "http://collector:4318/v1/traces").
+// Tracing is disabled when empty.
+var Endpoint string
+
+// Headers are extra HTTP headers sent with the export request (e.g. auth tokens).
+var Headers map[string]string
+
+// ServiceName is reported as the service.name resource attribute.
+var ServiceName = "goodchanges"
+
+// Span is a single timed operation. A nil *Span is valid and all methods are no-ops,
+// so call sites don't need to check whether tracing is enabled.
+type Span struct {
+	traceID  string
+	spanID   string
+	parentID string
+	name     string
+	start    time.Time
+	end      time.Time
+	attrs    map[string]string
+	isError  bool
+}
+
+var (
+	mu       sync.Mutex
+	finished []*Span
+	root     *Span
+)
+
+// Enabled reports whether an export endpoint is configured.
+func Enabled() bool {
+	return Endpoint != ""
+}
+
+// StartRun starts the root span of the run. Spans started with a nil parent
+// become its children. traceparent is an optional W3C traceparent header value
+// (e.g. from the TRACEPARENT env var set by CI) that the run joins as a child.
+func StartRun(name string, traceparent string) *Span {
+	if !Enabled() {
+		return nil
+	}
+	s := &Span{name: name, start: time.Now(), spanID: randomHex(8)}
+	if parts := strings.Split(traceparent, "-"); len(parts) == 4 && len(parts[1]) == 32 && len(parts[2]) == 16 {
+		s.traceID = parts[1]
+		s.parentID = parts[2]
+	} else {
+		s.traceID = randomHex(16)
+	}
+	mu.Lock()
+	root = s
+	mu.Unlock()
+	return s
+}
+
+// Start starts a child span of parent, or of the run span when parent is nil.
+// attrs are key/value pairs.
+func Start(parent *Span, name string, attrs ...string) *Span {
+	if !Enabled() {
+		return nil
+	}
+	if parent == nil {
+		mu.Lock()
+		parent = root
+		mu.Unlock()
+	}
+	s := &Span{name: name, start: time.Now(), spanID: randomHex(8)}
+	if parent != nil {
+		s.traceID = parent.traceID
+		s.parentID = parent.spanID
+	} else {
+		s.traceID = randomHex(16)
+	}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		s.SetAttr(attrs[i], attrs[i+1])
+	}
+	return s
+}
+
+// SetAttr sets a string attribute on the span.
+func (s *Span) SetAttr(key, value string) {
+	if s == nil {
+		return
+	}
+	if s.attrs == nil {
+		s.attrs = make(map[string]string)
+	}
+	s.attrs[key] = value
+}
+
+// SetError marks the span as failed with the given error message.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.isError = true
+	s.SetAttr("error.message", err.Error())
+}
+
+// End finishes the span and queues it for export.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.end = time.Now()
+	mu.Lock()
+	finished = append(finished, s)
+	mu.Unlock()
+}
+
+// Flush exports all finished spans to Endpoint in OTLP/HTTP JSON format.
+func Flush() error {
+	if !Enabled() {
+		return nil
+	}
+	mu.Lock()
+	spans := finished
+	finished = nil
+	mu.Unlock()
+	if len(spans) == 0 {
+		return nil
+	}
+
+	type kv struct {
+		Key   string `json:"key"`
+		Value struct {
+			StringValue string `json:"stringValue"`
+		} `json:"value"`
+	}
+	attr := func(k, v string) kv {
+		a := kv{Key: k}
+		a.Value.StringValue = v
+		return a
+	}
+	type status struct {
+		Code int `json:"code"`
+	}
+	type otlpSpan struct {
+		TraceID           string `json:"traceId"`
+		SpanID            string `json:"spanId"`
+		ParentSpanID      string `json:"parentSpanId,omitempty"`
+		Name              string `json:"name"`
+		Kind              int    `json:"kind"`
+		StartTimeUnixNano string `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string `json:"endTimeUnixNano"`
+		Attributes        []kv   `json:"attributes,omitempty"`
+		Status            status `json:"status"`
+	}
+
+	out := make([]otlpSpan, 0, len(spans))
+	for _, s := range spans {
+		sp := otlpSpan{
+			TraceID:           s.traceID,
+			SpanID:            s.spanID,
+			ParentSpanID:      s.parentID,
+			Name:              s.name,
+			Kind:              1, // SPAN_KIND_INTERNAL
+			StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+		}
+		for k, v := range s.attrs {
+			sp.Attributes = append(sp.Attributes, attr(k, v))
+		}
+		if s.isError {
+			sp.Status.Code = 2 // STATUS_CODE_ERROR
+		}
+		out = append(out, sp)
+	}
+
+	payload := map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": []kv{attr("service.name", ServiceName)},
+			},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "goodchanges"},
+				"spans": out,
+			}},
+		}},
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range Headers {
+		req.Header.Set(k, v)
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// ParseHeaders parses the OTEL_EXPORTER_OTLP_HEADERS format ("k1=v1,k2=v2").
+func ParseHeaders(s string) map[string]string {
+	if s == "" {
+		return nil
+	}
+	headers := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if ok {
+			headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return headers
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
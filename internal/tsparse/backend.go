@@ -0,0 +1,185 @@
+package tsparse
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// Backend extracts the imports, exports and top-level declarations of a source file.
+// Every backend must also set FileAnalysis.SourceFile (the tsgo AST), which the AST
+// diff uses to compare symbol bodies and strip type-only constructs.
+type Backend interface {
+	Name() string
+	Parse(content string, filename string) (*FileAnalysis, error)
+}
+
+// backend is the active parser backend, selected by SetBackend.
+var backend Backend = tsgoBackend{}
+
+// SetBackend selects the parser backend by name:
+//   - "tsgo" (default): the vendored TypeScript parser
+//   - "command": an external parser process (e.g. a Node script wrapping swc or
+//     esbuild) started from command, see commandBackend
+func SetBackend(name string, command string) error {
+	switch name {
+	case "", "tsgo":
+		backend = tsgoBackend{}
+	case "command":
+		args := strings.Fields(command)
+		if len(args) == 0 {
+			return fmt.Errorf("parser backend %q requires a command", name)
+		}
+		backend = &commandBackend{args: args}
+	default:
+		return fmt.Errorf("unknown parser backend %q: must be \"tsgo\" or \"command\"", name)
+	}
+	return nil
+}
+
+// CloseBackend stops the external parser process, if one was started.
+func CloseBackend() {
+	if cb, ok := backend.(*commandBackend); ok {
+		cb.close()
+	}
+}
+
+type tsgoBackend struct{}
+
+func (tsgoBackend) Name() string { return "tsgo" }
+
+func (tsgoBackend) Parse(content string, filename string) (*FileAnalysis, error) {
+	return parseTSGo(content, filename), nil
+}
+
+// commandBackend delegates extraction to a long-running external process speaking
+// JSON lines over stdio: one commandRequest per line on stdin, answered by one
+// commandResponse per line on stdout. Requests are serialized.
+//
+// The file is still parsed with tsgo for the AST diff (see Backend); the process's
+// imports, exports, symbols and errors replace tsgo's. If the process fails, the tsgo
+// result is used and the failure is reported as a parse error of the file.
+type commandBackend struct {
+	args []string
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+type commandRequest struct {
+	Filename string `json:"filename"`
+	Content  string `json:"content"`
+}
+
+type commandResponse struct {
+	Imports []Import     `json:"imports"`
+	Exports []Export     `json:"exports"`
+	Symbols []SymbolDecl `json:"symbols"`
+	Errors  []string     `json:"errors"` // "line:col: message"
+}
+
+func (cb *commandBackend) Name() string { return "command" }
+
+func (cb *commandBackend) Parse(content string, filename string) (*FileAnalysis, error) {
+	analysis := parseTSGo(content, filename)
+	resp, err := cb.request(commandRequest{Filename: filename, Content: content})
+	if err != nil {
+		analysis.ParseErrors = append(analysis.ParseErrors, fmt.Sprintf("1:1: parser command %q failed: %v", cb.args[0], err))
+		return analysis, nil
+	}
+
+	// Imports suppressed by a goodchanges-ignore-next-import annotation (detected on
+	// the tsgo AST) stay suppressed; ignore-symbol annotations are re-applied below.
+	suppressedSources := make(map[string]bool)
+	var wholeSuppressed []Import
+	for _, imp := range analysis.SuppressedImports {
+		if len(imp.Names) == 0 {
+			suppressedSources[imp.Source] = true
+			wholeSuppressed = append(wholeSuppressed, imp)
+		}
+	}
+	analysis.Imports = nil
+	for _, imp := range resp.Imports {
+		if !suppressedSources[imp.Source] {
+			analysis.Imports = append(analysis.Imports, imp)
+		}
+	}
+	analysis.SuppressedImports = wholeSuppressed
+	applySuppressionAnnotations(analysis.SourceFile, analysis)
+
+	analysis.Exports = resp.Exports
+	analysis.Symbols = resp.Symbols
+	analysis.ParseErrors = resp.Errors
+	return analysis, nil
+}
+
+// request sends one request to the process (starting it on first use) and reads
+// its response. A failed process is stopped and restarted on the next request.
+func (cb *commandBackend) request(req commandRequest) (*commandResponse, error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	if cb.cmd == nil {
+		if err := cb.start(); err != nil {
+			return nil, err
+		}
+	}
+	data, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := cb.stdin.Write(append(data, '\n')); err != nil {
+		cb.stop()
+		return nil, err
+	}
+	line, err := cb.stdout.ReadBytes('\n')
+	if err != nil {
+		cb.stop()
+		return nil, err
+	}
+	var resp commandResponse
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	return &resp, nil
+}
+
+func (cb *commandBackend) start() error {
+	cmd := exec.Command(cb.args[0], cb.args[1:]...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	cb.cmd, cb.stdin, cb.stdout = cmd, stdin, bufio.NewReader(stdout)
+	return nil
+}
+
+// stop closes the process's stdin and waits for it to exit. Callers hold cb.mu.
+func (cb *commandBackend) stop() {
+	if cb.cmd == nil {
+		return
+	}
+	cb.stdin.Close()
+	cb.cmd.Wait()
+	cb.cmd, cb.stdin, cb.stdout = nil, nil, nil
+}
+
+func (cb *commandBackend) close() {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+	cb.stop()
+}
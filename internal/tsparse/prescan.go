@@ -0,0 +1,356 @@
+package tsparse
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"goodchanges/internal/metrics"
+)
+
+// importWordRe finds the `import` keyword outside the import prologue.
+var importWordRe = regexp.MustCompile(`\bimport\b`)
+
+// ScanImports extracts the static imports of a TypeScript/JavaScript source without
+// parsing it: it reads the leading run of import declarations, re-exports and
+// directives token by token, as ParseContent would report them in Imports. The
+// result is only trusted when ok: any import syntax it doesn't handle, an `import`
+// keyword after the prologue (a late or dynamic import), or a goodchanges annotation
+// makes it give up, and the caller must parse the file instead. Syntax errors are not
+// detected.
+func ScanImports(content string) (imports []Import, ok bool) {
+	if strings.Contains(content, ignoreNextImportAnnotation) || ignoreSymbolRe.MatchString(content) {
+		return nil, false
+	}
+	s := &importScanner{src: content}
+	if strings.HasPrefix(content, "#!") {
+		s.pos = strings.IndexByte(content, '\n')
+		if s.pos < 0 {
+			s.pos = len(content)
+		}
+	}
+	for {
+		start := s.pos
+		tok := s.next()
+		switch {
+		case tok == "":
+			return s.done(imports, start)
+		case tok == "import":
+			imp, ok := s.importDecl()
+			if !ok {
+				return nil, false
+			}
+			imports = append(imports, imp)
+		case tok == "export":
+			if !s.reExport() {
+				return s.done(imports, start)
+			}
+		case isStringToken(tok):
+			s.semicolon() // directive, e.g. "use client"
+		default:
+			return s.done(imports, start)
+		}
+	}
+}
+
+// importScanner tokenizes the import prologue of a source file: identifiers, string
+// literals and single-character punctuators, skipping whitespace and comments.
+type importScanner struct {
+	src string
+	pos int
+}
+
+// done accepts the prologue's imports if the rest of the file from end has no
+// `import` keyword other than import.meta.
+func (s *importScanner) done(imports []Import, end int) ([]Import, bool) {
+	rest := s.src[end:]
+	for _, loc := range importWordRe.FindAllStringIndex(rest, -1) {
+		if !strings.HasPrefix(strings.TrimLeft(rest[loc[1]:], " \t\r\n"), ".") {
+			return nil, false
+		}
+	}
+	metrics.Add("goodchanges_files_prescanned_total", 1)
+	return imports, true
+}
+
+// next returns the next token, or "" at the end of the source or on a character it
+// doesn't tokenize (which ends the prologue).
+func (s *importScanner) next() string {
+	s.skipTrivia()
+	if s.pos >= len(s.src) {
+		return ""
+	}
+	start := s.pos
+	c := s.src[s.pos]
+	switch {
+	case c == '"' || c == '\'':
+		for s.pos++; s.pos < len(s.src); s.pos++ {
+			switch s.src[s.pos] {
+			case '\\':
+				s.pos++
+			case '\n':
+				return ""
+			case c:
+				s.pos++
+				return s.src[start:s.pos]
+			}
+		}
+		return ""
+	case isIdentStart(s.src[s.pos:]):
+		for s.pos < len(s.src) && isIdentPart(s.src[s.pos:]) {
+			_, n := utf8.DecodeRuneInString(s.src[s.pos:])
+			s.pos += n
+		}
+		return s.src[start:s.pos]
+	case strings.IndexByte("{}*,;:", c) >= 0:
+		s.pos++
+		return s.src[start:s.pos]
+	}
+	return ""
+}
+
+func (s *importScanner) peek() string {
+	pos := s.pos
+	tok := s.next()
+	s.pos = pos
+	return tok
+}
+
+func (s *importScanner) skipTrivia() {
+	for s.pos < len(s.src) {
+		switch {
+		case strings.IndexByte(" \t\r\n\f\v", s.src[s.pos]) >= 0:
+			s.pos++
+		case strings.HasPrefix(s.src[s.pos:], "//"):
+			end := strings.IndexByte(s.src[s.pos:], '\n')
+			if end < 0 {
+				s.pos = len(s.src)
+			} else {
+				s.pos += end
+			}
+		case strings.HasPrefix(s.src[s.pos:], "/*"):
+			end := strings.Index(s.src[s.pos+2:], "*/")
+			if end < 0 {
+				s.pos = len(s.src)
+			} else {
+				s.pos += end + 4
+			}
+		default:
+			return
+		}
+	}
+}
+
+// semicolon consumes an optional statement-ending semicolon.
+func (s *importScanner) semicolon() {
+	if s.peek() == ";" {
+		s.next()
+	}
+}
+
+// importDecl reads an import declaration after the `import` keyword.
+func (s *importScanner) importDecl() (Import, bool) {
+	tok := s.next()
+	if isStringToken(tok) {
+		// import "x"
+		return Import{Source: unquote(tok), IsBare: true}, s.attributes()
+	}
+	var imp Import
+	if tok == "type" {
+		switch s.peek() {
+		case "{", "*":
+			imp.IsTypeOnly = true
+			tok = s.next()
+		case "from", "", ",":
+			return imp, false // a default import named type, or unknown syntax
+		default:
+			imp.IsTypeOnly = true
+			tok = s.next()
+		}
+	}
+	if isIdentifier(tok) {
+		// Default import.
+		imp.Names = append(imp.Names, tok)
+		imp.LocalNames = append(imp.LocalNames, tok)
+		if s.peek() != "," {
+			return s.fromClause(imp)
+		}
+		s.next()
+		tok = s.next()
+	}
+	switch tok {
+	case "*":
+		if s.next() != "as" {
+			return imp, false
+		}
+		alias := s.next()
+		if !isIdentifier(alias) {
+			return imp, false
+		}
+		imp.Names = append(imp.Names, "*:"+alias)
+		imp.LocalNames = append(imp.LocalNames, "*:"+alias)
+	case "{":
+		if !s.namedImports(&imp) {
+			return imp, false
+		}
+	default:
+		return imp, false
+	}
+	return s.fromClause(imp)
+}
+
+// namedImports reads `{ a, b as c, type d }` after the opening brace.
+func (s *importScanner) namedImports(imp *Import) bool {
+	hasDefault := len(imp.Names) > 0
+	allTypes := !hasDefault
+	elements := 0
+	for {
+		tok := s.next()
+		if tok == "}" {
+			break
+		}
+		typeOnly := false
+		if tok == "type" {
+			if p := s.peek(); p != "," && p != "}" && p != "as" {
+				typeOnly = true
+				tok = s.next()
+			}
+		}
+		orig := tok
+		switch {
+		case isIdentifier(tok):
+		case isStringToken(tok):
+			orig = unquote(tok)
+		default:
+			return false
+		}
+		local := orig
+		if s.peek() == "as" {
+			s.next()
+			if local = s.next(); !isIdentifier(local) {
+				return false
+			}
+		} else if !isIdentifier(tok) {
+			return false
+		}
+		if typeOnly && imp.TypeOnlyNames == nil {
+			imp.TypeOnlyNames = make([]bool, len(imp.Names), len(imp.Names)+1)
+		}
+		if imp.TypeOnlyNames != nil {
+			imp.TypeOnlyNames = append(imp.TypeOnlyNames, typeOnly)
+		}
+		imp.Names = append(imp.Names, orig)
+		imp.LocalNames = append(imp.LocalNames, local)
+		allTypes = allTypes && typeOnly
+		elements++
+		switch s.next() {
+		case ",":
+		case "}":
+			imp.IsTypeOnly = imp.IsTypeOnly || (allTypes && elements > 0)
+			return true
+		default:
+			return false
+		}
+	}
+	imp.IsTypeOnly = imp.IsTypeOnly || (allTypes && elements > 0)
+	return true
+}
+
+// fromClause reads `from "x"` and completes the import.
+func (s *importScanner) fromClause(imp Import) (Import, bool) {
+	if s.next() != "from" {
+		return imp, false
+	}
+	source := s.next()
+	if !isStringToken(source) {
+		return imp, false
+	}
+	imp.Source = unquote(source)
+	imp.IsBare = len(imp.Names) == 0 && !imp.IsTypeOnly
+	return imp, s.attributes()
+}
+
+// attributes skips import attributes (`with { type: "json" }`) and the semicolon.
+func (s *importScanner) attributes() bool {
+	if p := s.peek(); p == "with" || p == "assert" {
+		s.next()
+		if s.next() != "{" {
+			return false
+		}
+		for {
+			switch s.next() {
+			case "}":
+				s.semicolon()
+				return true
+			case "":
+				return false
+			}
+		}
+	}
+	s.semicolon()
+	return true
+}
+
+// reExport skips an `export ... from "x"` re-export or an `export { a, b }` list
+// after the `export` keyword, reporting false for any other export statement.
+func (s *importScanner) reExport() bool {
+	tok := s.next()
+	if tok == "type" {
+		tok = s.next()
+	}
+	switch tok {
+	case "*":
+		for {
+			switch tok = s.next(); {
+			case tok == "from":
+				if !isStringToken(s.next()) {
+					return false
+				}
+				return s.attributes()
+			case tok == "" || tok == ";" || tok == "{" || tok == "}":
+				return false
+			}
+		}
+	case "{":
+		for {
+			switch tok = s.next(); tok {
+			case "}":
+				if s.peek() == "from" {
+					s.next()
+					if !isStringToken(s.next()) {
+						return false
+					}
+					return s.attributes()
+				}
+				s.semicolon()
+				return true
+			case "", ";", "{", "*":
+				return false
+			}
+		}
+	}
+	return false
+}
+
+func isStringToken(tok string) bool {
+	return tok != "" && (tok[0] == '"' || tok[0] == '\'')
+}
+
+func isIdentifier(tok string) bool {
+	return tok != "" && isIdentStart(tok)
+}
+
+func unquote(tok string) string {
+	return tok[1 : len(tok)-1]
+}
+
+func isIdentStart(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r == '_' || r == '$' || unicode.IsLetter(r)
+}
+
+func isIdentPart(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
@@ -0,0 +1,88 @@
+package tsparse
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestScanImportsMatchesParser(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+	}{
+		{"default and named", `import React, { useState, useEffect as useFx } from "react";` + "\nexport const x = 1;\n"},
+		{"namespace", `import * as path from "path";` + "\nconst a = path.join();\n"},
+		{"default and namespace", `import def, * as ns from "./mod";` + "\n"},
+		{"bare", `import "./polyfill";` + "\nimport './styles.css'\n"},
+		{"type import", `import type { Props } from "./types";` + "\n"},
+		{"type default import", `import type Foo from "./foo";` + "\n"},
+		{"type namespace import", `import type * as T from "./types";` + "\n"},
+		{"type modifiers", `import { type A, B, type C as D } from "./mod";` + "\n"},
+		{"all type modifiers", `import { type A, type B } from "./mod";` + "\n"},
+		{"name type", `import { type } from "./mod";` + "\n"},
+		{"name type aliased", `import { type as as } from "./mod";` + "\n"},
+		{"string specifier", `import { "a-b" as ab } from "./mod";` + "\n"},
+		{"attributes", `import data from "./data.json" with { type: "json" };` + "\n"},
+		{"assert attributes", `import data from "./data.json" assert { type: "json" };` + "\n"},
+		{"empty named", `import {} from "./side";` + "\n"},
+		{"directives", `"use client";` + "\n'use strict'\n" + `import { a } from "./a";` + "\n"},
+		{"shebang", "#!/usr/bin/env node\n" + `import { run } from "./cli";` + "\nrun();\n"},
+		{"comments", "// header\n/* block\n comment */\n" + `import { a } from "./a"; // trailing` + "\n"},
+		{"re-exports", `export * from "./a";` + "\n" + `export { b } from "./b";` + "\n" + `import { c } from "./c";` + "\n"},
+		{"type re-export", `export type { T } from "./t";` + "\n" + `import { c } from "./c";` + "\n"},
+		{"import.meta", `import { a } from "./a";` + "\nconst url = import.meta.url;\n"},
+		{"no semicolons", "import a from \"./a\"\nimport { b } from \"./b\"\nexport const c = a + b\n"},
+		{"no imports", "export const a = 1;\n"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := ScanImports(tc.src)
+			if !ok {
+				t.Fatalf("ScanImports gave up on:\n%s", tc.src)
+			}
+			analysis, err := ParseContent(tc.src, "test.ts")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if g, w := importsJSON(got), importsJSON(analysis.Imports); g != w {
+				t.Errorf("imports differ from the parser\n  scan:  %s\n  parse: %s", g, w)
+			}
+		})
+	}
+}
+
+func TestScanImportsFallsBack(t *testing.T) {
+	cases := []struct {
+		name string
+		src  string
+	}{
+		{"late import", "export const a = 1;\n" + `import { b } from "./b";` + "\n"},
+		{"dynamic import", `import { a } from "./a";` + "\nconst m = await import(\"./lazy\");\n"},
+		{"import equals", `import fs = require("fs");` + "\n"},
+		{"default named type", `import type from "./mod";` + "\n"},
+		{"ignore annotation", "// goodchanges-ignore-next-import\n" + `import { a } from "./a";` + "\n"},
+		{"unterminated string", "import { a } from \"./a\n"},
+		{"type modifier on as", `import { type as } from "./mod";` + "\n"},
+		{"missing from", `import { a } "./a";` + "\n"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if imports, ok := ScanImports(tc.src); ok {
+				t.Errorf("ScanImports accepted %s, want fall back to the parser:\n%s", importsJSON(imports), tc.src)
+			}
+		})
+	}
+}
+
+// importsJSON encodes imports for comparison, treating nil and empty slices alike.
+func importsJSON(imports []Import) string {
+	normalized := make([]Import, len(imports))
+	for i, imp := range imports {
+		if len(imp.Names) == 0 {
+			imp.Names, imp.LocalNames = nil, nil
+		}
+		normalized[i] = imp
+	}
+	data, _ := json.Marshal(normalized)
+	return string(data)
+}
@@ -1,41 +1,63 @@
 package tsparse
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
+	"goodchanges/internal/metrics"
 	"goodchanges/tsgo-vendor/pkg/ast"
 	"goodchanges/tsgo-vendor/pkg/core"
 	"goodchanges/tsgo-vendor/pkg/parser"
+	"goodchanges/tsgo-vendor/pkg/scanner"
 )
 
 type Import struct {
-	Names  []string // imported (source-side) names, or ["*:alias"] for namespace import
+	Names []string `json:"names"` // imported (source-side) names, or ["*:alias"] for namespace import
 	// LocalNames holds the local binding name for each entry in Names (parallel slice).
 	// For a plain `import { X }` LocalNames[i] == Names[i]; for an aliased
 	// `import { X as Y }` Names[i] is "X" (what the source exports) and
 	// LocalNames[i] is "Y" (what this file references in its body).
-	LocalNames []string
-	Source     string // module specifier (e.g., "./Button/Button.js")
+	LocalNames []string `json:"localNames"`
+	Source     string   `json:"source"` // module specifier (e.g., "./Button/Button.js")
+	// IsTypeOnly is set for `import type { X }` and for imports whose named
+	// specifiers are all `type` (`import { type X, type Y }`); these are erased
+	// from the emitted JavaScript.
+	IsTypeOnly bool `json:"isTypeOnly,omitempty"`
+	// TypeOnlyNames marks, parallel to Names, the specifiers written with a `type`
+	// modifier (`import { type X, Y }`). It is nil when no specifier has one.
+	TypeOnlyNames []bool `json:"typeOnlyNames,omitempty"`
+	// IsBare is set for static imports without bindings (`import "./polyfill"`),
+	// which are kept only for their side effects. Dynamic imports with no
+	// captured names also have empty Names but are not bare.
+	IsBare bool `json:"isBare,omitempty"`
+}
+
+// IsTypeOnlyName reports whether the i-th imported name is type-only, through the
+// statement (`import type`) or its own `type` modifier.
+func (imp Import) IsTypeOnlyName(i int) bool {
+	return imp.IsTypeOnly || (i < len(imp.TypeOnlyNames) && imp.TypeOnlyNames[i])
 }
 
 type Export struct {
-	Name       string // exported name (or "default")
-	LocalName  string // local name if aliased, otherwise same as Name
-	Source     string // re-export source (empty if local export)
-	IsTypeOnly bool
-	IsStar     bool // export * from "..."
+	Name       string `json:"name"`      // exported name (or "default")
+	LocalName  string `json:"localName"` // local name if aliased (`export default Button` → "Button"), otherwise same as Name
+	Source     string `json:"source"`    // re-export source (empty if local export)
+	IsTypeOnly bool   `json:"isTypeOnly"`
+	IsStar     bool   `json:"isStar"` // export * from "..."
 }
 
 type SymbolDecl struct {
-	Name       string
-	Kind       string // "function", "class", "interface", "type", "variable", "enum"
-	StartLine  int    // 1-based
-	EndLine    int    // 1-based
-	IsExported bool
-	ExportName string
-	IsTypeOnly bool // true for interface/type declarations
+	Name       string `json:"name"`
+	Kind       string `json:"kind"`      // "function", "class", "interface", "type", "variable", "enum"
+	StartLine  int    `json:"startLine"` // 1-based
+	EndLine    int    `json:"endLine"`   // 1-based
+	IsExported bool   `json:"isExported"`
+	ExportName string `json:"exportName"`
+	IsTypeOnly bool   `json:"isTypeOnly"` // true for interface/type declarations
 }
 
 type FileAnalysis struct {
@@ -44,8 +66,26 @@ type FileAnalysis struct {
 	Exports    []Export
 	Symbols    []SymbolDecl
 	SourceFile *ast.SourceFile
+	// SuppressedImports lists imports (or individual imported names) removed from
+	// Imports by goodchanges annotations, so callers can report them in debug output.
+	SuppressedImports []Import
+	// ParseErrors lists the syntax errors reported by the parser as "line:col: message".
+	// The AST is error-recovered, so symbols and imports around the errors may be missing.
+	ParseErrors []string
 }
 
+// Annotations recognized in source comments to suppress noisy taint edges:
+//
+//	// goodchanges-ignore-next-import
+//	import { log } from "@gooddata/util";        ← whole import is ignored
+//
+//	/* goodchanges: ignore-symbol Button, Icon */  ← these imported names are ignored file-wide
+const (
+	ignoreNextImportAnnotation = "goodchanges-ignore-next-import"
+)
+
+var ignoreSymbolRe = regexp.MustCompile(`goodchanges:\s*ignore-symbol\s+([A-Za-z0-9_$]+(?:\s*,\s*[A-Za-z0-9_$]+)*)`)
+
 func ParseFile(filePath string) (*FileAnalysis, error) {
 	content, err := os.ReadFile(filePath)
 	if err != nil {
@@ -54,9 +94,18 @@ func ParseFile(filePath string) (*FileAnalysis, error) {
 	return ParseContent(string(content), filePath)
 }
 
-// ParseContent parses TypeScript/JavaScript source code from a string.
+// ParseContent parses TypeScript/JavaScript source code from a string with the
+// selected backend (see SetBackend).
 // The filename is used to infer the script kind (TS, TSX, JS, JSX).
 func ParseContent(content string, filename string) (*FileAnalysis, error) {
+	metrics.Add("goodchanges_files_parsed_total", 1)
+	start := time.Now()
+	defer metrics.Since("goodchanges_parse_duration_seconds", start, "backend", backend.Name())
+	return backend.Parse(content, filename)
+}
+
+// parseTSGo parses source code with the vendored TypeScript parser.
+func parseTSGo(content string, filename string) *FileAnalysis {
 	scriptKind := inferScriptKind(filename)
 	absPath := filename
 	if !filepath.IsAbs(filename) {
@@ -83,7 +132,14 @@ func ParseContent(content string, filename string) (*FileAnalysis, error) {
 	// Walk entire AST for dynamic imports: import("specifier")
 	extractDynamicImports(sf, analysis)
 
-	return analysis, nil
+	applySuppressionAnnotations(sf, analysis)
+
+	for _, d := range sf.Diagnostics() {
+		line, col := scanner.GetECMALineAndUTF16CharacterOfPosition(sf, d.Pos())
+		analysis.ParseErrors = append(analysis.ParseErrors, fmt.Sprintf("%d:%d: %s", line+1, int(col)+1, d.String()))
+	}
+
+	return analysis
 }
 
 // ExtractTextForLines returns the text between the given 1-based line numbers.
@@ -131,11 +187,19 @@ func extractImports(stmt *ast.Node, analysis *FileAnalysis) {
 		return
 	}
 	imp := stmt.AsImportDeclaration()
+	if hasLeadingAnnotation(stmt, ignoreNextImportAnnotation) {
+		source := strings.Trim(imp.ModuleSpecifier.Text(), "\"'`")
+		analysis.SuppressedImports = append(analysis.SuppressedImports, Import{Source: source})
+		return
+	}
 	source := strings.Trim(imp.ModuleSpecifier.Text(), "\"'`")
 
 	var names, localNames []string
+	var typeOnlyNames []bool
+	typeOnly := false
 	if imp.ImportClause != nil {
 		clause := imp.ImportClause.AsImportClause()
+		typeOnly = clause.PhaseModifier == ast.KindTypeKeyword
 		if clause.Name() != nil {
 			n := clause.Name().Text()
 			names = append(names, n)
@@ -149,8 +213,16 @@ func extractImports(stmt *ast.Node, analysis *FileAnalysis) {
 			} else if ast.IsNamedImports(clause.NamedBindings) {
 				ni := clause.NamedBindings.AsNamedImports()
 				if ni.Elements != nil {
+					allTypes := clause.Name() == nil && len(ni.Elements.Nodes) > 0
 					for _, spec := range ni.Elements.Nodes {
 						is := spec.AsImportSpecifier()
+						allTypes = allTypes && is.IsTypeOnly
+						if is.IsTypeOnly && typeOnlyNames == nil {
+							typeOnlyNames = make([]bool, len(names), len(names)+len(ni.Elements.Nodes))
+						}
+						if typeOnlyNames != nil {
+							typeOnlyNames = append(typeOnlyNames, is.IsTypeOnly)
+						}
 						// is.Name() is the local binding; is.PropertyName (when present)
 						// is the original name exported by the source module.
 						local := is.Name().Text()
@@ -161,15 +233,19 @@ func extractImports(stmt *ast.Node, analysis *FileAnalysis) {
 						names = append(names, orig)
 						localNames = append(localNames, local)
 					}
+					typeOnly = typeOnly || allTypes
 				}
 			}
 		}
 	}
 
 	analysis.Imports = append(analysis.Imports, Import{
-		Names:      names,
-		LocalNames: localNames,
-		Source:     source,
+		Names:         names,
+		LocalNames:    localNames,
+		Source:        source,
+		IsTypeOnly:    typeOnly,
+		TypeOnlyNames: typeOnlyNames,
+		IsBare:        len(names) == 0 && !typeOnly,
 	})
 }
 
@@ -219,9 +295,15 @@ func extractExports(stmt *ast.Node, analysis *FileAnalysis) {
 		}
 
 	case ast.IsExportAssignment(stmt):
+		// `export default Button;` exports the local binding Button; other expressions
+		// have no binding of their own.
+		localName := "default"
+		if expr := stmt.AsExportAssignment().Expression; expr != nil && ast.IsIdentifier(expr) {
+			localName = expr.Text()
+		}
 		analysis.Exports = append(analysis.Exports, Export{
 			Name:      "default",
-			LocalName: "default",
+			LocalName: localName,
 		})
 
 	default:
@@ -712,3 +794,68 @@ func extractNamesFromThenCallback(callbackNode *ast.Node) []string {
 	}
 	return names
 }
+
+// hasLeadingAnnotation reports whether the comments directly preceding a statement
+// contain the given annotation.
+func hasLeadingAnnotation(stmt *ast.Node, annotation string) bool {
+	sf := ast.GetSourceFileOfNode(stmt)
+	if sf == nil {
+		return false
+	}
+	text := sf.Text()
+	start := scanner.SkipTrivia(text, stmt.Pos())
+	if stmt.Pos() < 0 || start > len(text) {
+		return false
+	}
+	return strings.Contains(text[stmt.Pos():start], annotation)
+}
+
+// applySuppressionAnnotations removes names listed in `goodchanges: ignore-symbol`
+// comments from the file's imports. An import left with no names is dropped entirely
+// (rather than becoming a side-effect import, which would taint the whole file).
+func applySuppressionAnnotations(sf *ast.SourceFile, analysis *FileAnalysis) {
+	ignored := make(map[string]bool)
+	for _, m := range ignoreSymbolRe.FindAllStringSubmatch(sf.Text(), -1) {
+		for _, name := range strings.Split(m[1], ",") {
+			ignored[strings.TrimSpace(name)] = true
+		}
+	}
+	if len(ignored) == 0 {
+		return
+	}
+	kept := analysis.Imports[:0]
+	for _, imp := range analysis.Imports {
+		if len(imp.Names) == 0 {
+			kept = append(kept, imp)
+			continue
+		}
+		var names, localNames, droppedNames []string
+		var typeOnlyNames []bool
+		for i, name := range imp.Names {
+			local := name
+			if i < len(imp.LocalNames) {
+				local = imp.LocalNames[i]
+			}
+			if ignored[name] || ignored[local] {
+				droppedNames = append(droppedNames, name)
+				continue
+			}
+			names = append(names, name)
+			localNames = append(localNames, local)
+			if imp.TypeOnlyNames != nil {
+				typeOnlyNames = append(typeOnlyNames, imp.IsTypeOnlyName(i))
+			}
+		}
+		if len(droppedNames) > 0 {
+			analysis.SuppressedImports = append(analysis.SuppressedImports, Import{
+				Names:      droppedNames,
+				LocalNames: droppedNames,
+				Source:     imp.Source,
+			})
+		}
+		if len(names) > 0 {
+			kept = append(kept, Import{Names: names, LocalNames: localNames, Source: imp.Source, IsTypeOnly: imp.IsTypeOnly, TypeOnlyNames: typeOnlyNames})
+		}
+	}
+	analysis.Imports = kept
+}
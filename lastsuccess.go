@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"goodchanges/internal/git"
+	"goodchanges/internal/log"
+	"goodchanges/internal/tracing"
+)
+
+// flagSinceLastSuccess (--since-last-success) diffs against the commit recorded in
+// --status-file by the last successful run, and records HEAD there once the run
+// succeeds: post-merge pipelines on the main branch then select the targets affected
+// by everything merged since, however many commits that is.
+var flagSinceLastSuccess bool
+
+// flagStatusFile (--status-file) is the file path or http(s) URL of the run status
+// --since-last-success reads and records.
+var flagStatusFile string
+
+// runStatus is the --status-file document.
+type runStatus struct {
+	Commit     string    `json:"commit"`     // HEAD of the last successful run
+	RecordedAt time.Time `json:"recordedAt"` // when it was recorded
+}
+
+// lastSuccessBase returns the commit the last successful run analyzed. Without a
+// recorded run (first run, or the status file was lost), it falls back to HEAD's first
+// parent, like --merge-queue. A recorded commit that is no longer an ancestor of HEAD
+// (rewritten history) is replaced by its merge base with HEAD.
+func lastSuccessBase(ctx context.Context) (string, error) {
+	status, err := readRunStatus(flagStatusFile)
+	if err != nil {
+		return "", fmt.Errorf("reading status file %s: %w", flagStatusFile, err)
+	}
+	if status == nil || status.Commit == "" {
+		fmt.Fprintf(os.Stderr, "Warning: no successful run recorded in %s; diffing against HEAD's first parent\n", flagStatusFile)
+		return mergeQueueBase(ctx)
+	}
+	exists, err := git.RefExists(ctx, status.Commit)
+	if err != nil {
+		return "", err
+	}
+	if !exists {
+		if git.IsShallow(ctx) {
+			return "", fmt.Errorf("last successful commit %s isn't fetched in this shallow clone: fetch more history (git fetch --deepen=<n>)", status.Commit)
+		}
+		return "", fmt.Errorf("last successful commit %s not found", status.Commit)
+	}
+	base, err := git.Cmd(ctx, "merge-base", "HEAD", status.Commit)
+	if err != nil {
+		return "", fmt.Errorf("merge base with last successful commit %s: %w", status.Commit, err)
+	}
+	if !strings.HasPrefix(base, status.Commit) {
+		log.Basicf("Last successful commit %s isn't an ancestor of HEAD (rewritten history?), diffing against the merge base %s", status.Commit, base)
+	} else if !status.RecordedAt.IsZero() {
+		log.Basicf("Diffing against the last successful commit %s (recorded %s)", status.Commit, status.RecordedAt.Format(time.RFC3339))
+	}
+	return base, nil
+}
+
+// readRunStatus reads the run status from a file path, or GETs it from an http(s) URL
+// with STATUS_FILE_HEADERS ("k1=v1,k2=v2"). A missing file or a 404 is no status (nil).
+func readRunStatus(src string) (*runStatus, error) {
+	var data []byte
+	if isURL(src) {
+		req, err := http.NewRequest(http.MethodGet, src, nil)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range tracing.ParseHeaders(os.Getenv("STATUS_FILE_HEADERS")) {
+			req.Header.Set(k, v)
+		}
+		client := &http.Client{Timeout: 10 * time.Second}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		if resp.StatusCode >= 300 {
+			return nil, fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		if data, err = io.ReadAll(resp.Body); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		data, err = os.ReadFile(src)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	var status runStatus
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, fmt.Errorf("parsing run status: %w", err)
+	}
+	return &status, nil
+}
+
+// recordRunStatus records HEAD as the last successful commit: it replaces the file
+// (atomically, through a temporary file), or PUTs the status as JSON to an http(s) URL
+// with STATUS_FILE_HEADERS.
+func recordRunStatus(ctx context.Context, dest string) error {
+	head, err := git.Cmd(ctx, "rev-parse", "HEAD")
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(runStatus{Commit: head, RecordedAt: time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+	if isURL(dest) {
+		req, err := http.NewRequest(http.MethodPut, dest, bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range tracing.ParseHeaders(os.Getenv("STATUS_FILE_HEADERS")) {
+			req.Header.Set(k, v)
+		}
+		client := &http.Client{Timeout: 10 * time.Second}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		return nil
+	}
+	dir := filepath.Dir(dest)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".goodchanges-status-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), dest)
+}
+
+// isURL reports whether a destination is an http(s) URL rather than a file path.
+func isURL(dest string) bool {
+	return strings.HasPrefix(dest, "http://") || strings.HasPrefix(dest, "https://")
+}
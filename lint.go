@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+
+	"goodchanges/internal/analyzer"
+	"goodchanges/internal/git"
+	"goodchanges/internal/rush"
+)
+
+// runLintConfig implements `goodchanges lint-config`: it loads rush.json, every
+// package.json, .goodchangesrc.json and goodchanges-contract.json, and reports
+// configuration problems. Errors (see the README "lint-config" section) make it exit
+// non-zero; warnings are reported but do not fail the check.
+func runLintConfig() int {
+	rushConfig, err := rush.LoadConfig(".")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading rush config: %v\n", err)
+		return 1
+	}
+	projectMap := rush.BuildProjectMap(rushConfig)
+	analyzer.ProjectFolders = rushConfig.ProjectFolders()
+	configMap, configErr := rush.LoadAllProjectConfigs(rushConfig)
+
+	trackedFiles, err := git.TrackedFiles(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing tracked files: %v\n", err)
+		return 1
+	}
+
+	trackedByProject := rushConfig.FilesByProject(trackedFiles)
+
+	var errs, warnings []string
+	if configErr != nil {
+		errs = append(errs, strings.Split(configErr.Error(), "\n")...)
+	}
+	// Root config errors are part of configErr.
+	rootCfg, _ := rush.LoadRootConfig(".")
+	rootCfgFile, _ := rush.FindConfigFile(".")
+	contracts, err := rush.LoadAllContracts(rushConfig)
+	if err != nil {
+		errs = append(errs, strings.Split(err.Error(), "\n")...)
+	}
+
+	targetOwners := make(map[string][]string) // target output name → config files defining it
+	targetRefs := make(map[string][]string)   // constantTargets/contracts/submodules target name → config paths referencing it
+	for _, rp := range rushConfig.Projects {
+		info := projectMap[rp.PackageName]
+		cfg := configMap[rp.ProjectFolder]
+		cfgFile, _ := rush.FindConfigFile(rp.ProjectFolder)
+		if cfgFile == "" {
+			cfgFile = rp.ProjectFolder // all of its config is inherited from projectDefaults
+		}
+
+		if info != nil && analyzer.IsLibrary(cfg, info.Package) {
+			for _, ep := range analyzer.FindUnresolvedEntrypoints(rp.ProjectFolder, info.Package, cfg) {
+				errs = append(errs, fmt.Sprintf("%s/package.json: unresolvable entrypoint %s", rp.ProjectFolder, ep))
+			}
+		}
+		for _, m := range analyzer.FindCaseMismatchedImports(rp.ProjectFolder) {
+			warnings = append(warnings, fmt.Sprintf("%s/%s (import path case differs from the file)", rp.ProjectFolder, m))
+		}
+
+		if cfg == nil {
+			continue
+		}
+
+		var projectFiles []string
+		for _, f := range trackedByProject[rp.ProjectFolder] {
+			projectFiles = append(projectFiles, strings.TrimPrefix(f, rp.ProjectFolder+"/"))
+		}
+		matchesAny := func(pattern string) bool {
+			for _, f := range projectFiles {
+				if matched, _ := doublestar.Match(pattern, f); matched {
+					return true
+				}
+			}
+			return false
+		}
+		checkChangeDirs := func(path string, changeDirs []rush.ChangeDir) {
+			for i, cd := range changeDirs {
+				if !matchesAny(cd.Glob) {
+					warnings = append(warnings, fmt.Sprintf("%s: %s[%d].glob %q matches no tracked files", cfgFile, path, i, cd.Glob))
+				}
+			}
+		}
+		checkIgnores := func(path string, ignores []string) {
+			for i, pattern := range ignores {
+				if !matchesAny(pattern) {
+					warnings = append(warnings, fmt.Sprintf("%s: %s[%d] %q matches no tracked files", cfgFile, path, i, pattern))
+				}
+			}
+		}
+
+		checkIgnores("ignores", cfg.Ignores)
+		checkChangeDirs("changeDirs", cfg.ChangeDirs)
+		// Repo-relative globs, usually pointing outside the project.
+		checkRepoGlobs := func(path string, globs []string) {
+			for i, pattern := range globs {
+				if !slices.ContainsFunc(trackedFiles, func(f string) bool {
+					matched, _ := doublestar.Match(pattern, f)
+					return matched
+				}) {
+					warnings = append(warnings, fmt.Sprintf("%s: %s[%d] %q matches no tracked files", cfgFile, path, i, pattern))
+				}
+			}
+		}
+		if cfg.Generated != nil {
+			checkRepoGlobs("generated.specs", cfg.Generated.Specs)
+		}
+		for i, td := range cfg.Targets {
+			checkIgnores(fmt.Sprintf("targets[%d].ignores", i), td.Ignores)
+			checkChangeDirs(fmt.Sprintf("targets[%d].changeDirs", i), td.ChangeDirs)
+			checkRepoGlobs(fmt.Sprintf("targets[%d].externalTriggers", i), td.ExternalTriggers)
+			for j, r := range td.AppRoutes {
+				if projectMap[r.App] == nil {
+					errs = append(errs, fmt.Sprintf("%s: targets[%d].appRoutes[%d].app %q is not a project in rush.json", cfgFile, i, j, r.App))
+				} else if appCfg := configMap[projectMap[r.App].ProjectFolder]; len(r.Chunks) > 0 && (appCfg == nil || appCfg.BundleStats == "") {
+					errs = append(errs, fmt.Sprintf("%s: targets[%d].appRoutes[%d].chunks: %s sets no bundleStats", cfgFile, i, j, r.App))
+				}
+				for k, pattern := range r.Specs {
+					if !matchesAny(pattern) {
+						warnings = append(warnings, fmt.Sprintf("%s: targets[%d].appRoutes[%d].specs[%d] %q matches no tracked files", cfgFile, i, j, k, pattern))
+					}
+				}
+			}
+			if td.IsUnit() && (info == nil || !analyzer.IsLibrary(cfg, info.Package)) {
+				warnings = append(warnings, fmt.Sprintf("%s: targets[%d]: unit target of %s, which is not a library, is never listed", cfgFile, i, rp.PackageName))
+			}
+			name := td.OutputName(rp.PackageName)
+			targetOwners[name] = append(targetOwners[name], cfgFile)
+		}
+		for i, ct := range cfg.ConstantTargets {
+			for j, t := range ct.Targets {
+				targetRefs[t] = append(targetRefs[t], fmt.Sprintf("%s: constantTargets[%d].targets[%d]", cfgFile, i, j))
+			}
+		}
+	}
+
+	if rootCfg != nil {
+		matchesTracked := func(pattern string) bool {
+			return slices.ContainsFunc(trackedFiles, func(f string) bool {
+				matched, _ := doublestar.Match(pattern, f)
+				return matched
+			})
+		}
+		for i, rt := range rootCfg.Targets {
+			targetOwners[rt.TargetName] = append(targetOwners[rt.TargetName], rootCfgFile)
+			for j, cd := range rt.ChangeDirs {
+				if !matchesTracked(cd.Glob) {
+					warnings = append(warnings, fmt.Sprintf("%s: targets[%d].changeDirs[%d].glob %q matches no tracked files", rootCfgFile, i, j, cd.Glob))
+				}
+			}
+			for j, pattern := range rt.Ignores {
+				if !matchesTracked(pattern) {
+					warnings = append(warnings, fmt.Sprintf("%s: targets[%d].ignores[%d] %q matches no tracked files", rootCfgFile, i, j, pattern))
+				}
+			}
+		}
+	}
+
+	// Contract suites must be the library's own targets.
+	for _, rp := range rushConfig.Projects {
+		contract := contracts[rp.PackageName]
+		if contract == nil {
+			continue
+		}
+		var own []string
+		if cfg := configMap[rp.ProjectFolder]; cfg != nil {
+			for _, td := range cfg.Targets {
+				own = append(own, td.OutputName(rp.PackageName))
+			}
+		}
+		for i, s := range contract.Suites {
+			if !slices.Contains(own, s) {
+				errs = append(errs, fmt.Sprintf("%s/%s: suites[%d]: %q is not a target of %s", rp.ProjectFolder, rush.ContractFileName, i, s, rp.PackageName))
+			}
+		}
+	}
+
+	for name, owners := range targetOwners {
+		if len(owners) > 1 {
+			errs = append(errs, fmt.Sprintf("target name %q is defined by multiple projects: %s", name, strings.Join(owners, ", ")))
+		}
+	}
+
+	if rootCfg != nil {
+		for i, c := range rootCfg.Contracts {
+			for j, t := range c.Targets {
+				targetRefs[t] = append(targetRefs[t], fmt.Sprintf("%s: contracts[%d].targets[%d]", rootCfgFile, i, j))
+			}
+			for j, e := range c.Endpoints {
+				for k, t := range e.Targets {
+					targetRefs[t] = append(targetRefs[t], fmt.Sprintf("%s: contracts[%d].endpoints[%d].targets[%d]", rootCfgFile, i, j, k))
+				}
+			}
+		}
+		for i, d := range rootCfg.ProjectDefaults {
+			if !slices.ContainsFunc(rushConfig.Projects, func(rp rush.Project) bool { return d.Matches(rp.ProjectFolder) }) {
+				warnings = append(warnings, fmt.Sprintf("%s: projectDefaults[%d].folders match no project", rootCfgFile, i))
+			}
+		}
+		for i, sm := range rootCfg.Submodules {
+			for j, t := range sm.Targets {
+				targetRefs[t] = append(targetRefs[t], fmt.Sprintf("%s: submodules[%d].targets[%d]", rootCfgFile, i, j))
+			}
+			for j, p := range sm.Packages {
+				if p != "" && projectMap[p] == nil {
+					errs = append(errs, fmt.Sprintf("%s: submodules[%d].packages[%d]: package %q is not a project in rush.json", rootCfgFile, i, j, p))
+				}
+			}
+		}
+	}
+
+	for name, refs := range targetRefs {
+		if len(targetOwners[name]) == 0 {
+			for _, ref := range refs {
+				errs = append(errs, fmt.Sprintf("%s: target %q is not defined by any project", ref, name))
+			}
+		}
+	}
+
+	sort.Strings(errs)
+	sort.Strings(warnings)
+	for _, e := range errs {
+		fmt.Printf("error: %s\n", e)
+	}
+	for _, w := range warnings {
+		fmt.Printf("warning: %s\n", w)
+	}
+	fmt.Printf("%d error(s), %d warning(s)\n", len(errs), len(warnings))
+	if len(errs) > 0 {
+		return 1
+	}
+	return 0
+}
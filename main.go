@@ -1,23 +1,33 @@
 package main
 
 import (
+	"context"
 	_ "embed"
 	"encoding/json"
 	"fmt"
 	"goodchanges/internal/log"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/bmatcuk/doublestar/v4"
 
 	"goodchanges/internal/analyzer"
 	"goodchanges/internal/git"
 	"goodchanges/internal/lockfile"
+	"goodchanges/internal/metrics"
+	"goodchanges/internal/registry"
 	"goodchanges/internal/rush"
+	"goodchanges/internal/tracing"
+	"goodchanges/internal/tsparse"
 )
 
 //go:embed VERSION
@@ -25,12 +35,48 @@ var version string
 
 var flagIncludeTypes bool
 var flagIncludeCSS bool
+var flagIncludeGraphQL bool
+var flagAPISurfaceOutput string
+var flagTaintUnparseable bool
+var flagRespectSideEffects bool
+var flagUnconsumedExports string
+var flagAnalysisErrors string
+var flagMetadataOutput string
+var flagReportFormat string
+var flagReportPath string
+var flagParserBackend string
+var flagParserCommand string
+var flagSampleSeed string
+var flagAuditLog string
+
+// flagSince is the --since revision or "refs/tags/<tag>" of --since-tag the run diffs
+// against instead of the merge base; flagReleaseNotes the --release-notes output path.
+var flagSince string
+var flagReleaseNotes string
+
+// flagUpstreamTaint (UPSTREAM_TAINT) and flagAffectedExportsOutput
+// (AFFECTED_EXPORTS_OUTPUT) carry taint between the repos of `goodchanges polyrepo`.
+var flagUpstreamTaint string
+var flagAffectedExportsOutput string
+
+// keepReport makes detectAffectedTargets keep the run report in lastReport, for
+// `goodchanges tui`.
+var keepReport bool
+var lastReport *runReport
 var flagLog bool
 var flagDebug bool
 
+// flagDebugPackages (--debug-package) restricts debug logging to the analysis and
+// target detection of the matching packages (`*` wildcards allowed).
+var flagDebugPackages []string
+
 type TargetResult struct {
 	Name       string   `json:"name"`
 	Detections []string `json:"detections,omitempty"`
+	Stories    []string `json:"stories,omitempty"` // story IDs of detected story files (storybook targets)
+	Tags       []string `json:"tags,omitempty"`    // runner tags subsetting a full run (specTags)
+	// Annotations are free-form notes set by selection policies.
+	Annotations map[string]string `json:"annotations,omitempty"`
 }
 
 // envBool returns true if the environment variable is set to a non-empty value.
@@ -39,7 +85,146 @@ func envBool(key string) bool {
 }
 
 func main() {
-	for _, arg := range os.Args[1:] {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "lint-config":
+			os.Exit(runLintConfig())
+		case "replay":
+			os.Exit(runReplay(os.Args[2:]))
+		case "compare-results":
+			os.Exit(runCompareResults(os.Args[2:]))
+		case "who-imports":
+			os.Exit(runWhoImports(os.Args[2:]))
+		case "unused-exports":
+			os.Exit(runUnusedExports(os.Args[2:]))
+		case "index":
+			os.Exit(runIndex(os.Args[2:]))
+		case "snapshot-exports":
+			os.Exit(runSnapshotExports(os.Args[2:]))
+		case "coverage":
+			os.Exit(runCoverage(os.Args[2:]))
+		case "polyrepo":
+			os.Exit(runPolyrepo(os.Args[2:]))
+		case "tui":
+			os.Exit(runTUI(os.Args[2:]))
+		case "summary":
+			os.Exit(runSummary(os.Args[2:]))
+		case "annotate":
+			os.Exit(runAnnotate(os.Args[2:]))
+		case "rpc":
+			os.Exit(runRPC(os.Args[2:]))
+		case "merge-results", "merge":
+			os.Exit(runMergeResults(os.Args[2:]))
+		}
+	}
+
+	var runTimeout time.Duration
+	var logFile string
+	pretty := false
+	for i := 1; i < len(os.Args); i++ {
+		arg := os.Args[i]
+		if value, ok := optionValue(os.Args, &i, "--timeout"); ok {
+			d, err := time.ParseDuration(value)
+			if err != nil || d <= 0 {
+				fmt.Fprintf(os.Stderr, "Error: invalid --timeout %q\n", value)
+				os.Exit(2)
+			}
+			runTimeout = d
+			continue
+		}
+		if value, ok := optionValue(os.Args, &i, "--scope"); ok {
+			flagScope = append(flagScope, splitList(value)...)
+			continue
+		}
+		if value, ok := optionValue(os.Args, &i, "--scope-folder"); ok {
+			flagScopeFolders = append(flagScopeFolders, splitList(value)...)
+			continue
+		}
+		if value, ok := optionValue(os.Args, &i, "--debug-package"); ok {
+			flagDebugPackages = append(flagDebugPackages, splitList(value)...)
+			continue
+		}
+		if value, ok := optionValue(os.Args, &i, "--since"); ok {
+			flagSince = value
+			continue
+		}
+		if value, ok := optionValue(os.Args, &i, "--since-tag"); ok {
+			flagSince = "refs/tags/" + strings.TrimPrefix(value, "refs/tags/")
+			continue
+		}
+		if value, ok := optionValue(os.Args, &i, "--release-notes"); ok {
+			flagReleaseNotes = value
+			continue
+		}
+		if value, ok := optionValue(os.Args, &i, "--direction"); ok {
+			if value != "up" && value != "down" {
+				fmt.Fprintf(os.Stderr, "Error: unknown --direction %q: must be \"up\" or \"down\"\n", value)
+				os.Exit(2)
+			}
+			flagDirection = value
+			continue
+		}
+		if value, ok := optionValue(os.Args, &i, "--output"); ok {
+			if value != "json" && value != "rush-build-args" && value != "projects" {
+				fmt.Fprintf(os.Stderr, "Error: unknown --output %q: must be \"json\", \"rush-build-args\" or \"projects\"\n", value)
+				os.Exit(2)
+			}
+			flagOutput = value
+			continue
+		}
+		if value, ok := optionValue(os.Args, &i, "--from"); ok {
+			flagFrom = append(flagFrom, splitList(value)...)
+			continue
+		}
+		if value, ok := optionValue(os.Args, &i, "--log-file"); ok {
+			logFile = value
+			continue
+		}
+		if arg == "--report" {
+			if i+2 >= len(os.Args) {
+				fmt.Fprintf(os.Stderr, "Error: --report requires a format and an output path\n")
+				os.Exit(2)
+			}
+			flagReportFormat, flagReportPath = os.Args[i+1], os.Args[i+2]
+			if flagReportFormat != "html" && flagReportFormat != "markdown" && flagReportFormat != "sarif" {
+				fmt.Fprintf(os.Stderr, "Error: unknown --report format %q: must be \"html\", \"markdown\" or \"sarif\"\n", flagReportFormat)
+				os.Exit(2)
+			}
+			i += 2
+			continue
+		}
+		if arg == "--pretty" {
+			pretty = true
+			continue
+		}
+		if arg == "--merge-queue" {
+			flagMergeQueue = true
+			continue
+		}
+		if arg == "--auto-fetch" {
+			flagAutoFetch = true
+			continue
+		}
+		if arg == "--since-last-success" {
+			flagSinceLastSuccess = true
+			continue
+		}
+		if value, ok := optionValue(os.Args, &i, "--status-file"); ok {
+			flagStatusFile = value
+			continue
+		}
+		if arg == "--per-commit" {
+			flagPerCommit = true
+			continue
+		}
+		if arg == "--show-ignored" {
+			flagShowIgnored = true
+			continue
+		}
+		if arg == "--deterministic" {
+			flagDeterministic = true
+			continue
+		}
 		if arg == "-v" || arg == "--version" {
 			fmt.Print(strings.TrimSpace(version))
 			fmt.Println()
@@ -61,57 +246,430 @@ func main() {
 		}
 	}
 
-	flagIncludeTypes = envBool("INCLUDE_TYPES")
-	flagIncludeCSS = envBool("INCLUDE_CSS")
+	if flagDeterministic && runTimeout > 0 {
+		// Which targets a timeout leaves unevaluated depends on the machine's speed.
+		fmt.Fprintf(os.Stderr, "Error: --deterministic can't be combined with --timeout\n")
+		os.Exit(2)
+	}
+	if flagMergeQueue && flagSince != "" {
+		fmt.Fprintf(os.Stderr, "Error: --merge-queue can't be combined with --since or --since-tag\n")
+		os.Exit(2)
+	}
+	if flagSinceLastSuccess != (flagStatusFile != "") {
+		fmt.Fprintf(os.Stderr, "Error: --since-last-success and --status-file require each other\n")
+		os.Exit(2)
+	}
+	if flagSinceLastSuccess && (flagMergeQueue || flagSince != "") {
+		fmt.Fprintf(os.Stderr, "Error: --since-last-success can't be combined with --merge-queue, --since or --since-tag\n")
+		os.Exit(2)
+	}
+	if flagPerCommit && (flagOutput != "json" || flagDirection != "up" || flagSinceLastSuccess) {
+		fmt.Fprintf(os.Stderr, "Error: --per-commit can't be combined with --output, --direction down or --since-last-success\n")
+		os.Exit(2)
+	}
+	if len(flagFrom) > 0 && flagDirection != "down" {
+		fmt.Fprintf(os.Stderr, "Error: --from requires --direction down\n")
+		os.Exit(2)
+	}
+	if flagOutput != "json" {
+		if flagDirection == "down" {
+			fmt.Fprintf(os.Stderr, "Error: --output %s can't be combined with --direction down\n", flagOutput)
+			os.Exit(2)
+		}
+		keepReport = true
+	}
 
-	logLevel := strings.ToUpper(os.Getenv("LOG_LEVEL"))
-	flagLog = logLevel == "BASIC" || logLevel == "DEBUG"
-	flagDebug = logLevel == "DEBUG"
+	loadEnvFlags()
+	if logFile != "" {
+		f, err := os.Create(logFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening log file: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		log.Output = f
+	}
+	// Stdout carries only the JSON result: anything else printing to it during
+	// detection ends up on stderr instead.
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	runSpan := tracing.StartRun("goodchanges", os.Getenv("TRACEPARENT"))
+
+	if len(flagFrom) > 0 {
+		// The packages are given: no change detection, and no git needed.
+		packages, err := dependenciesDown(flagFrom)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error %v\n", err)
+			os.Exit(1)
+		}
+		if err := writeJSONOutput(stdout, packages, pretty); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
 
-	log.Basic = flagLog
-	log.Debug = flagDebug
-	analyzer.IncludeCSS = flagIncludeCSS
+	ctx := context.Background()
+	if runTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, runTimeout)
+		defer cancel()
+	}
 
-	var mergeBase string
-	if commit := os.Getenv("COMPARE_COMMIT"); commit != "" {
-		mergeBase = commit
-	} else {
-		compareBranch := os.Getenv("COMPARE_BRANCH")
-		if compareBranch == "" {
-			compareBranch = "origin/master"
+	mergeBase, err := resolveMergeBase(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		os.Exit(1)
+	}
+
+	if flagPerCommit {
+		if ignored := perCommitIgnoredOutputs(os.Args[1:]); len(ignored) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: --per-commit doesn't write %s\n", strings.Join(ignored, ", "))
 		}
-		var err error
-		mergeBase, err = git.MergeBase(compareBranch)
+		breakdown, err := perCommitBreakdown(ctx, mergeBase)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error finding merge-base with %s: %v\n", compareBranch, err)
+			fmt.Fprintf(os.Stderr, "Error computing the per-commit breakdown: %v\n", err)
+			os.Exit(1)
+		}
+		if err := writeJSONOutput(stdout, breakdown, pretty); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
 			os.Exit(1)
 		}
+		finishRun(runSpan)
+		return
 	}
 
-	changedFiles, err := git.ChangedFilesSince(mergeBase)
+	changedFiles, err := git.ChangedFilesSince(ctx, mergeBase)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error getting changed files: %v\n", err)
 		os.Exit(1)
 	}
 
-	rushConfig, err := rush.LoadConfig(".")
+	e2eList, err := detectAffectedTargets(ctx, mergeBase, changedFiles)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading rush config: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
 		os.Exit(1)
 	}
 
-	projectMap := rush.BuildProjectMap(rushConfig)
-	configMap := rush.LoadAllProjectConfigs(rushConfig)
-
-	for projectFolder, cfg := range configMap {
-		if cfg == nil || cfg.Type == nil {
-			continue
+	// Stdout carries the JSON result (targets, --direction down packages or --output
+	// projects), or the rush arguments of --output rush-build-args.
+	var output any = e2eList
+	if flagOutput == "projects" {
+		output = affectedProjects(lastReport)
+	}
+	if flagDirection == "down" {
+		names := make([]string, len(e2eList))
+		for i, t := range e2eList {
+			names[i] = t.Name
 		}
-		if *cfg.Type != "library" && *cfg.Type != "app" {
-			fmt.Fprintf(os.Stderr, "Invalid type %q in %s/.goodchangesrc.json: must be \"library\" or \"app\"\n", *cfg.Type, projectFolder)
+		if output, err = dependenciesDown(names); err != nil {
+			fmt.Fprintf(os.Stderr, "Error %v\n", err)
 			os.Exit(1)
 		}
 	}
+	if flagOutput == "rush-build-args" {
+		fmt.Fprintln(stdout, strings.Join(rushBuildArgs(lastReport), " "))
+	} else if err := writeJSONOutput(stdout, output, pretty); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+		os.Exit(1)
+	}
+	if flagDeterministic {
+		fmt.Fprintf(os.Stderr, "Result hash: %s\n", resultHash(e2eList))
+	}
+	if flagSinceLastSuccess {
+		// A failed record only makes the next run diff against an older commit.
+		if err := recordRunStatus(context.WithoutCancel(ctx), flagStatusFile); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: recording the run in %s: %v\n", flagStatusFile, err)
+		}
+	}
+
+	finishRun(runSpan)
+}
+
+// finishRun pushes the run's metrics and exports its traces; failures are only warnings.
+func finishRun(runSpan *tracing.Span) {
+	if metrics.Enabled() {
+		if err := metrics.Push(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: pushing metrics failed: %v\n", err)
+		}
+	}
+	runSpan.End()
+	if err := tracing.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: exporting traces failed: %v\n", err)
+	}
+}
+
+// resolveMergeBase returns the commit the run diffs against: the --since revision,
+// HEAD's first parent with --merge-queue, the last successful run's commit with
+// --since-last-success, COMPARE_COMMIT, or the merge base with COMPARE_BRANCH (default
+// origin/master).
+func resolveMergeBase(ctx context.Context) (string, error) {
+	if flagMergeQueue {
+		mergeBase, err := mergeQueueBase(ctx)
+		if err != nil {
+			return "", fmt.Errorf("--merge-queue: %w", err)
+		}
+		return mergeBase, nil
+	}
+	if flagSinceLastSuccess {
+		mergeBase, err := lastSuccessBase(ctx)
+		if err != nil {
+			return "", fmt.Errorf("--since-last-success: %w", err)
+		}
+		return mergeBase, nil
+	}
+	if flagSince != "" {
+		mergeBase, err := git.ResolveAncestor(ctx, flagSince)
+		if err != nil {
+			return "", fmt.Errorf("resolving %s: %w", flagSince, err)
+		}
+		return mergeBase, nil
+	}
+	if commit := os.Getenv("COMPARE_COMMIT"); commit != "" {
+		return commit, nil
+	}
+	compareBranch := os.Getenv("COMPARE_BRANCH")
+	if compareBranch == "" {
+		compareBranch = "origin/master"
+	}
+	if err := ensureCompareBranch(ctx, compareBranch); err != nil {
+		return "", err
+	}
+	mergeBase, err := git.MergeBase(ctx, compareBranch)
+	if err != nil {
+		return "", mergeBaseError(ctx, compareBranch, err)
+	}
+	return mergeBase, nil
+}
+
+// optionValue matches os.Args[*i] against a "--name value" or "--name=value" option and
+// returns its value, advancing *i past a separate value. A missing value exits with usage
+// status.
+func optionValue(args []string, i *int, name string) (string, bool) {
+	if value, ok := strings.CutPrefix(args[*i], name+"="); ok {
+		return value, true
+	}
+	if args[*i] != name {
+		return "", false
+	}
+	if *i+1 >= len(args) {
+		fmt.Fprintf(os.Stderr, "Error: %s requires a value\n", name)
+		os.Exit(2)
+	}
+	*i++
+	return args[*i], true
+}
+
+// writeJSONOutput writes the target list (or the package list of --direction down) as a
+// single line of JSON, or indented with pretty.
+func writeJSONOutput(w io.Writer, results any, pretty bool) error {
+	var data []byte
+	var err error
+	if pretty {
+		data, err = json.MarshalIndent(results, "", "  ")
+	} else {
+		data, err = json.Marshal(results)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// loadEnvFlags reads the analysis and logging settings from environment variables.
+func loadEnvFlags() {
+	flagIncludeTypes = envBool("INCLUDE_TYPES")
+	flagIncludeCSS = envBool("INCLUDE_CSS")
+	flagIncludeGraphQL = envBool("INCLUDE_GRAPHQL")
+	flagAPISurfaceOutput = os.Getenv("API_SURFACE_OUTPUT")
+	flagTaintUnparseable = envBool("TAINT_UNPARSEABLE")
+	flagRespectSideEffects = envBool("RESPECT_SIDE_EFFECTS")
+	analyzer.PrescanImports = envBool("PRESCAN_IMPORTS")
+	flagUnconsumedExports = strings.ToLower(os.Getenv("UNCONSUMED_EXPORTS"))
+	flagAnalysisErrors = strings.ToLower(os.Getenv("ANALYSIS_ERRORS"))
+	flagMetadataOutput = os.Getenv("METADATA_OUTPUT")
+	flagParserBackend = os.Getenv("PARSER_BACKEND")
+	flagParserCommand = os.Getenv("PARSER_COMMAND")
+	flagSampleSeed = os.Getenv("SAMPLE_SEED")
+	flagAuditLog = os.Getenv("AUDIT_LOG")
+	flagUpstreamTaint = os.Getenv("UPSTREAM_TAINT")
+	flagAffectedExportsOutput = os.Getenv("AFFECTED_EXPORTS_OUTPUT")
+	flagUnitOutput = os.Getenv("UNIT_OUTPUT")
+	flagUnitResults = os.Getenv("UNIT_RESULTS")
+	if url := os.Getenv("NPM_REGISTRY"); url != "" {
+		registry.URL = url
+	}
+	registry.CacheDir = os.Getenv("REGISTRY_CACHE_DIR")
+	if registry.CacheDir == "" {
+		if dir, err := os.UserCacheDir(); err == nil {
+			registry.CacheDir = filepath.Join(dir, "goodchanges", "registry")
+		}
+	}
+	registry.Offline = envBool("REGISTRY_OFFLINE")
+	analyzer.AnalysisCacheDir = os.Getenv("ANALYSIS_CACHE_DIR")
+	if analyzer.AnalysisCacheDir == "" {
+		analyzer.AnalysisCacheDir = defaultAnalysisCacheDir
+	}
+	if envBool("NO_ANALYSIS_CACHE") {
+		analyzer.AnalysisCacheDir = ""
+	}
+	if v := os.Getenv("GIT_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d >= 0 {
+			git.Timeout = d
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring invalid GIT_TIMEOUT %q\n", v)
+		}
+	}
+	if v := os.Getenv("MAX_FILE_SIZE"); v != "" {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil && n >= 0 {
+			analyzer.MaxFileSize = n
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring invalid MAX_FILE_SIZE %q\n", v)
+		}
+	}
+	if v := os.Getenv("MAX_PACKAGE_FILES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			analyzer.MaxPackageFiles = n
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring invalid MAX_PACKAGE_FILES %q\n", v)
+		}
+	}
+	flagMaxPropagationDepth = 0
+	if v := os.Getenv("MAX_PROPAGATION_DEPTH"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			flagMaxPropagationDepth = n
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring invalid MAX_PROPAGATION_DEPTH %q\n", v)
+		}
+	}
+	flagMaxDetections = 0
+	if v := os.Getenv("MAX_DETECTIONS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			flagMaxDetections = n
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring invalid MAX_DETECTIONS %q\n", v)
+		}
+	}
+	if v := os.Getenv("FETCH_DEPTH"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			flagFetchDepth = n
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring invalid FETCH_DEPTH %q\n", v)
+		}
+	}
+	if v := os.Getenv("GIT_RETRIES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			git.Retries = n
+		} else {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring invalid GIT_RETRIES %q\n", v)
+		}
+	}
+
+	logLevel := strings.ToUpper(os.Getenv("LOG_LEVEL"))
+	flagLog = logLevel == "BASIC" || logLevel == "DEBUG"
+	flagDebug = logLevel == "DEBUG" || len(flagDebugPackages) > 0
+
+	log.Basic = flagLog
+	log.Debug = flagDebug && len(flagDebugPackages) == 0
+	analyzer.IncludeCSS = flagIncludeCSS
+	analyzer.IncludeGraphQL = flagIncludeGraphQL
+	analyzer.RespectSideEffects = flagRespectSideEffects
+
+	metrics.PushgatewayURL = os.Getenv("METRICS_PUSHGATEWAY_URL")
+	metrics.StatsDAddr = os.Getenv("METRICS_STATSD_ADDR")
+	if job := os.Getenv("METRICS_JOB"); job != "" {
+		metrics.Job = job
+	}
+
+	// Standard OpenTelemetry exporter variables; only OTLP/HTTP with JSON encoding is supported.
+	if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT"); endpoint != "" {
+		tracing.Endpoint = endpoint
+	} else if endpoint := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"); endpoint != "" {
+		tracing.Endpoint = strings.TrimSuffix(endpoint, "/") + "/v1/traces"
+	}
+	tracing.Headers = tracing.ParseHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		tracing.ServiceName = name
+	}
+}
+
+// detectAffectedTargets runs the full change detection pipeline for the repository in
+// the current directory and returns the affected targets sorted by name.
+func detectAffectedTargets(ctx context.Context, mergeBase string, changedFiles []string) ([]*TargetResult, error) {
+	runStart := time.Now()
+	defer metrics.Since("goodchanges_phase_duration_seconds", runStart, "phase", "total")
+	var timings []phaseTiming
+	endPhase := func(phase string, start time.Time) {
+		metrics.Since("goodchanges_phase_duration_seconds", start, "phase", phase)
+		timings = append(timings, phaseTiming{Phase: phase, Duration: time.Since(start).Round(time.Millisecond)})
+	}
+	metrics.Set("goodchanges_changed_files", float64(len(changedFiles)))
+
+	if err := tsparse.SetBackend(flagParserBackend, flagParserCommand); err != nil {
+		return nil, err
+	}
+	switch flagUnconsumedExports {
+	case "", "flag", "exclude":
+	default:
+		return nil, fmt.Errorf("invalid UNCONSUMED_EXPORTS %q: must be \"flag\" or \"exclude\"", flagUnconsumedExports)
+	}
+	switch flagAnalysisErrors {
+	case "", "select", "ignore", "fail":
+	default:
+		return nil, fmt.Errorf("invalid ANALYSIS_ERRORS %q: must be \"select\", \"ignore\" or \"fail\"", flagAnalysisErrors)
+	}
+	defer tsparse.CloseBackend()
+
+	phaseStart := time.Now()
+	rushConfig, err := rush.LoadConfig(".")
+	if err != nil {
+		return nil, fmt.Errorf("loading rush config: %w", err)
+	}
+
+	analyzer.ProjectFolders = rushConfig.ProjectFolders()
+	configMap, err := rush.LoadAllProjectConfigs(rushConfig)
+	if err != nil {
+		return nil, fmt.Errorf("in .goodchangesrc.json config:\n%w", err)
+	}
+	rootCfg, err := rush.LoadRootConfig(".")
+	if err != nil {
+		return nil, fmt.Errorf("in .goodchangesrc.json config:\n%w", err)
+	}
+	contracts, err := rush.LoadAllContracts(rushConfig)
+	if err != nil {
+		return nil, fmt.Errorf("in %s:\n%w", rush.ContractFileName, err)
+	}
+	var rootNoisyExports []string
+	if rootCfg != nil {
+		rootNoisyExports = rootCfg.NoisyExports
+		analyzer.SkipDirs = rootCfg.SkipDirs
+	}
+
+	// Projects added, removed or moved in rush.json. The changed files of moved projects
+	// move with them, and diff against their old paths.
+	rushChanges, err := rushConfigChanges(ctx, mergeBase, rushConfig, changedFiles)
+	if err != nil {
+		return nil, fmt.Errorf("comparing rush.json with the merge base: %w", err)
+	}
+	analyzer.MovedFolders = rushChanges.Moved
+	if changedFiles, err = moveChangedFiles(ctx, mergeBase, changedFiles, rushChanges.Moved); err != nil {
+		return nil, fmt.Errorf("mapping the files of moved projects: %w", err)
+	}
+
+	changedFiles = analyzer.FilterRegenerationOnlyChanges(ctx, changedFiles, mergeBase, rushConfig, configMap)
+	changedFiles, headerOnly := analyzer.FilterIgnoredHunks(ctx, changedFiles, mergeBase, rushConfig, configMap, rootCfg)
+	if len(changedFiles) == 0 && headerOnly > 0 {
+		// Copyright and license header mass changes: nothing is left to analyze.
+		log.Basicf("All %d changed files change only ignored hunks (header-only): skipping detection", headerOnly)
+		return headerOnlyRun(ctx, mergeBase, rushConfig)
+	}
+	changedFiles, binaryChanges := analyzer.ClassifyBinaryChanges(ctx, changedFiles, mergeBase, rushConfig, configMap)
+	// Changed files per owning project; nested projects own their files exclusively.
+	// Binary assets still make their project changed, but only through their importers.
+	projectChangedFiles := rushConfig.FilesByProject(slices.DeleteFunc(slices.Clone(changedFiles), func(f string) bool { return analyzer.AssetFiles[f] }))
+
+	endPhase("config", phaseStart)
 
 	// Parse TARGETS filter early to skip expensive detection for non-matching targets
 	var targetPatterns []string
@@ -121,9 +679,8 @@ func main() {
 
 	// When TARGETS is set, compute the relevant package set: active targets + their
 	// transitive dependencies. Only these packages need change detection and analysis.
-	var relevantPackages map[string]bool
+	var targetSeeds []string
 	if len(targetPatterns) > 0 {
-		var targetSeeds []string
 		for _, rp := range rushConfig.Projects {
 			cfg := configMap[rp.ProjectFolder]
 			if cfg == nil {
@@ -135,14 +692,96 @@ func main() {
 				}
 				targetSeeds = append(targetSeeds, rp.PackageName)
 			}
+			// A library whose constants select an active target must be analyzed too.
+			for _, ct := range cfg.ConstantTargets {
+				for _, t := range ct.Targets {
+					if matchesTargetFilter(t, targetPatterns) {
+						targetSeeds = append(targetSeeds, rp.PackageName)
+					}
+				}
+			}
 		}
+	}
+
+	// --scope restricts the run to the targets of the scoped packages, so only they
+	// and their transitive dependencies need change detection and analysis.
+	scope, err := scopePackages(rushConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// With TARGETS or --scope, only the package.json files of the relevant packages are
+	// read. Unconsumed export detection needs the dependents of every library.
+	var projectMap map[string]*rush.ProjectInfo
+	if (len(targetPatterns) > 0 || scope != nil) && flagUnconsumedExports == "" {
+		projectMap = rush.BuildProjectMapFor(rushConfig, append(targetSeeds, sortedKeys(scope)...))
+	} else {
+		projectMap = rush.BuildProjectMap(rushConfig)
+	}
+
+	var relevantPackages map[string]bool
+	if len(targetPatterns) > 0 {
 		relevantPackages = rush.FindTransitiveDependencies(projectMap, targetSeeds)
 	}
+	if scope != nil {
+		scopeDeps := rush.FindTransitiveDependencies(projectMap, sortedKeys(scope))
+		if relevantPackages == nil {
+			relevantPackages = scopeDeps
+		} else {
+			for pkg := range relevantPackages {
+				if !scopeDeps[pkg] {
+					delete(relevantPackages, pkg)
+				}
+			}
+		}
+		log.Basicf("Scope: %d packages, %d with dependencies", len(scope), len(scopeDeps))
+	}
 
 	changedProjects := rush.FindChangedProjects(rushConfig, projectMap, changedFiles, configMap, relevantPackages)
 
+	// Projects added to rush.json count as directly changed, and libraries among them
+	// get all exports tainted: nothing could import them before.
+	addedProjects := make(map[string]bool) // project folder → added
+	for _, rp := range rushChanges.Added {
+		info := projectMap[rp.PackageName]
+		if info == nil || relevantPackages != nil && !relevantPackages[rp.PackageName] {
+			continue
+		}
+		addedProjects[rp.ProjectFolder] = true
+		changedProjects[rp.PackageName] = info
+	}
+
+	// Libraries whose generated client is produced from a changed API spec count as
+	// directly changed, even when the spec lives outside them.
+	specChangedFiles := make(map[string][]string) // project folder → changed specs
+	for _, rp := range rushConfig.Projects {
+		specs := configMap[rp.ProjectFolder].ChangedSpecs(changedFiles)
+		if len(specs) == 0 || relevantPackages != nil && !relevantPackages[rp.PackageName] {
+			continue
+		}
+		specChangedFiles[rp.ProjectFolder] = specs
+		if changedProjects[rp.PackageName] == nil && projectMap[rp.PackageName] != nil {
+			changedProjects[rp.PackageName] = projectMap[rp.PackageName]
+		}
+	}
+
+	// Packages wrapping a bumped submodule count as directly changed; the pointer change
+	// has no content to analyze, so libraries among them get all exports tainted.
+	submoduleWrappers := make(map[string][]string) // project folder → bumped submodule paths
+	for _, sm := range rootCfg.ChangedSubmodules(changedFiles) {
+		for _, name := range sm.Packages {
+			info := projectMap[name]
+			if info == nil || relevantPackages != nil && !relevantPackages[name] {
+				continue
+			}
+			submoduleWrappers[info.ProjectFolder] = append(submoduleWrappers[info.ProjectFolder], sm.Path)
+			changedProjects[name] = info
+		}
+	}
+
 	// Detect lockfile dep changes per subspace (folder → set of changed dep names)
-	depChangedDeps, versionChangedSubspaces := findLockfileAffectedProjects(rushConfig, mergeBase)
+	phaseStart = time.Now()
+	depChangedDeps, versionChangedSubspaces, depVersionChanges := findLockfileAffectedProjects(ctx, rushConfig, mergeBase)
 
 	// When lockfileVersion changes in a subspace, treat all projects in that subspace
 	// as having all external deps changed. This feeds into the existing taint propagation:
@@ -160,8 +799,93 @@ func main() {
 		}
 	}
 
+	// A project moved to another subspace resolves its external deps from another
+	// lockfile: all of them count as changed, as on a lockfileVersion change. The
+	// previous dependents of a project removed from rush.json count the removed
+	// package as a changed external dep.
+	for _, rp := range rushChanges.SubspaceChanged {
+		if depChangedDeps[rp.ProjectFolder] == nil {
+			depChangedDeps[rp.ProjectFolder] = make(map[string]bool)
+		}
+		depChangedDeps[rp.ProjectFolder]["*"] = true
+	}
+	dependents, err := previousDependents(ctx, mergeBase, rushConfig, rushChanges.Removed)
+	if err != nil {
+		return nil, fmt.Errorf("finding dependents of removed projects: %w", err)
+	}
+	for folder, names := range dependents {
+		if depChangedDeps[folder] == nil {
+			depChangedDeps[folder] = make(map[string]bool)
+		}
+		for _, name := range names {
+			depChangedDeps[folder][name] = true
+		}
+	}
+
+	// Bumps of sibling-repo packages taint only the exports changed between the versions.
+	externalTaint, siblingWarnings := analyzer.NarrowSiblingDeps(rootCfg, depVersionChanges, depChangedDeps)
+	sort.Strings(siblingWarnings)
+	for _, w := range siblingWarnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+	}
+	// Exports affected in the upstream repos of a polyrepo run taint their importers.
+	if flagUpstreamTaint != "" {
+		upstreamTaint, err := readUpstreamTaint(flagUpstreamTaint, projectMap)
+		if err != nil {
+			return nil, fmt.Errorf("reading UPSTREAM_TAINT: %w", err)
+		}
+		if externalTaint == nil {
+			externalTaint = make(map[string]map[string]map[string]bool)
+		}
+		for folder, specifiers := range upstreamTaint {
+			if externalTaint[folder] == nil {
+				externalTaint[folder] = make(map[string]map[string]bool)
+			}
+			for specifier, names := range specifiers {
+				externalTaint[folder][specifier] = names
+			}
+		}
+	}
+
+	// Bumps of types-only packages, and of side-effect-free packages imported only for
+	// types, can't change runtime behavior.
+	if !flagIncludeTypes {
+		dropped, registryWarnings := analyzer.DropTypeOnlyDeps(depVersionChanges, depChangedDeps)
+		sort.Strings(registryWarnings)
+		for _, w := range registryWarnings {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+		}
+		if len(dropped) > 0 {
+			sort.Strings(dropped)
+			log.Basicf("Type-only dependency bumps (not tainting runtime targets): %s", strings.Join(dropped, ", "))
+		}
+	}
+
+	// Bare imports of side-effect-free workspace and external packages are dropped by
+	// bundlers and don't taint the importer.
+	if flagRespectSideEffects {
+		free, registryWarnings := analyzer.SideEffectFreeDeps(depVersionChanges, depChangedDeps)
+		sort.Strings(registryWarnings)
+		for _, w := range registryWarnings {
+			fmt.Fprintf(os.Stderr, "Warning: %s\n", w)
+		}
+		for name, info := range projectMap {
+			if info.Package.SideEffectFree() {
+				free[name] = true
+			}
+		}
+		analyzer.SideEffectFreePackages = free
+	}
+
 	// Add dep-affected projects to the changed set (they count as directly changed)
+	depAffectedFolders := make(map[string]bool)
 	for folder := range depChangedDeps {
+		depAffectedFolders[folder] = true
+	}
+	for folder := range externalTaint {
+		depAffectedFolders[folder] = true
+	}
+	for folder := range depAffectedFolders {
 		for _, rp := range rushConfig.Projects {
 			if rp.ProjectFolder == folder {
 				if relevantPackages != nil && !relevantPackages[rp.PackageName] {
@@ -175,11 +899,10 @@ func main() {
 		}
 	}
 
+	endPhase("lockfile", phaseStart)
+
 	// Find the full affected subgraph: directly changed + all transitive dependents
-	var seeds []string
-	for pkgName := range changedProjects {
-		seeds = append(seeds, pkgName)
-	}
+	seeds := sortedKeys(changedProjects)
 	affectedSet := rush.FindTransitiveDependents(projectMap, seeds)
 
 	// Narrow to relevant packages when TARGETS is set
@@ -191,9 +914,18 @@ func main() {
 		}
 	}
 
+	// Packages beyond the propagation depth limits aren't analyzed and see no taint.
+	truncated := limitPropagationDepth(projectMap, changedProjects, affectedSet, configMap)
+	truncatedFolders := make(map[string]bool, len(truncated))
+	for _, tp := range truncated {
+		truncatedFolders[projectMap[tp.Package].ProjectFolder] = true
+	}
+
 	// Topologically sort: level 0 = lowest-level (no deps on other affected packages)
 	levels := rush.TopologicalSort(projectMap, affectedSet)
 
+	metrics.Set("goodchanges_affected_packages", float64(len(affectedSet)))
+
 	log.Basicf("Merge base: %s\n", mergeBase)
 	log.Basicf("Directly changed projects: %d", len(changedProjects))
 	log.Basicf("Dep-affected projects (lockfile): %d", len(depChangedDeps))
@@ -233,30 +965,61 @@ func main() {
 	// CSS-tainted package inherits taint on its JS exports, which then propagates through
 	// the normal bottom-up TS import graph into JS consumers (Pattern A — JS-bundled CSS).
 	if flagIncludeCSS {
+		phaseStart = time.Now()
 		cssTaintedPkgs := analyzer.FindCSSTaintedPackages(changedFiles, rushConfig, projectMap)
-		for pkgName := range cssTaintedPkgs {
+		for _, pkgName := range sortedKeys(cssTaintedPkgs) {
 			key := analyzer.CSSTaintPrefix + pkgName
 			if allUpstreamTaint[key] == nil {
 				allUpstreamTaint[key] = make(map[string]bool)
 			}
 			allUpstreamTaint[key]["*"] = true
-			if flagDebug {
-				fmt.Fprintf(os.Stderr, "[DEBUG] CSS taint: %s\n", pkgName)
-			}
+			log.Debugf("CSS taint: %s", pkgName)
 		}
 		// Propagate CSS taint through SCSS @use chains across libraries
 		analyzer.PropagateCSSTaint(rushConfig, projectMap, allUpstreamTaint)
+		endPhase("css", phaseStart)
+	}
+
+	// GraphQL taint: when INCLUDE_GRAPHQL is set, changed operations and fragments
+	// (in .graphql documents or inline gql literals) are recorded per package and
+	// extended through fragment spreads before library analysis, so TS files importing
+	// or embedding them are seeded while analysing each package.
+	if flagIncludeGraphQL {
+		phaseStart = time.Now()
+		graphqlTainted := analyzer.FindGraphQLTaintedPackages(ctx, changedFiles, mergeBase, rushConfig)
+		for _, pkgName := range sortedKeys(graphqlTainted) {
+			names := graphqlTainted[pkgName]
+			allUpstreamTaint[analyzer.GraphQLTaintPrefix+pkgName] = names
+			log.Debugf("GraphQL taint: %s %v", pkgName, names)
+		}
+		analyzer.PropagateGraphQLTaint(rushConfig, projectMap, allUpstreamTaint)
+		endPhase("graphql", phaseStart)
 	}
 
 	type pkgResult struct {
 		pkgName  string
 		affected []analyzer.AffectedExport
+		err      error
 	}
-
+	// Libraries whose analysis failed: package name → error.
+	analysisErrors := make(map[string]error)
+	// Affected exports per library, for the API surface report.
+	affectedLibExports := make(map[string][]analyzer.AffectedExport)
+	// Targets selected by constantTargets config: target name → "specifier#export" reasons.
+	constantSelected := make(map[string][]string)
+
+	phaseStart = time.Now()
 	for levelIdx, level := range levels {
+		if ctx.Err() != nil {
+			// Upstream taint is incomplete from here on; every target is selected below.
+			fmt.Fprintf(os.Stderr, "Warning: run timed out during analysis (level %d of %d); selecting all targets\n", levelIdx, len(levels))
+			break
+		}
 		log.Basicf("--- Level %d (%d packages) ---\n", levelIdx, len(level))
+		levelSpan := tracing.Start(nil, "level", "level", strconv.Itoa(levelIdx), "packages", strconv.Itoa(len(level)))
 
 		var wg sync.WaitGroup
+		var debugged []func() // --debug-package analyses, run after the level's goroutines
 		resultsCh := make(chan pkgResult, len(level))
 
 		for _, pkgName := range level {
@@ -293,7 +1056,7 @@ func main() {
 				// then match these in HasTaintedImportsForGlob / FindAffectedFiles —
 				// including bare/dynamic side-effect imports, which match on any
 				// non-empty symbol set for the package.
-				entrypoints := analyzer.FindEntrypoints(info.ProjectFolder, pkg)
+				entrypoints := analyzer.FindEntrypoints(info.ProjectFolder, pkg, configMap[info.ProjectFolder])
 				totalExports := 0
 				for _, ep := range entrypoints {
 					specifier := pkgName
@@ -323,7 +1086,11 @@ func main() {
 
 			log.Basicf("  Type: library")
 
-			entrypoints := analyzer.FindEntrypoints(info.ProjectFolder, pkg)
+			entrypoints := analyzer.FindEntrypoints(info.ProjectFolder, pkg, configMap[info.ProjectFolder])
+			// Exports of an unresolved entrypoint are never analyzed, so changes behind it go unnoticed.
+			for _, ep := range analyzer.FindUnresolvedEntrypoints(info.ProjectFolder, pkg, configMap[info.ProjectFolder]) {
+				fmt.Fprintf(os.Stderr, "Warning: %s: unresolved entrypoint %s\n", pkgName, ep)
+			}
 			if len(entrypoints) == 0 {
 				log.Basicf("  No entrypoints found — skipping\n")
 				continue
@@ -343,32 +1110,87 @@ func main() {
 
 			// Global changeDirs: if triggered, enumerate all exports per entrypoint
 			// and seed them as tainted (skip expensive per-symbol analysis).
+			// The "package" generated-code policy and TAINT_UNPARSEABLE seed the same way.
 			libCfg := configMap[info.ProjectFolder]
-			if libCfg != nil && len(libCfg.ChangeDirs) > 0 {
-				if globalChangeDirTriggered(libCfg.ChangeDirs, changedFiles, info.ProjectFolder, libCfg) {
-					totalExports := 0
-					for _, ep := range entrypoints {
-						specifier := pkgName
-						if ep.ExportPath != "." {
-							specifier = pkgName + strings.TrimPrefix(ep.ExportPath, ".")
-						}
-						exports := analyzer.CollectEntrypointExports(info.ProjectFolder, ep)
-						if allUpstreamTaint[specifier] == nil {
-							allUpstreamTaint[specifier] = make(map[string]bool)
-						}
-						for _, name := range exports {
-							allUpstreamTaint[specifier][name] = true
-						}
-						totalExports += len(exports)
+			globalTriggered := libCfg != nil && len(libCfg.ChangeDirs) > 0 && globalChangeDirTriggered(libCfg.ChangeDirs, projectChangedFiles[info.ProjectFolder], info.ProjectFolder, libCfg)
+			generatedTriggered := !globalTriggered && analyzer.GeneratedPackageTriggered(projectChangedFiles[info.ProjectFolder], info.ProjectFolder, libCfg)
+			augmentationTriggered := flagIncludeTypes && !globalTriggered && !generatedTriggered && analyzer.AugmentationPackageTriggered(ctx, projectChangedFiles[info.ProjectFolder], info.ProjectFolder, mergeBase, libCfg)
+			var unparseable []string
+			if flagTaintUnparseable && !globalTriggered && !generatedTriggered {
+				unparseable = analyzer.UnparseableChangedFiles(projectChangedFiles[info.ProjectFolder], info.ProjectFolder)
+			}
+			// Changed API specs taint the generated client exports named after the changed
+			// operations and types, or the whole package when they can't be correlated.
+			var specAffected []analyzer.AffectedExport
+			specWhole := false
+			if specs := specChangedFiles[info.ProjectFolder]; len(specs) > 0 && !globalTriggered && !generatedTriggered {
+				specAffected, specWhole = analyzer.CorrelateSpecChanges(ctx, info.ProjectFolder, entrypoints, specs, mergeBase)
+			}
+			submodules := submoduleWrappers[info.ProjectFolder]
+			added := addedProjects[info.ProjectFolder]
+			if globalTriggered || generatedTriggered || augmentationTriggered || len(unparseable) > 0 || specWhole || len(submodules) > 0 || added {
+				totalExports := 0
+				for _, ep := range entrypoints {
+					specifier := pkgName
+					if ep.ExportPath != "." {
+						specifier = pkgName + strings.TrimPrefix(ep.ExportPath, ".")
+					}
+					exports := analyzer.CollectEntrypointExports(info.ProjectFolder, ep)
+					if allUpstreamTaint[specifier] == nil {
+						allUpstreamTaint[specifier] = make(map[string]bool)
 					}
+					for _, name := range exports {
+						allUpstreamTaint[specifier][name] = true
+					}
+					totalExports += len(exports)
+					affectedLibExports[pkgName] = append(affectedLibExports[pkgName], analyzer.AffectedExport{EntrypointPath: ep.ExportPath, ExportNames: exports})
+				}
+				if added && !globalTriggered {
+					log.Basicf("  Added to rush.json — %d exports tainted across %d entrypoints\n", totalExports, len(entrypoints))
+				} else if len(submodules) > 0 && !globalTriggered {
+					log.Basicf("  Submodules bumped (%s) — %d exports tainted across %d entrypoints\n", strings.Join(submodules, ", "), totalExports, len(entrypoints))
+				} else if generatedTriggered {
+					log.Basicf("  Generated files changed (package policy) — %d exports tainted across %d entrypoints\n", totalExports, len(entrypoints))
+				} else if augmentationTriggered {
+					log.Basicf("  Type augmentations changed (package policy) — %d exports tainted across %d entrypoints\n", totalExports, len(entrypoints))
+				} else if specWhole {
+					log.Basicf("  Changed API specs (%s) not correlated to client exports — %d exports tainted across %d entrypoints\n", strings.Join(specChangedFiles[info.ProjectFolder], ", "), totalExports, len(entrypoints))
+				} else if len(unparseable) > 0 {
+					log.Basicf("  Unparseable changed files (%s) — %d exports tainted across %d entrypoints\n", strings.Join(unparseable, ", "), totalExports, len(entrypoints))
+				} else {
 					log.Basicf("  Global changeDirs triggered — %d exports tainted across %d entrypoints\n", totalExports, len(entrypoints))
-					continue
 				}
+				continue
+			}
+
+			if len(specAffected) > 0 {
+				log.Basicf("  Changed API specs (%s) — correlated client exports:", strings.Join(specChangedFiles[info.ProjectFolder], ", "))
+				for _, ae := range specAffected {
+					specifier := pkgName
+					if ae.EntrypointPath != "." {
+						specifier = pkgName + strings.TrimPrefix(ae.EntrypointPath, ".")
+					}
+					if allUpstreamTaint[specifier] == nil {
+						allUpstreamTaint[specifier] = make(map[string]bool)
+					}
+					for _, name := range ae.ExportNames {
+						log.Basicf("    %s#%s", specifier, name)
+						allUpstreamTaint[specifier][name] = true
+					}
+				}
+				affectedLibExports[pkgName] = append(affectedLibExports[pkgName], specAffected...)
 			}
 
 			// Build upstream taint for this package from its dependencies.
 			// allUpstreamTaint is only read here — writes happen after the level completes.
 			pkgUpstreamTaint := make(map[string]map[string]bool)
+			for specifier, names := range externalTaint[info.ProjectFolder] {
+				pkgUpstreamTaint[specifier] = names
+			}
+			if names := allUpstreamTaint[analyzer.GraphQLTaintPrefix+pkgName]; names != nil {
+				// A package's own GraphQL taint is needed to seed its fragment consumers.
+				pkgUpstreamTaint[analyzer.GraphQLTaintPrefix+pkgName] = names
+			}
 			for _, dep := range info.DependsOn {
 				for specifier, names := range allUpstreamTaint {
 					matches := strings.HasPrefix(specifier, dep)
@@ -377,6 +1199,9 @@ func main() {
 						// so a dep's CSS taint is visible while analysing this package's style @use chains.
 						matches = strings.HasPrefix(strings.TrimPrefix(specifier, analyzer.CSSTaintPrefix), dep)
 					}
+					if !matches && strings.HasPrefix(specifier, analyzer.GraphQLTaintPrefix) {
+						matches = strings.TrimPrefix(specifier, analyzer.GraphQLTaintPrefix) == dep
+					}
 					if matches {
 						if pkgUpstreamTaint[specifier] == nil {
 							pkgUpstreamTaint[specifier] = make(map[string]bool)
@@ -388,25 +1213,106 @@ func main() {
 				}
 			}
 
-			wg.Add(1)
-			go func(pkgName string, projectFolder string, entrypoints []analyzer.Entrypoint, pkgUpstreamTaint map[string]map[string]bool, changedDeps map[string]bool) {
-				defer wg.Done()
-				affected, err := analyzer.AnalyzeLibraryPackage(projectFolder, entrypoints, mergeBase, changedFiles, flagIncludeTypes, pkgUpstreamTaint, changedDeps)
+			analyze := func(pkgName string, projectFolder string, entrypoints []analyzer.Entrypoint, pkgUpstreamTaint map[string]map[string]bool, changedDeps map[string]bool) {
+				pkgStart := time.Now()
+				defer metrics.Since("goodchanges_package_analysis_duration_seconds", pkgStart)
+				metrics.Add("goodchanges_packages_analyzed_total", 1)
+				span := tracing.Start(levelSpan, "AnalyzeLibraryPackage", "package", pkgName)
+				defer span.End()
+				cacheKey := analysisCacheKey(ctx, projectFolder, configMap[projectFolder], entrypoints, mergeBase, projectChangedFiles[projectFolder], pkgUpstreamTaint, changedDeps)
+				affected, cached := analyzer.LoadCachedAnalysis(cacheKey, projectFolder)
+				var err error
+				if cached {
+					log.Debugf("  %s: analysis result from cache", pkgName)
+				} else {
+					affected, err = analyzer.AnalyzeLibraryPackage(ctx, projectFolder, entrypoints, configMap[projectFolder], mergeBase, projectChangedFiles[projectFolder], flagIncludeTypes, pkgUpstreamTaint, changedDeps)
+					if err == nil {
+						analyzer.StoreCachedAnalysis(cacheKey, projectFolder, affected)
+					}
+				}
 				if err != nil {
-					fmt.Fprintf(os.Stderr, "  Error analyzing package %s: %v\n", pkgName, err)
+					span.SetError(err)
+					if ctx.Err() == nil {
+						fmt.Fprintf(os.Stderr, "  Error analyzing package %s: %v\n", pkgName, err)
+						resultsCh <- pkgResult{pkgName: pkgName, err: err}
+					}
 					return
 				}
 				if len(affected) > 0 {
 					resultsCh <- pkgResult{pkgName: pkgName, affected: affected}
 				}
-			}(pkgName, info.ProjectFolder, entrypoints, pkgUpstreamTaint, changedDeps)
+			}
+			if isDebugPackage(pkgName) {
+				// Analyzed alone after the level, so its debug output isn't interleaved.
+				debugged = append(debugged, func() {
+					analyze(pkgName, info.ProjectFolder, entrypoints, pkgUpstreamTaint, changedDeps)
+				})
+				continue
+			}
+			if flagDeterministic {
+				// One at a time in package order: shared caches fill in the same order every run.
+				analyze(pkgName, info.ProjectFolder, entrypoints, pkgUpstreamTaint, changedDeps)
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				analyze(pkgName, info.ProjectFolder, entrypoints, pkgUpstreamTaint, changedDeps)
+			}()
 		}
 
 		wg.Wait()
+		for _, run := range debugged {
+			log.Debug = true
+			run()
+			log.Debug = false
+		}
 		close(resultsCh)
+		levelSpan.End()
 
-		// Merge results into allUpstreamTaint after all goroutines in this level are done
+		// Merge results into allUpstreamTaint after all goroutines in this level are done,
+		// in package order so logs and constant target reasons don't depend on scheduling.
+		results := make([]pkgResult, 0, len(resultsCh))
 		for res := range resultsCh {
+			results = append(results, res)
+		}
+		sort.Slice(results, func(i, j int) bool { return results[i].pkgName < results[j].pkgName })
+		for _, res := range results {
+			if res.err != nil {
+				analysisErrors[res.pkgName] = res.err
+				if flagAnalysisErrors == "ignore" || flagAnalysisErrors == "fail" {
+					continue
+				}
+				// The failed library's changes can't be narrowed down: taint all of its
+				// exports, so its dependents are selected conservatively.
+				info := projectMap[res.pkgName]
+				for _, ep := range analyzer.FindEntrypoints(info.ProjectFolder, info.Package, configMap[info.ProjectFolder]) {
+					specifier := res.pkgName
+					if ep.ExportPath != "." {
+						specifier = res.pkgName + strings.TrimPrefix(ep.ExportPath, ".")
+					}
+					exports := analyzer.CollectEntrypointExports(info.ProjectFolder, ep)
+					if allUpstreamTaint[specifier] == nil {
+						allUpstreamTaint[specifier] = make(map[string]bool)
+					}
+					for _, name := range exports {
+						allUpstreamTaint[specifier][name] = true
+					}
+					if len(exports) == 0 {
+						allUpstreamTaint[specifier]["*"] = true
+					}
+					affectedLibExports[res.pkgName] = append(affectedLibExports[res.pkgName], analyzer.AffectedExport{EntrypointPath: ep.ExportPath, ExportNames: exports})
+				}
+				log.Basicf("  Analysis of %s failed — all exports tainted\n", res.pkgName)
+				continue
+			}
+			affectedLibExports[res.pkgName] = append(affectedLibExports[res.pkgName], res.affected...)
+			// Noisy exports are still reported as affected but not propagated downstream.
+			noisyExports := rootNoisyExports
+			libCfg := configMap[projectMap[res.pkgName].ProjectFolder]
+			if libCfg != nil {
+				noisyExports = slices.Concat(rootNoisyExports, libCfg.NoisyExports)
+			}
 			log.Basicf("  Affected exports for %s:", res.pkgName)
 			for _, ae := range res.affected {
 				log.Basicf("    Entrypoint %q:", ae.EntrypointPath)
@@ -418,10 +1324,22 @@ func main() {
 				if ae.EntrypointPath != "." {
 					specifier = res.pkgName + strings.TrimPrefix(ae.EntrypointPath, ".")
 				}
-				if allUpstreamTaint[specifier] == nil {
-					allUpstreamTaint[specifier] = make(map[string]bool)
-				}
 				for _, name := range ae.ExportNames {
+					// Constants with dedicated targets select those targets instead of propagating.
+					if targets := libCfg.ConstantTargetsFor(specifier, name); len(targets) > 0 {
+						log.Basicf("      (%s selects constant targets %s — not propagated downstream)", name, strings.Join(targets, ", "))
+						for _, t := range targets {
+							constantSelected[t] = append(constantSelected[t], specifier+"#"+name)
+						}
+						continue
+					}
+					if rush.IsNoisyExport(noisyExports, specifier, name) {
+						log.Basicf("      (%s is a noisy export — not propagated downstream)", name)
+						continue
+					}
+					if allUpstreamTaint[specifier] == nil {
+						allUpstreamTaint[specifier] = make(map[string]bool)
+					}
 					allUpstreamTaint[specifier][name] = true
 				}
 			}
@@ -429,101 +1347,237 @@ func main() {
 		}
 	}
 
+	// Affected exports no workspace project imports are dead API surface; with
+	// "exclude" they don't select downstream targets.
+	var unconsumedExports map[string]map[string]bool
+	if flagUnconsumedExports != "" {
+		unconsumedExports = findUnconsumedExports(projectMap, affectedLibExports)
+		count := 0
+		for specifier, names := range unconsumedExports {
+			count += len(names)
+			if flagUnconsumedExports != "exclude" || allUpstreamTaint[specifier] == nil {
+				continue
+			}
+			for name := range names {
+				delete(allUpstreamTaint[specifier], name)
+			}
+			if len(allUpstreamTaint[specifier]) == 0 {
+				delete(allUpstreamTaint, specifier)
+			}
+		}
+		metrics.Set("goodchanges_unconsumed_exports", float64(count))
+	}
+
+	endPhase("analysis", phaseStart)
+	metrics.Set("goodchanges_analysis_errors", float64(len(analysisErrors)))
+	if len(analysisErrors) > 0 && flagAnalysisErrors == "fail" {
+		return nil, fmt.Errorf("analysis failed for %d package(s): %s", len(analysisErrors), strings.Join(sortedKeys(analysisErrors), ", "))
+	}
+
 	// Detect affected targets from .goodchangesrc.json configs.
+	phaseStart = time.Now()
+	targetsSpan := tracing.Start(nil, "target detection")
 	changedE2E := make(map[string]*TargetResult)
 	defaultChangeDirs := []rush.ChangeDir{{Glob: "**/*"}}
 
+	detectors, err := buildDetectors(rootCfg)
+	if err != nil {
+		return nil, fmt.Errorf("in .goodchangesrc.json config:\n%w", err)
+	}
+	detection := &DetectionContext{
+		Context:             ctx,
+		MergeBase:           mergeBase,
+		ChangedFiles:        changedFiles,
+		ProjectChangedFiles: projectChangedFiles,
+		DepChangedDeps:      depChangedDeps,
+		UpstreamTaint:       allUpstreamTaint,
+		ExternalTaint:       externalTaint,
+		Truncated:           truncatedFolders,
+		ConstantSelected:    constantSelected,
+		Contracts:           contracts,
+		Projects:            make(map[string]rush.Project, len(rushConfig.Projects)),
+		ProjectConfigs:      configMap,
+	}
+	for _, rp := range rushConfig.Projects {
+		detection.Projects[rp.PackageName] = rp
+	}
+	definedTargets := make(map[string]bool) // every target name, including those excluded by TARGETS
+	var unitDefs []*DetectorTarget
 	for _, rp := range rushConfig.Projects {
 		cfg := configMap[rp.ProjectFolder]
 		if cfg == nil {
 			continue
 		}
-
-		// Global changeDirs: if triggered, add ALL targets for this package
-		if len(cfg.ChangeDirs) > 0 {
-			if globalChangeDirTriggered(cfg.ChangeDirs, changedFiles, rp.ProjectFolder, cfg) {
-				for _, td := range cfg.Targets {
-					name := td.OutputName(rp.PackageName)
-					if len(targetPatterns) > 0 && !matchesTargetFilter(name, targetPatterns) {
-						continue
-					}
-					changedE2E[name] = &TargetResult{Name: name}
-				}
-				continue
-			}
-		}
-
 		for _, td := range cfg.Targets {
 			name := td.OutputName(rp.PackageName)
+			definedTargets[name] = true
 			if len(targetPatterns) > 0 && !matchesTargetFilter(name, targetPatterns) {
 				continue
 			}
-
-			// Merge global + per-target ignores for this target's detection
-			targetCfg := cfg.WithTargetIgnores(td)
-
-			// Quick check: lockfile dep changes (project-wide)
-			if len(depChangedDeps[rp.ProjectFolder]) > 0 {
-				changedE2E[name] = &TargetResult{Name: name}
+			if scope != nil && !scope[rp.PackageName] {
 				continue
 			}
-
 			// ChangeDirs detection (defaults to **/* if not configured)
 			changeDirs := td.ChangeDirs
 			if len(changeDirs) == 0 {
 				changeDirs = defaultChangeDirs
 			}
+			if td.IsStorybook() {
+				changeDirs = storybookChangeDirs(changeDirs)
+			}
+			t := &DetectorTarget{
+				Name:          name,
+				Project:       rp,
+				Def:           td,
+				ProjectConfig: cfg,
+				Config:        cfg.WithTargetIgnores(td), // global + per-target ignores
+				ChangeDirs:    changeDirs,
+			}
+			// Unit targets aren't selected: they are listed by their library's affected exports.
+			if td.IsUnit() {
+				unitDefs = append(unitDefs, t)
+				continue
+			}
+			detection.Targets = append(detection.Targets, t)
+		}
+	}
+	if rootCfg != nil {
+		rootProject := rush.Project{ProjectFolder: "."}
+		for _, rt := range rootCfg.Targets {
+			definedTargets[rt.TargetName] = true
+			if len(targetPatterns) > 0 && !matchesTargetFilter(rt.TargetName, targetPatterns) {
+				continue
+			}
+			// Root targets belong to no package, so no --scope includes them.
+			if scope != nil {
+				continue
+			}
+			td := rt.TargetDef()
+			cfg := &rush.ProjectConfig{}
+			detection.Targets = append(detection.Targets, &DetectorTarget{
+				Name:          rt.TargetName,
+				Project:       rootProject,
+				Def:           td,
+				ProjectConfig: cfg,
+				Config:        cfg.WithTargetIgnores(td),
+				ChangeDirs:    rt.ChangeDirs,
+				Root:          true,
+			})
+		}
+	}
 
-			normalTriggered := false
-			var fineGrainedDetections []string
+	detection.UpstreamTaint = pruneUpstreamTaint(allUpstreamTaint, projectMap, detection.Targets)
+	if pruned := len(allUpstreamTaint) - len(detection.UpstreamTaint); pruned > 0 {
+		log.Basicf("Pruned upstream taint of %d specifiers no target depends on", pruned)
+	}
 
-			for _, cd := range changeDirs {
-				if cd.IsFineGrained() {
-					filterPattern := ""
-					if cd.Filter != nil {
-						filterPattern = *cd.Filter
-					}
-					detected := analyzer.FindAffectedFiles(cd.Glob, filterPattern, allUpstreamTaint, changedFiles, rp.ProjectFolder, targetCfg, depChangedDeps[rp.ProjectFolder], mergeBase, flagIncludeTypes)
-					if len(detected) > 0 {
-						fineGrainedDetections = append(fineGrainedDetections, detected...)
-					}
-				} else {
-					// Normal: check for any changed file matching the glob
-					for _, f := range changedFiles {
-						if !strings.HasPrefix(f, rp.ProjectFolder+"/") {
-							continue
-						}
-						relPath := strings.TrimPrefix(f, rp.ProjectFolder+"/")
-						if targetCfg.IsIgnored(relPath) {
-							continue
-						}
-						if matched, _ := doublestar.Match(cd.Glob, relPath); matched {
-							normalTriggered = true
-							break
-						}
-					}
-					if !normalTriggered {
-						if analyzer.HasTaintedImportsForGlob(rp.ProjectFolder, cd.Glob, allUpstreamTaint, targetCfg) {
-							normalTriggered = true
-						}
-					}
-				}
-				if normalTriggered {
-					break
+	var ignoredCounts []IgnoredFiles
+	if flagShowIgnored || flagMetadataOutput != "" {
+		var ignored []ignoredFile
+		ignoredCounts, ignored = collectIgnoredFiles(rushConfig, configMap, projectChangedFiles, detection.Targets)
+		if flagShowIgnored {
+			printIgnoredFiles(os.Stderr, ignored)
+		}
+	}
+
+	reasons := make(map[string]string) // target name → detection reason
+	timedOut := ctx.Err() != nil
+	for _, t := range detection.Targets {
+		if !timedOut && ctx.Err() != nil {
+			fmt.Fprintf(os.Stderr, "Warning: run timed out during target detection; selecting all remaining targets\n")
+			timedOut = true
+		}
+		if timedOut {
+			// Partial result: targets evaluated so far keep their verdict, the rest run in full.
+			reasons[t.Name] = "timeout"
+			changedE2E[t.Name] = &TargetResult{Name: t.Name}
+			continue
+		}
+		if analysisErrors[t.Project.PackageName] != nil && flagAnalysisErrors != "ignore" {
+			// The project's own analysis failed, so its detection can't be trusted.
+			log.Basicf("Target %s selected: analysis of %s failed", t.Name, t.Project.PackageName)
+			reasons[t.Name] = "analysis-error"
+			changedE2E[t.Name] = &TargetResult{Name: t.Name}
+			continue
+		}
+		if len(flagDebugPackages) > 0 {
+			log.Debug = isDebugPackage(t.Project.PackageName)
+		}
+		det, err := runDetectors(detectors, detection, t)
+		if len(flagDebugPackages) > 0 {
+			log.Debug = false
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				fmt.Fprintf(os.Stderr, "Warning: run timed out during target detection; selecting all remaining targets\n")
+				timedOut = true
+				reasons[t.Name] = "timeout"
+				changedE2E[t.Name] = &TargetResult{Name: t.Name}
+				continue
+			}
+			return nil, fmt.Errorf("target %s: %w", t.Name, err)
+		}
+		det = escalateDetections(det, t.Def)
+		reasons[t.Name] = det.Reason
+		if det.Full {
+			log.Basicf("Target %s selected by %s", t.Name, det.Reason)
+			changedE2E[t.Name] = &TargetResult{Name: t.Name, Tags: targetTags(detection, t, det)}
+		} else if len(det.Files) > 0 {
+			log.Debugf("Target %s: %d fine-grained detections (%s)", t.Name, len(det.Files), det.Reason)
+			result := &TargetResult{
+				Name:       t.Name,
+				Detections: det.Files,
+			}
+			if t.Def.IsStorybook() {
+				for _, f := range det.Files {
+					result.Stories = append(result.Stories, analyzer.StoryIDs(t.Project.ProjectFolder, f)...)
 				}
 			}
+			changedE2E[t.Name] = result
+		}
+	}
 
-			if normalTriggered {
-				changedE2E[name] = &TargetResult{Name: name}
-			} else if len(fineGrainedDetections) > 0 {
-				sort.Strings(fineGrainedDetections)
-				changedE2E[name] = &TargetResult{
-					Name:       name,
-					Detections: fineGrainedDetections,
-				}
+	// minimumRun: sample targets no detector selected, to catch detection blind spots.
+	sampler := &targetSampler{ctx: ctx, seed: flagSampleSeed}
+	for _, t := range detection.Targets {
+		if changedE2E[t.Name] != nil || t.Def.MinimumRun == nil {
+			continue
+		}
+		selected, reason, err := sampler.sample(t.Name, *t.Def.MinimumRun)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: target %s: minimumRun not applied: %v\n", t.Name, err)
+			continue
+		}
+		reasons[t.Name] = reason
+		if selected {
+			log.Basicf("Target %s selected by %s", t.Name, reason)
+			changedE2E[t.Name] = &TargetResult{Name: t.Name}
+		} else {
+			log.Debugf("Target %s not sampled: %s", t.Name, reason)
+		}
+	}
+
+	targetsSpan.End()
+
+	// Files with syntax errors are analyzed from an error-recovered AST, which may miss
+	// imports and symbols; surface them so detection gaps don't go unnoticed.
+	failures := analyzer.ParseFailures()
+	for _, rp := range rushConfig.Projects {
+		for _, pf := range failures[rp.ProjectFolder] {
+			more := ""
+			if len(pf.Errors) > 1 {
+				more = fmt.Sprintf(" (and %d more)", len(pf.Errors)-1)
 			}
+			fmt.Fprintf(os.Stderr, "Warning: %s: parse errors in %s/%s: %s%s\n", rp.PackageName, rp.ProjectFolder, pf.File, pf.Errors[0], more)
 		}
 	}
+	skipped := analyzer.SkippedFiles()
+	for _, rp := range rushConfig.Projects {
+		for _, sf := range skipped[rp.ProjectFolder] {
+			fmt.Fprintf(os.Stderr, "Warning: %s: skipped %s: %s\n", rp.PackageName, path.Join(rp.ProjectFolder, sf.File), sf.Reason)
+		}
+	}
+	endPhase("targets", phaseStart)
 
 	// Build sorted list of affected targets
 	e2eList := make([]*TargetResult, 0, len(changedE2E))
@@ -533,6 +1587,26 @@ func main() {
 	sort.Slice(e2eList, func(i, j int) bool {
 		return e2eList[i].Name < e2eList[j].Name
 	})
+	if rootCfg != nil && len(rootCfg.Policies) > 0 {
+		affectedPackages := make([]string, 0, len(affectedSet))
+		for pkg := range affectedSet {
+			affectedPackages = append(affectedPackages, pkg)
+		}
+		sort.Strings(affectedPackages)
+		e2eList, err = applyPolicies(rootCfg.Policies, detection, definedTargets, affectedPackages, e2eList, reasons)
+		if err != nil {
+			return nil, err
+		}
+	}
+	units := unitTargets(unitDefs, projectMap, affectedLibExports, e2eList, detection.Targets, timedOut)
+	if flagUnitResults != "" {
+		results, err := readUnitResults(flagUnitResults)
+		if err != nil {
+			return nil, fmt.Errorf("reading unit results: %w", err)
+		}
+		e2eList = gateOnUnitResults(e2eList, units, results, reasons)
+	}
+	metrics.Set("goodchanges_targets_selected", float64(len(e2eList)))
 
 	if flagLog {
 		log.Basicf("Affected e2e packages (%d):", len(e2eList))
@@ -542,15 +1616,124 @@ func main() {
 				for _, d := range result.Detections {
 					log.Basicf("      %s", d)
 				}
+				for _, id := range result.Stories {
+					log.Basicf("      story: %s", id)
+				}
 			} else {
 				log.Basicf("  - %s", result.Name)
 			}
 		}
 	}
 
-	// Always output JSON to stdout
-	jsonBytes, _ := json.Marshal(e2eList)
-	fmt.Println(string(jsonBytes))
+	if flagAuditLog != "" {
+		// An audit failure must not fail the run it describes.
+		rec := buildAuditRecord(ctx, mergeBase, changedFiles, rushConfig, detection.Targets, e2eList, reasons, timedOut)
+		if err := writeAuditRecord(flagAuditLog, rec); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: writing audit record to %s: %v\n", flagAuditLog, err)
+		}
+	}
+
+	if flagMetadataOutput != "" {
+		meta := RunMetadata{MergeBase: mergeBase, TimedOut: timedOut, AnalysisErrors: analysisErrorList(analysisErrors), BinaryChanges: binaryChanges, Truncated: truncated, IgnoredFiles: ignoredCounts}
+		meta.TargetReasons = selectedTargetReasons(e2eList, reasons)
+		if flagDeterministic {
+			meta.ResultHash = resultHash(e2eList)
+		}
+		meta.ChangedLines, err = changedLineMap(ctx, mergeBase, rushConfig, affectedLibExports)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: mapping changed lines to symbols: %v\n", err)
+		}
+		if err := writeRunMetadata(flagMetadataOutput, meta); err != nil {
+			return nil, fmt.Errorf("writing run metadata: %w", err)
+		}
+	}
+
+	if flagReportFormat != "" || flagReleaseNotes != "" || keepReport {
+		report := &runReport{
+			Version:        strings.TrimSpace(version),
+			MergeBase:      mergeBase,
+			ChangedFiles:   changedFiles,
+			Timings:        timings,
+			Total:          time.Since(runStart).Round(time.Millisecond),
+			AnalysisErrors: analysisErrorList(analysisErrors),
+			TimedOut:       timedOut,
+			LinkBase:       os.Getenv("REPORT_LINK_BASE"),
+		}
+		targetProjects := make(map[string]string)
+		for _, t := range detection.Targets {
+			targetProjects[t.Name] = t.Project.PackageName
+		}
+		report.addTargets(e2eList, reasons, targetProjects)
+		report.addPackages(projectMap, changedProjects, affectedSet, projectChangedFiles, depChangedDeps, affectedLibExports)
+		if keepReport {
+			lastReport = report
+		}
+		if flagReportFormat != "" {
+			if err := writeReport(flagReportFormat, flagReportPath, report); err != nil {
+				return nil, fmt.Errorf("writing %s report: %w", flagReportFormat, err)
+			}
+		}
+		if flagReleaseNotes != "" {
+			if err := writeReleaseNotes(ctx, flagReleaseNotes, report, rushConfig); err != nil {
+				return nil, fmt.Errorf("writing release notes: %w", err)
+			}
+		}
+	}
+
+	if flagUnitOutput != "" {
+		if units == nil {
+			units = []UnitTarget{}
+		}
+		if err := writeJSONFile(flagUnitOutput, units); err != nil {
+			return nil, fmt.Errorf("writing unit targets: %w", err)
+		}
+	}
+
+	if flagAffectedExportsOutput != "" {
+		if err := writeAffectedExports(flagAffectedExportsOutput, affectedLibExports); err != nil {
+			return nil, fmt.Errorf("writing affected exports: %w", err)
+		}
+	}
+
+	if flagAPISurfaceOutput != "" {
+		if err := writeAPISurfaceReport(flagAPISurfaceOutput, buildAPISurfaceReport(rushConfig, affectedLibExports, unconsumedExports)); err != nil {
+			return nil, fmt.Errorf("writing API surface report: %w", err)
+		}
+	}
+
+	return e2eList, nil
+}
+
+// headerOnlyRun finishes a run whose changed files all changed only in ignoreHunks
+// hunks: no targets are selected, not even by minimum-run sampling, and the run
+// metadata and audit record carry the "header-only" reason.
+func headerOnlyRun(ctx context.Context, mergeBase string, rushConfig *rush.Config) ([]*TargetResult, error) {
+	const reason = "header-only"
+	if keepReport {
+		lastReport = &runReport{Version: strings.TrimSpace(version), MergeBase: mergeBase}
+	}
+	if flagAuditLog != "" {
+		rec := buildAuditRecord(ctx, mergeBase, []string{}, rushConfig, nil, nil, nil, false)
+		rec.Reason, rec.Targets = reason, []auditTarget{}
+		if err := writeAuditRecord(flagAuditLog, rec); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: writing audit record to %s: %v\n", flagAuditLog, err)
+		}
+	}
+	if flagMetadataOutput != "" {
+		meta := RunMetadata{MergeBase: mergeBase, AnalysisErrors: analysisErrorList(nil), Reason: reason}
+		if flagDeterministic {
+			meta.ResultHash = resultHash([]*TargetResult{})
+		}
+		if err := writeRunMetadata(flagMetadataOutput, meta); err != nil {
+			return nil, fmt.Errorf("writing run metadata: %w", err)
+		}
+	}
+	if flagUnitOutput != "" {
+		if err := writeJSONFile(flagUnitOutput, []UnitTarget{}); err != nil {
+			return nil, fmt.Errorf("writing unit targets: %w", err)
+		}
+	}
+	return []*TargetResult{}, nil
 }
 
 // findLockfileAffectedProjects checks each subspace's pnpm-lock.yaml for dep changes.
@@ -559,7 +1742,8 @@ func main() {
 // Returns:
 //   - depChanges: project folder → set of changed external dep package names
 //   - versionChanges: subspace name → true for subspaces where lockfileVersion changed
-func findLockfileAffectedProjects(config *rush.Config, mergeBase string) (map[string]map[string]bool, map[string]bool) {
+//   - depVersions: project folder → external dep → old/new version, for direct version bumps
+func findLockfileAffectedProjects(ctx context.Context, config *rush.Config, mergeBase string) (map[string]map[string]bool, map[string]bool, map[string]map[string]lockfile.VersionChange) {
 	// Collect subspaces: "default" for projects without subspaceName, plus named ones
 	subspaces := make(map[string]bool)
 	subspaces["default"] = true
@@ -571,13 +1755,14 @@ func findLockfileAffectedProjects(config *rush.Config, mergeBase string) (map[st
 
 	result := make(map[string]map[string]bool)
 	versionChanged := make(map[string]bool)
-	for subspace := range subspaces {
+	depVersions := make(map[string]map[string]lockfile.VersionChange)
+	for _, subspace := range sortedKeys(subspaces) {
 		lockfilePath := filepath.Join("common", "config", "subspaces", subspace, "pnpm-lock.yaml")
 		newContent, err := os.ReadFile(lockfilePath)
 		if err != nil {
 			continue
 		}
-		oldContent, _ := git.ShowFile(mergeBase, lockfilePath)
+		oldContent, _ := git.ShowFile(ctx, mergeBase, lockfilePath)
 
 		oldLf := lockfile.ParseLockfile([]byte(oldContent))
 		newLf := lockfile.ParseLockfile(newContent)
@@ -596,8 +1781,32 @@ func findLockfileAffectedProjects(config *rush.Config, mergeBase string) (map[st
 				result[folder][dep] = true
 			}
 		}
+		for folder, deps := range lockfile.FindDirectVersionChanges(oldLf, newLf, subspace) {
+			if depVersions[folder] == nil {
+				depVersions[folder] = make(map[string]lockfile.VersionChange)
+			}
+			for dep, vc := range deps {
+				depVersions[folder][dep] = vc
+			}
+		}
 	}
-	return result, versionChanged
+	return result, versionChanged, depVersions
+}
+
+// storybookChangeDirs turns a storybook target's changeDirs into fine-grained ones
+// whose output is limited to story files (unless a changeDir sets its own filter).
+func storybookChangeDirs(changeDirs []rush.ChangeDir) []rush.ChangeDir {
+	fineGrained := "fine-grained"
+	result := make([]rush.ChangeDir, len(changeDirs))
+	for i, cd := range changeDirs {
+		cd.Type = &fineGrained
+		if cd.Filter == nil {
+			filter := analyzer.StoryFilePattern
+			cd.Filter = &filter
+		}
+		result[i] = cd
+	}
+	return result
 }
 
 // matchesTargetFilter checks if a target name matches any of the given patterns.
@@ -621,6 +1830,12 @@ func globalChangeDirTriggered(changeDirs []rush.ChangeDir, changedFiles []string
 	return false
 }
 
+// isDebugPackage reports whether --debug-package selects the package for debug
+// logging.
+func isDebugPackage(pkgName string) bool {
+	return len(flagDebugPackages) > 0 && matchesTargetFilter(pkgName, flagDebugPackages)
+}
+
 func matchesTargetFilter(name string, patterns []string) bool {
 	for _, p := range patterns {
 		p = strings.TrimSpace(p)
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"goodchanges/internal/git"
+	"goodchanges/internal/log"
+)
+
+// flagMergeQueue (--merge-queue) diffs HEAD against its first parent instead of the
+// merge base with COMPARE_BRANCH: in a merge queue (or bors staging), HEAD merges the
+// PR onto the target branch plus the PRs queued before it, so only the PR's changes
+// select targets.
+var flagMergeQueue bool
+
+// mergeQueueBase returns the first parent of HEAD: the queue's state before the PR for
+// a merge commit, or the previous queue entry for a squash or rebase queue.
+func mergeQueueBase(ctx context.Context) (string, error) {
+	parents, err := git.Parents(ctx, "HEAD")
+	if err != nil {
+		return "", err
+	}
+	if len(parents) == 0 {
+		if git.IsShallow(ctx) {
+			return "", errors.New("HEAD's parent isn't fetched in this shallow clone: fetch at least 2 commits (--depth=2)")
+		}
+		return "", errors.New("HEAD has no parent")
+	}
+	if len(parents) == 1 {
+		log.Basicf("Merge queue: HEAD is a single commit (squash or rebase queue), diffing against its parent %s", parents[0])
+	} else {
+		log.Basicf("Merge queue: HEAD merges %s onto %s, diffing against the first parent", strings.Join(parents[1:], ", "), parents[0])
+	}
+	return parents[0], nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+
+	"goodchanges/internal/analyzer"
+)
+
+// runMergeResults implements `goodchanges merge-results a.json b.json ...` (alias
+// `merge`): it merges the outputs of runs split with --scope into one. A target
+// selected in full by any run is selected in full; otherwise fine-grained detections
+// and stories are united. With --metadata, the runs' METADATA_OUTPUT files are
+// reconciled into --metadata-output.
+func runMergeResults(args []string) int {
+	pretty := false
+	var files, metadataFiles []string
+	var metadataOutput string
+	for i := 0; i < len(args); i++ {
+		if args[i] == "--pretty" {
+			pretty = true
+		} else if v, ok := optionValue(args, &i, "--metadata"); ok {
+			metadataFiles = append(metadataFiles, v)
+		} else if v, ok := optionValue(args, &i, "--metadata-output"); ok {
+			metadataOutput = v
+		} else {
+			files = append(files, args[i])
+		}
+	}
+	if len(files) == 0 || (len(metadataFiles) > 0) != (metadataOutput != "") {
+		fmt.Fprintln(os.Stderr, "Usage: goodchanges merge-results [--pretty] [--metadata <metadata.json>... --metadata-output <path>] <result.json>...")
+		return 2
+	}
+
+	merged := make(map[string]*TargetResult)
+	for _, f := range files {
+		results, err := readTargetResults(f)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", f, err)
+			return 1
+		}
+		for name, r := range results {
+			merged[name] = mergeTargetResult(merged[name], r)
+		}
+	}
+
+	if metadataOutput != "" {
+		meta, err := mergeRunMetadata(metadataFiles)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error merging metadata: %v\n", err)
+			return 1
+		}
+		if err := writeRunMetadata(metadataOutput, meta); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", metadataOutput, err)
+			return 1
+		}
+	}
+
+	list := make([]*TargetResult, 0, len(merged))
+	for _, r := range merged {
+		list = append(list, r)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	if err := writeJSONOutput(os.Stdout, list, pretty); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// mergeRunMetadata reconciles the run metadata of split runs. The runs must share a
+// merge base; the merged run timed out if any did, and analysis errors, changed lines,
+// binary changes, truncated packages and target reasons are united (the first run
+// reporting a package, file or target wins).
+func mergeRunMetadata(paths []string) (RunMetadata, error) {
+	merged := RunMetadata{AnalysisErrors: []AnalysisError{}}
+	failed := make(map[string]error)
+	changed := make(map[string]ChangedFile)
+	binaries := make(map[string]analyzer.BinaryChange)
+	truncated := make(map[string]TruncatedPath)
+	targetReasons := make(map[string]string)
+	for i, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return RunMetadata{}, err
+		}
+		var meta RunMetadata
+		if err := json.Unmarshal(data, &meta); err != nil {
+			return RunMetadata{}, fmt.Errorf("%s: %w", p, err)
+		}
+		if merged.MergeBase == "" {
+			merged.MergeBase = meta.MergeBase
+		} else if meta.MergeBase != "" && meta.MergeBase != merged.MergeBase {
+			return RunMetadata{}, fmt.Errorf("%s: merge base %s differs from %s", p, meta.MergeBase, merged.MergeBase)
+		}
+		merged.TimedOut = merged.TimedOut || meta.TimedOut
+		// A fast-path reason holds for the merged run only if every shard took it.
+		if i == 0 {
+			merged.Reason = meta.Reason
+		} else if meta.Reason != merged.Reason {
+			merged.Reason = ""
+		}
+		for _, e := range meta.AnalysisErrors {
+			if _, ok := failed[e.Package]; !ok {
+				failed[e.Package] = errors.New(e.Error)
+			}
+		}
+		for _, cf := range meta.ChangedLines {
+			if _, ok := changed[cf.File]; !ok {
+				changed[cf.File] = cf
+			}
+		}
+		for _, bc := range meta.BinaryChanges {
+			if _, ok := binaries[bc.File]; !ok {
+				binaries[bc.File] = bc
+			}
+		}
+		for _, tp := range meta.Truncated {
+			if _, ok := truncated[tp.Package]; !ok {
+				truncated[tp.Package] = tp
+			}
+		}
+		for name, reason := range meta.TargetReasons {
+			if _, ok := targetReasons[name]; !ok {
+				targetReasons[name] = reason
+			}
+		}
+	}
+	if len(targetReasons) > 0 {
+		merged.TargetReasons = targetReasons
+	}
+	merged.AnalysisErrors = analysisErrorList(failed)
+	for _, file := range sortedKeys(changed) {
+		merged.ChangedLines = append(merged.ChangedLines, changed[file])
+	}
+	for _, file := range sortedKeys(binaries) {
+		merged.BinaryChanges = append(merged.BinaryChanges, binaries[file])
+	}
+	for _, pkg := range sortedKeys(truncated) {
+		merged.Truncated = append(merged.Truncated, truncated[pkg])
+	}
+	return merged, nil
+}
+
+// mergeTargetResult merges two results for the same target; a is nil for the first.
+func mergeTargetResult(a, b *TargetResult) *TargetResult {
+	if a == nil {
+		return b
+	}
+	if len(a.Detections) == 0 && len(b.Detections) == 0 {
+		// Tags subset a full run only while every run to merge is tagged.
+		if len(a.Tags) == 0 || len(b.Tags) == 0 {
+			a.Tags = nil
+		} else {
+			a.Tags = compactStrings(slices.Sorted(slices.Values(append(a.Tags, b.Tags...))))
+		}
+	} else if len(a.Detections) == 0 || len(b.Detections) == 0 {
+		// A full run covers any fine-grained selection.
+		a.Detections, a.Stories, a.Tags = nil, nil, nil
+	} else {
+		a.Detections = compactStrings(slices.Sorted(slices.Values(append(a.Detections, b.Detections...))))
+		a.Stories = compactStrings(slices.Sorted(slices.Values(append(a.Stories, b.Stories...))))
+	}
+	for k, v := range b.Annotations {
+		if a.Annotations == nil {
+			a.Annotations = make(map[string]string)
+		}
+		a.Annotations[k] = v
+	}
+	return a
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"goodchanges/internal/analyzer"
+	"goodchanges/internal/diff"
+	"goodchanges/internal/git"
+	"goodchanges/internal/rush"
+)
+
+// AnalysisError records a library whose export analysis failed. Its changes could not
+// be narrowed down to symbols, so (with ANALYSIS_ERRORS=select) all of its exports are
+// tainted and its targets selected.
+type AnalysisError struct {
+	Package string `json:"package"`
+	Error   string `json:"error"`
+}
+
+// RunMetadata describes how complete a run's result is, written to METADATA_OUTPUT.
+type RunMetadata struct {
+	MergeBase      string          `json:"mergeBase"`
+	TimedOut       bool            `json:"timedOut,omitempty"` // --timeout expired; unevaluated targets were selected
+	AnalysisErrors []AnalysisError `json:"analysisErrors"`
+	ChangedLines   []ChangedFile   `json:"changedLines,omitempty"`
+	// BinaryChanges are the changed binary files and the binaries policy applied to them.
+	BinaryChanges []analyzer.BinaryChange `json:"binaryChanges,omitempty"`
+	// Truncated lists the affected packages left out by propagation depth limits.
+	Truncated []TruncatedPath `json:"truncated,omitempty"`
+	// IgnoredFiles counts, per project, the changed files excluded by ignore globs.
+	IgnoredFiles []IgnoredFiles `json:"ignoredFiles,omitempty"`
+	// ResultHash is the hash of the run's output with --deterministic.
+	ResultHash string `json:"resultHash,omitempty"`
+	// Reason is "header-only" when every changed file changed only in ignoreHunks
+	// hunks and detection was skipped.
+	Reason string `json:"reason,omitempty"`
+	// TargetReasons maps each selected target to the reason it was selected.
+	TargetReasons map[string]string `json:"targetReasons,omitempty"`
+}
+
+// ChangedFile maps the changed line ranges of a library source file to the changed
+// symbols declared in them.
+type ChangedFile struct {
+	File           string        `json:"file"` // repo-relative
+	Package        string        `json:"package"`
+	Hunks          []ChangedHunk `json:"hunks"`
+	RemovedSymbols []string      `json:"removedSymbols,omitempty"` // changed symbols no longer declared
+}
+
+// ChangedHunk is a changed line range, in lines of the new file.
+type ChangedHunk struct {
+	StartLine int      `json:"startLine"`
+	EndLine   int      `json:"endLine"`
+	Symbols   []string `json:"symbols,omitempty"` // changed symbols whose declaration overlaps the range
+	// Exports are the package's affected exports named like those symbols, as
+	// "specifier#name" for non-root entrypoints.
+	Exports []string `json:"exports,omitempty"`
+}
+
+// selectedTargetReasons maps the selected targets to the reason each was selected;
+// targets without a detection reason were added by a selection policy.
+func selectedTargetReasons(selected []*TargetResult, reasons map[string]string) map[string]string {
+	result := make(map[string]string, len(selected))
+	for _, t := range selected {
+		reason := reasons[t.Name]
+		if reason == "" {
+			reason = "selection policy"
+		}
+		result[t.Name] = reason
+	}
+	return result
+}
+
+// analysisErrorList returns the failed packages (package name → error) sorted by package.
+func analysisErrorList(failed map[string]error) []AnalysisError {
+	list := make([]AnalysisError, 0, len(failed))
+	for pkg, err := range failed {
+		list = append(list, AnalysisError{Package: pkg, Error: err.Error()})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Package < list[j].Package })
+	return list
+}
+
+func writeRunMetadata(path string, meta RunMetadata) error {
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// changedLineMap maps the hunks of every changed library file whose AST diff seeded
+// taint to the changed symbols and affected exports they touch.
+func changedLineMap(ctx context.Context, mergeBase string, rushConfig *rush.Config, affectedExports map[string][]analyzer.AffectedExport) ([]ChangedFile, error) {
+	var result []ChangedFile
+	for _, rp := range rushConfig.Projects {
+		seeds := analyzer.ChangeSeeds(rp.ProjectFolder)
+		for len(seeds) > 0 {
+			file := seeds[0].File
+			n := 1
+			for n < len(seeds) && seeds[n].File == file {
+				n++
+			}
+			fileSeeds := seeds[:n]
+			seeds = seeds[n:]
+
+			patch, err := git.DiffFile(ctx, mergeBase, file)
+			if err != nil {
+				return nil, err
+			}
+			cf := ChangedFile{File: file, Package: rp.PackageName, Hunks: []ChangedHunk{}}
+			for _, seed := range fileSeeds {
+				if seed.Line == 0 {
+					cf.RemovedSymbols = append(cf.RemovedSymbols, seed.Symbol)
+				}
+			}
+			for _, fd := range diff.Parse(patch) {
+				for _, h := range fd.Hunks {
+					hunk := ChangedHunk{StartLine: h.NewStart, EndLine: h.NewEnd()}
+					for _, seed := range fileSeeds {
+						if seed.Line == 0 || seed.Line > hunk.EndLine || seed.EndLine < hunk.StartLine {
+							continue
+						}
+						hunk.Symbols = append(hunk.Symbols, seed.Symbol)
+						name := seed.ExportName
+						if name == "" {
+							name = seed.Symbol
+						}
+						for _, ae := range affectedExports[rp.PackageName] {
+							if !slices.Contains(ae.ExportNames, name) {
+								continue
+							}
+							qualified := name
+							if ae.EntrypointPath != "." {
+								qualified = rp.PackageName + strings.TrimPrefix(ae.EntrypointPath, ".") + "#" + name
+							}
+							if !slices.Contains(hunk.Exports, qualified) {
+								hunk.Exports = append(hunk.Exports, qualified)
+							}
+						}
+					}
+					sort.Strings(hunk.Exports)
+					cf.Hunks = append(cf.Hunks, hunk)
+				}
+			}
+			result = append(result, cf)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"goodchanges/internal/git"
+	"goodchanges/internal/log"
+)
+
+// flagPerCommit (--per-commit) analyzes every commit between the merge base and HEAD
+// on its own, against its first parent, and outputs the targets each selects: in a
+// batch of commits, the one that needs a heavy suite can be told apart.
+var flagPerCommit bool
+
+// perCommitOutputEnv are the environment variables naming outputs of a run (files,
+// metrics and traces). Child runs would write them once per commit, relative to a
+// worktree deleted afterwards, so they are not passed on.
+var perCommitOutputEnv = []string{
+	"AFFECTED_EXPORTS_OUTPUT", "API_SURFACE_OUTPUT", "AUDIT_LOG", "METADATA_OUTPUT", "UNIT_OUTPUT",
+	"METRICS_PUSHGATEWAY_URL", "METRICS_STATSD_ADDR",
+	"OTEL_EXPORTER_OTLP_ENDPOINT", "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "TRACEPARENT",
+}
+
+// perCommitInputEnv are the environment variables naming files or directories a run
+// reads; child runs get them resolved against the working directory of the parent.
+var perCommitInputEnv = []string{"ANALYSIS_CACHE_DIR", "REGISTRY_CACHE_DIR", "UNIT_RESULTS", "UPSTREAM_TAINT"}
+
+// CommitTargets is the --per-commit breakdown entry of a commit.
+type CommitTargets struct {
+	Commit  string          `json:"commit"`
+	Subject string          `json:"subject"`
+	Targets []*TargetResult `json:"targets"`
+}
+
+// perCommitBreakdown runs the detection for each first-parent commit after mergeBase,
+// oldest first. Each commit is checked out in a temporary worktree and analyzed by a
+// child run against its first parent, so every commit sees its own tree; a failing
+// commit fails the breakdown. Uncommitted changes aren't analyzed.
+func perCommitBreakdown(ctx context.Context, mergeBase string) ([]CommitTargets, error) {
+	out, err := git.Cmd(ctx, "log", "--reverse", "--first-parent", "--format=%H %s", mergeBase+"..HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("listing commits since %s: %w", mergeBase, err)
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	env, err := perCommitEnv(os.Environ())
+	if err != nil {
+		return nil, err
+	}
+	breakdown := []CommitTargets{}
+	for _, line := range strings.Split(out, "\n") {
+		if line == "" {
+			continue
+		}
+		commit, subject, _ := strings.Cut(line, " ")
+		parents, err := git.Parents(ctx, commit)
+		if err != nil {
+			return nil, err
+		}
+		if len(parents) == 0 {
+			return nil, fmt.Errorf("commit %s has no parent", commit)
+		}
+		log.Basicf("Per commit: analyzing %s against %s", commit, parents[0])
+		targets, err := analyzeCommit(ctx, self, env, commit, parents[0])
+		if err != nil {
+			return nil, fmt.Errorf("commit %s: %w", commit, err)
+		}
+		breakdown = append(breakdown, CommitTargets{Commit: commit, Subject: subject, Targets: targets})
+	}
+	return breakdown, nil
+}
+
+// analyzeCommit checks commit out in a temporary worktree and runs goodchanges there
+// with env plus COMPARE_COMMIT set to parent, and the run's options minus those
+// choosing the comparison commit or naming outputs.
+func analyzeCommit(ctx context.Context, self string, env []string, commit, parent string) ([]*TargetResult, error) {
+	dir, err := os.MkdirTemp("", "goodchanges-commit-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	if _, err := git.Cmd(ctx, "worktree", "add", "--detach", dir, commit); err != nil {
+		return nil, fmt.Errorf("checking out: %w", err)
+	}
+	defer git.Cmd(context.WithoutCancel(ctx), "worktree", "remove", "--force", dir)
+
+	var stdout bytes.Buffer
+	cmd := exec.CommandContext(ctx, self, perCommitChildArgs(os.Args[1:])...)
+	cmd.Dir = dir
+	cmd.Env = append(slices.Clone(env), "COMPARE_COMMIT="+parent)
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	var targets []*TargetResult
+	if err := json.Unmarshal(stdout.Bytes(), &targets); err != nil {
+		return nil, fmt.Errorf("parsing output: %w", err)
+	}
+	return targets, nil
+}
+
+// perCommitEnv returns the environment of the child runs: environ without the
+// comparison commit and the outputs, with input paths made absolute and the analysis
+// cache of the parent shared.
+func perCommitEnv(environ []string) ([]string, error) {
+	env := make([]string, 0, len(environ)+1)
+	for _, kv := range environ {
+		name, value, _ := strings.Cut(kv, "=")
+		switch {
+		case name == "COMPARE_COMMIT", name == "COMPARE_BRANCH", slices.Contains(perCommitOutputEnv, name):
+		case slices.Contains(perCommitInputEnv, name) && value != "":
+			abs, err := filepath.Abs(value)
+			if err != nil {
+				return nil, fmt.Errorf("resolving %s: %w", name, err)
+			}
+			env = append(env, name+"="+abs)
+		default:
+			env = append(env, kv)
+		}
+	}
+	if os.Getenv("ANALYSIS_CACHE_DIR") == "" {
+		abs, err := filepath.Abs(defaultAnalysisCacheDir)
+		if err != nil {
+			return nil, fmt.Errorf("resolving the analysis cache directory: %w", err)
+		}
+		env = append(env, "ANALYSIS_CACHE_DIR="+abs)
+	}
+	return env, nil
+}
+
+// perCommitIgnoredOutputs returns the outputs set for the run that --per-commit
+// doesn't write: output environment variables and the --report and --release-notes
+// options.
+func perCommitIgnoredOutputs(args []string) []string {
+	var ignored []string
+	for _, name := range perCommitOutputEnv {
+		if os.Getenv(name) != "" {
+			ignored = append(ignored, name)
+		}
+	}
+	for _, arg := range args {
+		for _, option := range []string{"--report", "--release-notes"} {
+			if arg == option || strings.HasPrefix(arg, option+"=") {
+				ignored = append(ignored, option)
+			}
+		}
+	}
+	return ignored
+}
+
+// perCommitChildArgs drops --per-commit, --pretty, the options choosing the comparison
+// commit (--since, --since-tag, --merge-queue, --auto-fetch) and the output options
+// (--report, --release-notes, --log-file) from the run's arguments.
+func perCommitChildArgs(args []string) []string {
+	var child []string
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--per-commit", arg == "--pretty", arg == "--merge-queue", arg == "--auto-fetch":
+		case arg == "--since", arg == "--since-tag", arg == "--release-notes", arg == "--log-file":
+			i++ // and its value
+		case arg == "--report":
+			i += 2 // and its format and path
+		case strings.HasPrefix(arg, "--since="), strings.HasPrefix(arg, "--since-tag="),
+			strings.HasPrefix(arg, "--release-notes="), strings.HasPrefix(arg, "--log-file="):
+		default:
+			child = append(child, arg)
+		}
+	}
+	return child
+}
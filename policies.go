@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"goodchanges/internal/log"
+	"goodchanges/internal/rush"
+)
+
+// policyRequest is written to a selection policy process on stdin.
+type policyRequest struct {
+	MergeBase        string                       `json:"mergeBase"`
+	ChangedFiles     []string                     `json:"changedFiles"`
+	AffectedPackages []string                     `json:"affectedPackages"`
+	Targets          []policyTarget               `json:"targets"`          // selection so far
+	AvailableTargets []commandDetectorRequestItem `json:"availableTargets"` // every target of the run
+}
+
+// policyResponse is the final selection answered by a policy process on stdout.
+type policyResponse struct {
+	Targets []policyTarget `json:"targets"`
+}
+
+type policyTarget struct {
+	TargetResult
+	Reason string `json:"reason,omitempty"` // detector verdict, or set by a policy
+}
+
+// applyPolicies runs the selection policies of the root config in order. Each
+// receives the targets selected so far with their reasons and answers the new
+// selection, so it can add, remove or annotate targets. Added targets must be
+// defined by a project (definedTargets); those excluded by TARGETS are dropped.
+func applyPolicies(policies []rush.PolicyConfig, detection *DetectionContext, definedTargets map[string]bool, affectedPackages []string, selected []*TargetResult, reasons map[string]string) ([]*TargetResult, error) {
+	if len(policies) == 0 {
+		return selected, nil
+	}
+	available := make(map[string]bool, len(detection.Targets))
+	var availableItems []commandDetectorRequestItem
+	for _, t := range detection.Targets {
+		available[t.Name] = true
+		availableItems = append(availableItems, commandDetectorRequestItem{Name: t.Name, Project: t.Project.PackageName, ProjectFolder: t.Project.ProjectFolder})
+	}
+
+	current := make([]policyTarget, 0, len(selected))
+	for _, r := range selected {
+		current = append(current, policyTarget{TargetResult: *r, Reason: reasons[r.Name]})
+	}
+	for _, p := range policies {
+		input, err := json.Marshal(policyRequest{
+			MergeBase:        detection.MergeBase,
+			ChangedFiles:     detection.ChangedFiles,
+			AffectedPackages: affectedPackages,
+			Targets:          current,
+			AvailableTargets: availableItems,
+		})
+		if err != nil {
+			return nil, err
+		}
+		args := strings.Fields(p.Command)
+		cmd := exec.Command(args[0], args[1:]...)
+		cmd.Stdin = bytes.NewReader(input)
+		cmd.Stderr = os.Stderr
+		output, err := cmd.Output()
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: running %q: %w", p.Name, p.Command, err)
+		}
+		var resp policyResponse
+		if err := json.Unmarshal(output, &resp); err != nil {
+			return nil, fmt.Errorf("policy %s: invalid response from %q: %w", p.Name, p.Command, err)
+		}
+
+		before := make(map[string]bool, len(current))
+		for _, t := range current {
+			before[t.Name] = true
+		}
+		seen := make(map[string]bool, len(resp.Targets))
+		next := make([]policyTarget, 0, len(resp.Targets))
+		for _, t := range resp.Targets {
+			if seen[t.Name] {
+				continue
+			}
+			seen[t.Name] = true
+			if !before[t.Name] {
+				if !definedTargets[t.Name] {
+					return nil, fmt.Errorf("policy %s: added unknown target %q", p.Name, t.Name)
+				}
+				if !available[t.Name] {
+					log.Basicf("Target %s added by policy %s is excluded by TARGETS", t.Name, p.Name)
+					continue
+				}
+				log.Basicf("Target %s added by policy %s: %s", t.Name, p.Name, t.Reason)
+			}
+			next = append(next, t)
+		}
+		for _, t := range current {
+			if !seen[t.Name] {
+				log.Basicf("Target %s removed by policy %s", t.Name, p.Name)
+			}
+		}
+		current = next
+	}
+
+	result := make([]*TargetResult, 0, len(current))
+	for _, t := range current {
+		r := t.TargetResult
+		result = append(result, &r)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+
+	"goodchanges/internal/analyzer"
+	"goodchanges/internal/log"
+	"goodchanges/internal/rush"
+)
+
+// polyrepoManifest links several checked-out rush repositories for `goodchanges
+// polyrepo`. Repos are analyzed in order, so a repo must come after the repos
+// publishing the packages it consumes.
+type polyrepoManifest struct {
+	Repos []polyrepoRepo `json:"repos"`
+}
+
+type polyrepoRepo struct {
+	Name string `json:"name"`
+	Path string `json:"path"` // relative to the manifest
+	// CompareCommit or CompareBranch set the repo's comparison point, like
+	// COMPARE_COMMIT / COMPARE_BRANCH; unset, those of the environment apply.
+	CompareCommit string `json:"compareCommit,omitempty"`
+	CompareBranch string `json:"compareBranch,omitempty"`
+	// Packages are the package names (or globs) the repo publishes to the repos after it.
+	Packages []string `json:"packages,omitempty"`
+}
+
+// publishes reports whether pkg is one of the repo's published packages.
+func (r *polyrepoRepo) publishes(pkg string) bool {
+	for _, p := range r.Packages {
+		if matched, _ := doublestar.Match(p, pkg); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// runPolyrepo implements `goodchanges polyrepo --manifest <file> [--pretty]`: it runs
+// the detection in every repo of the manifest, in order, each in a child process.
+// The affected exports of a repo's published packages taint their importers in the
+// repos after it (UPSTREAM_TAINT), so a library repo change selects app repo targets.
+// The output is an object of each repo's selected targets, keyed by repo name.
+func runPolyrepo(args []string) int {
+	var manifestPath string
+	pretty := false
+	usage := func() int {
+		fmt.Fprintln(os.Stderr, "Usage: goodchanges polyrepo --manifest <polyrepo.json> [--pretty]")
+		return 2
+	}
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "--pretty":
+			pretty = true
+		case args[i] == "--manifest" && i+1 < len(args):
+			i++
+			manifestPath = args[i]
+		case strings.HasPrefix(args[i], "--manifest="):
+			manifestPath = strings.TrimPrefix(args[i], "--manifest=")
+		default:
+			return usage()
+		}
+	}
+	if manifestPath == "" {
+		return usage()
+	}
+
+	manifest, err := loadPolyrepoManifest(manifestPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error in %s: %v\n", manifestPath, err)
+		return 1
+	}
+	self, err := os.Executable()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error locating the goodchanges binary: %v\n", err)
+		return 1
+	}
+	tmpDir, err := os.MkdirTemp("", "goodchanges-polyrepo-")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	defer os.RemoveAll(tmpDir)
+
+	loadEnvFlags()
+	results := make(map[string][]*TargetResult, len(manifest.Repos))
+	upstream := make(map[string][]string) // import specifier → affected export names
+	for i := range manifest.Repos {
+		repo := &manifest.Repos[i]
+		taintFile := filepath.Join(tmpDir, repo.Name+".taint.json")
+		exportsFile := filepath.Join(tmpDir, repo.Name+".exports.json")
+		if err := writeJSONFile(taintFile, upstream); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			return 1
+		}
+		targets, err := runPolyrepoChild(self, filepath.Join(filepath.Dir(manifestPath), repo.Path), repo, taintFile, exportsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error in repo %s: %v\n", repo.Name, err)
+			return 1
+		}
+		results[repo.Name] = targets
+
+		exports, err := readAffectedExports(exportsFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading the affected exports of repo %s: %v\n", repo.Name, err)
+			return 1
+		}
+		for _, specifier := range sortedKeys(exports) {
+			if !repo.publishes(analyzer.PackageOfSpecifier(specifier)) {
+				continue
+			}
+			log.Basicf("Repo %s: %s affected (%d exports)", repo.Name, specifier, len(exports[specifier]))
+			upstream[specifier] = exports[specifier]
+		}
+	}
+
+	var data []byte
+	if pretty {
+		data, err = json.MarshalIndent(results, "", "  ")
+	} else {
+		data, err = json.Marshal(results)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+		return 1
+	}
+	fmt.Println(string(data))
+	return 0
+}
+
+func loadPolyrepoManifest(path string) (*polyrepoManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var m polyrepoManifest
+	if err := dec.Decode(&m); err != nil {
+		return nil, err
+	}
+	if len(m.Repos) == 0 {
+		return nil, errors.New("no repos")
+	}
+	seen := make(map[string]bool)
+	for i, r := range m.Repos {
+		switch {
+		case r.Name == "":
+			return nil, fmt.Errorf("repos[%d]: missing required field \"name\"", i)
+		case seen[r.Name]:
+			return nil, fmt.Errorf("repos[%d]: duplicate repo name %q", i, r.Name)
+		case r.Path == "":
+			return nil, fmt.Errorf("repos[%d]: missing required field \"path\"", i)
+		case r.CompareCommit != "" && r.CompareBranch != "":
+			return nil, fmt.Errorf("repos[%d]: compareCommit and compareBranch are mutually exclusive", i)
+		}
+		seen[r.Name] = true
+		for j, p := range r.Packages {
+			if !doublestar.ValidatePattern(p) {
+				return nil, fmt.Errorf("repos[%d].packages[%d]: invalid glob %q", i, j, p)
+			}
+		}
+	}
+	return &m, nil
+}
+
+// runPolyrepoChild runs the detection in one repo and returns its selected targets.
+// The child's log goes to stderr as usual.
+func runPolyrepoChild(self, dir string, repo *polyrepoRepo, taintFile, exportsFile string) ([]*TargetResult, error) {
+	env := make([]string, 0, len(os.Environ())+4)
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		if (repo.CompareCommit != "" || repo.CompareBranch != "") && (name == "COMPARE_COMMIT" || name == "COMPARE_BRANCH") {
+			continue
+		}
+		env = append(env, kv)
+	}
+	if repo.CompareCommit != "" {
+		env = append(env, "COMPARE_COMMIT="+repo.CompareCommit)
+	}
+	if repo.CompareBranch != "" {
+		env = append(env, "COMPARE_BRANCH="+repo.CompareBranch)
+	}
+	env = append(env, "UPSTREAM_TAINT="+taintFile, "AFFECTED_EXPORTS_OUTPUT="+exportsFile)
+
+	var stdout bytes.Buffer
+	cmd := exec.Command(self)
+	cmd.Dir = dir
+	cmd.Env = env
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, err
+	}
+	var targets []*TargetResult
+	if err := json.Unmarshal(stdout.Bytes(), &targets); err != nil {
+		return nil, fmt.Errorf("parsing output: %w", err)
+	}
+	return targets, nil
+}
+
+// readAffectedExports reads an AFFECTED_EXPORTS_OUTPUT file. A run that skipped
+// detection writes none, which means nothing is affected.
+func readAffectedExports(path string) (map[string][]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var exports map[string][]string
+	return exports, json.Unmarshal(data, &exports)
+}
+
+// writeAffectedExports writes the affected exports of the analyzed libraries as an
+// object of import specifiers ("pkg" or "pkg/subpath") to sorted export names.
+func writeAffectedExports(path string, affected map[string][]analyzer.AffectedExport) error {
+	exports := make(map[string][]string)
+	for pkg, list := range affected {
+		for _, ae := range list {
+			specifier := pkg + strings.TrimPrefix(ae.EntrypointPath, ".")
+			exports[specifier] = append(exports[specifier], ae.ExportNames...)
+		}
+	}
+	for specifier, names := range exports {
+		sort.Strings(names)
+		exports[specifier] = slices.Compact(names)
+	}
+	return writeJSONFile(path, exports)
+}
+
+// readUpstreamTaint reads an UPSTREAM_TAINT file (import specifier → export names)
+// and resolves it to the projects depending on each specifier's package, as external
+// taint (project folder → specifier → names).
+func readUpstreamTaint(path string, projectMap map[string]*rush.ProjectInfo) (map[string]map[string]map[string]bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var upstream map[string][]string
+	if err := json.Unmarshal(data, &upstream); err != nil {
+		return nil, err
+	}
+	taint := make(map[string]map[string]map[string]bool)
+	for _, specifier := range sortedKeys(upstream) {
+		pkg := analyzer.PackageOfSpecifier(specifier)
+		for _, name := range sortedKeys(projectMap) {
+			info := projectMap[name]
+			if _, ok := info.Package.Dependencies[pkg]; !ok {
+				if _, ok := info.Package.DevDependencies[pkg]; !ok {
+					continue
+				}
+			}
+			if taint[info.ProjectFolder] == nil {
+				taint[info.ProjectFolder] = make(map[string]map[string]bool)
+			}
+			names := make(map[string]bool, len(upstream[specifier]))
+			for _, n := range upstream[specifier] {
+				names[n] = true
+			}
+			taint[info.ProjectFolder][specifier] = names
+			log.Basicf("Upstream repo taint: %s imports %s (%d exports)", name, specifier, len(names))
+		}
+	}
+	return taint, nil
+}
+
+func writeJSONFile(path string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
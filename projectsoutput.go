@@ -0,0 +1,29 @@
+package main
+
+import "goodchanges/internal/rush"
+
+// AffectedProject is an entry of --output projects: a project the run affects, with
+// the command running its tests, so orchestrators needn't map packages to commands.
+type AffectedProject struct {
+	Name   string `json:"name"`
+	Folder string `json:"folder"`
+	Reason string `json:"reason"` // "changed files", "lockfile" or "dependency"
+	// TestCommand runs the project's tests in Folder (see rush.DiscoverTestCommand);
+	// omitted when none is found.
+	TestCommand string `json:"testCommand,omitempty"`
+}
+
+// affectedProjects returns the projects of the run report, sorted by name, with their
+// discovered test commands.
+func affectedProjects(report *runReport) []AffectedProject {
+	projects := []AffectedProject{}
+	for _, p := range report.Packages {
+		projects = append(projects, AffectedProject{
+			Name:        p.Name,
+			Folder:      p.Folder,
+			Reason:      p.Reason,
+			TestCommand: rush.DiscoverTestCommand(p.Folder),
+		})
+	}
+	return projects
+}
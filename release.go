@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"goodchanges/internal/git"
+	"goodchanges/internal/rush"
+)
+
+// writeReleaseNotes writes the markdown summary of a release delta (`--release-notes`,
+// usually with --since-tag): the affected published packages (shouldPublish in
+// rush.json) with why they are affected and their affected exports, then the selected
+// targets.
+func writeReleaseNotes(ctx context.Context, path string, r *runReport, rushConfig *rush.Config) error {
+	commits, err := git.CommitCount(ctx, r.MergeBase)
+	if err != nil {
+		return fmt.Errorf("counting commits since %s: %w", r.MergeBase, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(formatReleaseNotes(r, rushConfig, commits)), 0o644)
+}
+
+func formatReleaseNotes(r *runReport, rushConfig *rush.Config, commits int) string {
+	since := r.MergeBase
+	if flagSince != "" {
+		since = strings.TrimPrefix(flagSince, "refs/tags/")
+	}
+	published := make(map[string]bool)
+	for _, rp := range rushConfig.Projects {
+		published[rp.PackageName] = rp.ShouldPublish
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Changes since %s\n\n", since)
+	fmt.Fprintf(&b, "Commits: %d, changed files: %d (goodchanges %s).\n", commits, len(r.ChangedFiles), r.Version)
+	if r.TimedOut {
+		b.WriteString("\nThe analysis timed out; targets not evaluated in time were all selected and packages may be missing.\n")
+	}
+
+	b.WriteString("\n## Affected published packages\n\n")
+	count := 0
+	for _, p := range r.Packages {
+		if !published[p.Name] {
+			continue
+		}
+		count++
+		var by string
+		switch {
+		case p.Reason == "dependency":
+			by = "through " + strings.Join(p.Details, " → ")
+		case p.Reason == "changed files":
+			by = fmt.Sprintf("changed files: %d", len(p.Details))
+		case len(p.Details) > 0:
+			by = "dependency bumps: " + strings.Join(p.Details, ", ")
+		default:
+			by = "dependency bumps"
+		}
+		fmt.Fprintf(&b, "- `%s` (%s)\n", p.Name, by)
+		for _, e := range p.Exports {
+			fmt.Fprintf(&b, "  - `%s`: %s\n", e.Specifier, strings.Join(e.Names, ", "))
+		}
+	}
+	if count == 0 {
+		b.WriteString("None.\n")
+	}
+
+	b.WriteString("\n## Selected targets\n\n")
+	if len(r.Targets) == 0 {
+		b.WriteString("None.\n")
+	}
+	for _, t := range r.Targets {
+		fmt.Fprintf(&b, "- `%s`: %s\n", t.Name, t.Reason)
+	}
+	return b.String()
+}
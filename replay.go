@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"goodchanges/internal/git"
+)
+
+// Fixture layout for `goodchanges replay`:
+//
+//	<fixture>/changed-files.txt  repo-relative changed paths, one per line
+//	<fixture>/base/              contents of the changed files at the comparison commit
+//	                             (a missing file means it was added)
+//	<fixture>/head/              the repository tree at HEAD (rush.json, projects, lockfiles)
+//	<fixture>/expected.json      optional expected output
+const (
+	fixtureChangedFiles = "changed-files.txt"
+	fixtureBaseDir      = "base"
+	fixtureHeadDir      = "head"
+	fixtureExpected     = "expected.json"
+)
+
+// runReplay implements `goodchanges replay --fixture dir`: it runs the full detection
+// pipeline against a recorded fixture instead of a live git repository. The output
+// JSON is printed to stdout. When the fixture contains expected.json, the result is
+// compared against it and a mismatch exits non-zero, so fixtures can serve as
+// regression tests for detection behavior.
+func runReplay(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	fixtureDir := fs.String("fixture", "", "path to the fixture directory")
+	pretty := fs.Bool("pretty", false, "indent the output JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *fixtureDir == "" {
+		fmt.Fprintln(os.Stderr, "Usage: goodchanges replay --fixture <dir> [--pretty]")
+		return 2
+	}
+
+	absFixture, err := filepath.Abs(*fixtureDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error resolving fixture path: %v\n", err)
+		return 1
+	}
+	changedFiles, err := readChangedFilesList(filepath.Join(absFixture, fixtureChangedFiles))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading fixture: %v\n", err)
+		return 1
+	}
+
+	loadEnvFlags()
+	git.FixtureDir = filepath.Join(absFixture, fixtureBaseDir)
+	if err := os.Chdir(filepath.Join(absFixture, fixtureHeadDir)); err != nil {
+		fmt.Fprintf(os.Stderr, "Error entering fixture head tree: %v\n", err)
+		return 1
+	}
+
+	e2eList, err := detectAffectedTargets(context.Background(), "fixture", changedFiles)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error running detection on fixture: %v\n", err)
+		return 1
+	}
+	if err := writeJSONOutput(os.Stdout, e2eList, *pretty); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing output: %v\n", err)
+		return 1
+	}
+
+	expectedData, err := os.ReadFile(filepath.Join(absFixture, fixtureExpected))
+	if err != nil {
+		return 0
+	}
+	var expected []*TargetResult
+	if err := json.Unmarshal(expectedData, &expected); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing %s: %v\n", fixtureExpected, err)
+		return 1
+	}
+	expectedBytes, _ := json.Marshal(expected)
+	jsonBytes, _ := json.Marshal(e2eList)
+	if string(expectedBytes) != string(jsonBytes) {
+		fmt.Fprintf(os.Stderr, "Replay output does not match %s\n  expected: %s\n  actual:   %s\n", fixtureExpected, expectedBytes, jsonBytes)
+		return 1
+	}
+	return 0
+}
+
+// readChangedFilesList reads a newline-separated list of paths, skipping blank lines.
+func readChangedFilesList(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			files = append(files, line)
+		}
+	}
+	return files, nil
+}
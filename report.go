@@ -0,0 +1,257 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"goodchanges/internal/analyzer"
+	"goodchanges/internal/rush"
+)
+
+//go:embed report.html.tmpl
+var reportHTMLTemplate string
+
+// markdownReportMarker starts every markdown report, so CI can find and update the
+// comment of a previous run instead of posting a new one.
+const markdownReportMarker = "<!-- goodchanges-report -->"
+
+// markdownMaxFiles caps the changed files listed per package in the markdown report.
+const markdownMaxFiles = 5
+
+// runReport is a human-readable account of a run (`--report`): why each package is
+// affected, which exports changed and why each target was selected.
+type runReport struct {
+	Version        string
+	MergeBase      string
+	ChangedFiles   []string
+	Packages       []reportPackage
+	Targets        []reportTarget
+	Timings        []phaseTiming
+	Total          time.Duration
+	AnalysisErrors []AnalysisError
+	TimedOut       bool
+	LinkBase       string // URL prefix for linking repo-relative files in the markdown report
+}
+
+type reportPackage struct {
+	Name    string
+	Folder  string
+	Reason  string   // "changed files", "lockfile" or "dependency"
+	Details []string // changed files, changed external deps, or the propagation chain from a changed package
+	Exports []reportExport
+	Seeds   []analyzer.ChangeSeed // changed symbols that seeded the package's taint (libraries)
+	Targets []string              // selected targets of the package and its dependents
+}
+
+type reportExport struct {
+	Specifier string
+	Names     []string
+}
+
+type reportTarget struct {
+	Name       string
+	Project    string // package name; empty for root targets and targets added by a selection policy
+	Reason     string
+	Detections []string
+}
+
+// phaseTiming is the duration of one pipeline phase (config, lockfile, analysis, ...).
+type phaseTiming struct {
+	Phase    string
+	Duration time.Duration
+}
+
+// addPackages records every affected package with the reason it is affected. Packages
+// affected only through the workspace graph get the dependency chain from the nearest
+// changed package. Call it after addTargets.
+func (r *runReport) addPackages(projectMap, changedProjects map[string]*rush.ProjectInfo, affectedSet map[string]bool, projectChangedFiles map[string][]string, depChangedDeps map[string]map[string]bool, affectedExports map[string][]analyzer.AffectedExport) {
+	for _, name := range sortedKeys(affectedSet) {
+		info := projectMap[name]
+		if info == nil {
+			continue
+		}
+		pkg := reportPackage{Name: name, Folder: info.ProjectFolder}
+		switch {
+		case len(projectChangedFiles[info.ProjectFolder]) > 0:
+			pkg.Reason = "changed files"
+			pkg.Details = projectChangedFiles[info.ProjectFolder]
+		case len(depChangedDeps[info.ProjectFolder]) > 0:
+			pkg.Reason = "lockfile"
+			pkg.Details = sortedKeys(depChangedDeps[info.ProjectFolder])
+		case changedProjects[name] != nil:
+			pkg.Reason = "lockfile"
+		default:
+			pkg.Reason = "dependency"
+			pkg.Details = propagationChain(projectMap, changedProjects, affectedSet, name)
+		}
+		for _, ae := range affectedExports[name] {
+			specifier := name
+			if ae.EntrypointPath != "." {
+				specifier = name + strings.TrimPrefix(ae.EntrypointPath, ".")
+			}
+			names := append([]string(nil), ae.ExportNames...)
+			sort.Strings(names)
+			pkg.Exports = append(pkg.Exports, reportExport{Specifier: specifier, Names: names})
+		}
+		sort.SliceStable(pkg.Exports, func(i, j int) bool { return pkg.Exports[i].Specifier < pkg.Exports[j].Specifier })
+		pkg.Seeds = analyzer.ChangeSeeds(info.ProjectFolder)
+		dependents := rush.FindTransitiveDependents(projectMap, []string{name})
+		for _, t := range r.Targets {
+			if dependents[t.Project] {
+				pkg.Targets = append(pkg.Targets, t.Name)
+			}
+		}
+		r.Packages = append(r.Packages, pkg)
+	}
+}
+
+// propagationChain returns the shortest path of affected packages from a changed
+// package to name, following workspace dependencies.
+func propagationChain(projectMap, changedProjects map[string]*rush.ProjectInfo, affectedSet map[string]bool, name string) []string {
+	prev := map[string]string{name: ""}
+	queue := []string{name}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if changedProjects[current] != nil {
+			var chain []string
+			for p := current; p != ""; p = prev[p] {
+				chain = append(chain, p)
+			}
+			return chain
+		}
+		deps := append([]string(nil), projectMap[current].DependsOn...)
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if _, seen := prev[dep]; seen || !affectedSet[dep] || projectMap[dep] == nil {
+				continue
+			}
+			prev[dep] = current
+			queue = append(queue, dep)
+		}
+	}
+	return nil
+}
+
+// addTargets records the selected targets with the detector reason that selected them.
+// projects maps target names to the package defining them.
+func (r *runReport) addTargets(targets []*TargetResult, reasons map[string]string, projects map[string]string) {
+	targetReasons := selectedTargetReasons(targets, reasons)
+	for _, t := range targets {
+		r.Targets = append(r.Targets, reportTarget{Name: t.Name, Project: projects[t.Name], Reason: targetReasons[t.Name], Detections: t.Detections})
+	}
+}
+
+// writeReport renders the run report in the given format to path.
+func writeReport(format, path string, r *runReport) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	switch format {
+	case "markdown":
+		if _, err := f.WriteString(renderMarkdownReport(r)); err != nil {
+			return err
+		}
+	case "sarif":
+		if err := writeSARIFReport(f, r); err != nil {
+			return err
+		}
+	case "html":
+		tmpl, err := template.New("report").Parse(reportHTMLTemplate)
+		if err != nil {
+			return err
+		}
+		if err := tmpl.Execute(f, r); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown report format %q", format)
+	}
+	return f.Close()
+}
+
+// renderMarkdownReport renders a compact summary of the run for a PR comment.
+func renderMarkdownReport(r *runReport) string {
+	var b strings.Builder
+	b.WriteString(markdownReportMarker + "\n")
+	b.WriteString("### goodchanges\n\n")
+	fmt.Fprintf(&b, "**%d** targets selected · %d affected packages · %d changed files · merge base `%s` · %s\n\n",
+		len(r.Targets), len(r.Packages), len(r.ChangedFiles), r.MergeBase, r.Total)
+	if r.TimedOut {
+		b.WriteString("> [!WARNING]\n> The run timed out. Targets not evaluated in time were all selected.\n\n")
+	}
+	if len(r.AnalysisErrors) > 0 {
+		b.WriteString("> [!WARNING]\n> Analysis failed for ")
+		for i, e := range r.AnalysisErrors {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "`%s`", e.Package)
+		}
+		b.WriteString("; their dependents and targets were selected conservatively.\n\n")
+	}
+
+	if len(r.Targets) == 0 {
+		b.WriteString("No targets selected.\n")
+	} else {
+		b.WriteString("| Target | Reason | Detections |\n|---|---|---|\n")
+		for _, t := range r.Targets {
+			detections := "all"
+			if len(t.Detections) > 0 {
+				detections = fmt.Sprintf("%d files", len(t.Detections))
+			}
+			fmt.Fprintf(&b, "| `%s` | %s | %s |\n", t.Name, markdownCell(t.Reason), detections)
+		}
+	}
+
+	if len(r.Packages) > 0 {
+		fmt.Fprintf(&b, "\n<details><summary>%d affected packages</summary>\n\n", len(r.Packages))
+		b.WriteString("| Package | Affected by | Affected exports |\n|---|---|---|\n")
+		for _, p := range r.Packages {
+			var by string
+			switch {
+			case p.Reason == "dependency":
+				by = strings.Join(p.Details, " → ")
+			case p.Reason == "changed files":
+				var links []string
+				for _, f := range p.Details[:min(len(p.Details), markdownMaxFiles)] {
+					links = append(links, r.fileLink(f))
+				}
+				by = strings.Join(links, ", ")
+				if more := len(p.Details) - markdownMaxFiles; more > 0 {
+					by += fmt.Sprintf(" and %d more", more)
+				}
+			case len(p.Details) > 0:
+				by = p.Reason + ": " + strings.Join(p.Details, ", ")
+			default:
+				by = p.Reason
+			}
+			exports := 0
+			for _, e := range p.Exports {
+				exports += len(e.Names)
+			}
+			fmt.Fprintf(&b, "| `%s` | %s | %d |\n", p.Name, markdownCell(by), exports)
+		}
+		b.WriteString("\n</details>\n")
+	}
+	return b.String()
+}
+
+// fileLink renders a repo-relative path, linked when LinkBase is set.
+func (r *runReport) fileLink(path string) string {
+	if r.LinkBase == "" {
+		return "`" + path + "`"
+	}
+	return "[`" + path + "`](" + strings.TrimSuffix(r.LinkBase, "/") + "/" + path + ")"
+}
+
+func markdownCell(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"` // absent for notifications
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// rpcAffected is the result of affectedByFile.
+type rpcAffected struct {
+	File    string          `json:"file"` // repo-relative
+	Targets []*TargetResult `json:"targets"`
+}
+
+// rpcWhy is the result of whyTarget.
+type rpcWhy struct {
+	Name       string      `json:"name"`
+	Package    string      `json:"package,omitempty"`
+	Reason     string      `json:"reason"`
+	Detections []string    `json:"detections,omitempty"`
+	Chain      []string    `json:"chain,omitempty"` // packages from the changed one to the target's
+	Exports    []rpcExport `json:"exports,omitempty"`
+	Seeds      []rpcSeed   `json:"seeds,omitempty"`
+}
+
+type rpcExport struct {
+	Specifier string   `json:"specifier"`
+	Names     []string `json:"names"`
+}
+
+type rpcSeed struct {
+	File   string `json:"file"`
+	Symbol string `json:"symbol"`
+	Line   int    `json:"line,omitempty"`
+	Change string `json:"change,omitempty"`
+}
+
+// runRPC implements `goodchanges rpc`: a long-running JSON-RPC 2.0 server on stdin and
+// stdout with LSP framing (Content-Length headers), for editor extensions. Requests
+// are handled one at a time:
+//
+//   - affectedByFile {"uri"}: the targets the file's changes select on their own
+//   - whyTarget {"name"}: why the last full run selected a target
+//   - exportsOf {"package"}: the affected exports of a package in the last full run
+//   - refresh: re-run the full detection; it otherwise runs on the first
+//     whyTarget or exportsOf
+//   - shutdown, then the exit notification, end the session.
+func runRPC(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "Usage: goodchanges rpc")
+		return 2
+	}
+	loadEnvFlags()
+	root, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	// Stdout carries only the protocol: anything else printing to it ends up on stderr.
+	out := bufio.NewWriter(os.Stdout)
+	os.Stdout = os.Stderr
+
+	s := &rpcServer{root: root}
+	in := bufio.NewReader(os.Stdin)
+	for {
+		body, err := readRPCMessage(in)
+		if errors.Is(err, io.EOF) {
+			return 0
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading request: %v\n", err)
+			return 1
+		}
+		var req rpcRequest
+		var result any
+		var rerr *rpcError
+		if err := json.Unmarshal(body, &req); err != nil {
+			rerr = &rpcError{Code: rpcParseError, Message: err.Error()}
+		} else if req.JSONRPC != "2.0" || req.Method == "" {
+			rerr = &rpcError{Code: rpcInvalidRequest, Message: "not a JSON-RPC 2.0 request"}
+		} else {
+			if req.Method == "exit" {
+				if s.shutdown {
+					return 0
+				}
+				return 1
+			}
+			result, rerr = s.handle(req.Method, req.Params)
+		}
+		if req.ID == nil && rerr == nil {
+			continue // notification
+		}
+		resp := rpcResponse{JSONRPC: "2.0", ID: req.ID, Result: result, Error: rerr}
+		if resp.ID == nil {
+			resp.ID = json.RawMessage("null")
+		}
+		if rerr == nil && result == nil {
+			resp.Result = json.RawMessage("null")
+		}
+		if err := writeRPCMessage(out, resp); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing response: %v\n", err)
+			return 1
+		}
+	}
+}
+
+// rpcServer is the state of a `goodchanges rpc` session.
+type rpcServer struct {
+	root     string     // repository root; file URIs are resolved against it
+	report   *runReport // last full run; nil before the first
+	shutdown bool
+}
+
+func (s *rpcServer) handle(method string, params json.RawMessage) (any, *rpcError) {
+	if s.shutdown {
+		return nil, &rpcError{Code: rpcInvalidRequest, Message: "server is shut down"}
+	}
+	var p struct {
+		URI     string `json:"uri"`
+		Name    string `json:"name"`
+		Package string `json:"package"`
+	}
+	if len(params) > 0 {
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+	}
+	ctx := context.Background()
+	switch method {
+	case "initialize":
+		return map[string]any{"serverInfo": map[string]string{"name": "goodchanges", "version": strings.TrimSpace(version)}}, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "affectedByFile":
+		rel, err := s.repoPath(p.URI)
+		if err != nil {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+		}
+		_, results, _, err := runDetection(ctx, []string{rel})
+		if err != nil {
+			return nil, &rpcError{Code: rpcInternalError, Message: err.Error()}
+		}
+		if results == nil {
+			results = []*TargetResult{}
+		}
+		return rpcAffected{File: rel, Targets: results}, nil
+	case "refresh":
+		s.report = nil
+		if err := s.ensureReport(ctx); err != nil {
+			return nil, err
+		}
+		return map[string]int{"targets": len(s.report.Targets), "packages": len(s.report.Packages)}, nil
+	case "whyTarget":
+		if p.Name == "" {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "missing name"}
+		}
+		if err := s.ensureReport(ctx); err != nil {
+			return nil, err
+		}
+		for _, t := range s.report.Targets {
+			if t.Name != p.Name {
+				continue
+			}
+			why := &rpcWhy{Name: t.Name, Package: t.Project, Reason: t.Reason, Detections: t.Detections}
+			if pkg := s.reportPackage(t.Project); pkg != nil {
+				if pkg.Reason == "dependency" {
+					why.Chain = pkg.Details
+				}
+				why.Exports = rpcExports(pkg)
+				for _, seed := range pkg.Seeds {
+					why.Seeds = append(why.Seeds, rpcSeed{File: seed.File, Symbol: seed.Symbol, Line: seed.Line, Change: seed.Change})
+				}
+			}
+			return why, nil
+		}
+		return nil, nil // not selected
+	case "exportsOf":
+		if p.Package == "" {
+			return nil, &rpcError{Code: rpcInvalidParams, Message: "missing package"}
+		}
+		if err := s.ensureReport(ctx); err != nil {
+			return nil, err
+		}
+		exports := []rpcExport{}
+		if pkg := s.reportPackage(p.Package); pkg != nil {
+			exports = append(exports, rpcExports(pkg)...)
+		}
+		return exports, nil
+	}
+	return nil, &rpcError{Code: rpcMethodNotFound, Message: "unknown method " + method}
+}
+
+// ensureReport runs the full detection unless a report of an earlier one is kept.
+func (s *rpcServer) ensureReport(ctx context.Context) *rpcError {
+	if s.report != nil {
+		return nil
+	}
+	_, _, report, err := runDetection(ctx, nil)
+	if err != nil {
+		return &rpcError{Code: rpcInternalError, Message: err.Error()}
+	}
+	s.report = report
+	return nil
+}
+
+func (s *rpcServer) reportPackage(name string) *reportPackage {
+	for i := range s.report.Packages {
+		if s.report.Packages[i].Name == name {
+			return &s.report.Packages[i]
+		}
+	}
+	return nil
+}
+
+func rpcExports(pkg *reportPackage) []rpcExport {
+	var exports []rpcExport
+	for _, e := range pkg.Exports {
+		exports = append(exports, rpcExport{Specifier: e.Specifier, Names: e.Names})
+	}
+	return exports
+}
+
+// repoPath resolves a file:// URI or a path to a repo-relative path.
+func (s *rpcServer) repoPath(uri string) (string, error) {
+	if uri == "" {
+		return "", errors.New("missing uri")
+	}
+	path := uri
+	if strings.HasPrefix(uri, "file:") {
+		u, err := url.Parse(uri)
+		if err != nil {
+			return "", err
+		}
+		path = u.Path
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(s.root, path)
+	}
+	rel, err := filepath.Rel(s.root, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("%s is outside the repository", uri)
+	}
+	return filepath.ToSlash(rel), nil
+}
+
+// readRPCMessage reads one LSP-framed message: headers, a blank line, then
+// Content-Length bytes of body.
+func readRPCMessage(r *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && line == "" && length < 0 {
+				return nil, io.EOF
+			}
+			return nil, io.ErrUnexpectedEOF
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil || length < 0 {
+				return nil, fmt.Errorf("invalid Content-Length %q", strings.TrimSpace(value))
+			}
+		}
+	}
+	if length < 0 {
+		return nil, errors.New("missing Content-Length header")
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func writeRPCMessage(w *bufio.Writer, msg any) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(data))
+	w.Write(data)
+	return w.Flush()
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"goodchanges/internal/analyzer"
+	"goodchanges/internal/git"
+	"goodchanges/internal/log"
+	"goodchanges/internal/rush"
+)
+
+// rushConfigChanges returns the projects added, removed, moved or moved to another
+// subspace in rush.json since the merge base. It only looks when rush.json or a
+// package.json changed, as project globs expand to the folders of package.json files.
+// A merge base without a rush.json, or with one that doesn't parse, has no changes.
+func rushConfigChanges(ctx context.Context, mergeBase string, head *rush.Config, changedFiles []string) (rush.ProjectChanges, error) {
+	if !slices.ContainsFunc(changedFiles, func(f string) bool { return f == "rush.json" || path.Base(f) == "package.json" }) {
+		return rush.ProjectChanges{}, nil
+	}
+	data, err := git.ShowFile(ctx, mergeBase, "rush.json")
+	if err != nil || data == "" {
+		return rush.ProjectChanges{}, err
+	}
+	base, err := rush.ParseConfig([]byte(data))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: rush.json at the merge base: %v (project changes not detected)\n", err)
+		return rush.ProjectChanges{}, nil
+	}
+	if rush.HasProjectGlobs(base.Projects) {
+		files, err := git.FilesAt(ctx, mergeBase)
+		if err != nil {
+			return rush.ProjectChanges{}, err
+		}
+		read := func(name string) ([]byte, error) {
+			content, err := git.ShowFile(ctx, mergeBase, name)
+			return []byte(content), err
+		}
+		if base.Projects, err = rush.ExpandProjectGlobsIn(base.Projects, files, read); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: rush.json at the merge base: %v (project changes not detected)\n", err)
+			return rush.ProjectChanges{}, nil
+		}
+	}
+	changes := rush.DiffProjects(base.Projects, head.Projects)
+	if !changes.Empty() {
+		for _, rp := range changes.Added {
+			log.Basicf("rush.json: added %s (%s)", rp.PackageName, rp.ProjectFolder)
+		}
+		for _, rp := range changes.Removed {
+			log.Basicf("rush.json: removed %s (%s)", rp.PackageName, rp.ProjectFolder)
+		}
+		for _, folder := range sortedKeys(changes.Moved) {
+			log.Basicf("rush.json: moved %s to %s", changes.Moved[folder], folder)
+		}
+		for _, rp := range changes.SubspaceChanged {
+			log.Basicf("rush.json: %s moved to subspace %q", rp.PackageName, rp.SubspaceName)
+		}
+	}
+	return changes, nil
+}
+
+// moveChangedFiles maps the changed files of moved projects to their new folders: a
+// file gone from an old folder is the project's file in the new one (deleted there
+// too, unless it still exists), and files whose content didn't change with the move
+// aren't changed at all. Files under an old folder that exist at HEAD belong to
+// whatever project now owns them. Needs analyzer.MovedFolders set.
+func moveChangedFiles(ctx context.Context, mergeBase string, changedFiles []string, moved map[string]string) ([]string, error) {
+	if len(moved) == 0 {
+		return changedFiles, nil
+	}
+	newFolders := make(map[string]string, len(moved)) // old folder → new folder
+	for folder, old := range moved {
+		newFolders[old] = folder
+	}
+	seen := make(map[string]bool, len(changedFiles))
+	var result []string
+	for _, f := range changedFiles {
+		if _, err := os.Stat(f); os.IsNotExist(err) {
+			best := ""
+			for old := range newFolders {
+				if strings.HasPrefix(f, old+"/") && len(old) > len(best) {
+					best = old
+				}
+			}
+			if best != "" {
+				f = newFolders[best] + strings.TrimPrefix(f, best)
+			}
+		}
+		if seen[f] {
+			continue
+		}
+		seen[f] = true
+		if base := analyzer.BasePath(f); base != f {
+			newContent, err := os.ReadFile(f)
+			if err == nil {
+				oldContent, err := git.ShowFile(ctx, mergeBase, base)
+				if err != nil {
+					return nil, err
+				}
+				if bytes.Equal(newContent, []byte(oldContent)) {
+					continue
+				}
+			}
+		}
+		result = append(result, f)
+	}
+	slices.Sort(result)
+	return result, nil
+}
+
+// previousDependents returns the folders of the projects that depended on a project
+// removed from rush.json at the merge base (by their package.json there), with the
+// removed packages they depended on. Their workspace dependency now resolves to
+// something else, if anything, like a changed external dependency.
+func previousDependents(ctx context.Context, mergeBase string, config *rush.Config, removed []rush.Project) (map[string][]string, error) {
+	if len(removed) == 0 {
+		return nil, nil
+	}
+	removedNames := make(map[string]bool, len(removed))
+	for _, rp := range removed {
+		removedNames[rp.PackageName] = true
+	}
+	result := make(map[string][]string)
+	for _, rp := range config.Projects {
+		content, err := git.ShowFile(ctx, mergeBase, analyzer.BasePath(rp.ProjectFolder+"/package.json"))
+		if err != nil {
+			return nil, err
+		}
+		var pkg rush.PackageJSON
+		if content == "" || json.Unmarshal([]byte(content), &pkg) != nil {
+			continue
+		}
+		for _, deps := range []map[string]string{pkg.Dependencies, pkg.DevDependencies} {
+			for name, version := range deps {
+				if removedNames[name] && strings.HasPrefix(version, "workspace:") && !slices.Contains(result[rp.ProjectFolder], name) {
+					result[rp.ProjectFolder] = append(result[rp.ProjectFolder], name)
+				}
+			}
+		}
+	}
+	return result, nil
+}
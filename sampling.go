@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"goodchanges/internal/git"
+	"goodchanges/internal/rush"
+)
+
+// targetSampler decides which unaffected targets a minimumRun selects. The seed is
+// the UTC date for rotating samples and the HEAD commit for commit samples, unless
+// SAMPLE_SEED overrides both (a YYYY-MM-DD date for rotating samples).
+type targetSampler struct {
+	ctx  context.Context
+	seed string // SAMPLE_SEED
+
+	head    string
+	headErr error
+	headSet bool
+}
+
+// sample returns whether the target is selected and the reason recording the
+// decision and its seed.
+func (s *targetSampler) sample(name string, mr rush.MinimumRun) (bool, string, error) {
+	if mr.IsCommitSample() {
+		seed := s.seed
+		if seed == "" {
+			if !s.headSet {
+				s.head, s.headErr = git.Cmd(s.ctx, "rev-parse", "HEAD")
+				s.headSet = true
+			}
+			if s.headErr != nil {
+				return false, "", fmt.Errorf("resolving HEAD: %w", s.headErr)
+			}
+			seed = s.head
+		}
+		draw := int(hashString(seed+"/"+name) % 100)
+		return draw < mr.Percent, fmt.Sprintf("minimum-run: commit sample %d of 100, selected below %d (seed %s)", draw, mr.Percent, seed), nil
+	}
+
+	seed := s.seed
+	if seed == "" {
+		seed = time.Now().UTC().Format(time.DateOnly)
+	}
+	day, err := time.Parse(time.DateOnly, seed)
+	if err != nil {
+		return false, "", fmt.Errorf("rotating samples need a YYYY-MM-DD SAMPLE_SEED, got %q", seed)
+	}
+	// Each target runs on one day of the cycle; the name hash spreads targets over it.
+	cycle := 100 / mr.Percent
+	today := int(day.Unix()/86400) % cycle
+	turn := int(hashString(name) % uint32(cycle))
+	return today == turn, fmt.Sprintf("minimum-run: rotating day %d of %d, target's turn on day %d (seed %s)", today+1, cycle, turn+1, seed), nil
+}
+
+func hashString(s string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(s))
+	return h.Sum32()
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// SARIF 2.1.0 subset written by `--report sarif`, for code scanning UIs that show
+// results inline in the PR diff.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	Version        string      `json:"version"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// sarifMaxNames caps the export and target names listed in a result message.
+const sarifMaxNames = 10
+
+// writeSARIFReport writes one result per changed library symbol that seeded taint,
+// naming the package's affected exports and the selected targets consuming them, and
+// one per target selected by a changed file of its own project or an external trigger.
+func writeSARIFReport(w io.Writer, r *runReport) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "goodchanges",
+			Version:        r.Version,
+			InformationURI: "https://github.com/gooddata/gooddata-goodchanges",
+			Rules: []sarifRule{
+				{ID: "affected-export", ShortDescription: sarifMessage{Text: "Change affects exports of a library"}},
+				{ID: "selected-target", ShortDescription: sarifMessage{Text: "Change selects a target"}},
+			},
+		}},
+		Results: []sarifResult{},
+	}
+
+	folders := make(map[string]string) // package name → project folder
+	for _, p := range r.Packages {
+		folders[p.Name] = p.Folder
+		if len(p.Seeds) == 0 {
+			continue
+		}
+		var exports []string
+		for _, e := range p.Exports {
+			for _, name := range e.Names {
+				if e.Specifier == p.Name {
+					exports = append(exports, name)
+				} else {
+					exports = append(exports, e.Specifier+"#"+name)
+				}
+			}
+		}
+		effect := "affects no exports of " + p.Name
+		if len(exports) > 0 {
+			effect = fmt.Sprintf("affects exports %s of %s", sarifNameList(exports), p.Name)
+		}
+		if len(p.Targets) > 0 {
+			effect += fmt.Sprintf(", consumed by selected targets %s", sarifNameList(p.Targets))
+		}
+		for _, seed := range p.Seeds {
+			change := "Change"
+			if seed.Change != "" {
+				change = "Component " + seed.Change + " change"
+			}
+			run.Results = append(run.Results, sarifResult{
+				RuleID:    "affected-export",
+				Level:     "note",
+				Message:   sarifMessage{Text: fmt.Sprintf("%s to %s %s.", change, seed.Symbol, effect)},
+				Locations: []sarifLocation{newSARIFLocation(seed.File, seed.Line)},
+			})
+		}
+	}
+
+	for _, t := range r.Targets {
+		detector, detail, _ := strings.Cut(t.Reason, ": ")
+		var file string
+		switch detector {
+		case "direct-change":
+			if t.Project == "" {
+				file = detail // a root target: repo-relative already
+			} else if folder := folders[t.Project]; folder != "" {
+				file = path.Join(folder, detail)
+			}
+		case "external-trigger":
+			file = detail
+		}
+		if file == "" {
+			continue
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    "selected-target",
+			Level:     "note",
+			Message:   sarifMessage{Text: fmt.Sprintf("Change selects target %s (%s).", t.Name, detector)},
+			Locations: []sarifLocation{newSARIFLocation(file, 1)},
+		})
+	}
+
+	data, err := json.MarshalIndent(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// newSARIFLocation anchors a result to a repo-relative file; removed symbols (line 0)
+// are anchored to the top of the file.
+func newSARIFLocation(file string, line int) sarifLocation {
+	return sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+		ArtifactLocation: sarifArtifactLocation{URI: file},
+		Region:           sarifRegion{StartLine: max(line, 1)},
+	}}
+}
+
+func sarifNameList(names []string) string {
+	if len(names) <= sarifMaxNames {
+		return strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(names[:sarifMaxNames], ", "), len(names)-sarifMaxNames)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+
+	"goodchanges/internal/rush"
+)
+
+// flagScope and flagScopeFolders restrict a run to a subtree of the monorepo
+// (--scope, --scope-folder): comma-separated package name patterns (`*` wildcards)
+// and project folder globs.
+var flagScope []string
+var flagScopeFolders []string
+
+// scopePackages returns the packages selected by --scope and --scope-folder, or nil
+// when neither is given.
+func scopePackages(config *rush.Config) (map[string]bool, error) {
+	if len(flagScope) == 0 && len(flagScopeFolders) == 0 {
+		return nil, nil
+	}
+	scope := make(map[string]bool)
+	for _, rp := range config.Projects {
+		if matchesTargetFilter(rp.PackageName, flagScope) {
+			scope[rp.PackageName] = true
+			continue
+		}
+		for _, g := range flagScopeFolders {
+			if matched, _ := doublestar.Match(strings.TrimSuffix(g, "/"), rp.ProjectFolder); matched {
+				scope[rp.PackageName] = true
+				break
+			}
+		}
+	}
+	if len(scope) == 0 {
+		var given []string
+		if len(flagScope) > 0 {
+			given = append(given, "--scope "+strings.Join(flagScope, ","))
+		}
+		if len(flagScopeFolders) > 0 {
+			given = append(given, "--scope-folder "+strings.Join(flagScopeFolders, ","))
+		}
+		return nil, fmt.Errorf("no project matches %s", strings.Join(given, " "))
+	}
+	return scope, nil
+}
+
+// splitList splits a comma-separated option value, dropping empty entries.
+func splitList(value string) []string {
+	var list []string
+	for _, s := range strings.Split(value, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			list = append(list, s)
+		}
+	}
+	return list
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"goodchanges/internal/analyzer"
+	"goodchanges/internal/rush"
+	"goodchanges/internal/tsparse"
+)
+
+// exportSnapshot is the export surface of the workspace libraries, as written by
+// `goodchanges snapshot-exports`. It has no version field so that committed
+// snapshots only change when an export does.
+type exportSnapshot struct {
+	Packages []packageSnapshot `json:"packages"` // sorted by package name
+}
+
+type packageSnapshot struct {
+	Package     string               `json:"package"`
+	Entrypoints []entrypointSnapshot `json:"entrypoints"` // sorted by export path
+}
+
+type entrypointSnapshot struct {
+	ExportPath string   `json:"exportPath"` // "." or "./internal"
+	Exports    []string `json:"exports"`    // sorted; "*" for an unenumerable `export *`
+}
+
+// runSnapshotExports implements `goodchanges snapshot-exports [--check] <file>`: it
+// writes the export names of every entrypoint of every workspace library to the file
+// or, with --check, compares them against it and exits non-zero when an export,
+// entrypoint or library of the snapshot is gone. Added exports are listed but pass.
+func runSnapshotExports(args []string) int {
+	check := len(args) == 2 && args[0] == "--check"
+	if check {
+		args = args[1:]
+	}
+	if len(args) != 1 || args[0] == "" || args[0][0] == '-' {
+		fmt.Fprintln(os.Stderr, "Usage: goodchanges snapshot-exports [--check] <file>")
+		return 2
+	}
+	path := args[0]
+
+	current, err := buildExportSnapshot()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	if !check {
+		data, err := json.MarshalIndent(current, "", "  ")
+		if err == nil {
+			if err = os.MkdirAll(filepath.Dir(path), 0o755); err == nil {
+				err = os.WriteFile(path, append(data, '\n'), 0o644)
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", path, err)
+			return 1
+		}
+		exports := 0
+		for _, p := range current.Packages {
+			for _, ep := range p.Entrypoints {
+				exports += len(ep.Exports)
+			}
+		}
+		fmt.Printf("Wrote %d exports of %d libraries to %s\n", exports, len(current.Packages), path)
+		return 0
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+		return 1
+	}
+	var snapshot exportSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing %s: %v\n", path, err)
+		return 1
+	}
+
+	removed, added := diffExportSnapshots(&snapshot, current)
+	for _, line := range removed {
+		fmt.Printf("- %s\n", line)
+	}
+	for _, line := range added {
+		fmt.Printf("+ %s\n", line)
+	}
+	if len(removed) > 0 {
+		fmt.Printf("%d removals since %s; restore them or update the snapshot with `goodchanges snapshot-exports %s`\n", len(removed), path, path)
+		return 1
+	}
+	if len(added) > 0 {
+		fmt.Printf("%d additions since %s\n", len(added), path)
+	} else {
+		fmt.Printf("Exports match %s\n", path)
+	}
+	return 0
+}
+
+// buildExportSnapshot collects the entrypoint exports of every library project.
+func buildExportSnapshot() (*exportSnapshot, error) {
+	loadEnvFlags()
+	rushConfig, err := rush.LoadConfig(".")
+	if err != nil {
+		return nil, fmt.Errorf("loading rush config: %w", err)
+	}
+	if err := tsparse.SetBackend(flagParserBackend, flagParserCommand); err != nil {
+		return nil, err
+	}
+	defer tsparse.CloseBackend()
+	analyzer.ProjectFolders = rushConfig.ProjectFolders()
+	configMap, err := rush.LoadAllProjectConfigs(rushConfig)
+	if err != nil {
+		return nil, fmt.Errorf("in .goodchangesrc.json config:\n%w", err)
+	}
+	projectMap := rush.BuildProjectMap(rushConfig)
+
+	snapshot := &exportSnapshot{Packages: []packageSnapshot{}}
+	for _, rp := range rushConfig.Projects {
+		info := projectMap[rp.PackageName]
+		cfg := configMap[rp.ProjectFolder]
+		if info == nil || !analyzer.IsLibrary(cfg, info.Package) {
+			continue
+		}
+		pkg := packageSnapshot{Package: rp.PackageName, Entrypoints: []entrypointSnapshot{}}
+		for _, ep := range analyzer.FindEntrypoints(rp.ProjectFolder, info.Package, cfg) {
+			exports := analyzer.CollectEntrypointExports(rp.ProjectFolder, ep)
+			if exports == nil {
+				exports = []string{}
+			}
+			pkg.Entrypoints = append(pkg.Entrypoints, entrypointSnapshot{ExportPath: ep.ExportPath, Exports: exports})
+		}
+		sort.Slice(pkg.Entrypoints, func(i, j int) bool { return pkg.Entrypoints[i].ExportPath < pkg.Entrypoints[j].ExportPath })
+		snapshot.Packages = append(snapshot.Packages, pkg)
+	}
+	sort.Slice(snapshot.Packages, func(i, j int) bool { return snapshot.Packages[i].Package < snapshot.Packages[j].Package })
+	return snapshot, nil
+}
+
+// diffExportSnapshots returns the exports of old missing from new and those of new
+// missing from old, as "<package><subpath>#<name>" (a whole entrypoint or library
+// that disappeared is reported once, without a name).
+func diffExportSnapshots(old, new *exportSnapshot) (removed, added []string) {
+	index := func(s *exportSnapshot) map[string]map[string]map[string]bool {
+		m := make(map[string]map[string]map[string]bool) // package → export path → names
+		for _, p := range s.Packages {
+			m[p.Package] = make(map[string]map[string]bool)
+			for _, ep := range p.Entrypoints {
+				names := make(map[string]bool)
+				for _, n := range ep.Exports {
+					names[n] = true
+				}
+				m[p.Package][ep.ExportPath] = names
+			}
+		}
+		return m
+	}
+	oldIdx, newIdx := index(old), index(new)
+	missing := func(from, to map[string]map[string]map[string]bool) []string {
+		var lines []string
+		for _, pkg := range sortedKeys(from) {
+			if to[pkg] == nil {
+				lines = append(lines, pkg+" (library)")
+				continue
+			}
+			for _, exportPath := range sortedKeys(from[pkg]) {
+				specifier := pkg + strings.TrimPrefix(exportPath, ".")
+				if to[pkg][exportPath] == nil {
+					lines = append(lines, specifier+" (entrypoint)")
+					continue
+				}
+				for _, name := range sortedKeys(from[pkg][exportPath]) {
+					if !to[pkg][exportPath][name] {
+						lines = append(lines, specifier+"#"+name)
+					}
+				}
+			}
+		}
+		return lines
+	}
+	return missing(oldIdx, newIdx), missing(newIdx, oldIdx)
+}
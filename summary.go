@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"goodchanges/internal/analyzer"
+)
+
+// runSummary implements `goodchanges summary [--since <rev>] [--top <n>] [--output <file>]`:
+// a markdown overview of what the change affects, for PR descriptions. It counts the
+// directly changed, dependency-affected and transitively affected packages, and lists
+// each affected package's top changed symbols and affected entrypoints. Selected
+// targets are not part of it.
+func runSummary(args []string) int {
+	top := 5
+	output := ""
+	for i := 0; i < len(args); i++ {
+		if value, ok := optionValue(args, &i, "--since"); ok {
+			flagSince = value
+			continue
+		}
+		if value, ok := optionValue(args, &i, "--top"); ok {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 {
+				fmt.Fprintf(os.Stderr, "Error: invalid --top %q: must be a positive number\n", value)
+				return 2
+			}
+			top = n
+			continue
+		}
+		if value, ok := optionValue(args, &i, "--output"); ok {
+			output = value
+			continue
+		}
+		fmt.Fprintln(os.Stderr, "Usage: goodchanges summary [--since <rev>] [--top <n>] [--output <file>]")
+		return 2
+	}
+	loadEnvFlags()
+
+	_, _, report, err := runDetection(context.Background(), nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error %v\n", err)
+		return 1
+	}
+	summary := renderSummary(report, top)
+	if output == "" {
+		fmt.Print(summary)
+		return 0
+	}
+	if err := os.WriteFile(output, []byte(summary), 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: writing %s: %v\n", output, err)
+		return 1
+	}
+	return 0
+}
+
+// renderSummary renders the affected packages of a run, grouped by how they are
+// affected, with at most top changed symbols and entrypoints each.
+func renderSummary(r *runReport, top int) string {
+	var direct, lockfile, transitive []reportPackage
+	for _, p := range r.Packages {
+		switch p.Reason {
+		case "changed files":
+			direct = append(direct, p)
+		case "lockfile":
+			lockfile = append(lockfile, p)
+		default:
+			transitive = append(transitive, p)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("### What changed\n\n")
+	fmt.Fprintf(&b, "Packages: **%d** directly changed · **%d** dependency-affected · **%d** transitively affected · %s · merge base `%s`\n\n",
+		len(direct), len(lockfile), len(transitive), plural(len(r.ChangedFiles), "changed file"), shortCommit(r.MergeBase))
+	if r.TimedOut {
+		b.WriteString("> [!WARNING]\n> The run timed out; the affected exports may be incomplete.\n\n")
+	}
+	if len(r.AnalysisErrors) > 0 {
+		b.WriteString("> [!WARNING]\n> Analysis failed for ")
+		for i, e := range r.AnalysisErrors {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "`%s`", e.Package)
+		}
+		b.WriteString("; all of their exports count as affected.\n\n")
+	}
+	if len(r.Packages) == 0 {
+		b.WriteString("No package is affected.\n")
+		return b.String()
+	}
+
+	if len(direct) > 0 {
+		b.WriteString("#### Directly changed\n\n")
+		for _, p := range direct {
+			fmt.Fprintf(&b, "- `%s` -- %s%s\n", p.Name, plural(len(p.Details), "changed file"), exportCounts(p))
+			if symbols := summarySymbols(p, top); symbols != "" {
+				fmt.Fprintf(&b, "  - Changed symbols: %s\n", symbols)
+			}
+			if entrypoints := summaryEntrypoints(p, top); entrypoints != "" {
+				fmt.Fprintf(&b, "  - Affected entrypoints: %s\n", entrypoints)
+			}
+		}
+		b.WriteString("\n")
+	}
+	if len(lockfile) > 0 {
+		b.WriteString("#### Dependency changes\n\n")
+		for _, p := range lockfile {
+			fmt.Fprintf(&b, "- `%s`", p.Name)
+			if len(p.Details) > 0 {
+				var deps []string
+				for _, d := range p.Details {
+					deps = append(deps, "`"+d+"`")
+				}
+				fmt.Fprintf(&b, " -- %s", summaryList(deps, top))
+			}
+			b.WriteString(exportCounts(p) + "\n")
+		}
+		b.WriteString("\n")
+	}
+	if len(transitive) > 0 {
+		fmt.Fprintf(&b, "<details><summary>Transitively affected (%d)</summary>\n\n", len(transitive))
+		for _, p := range transitive {
+			fmt.Fprintf(&b, "- `%s`", p.Name)
+			if len(p.Details) > 1 {
+				fmt.Fprintf(&b, " via `%s`", p.Details[len(p.Details)-2])
+			}
+			b.WriteString(exportCounts(p) + "\n")
+			if entrypoints := summaryEntrypoints(p, top); entrypoints != "" {
+				fmt.Fprintf(&b, "  - Affected entrypoints: %s\n", entrypoints)
+			}
+		}
+		b.WriteString("\n</details>\n")
+	}
+	return b.String()
+}
+
+// exportCounts renders ", N affected exports in M entrypoints", or nothing for none.
+func exportCounts(p reportPackage) string {
+	exports := 0
+	for _, e := range p.Exports {
+		exports += len(e.Names)
+	}
+	if exports == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", %s in %s", plural(exports, "affected export"), plural(len(p.Exports), "entrypoint"))
+}
+
+// summarySymbols lists the changed symbols of a package, exported ones first.
+func summarySymbols(p reportPackage, top int) string {
+	seeds := slices.Clone(p.Seeds)
+	slices.SortStableFunc(seeds, func(a, b analyzer.ChangeSeed) int {
+		switch {
+		case a.ExportName != "" && b.ExportName == "":
+			return -1
+		case a.ExportName == "" && b.ExportName != "":
+			return 1
+		}
+		return 0
+	})
+	var names []string
+	for _, s := range seeds {
+		name := "`" + s.Symbol + "`"
+		switch {
+		case s.Line == 0:
+			name += " (removed)"
+		case s.Change != "":
+			name += " (" + s.Change + ")"
+		}
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return summaryList(names, top)
+}
+
+// summaryEntrypoints lists the affected entrypoints of a package with their number
+// of affected exports.
+func summaryEntrypoints(p reportPackage, top int) string {
+	var entrypoints []string
+	for _, e := range p.Exports {
+		entrypoints = append(entrypoints, fmt.Sprintf("`%s` (%d)", e.Specifier, len(e.Names)))
+	}
+	return summaryList(entrypoints, top)
+}
+
+// summaryList joins at most top items, noting how many more there are.
+func summaryList(items []string, top int) string {
+	s := strings.Join(items[:min(len(items), top)], ", ")
+	if more := len(items) - top; more > 0 {
+		s += fmt.Sprintf(" and %d more", more)
+	}
+	return s
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return "1 " + noun
+	}
+	return strconv.Itoa(n) + " " + noun + "s"
+}
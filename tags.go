@@ -0,0 +1,73 @@
+package main
+
+import (
+	"slices"
+	"strings"
+
+	"goodchanges/internal/analyzer"
+	"goodchanges/internal/rush"
+)
+
+// targetTags returns the runner tags of a target selected in full by tainted-import
+// when its specTags cover every tainted import reaching its files, so the suite can
+// be subset by tag. Any other selection, or uncovered taint, runs the whole suite (nil).
+func targetTags(ctx *DetectionContext, t *DetectorTarget, det Detection) []string {
+	if len(t.Def.SpecTags) == 0 || !det.Full || !strings.HasPrefix(det.Reason, "tainted-import: ") {
+		return nil
+	}
+	reaches := func(taint map[string]map[string]bool) bool {
+		if len(taint) == 0 {
+			return false
+		}
+		for _, cd := range t.ChangeDirs {
+			if !cd.IsFineGrained() && analyzer.HasTaintedImportsForGlob(t.Project.ProjectFolder, cd.Glob, changeDirTaint(taint, cd), t.Config) {
+				return true
+			}
+		}
+		return false
+	}
+
+	taint := ctx.targetUpstreamTaint(t)
+	uncovered := filterTaint(taint, func(specifier, name string) bool {
+		return !slices.ContainsFunc(t.Def.SpecTags, func(st rush.SpecTag) bool { return specTagMatches(st.Match, specifier, name) })
+	})
+	if reaches(uncovered) {
+		return nil
+	}
+	var tags []string
+	for _, st := range t.Def.SpecTags {
+		covered := filterTaint(taint, func(specifier, name string) bool { return specTagMatches(st.Match, specifier, name) })
+		if reaches(covered) {
+			tags = append(tags, st.Tags...)
+		}
+	}
+	slices.Sort(tags)
+	return slices.Compact(tags)
+}
+
+// specTagMatches reports whether a specTags match covers a tainted export: a package
+// covers every export of its entrypoints, "specifier#name" exactly that export.
+func specTagMatches(match, specifier, name string) bool {
+	pkg, symbol, found := strings.Cut(match, "#")
+	if found {
+		return specifier == pkg && name == symbol
+	}
+	return specifier == pkg || strings.HasPrefix(specifier, pkg+"/")
+}
+
+// filterTaint returns the tainted exports keep accepts, dropping emptied specifiers.
+func filterTaint(taint map[string]map[string]bool, keep func(specifier, name string) bool) map[string]map[string]bool {
+	result := make(map[string]map[string]bool)
+	for specifier, names := range taint {
+		for name, tainted := range names {
+			if !tainted || !keep(specifier, name) {
+				continue
+			}
+			if result[specifier] == nil {
+				result[specifier] = make(map[string]bool)
+			}
+			result[specifier][name] = true
+		}
+	}
+	return result
+}
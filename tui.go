@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+
+	"goodchanges/internal/analyzer"
+	"goodchanges/internal/git"
+)
+
+const tuiHelp = `Commands:
+  targets [glob]    list the selected targets (of packages matching glob)
+  packages [glob]   list the affected packages (matching glob)
+  why <name|#>      show why a package or target is affected: propagation chain,
+                    affected exports, seeds and detections (# from the last list)
+  filter [glob]     restrict targets and packages to packages matching glob; no
+                    glob clears the filter
+  rerun             re-run the detection on the current working tree
+  help              show this help
+  quit              leave`
+
+// runTUI implements `goodchanges tui [--since <rev>]`: an interactive session over the
+// result of a detection run, for exploring locally why targets are selected. The
+// detection runs once on start and again on `rerun`, in the same process, so the
+// analysis cache and loaded configuration are reused.
+func runTUI(args []string) int {
+	for i := 0; i < len(args); i++ {
+		if value, ok := optionValue(args, &i, "--since"); ok {
+			flagSince = value
+			continue
+		}
+		fmt.Fprintln(os.Stderr, "Usage: goodchanges tui [--since <rev>]")
+		return 2
+	}
+	loadEnvFlags()
+
+	s := &tuiSession{out: os.Stdout}
+	if err := s.run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error running detection: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(s.out, "%d targets selected, %d packages affected since %s. Type help for commands.\n", len(s.results), len(s.packages()), shortCommit(s.mergeBase))
+
+	in := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Fprint(s.out, "> ")
+		if !in.Scan() {
+			fmt.Fprintln(s.out)
+			return 0
+		}
+		cmd, arg, _ := strings.Cut(strings.TrimSpace(in.Text()), " ")
+		arg = strings.TrimSpace(arg)
+		switch cmd {
+		case "":
+		case "targets", "t":
+			s.listTargets(arg)
+		case "packages", "p":
+			s.listPackages(arg)
+		case "why", "w":
+			s.why(arg)
+		case "filter", "f":
+			s.setFilter(arg)
+		case "rerun", "r":
+			s.rerun()
+		case "help", "h", "?":
+			fmt.Fprintln(s.out, tuiHelp)
+		case "quit", "q", "exit":
+			return 0
+		default:
+			fmt.Fprintf(s.out, "Unknown command %q. Type help for commands.\n", cmd)
+		}
+	}
+}
+
+// tuiSession is the state of a `goodchanges tui` session.
+type tuiSession struct {
+	out       io.Writer
+	mergeBase string
+	results   []*TargetResult
+	report    *runReport
+	filter    string   // package name glob; empty for none
+	listing   []string // names of the last list, for `why <#>`
+}
+
+// run runs the detection against the current working tree.
+func (s *tuiSession) run() error {
+	mergeBase, results, report, err := runDetection(context.Background(), nil)
+	if err != nil {
+		return err
+	}
+	s.mergeBase, s.results, s.report = mergeBase, results, report
+	return nil
+}
+
+// runDetection runs the detection in-process, as the tui and rpc subcommands do
+// repeatedly, and returns the merge base, the selected targets and the run report.
+// With files (repo-relative), only the changed files among them count.
+func runDetection(ctx context.Context, files []string) (string, []*TargetResult, *runReport, error) {
+	analyzer.ResetRunState()
+	keepReport, lastReport = true, nil
+	mergeBase, err := resolveMergeBase(ctx)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	changedFiles, err := git.ChangedFilesSince(ctx, mergeBase)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("getting changed files: %w", err)
+	}
+	if files != nil {
+		changedFiles = slices.DeleteFunc(changedFiles, func(f string) bool { return !slices.Contains(files, f) })
+	}
+	results, err := detectAffectedTargets(ctx, mergeBase, changedFiles)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	report := lastReport
+	if report == nil {
+		// Nothing was analyzed (no changed files).
+		report = &runReport{MergeBase: mergeBase}
+	}
+	return mergeBase, results, report, nil
+}
+
+func (s *tuiSession) rerun() {
+	before := make(map[string]bool, len(s.results))
+	for _, r := range s.results {
+		before[r.Name] = true
+	}
+	start := time.Now()
+	if err := s.run(); err != nil {
+		fmt.Fprintf(s.out, "Error running detection: %v\n", err)
+		return
+	}
+	s.listing = nil
+	after := make(map[string]bool, len(s.results))
+	for _, r := range s.results {
+		after[r.Name] = true
+	}
+	fmt.Fprintf(s.out, "%d targets selected, %d packages affected (%s).\n", len(s.results), len(s.packages()), time.Since(start).Round(time.Millisecond))
+	for _, name := range sortedKeys(after) {
+		if !before[name] {
+			fmt.Fprintf(s.out, "  + %s\n", name)
+		}
+	}
+	for _, name := range sortedKeys(before) {
+		if !after[name] {
+			fmt.Fprintf(s.out, "  - %s\n", name)
+		}
+	}
+}
+
+func (s *tuiSession) setFilter(glob string) {
+	if glob != "" && !doublestar.ValidatePattern(glob) {
+		fmt.Fprintf(s.out, "Invalid glob %q\n", glob)
+		return
+	}
+	s.filter = glob
+	if glob == "" {
+		fmt.Fprintln(s.out, "Filter cleared.")
+		return
+	}
+	fmt.Fprintf(s.out, "Showing packages matching %s: %d affected.\n", glob, len(s.packages()))
+}
+
+// packages returns the affected packages matching the session filter.
+func (s *tuiSession) packages() []reportPackage {
+	var pkgs []reportPackage
+	for _, p := range s.report.Packages {
+		if matchesGlob(s.filter, p.Name) {
+			pkgs = append(pkgs, p)
+		}
+	}
+	return pkgs
+}
+
+func (s *tuiSession) listTargets(glob string) {
+	if glob != "" && !doublestar.ValidatePattern(glob) {
+		fmt.Fprintf(s.out, "Invalid glob %q\n", glob)
+		return
+	}
+	s.listing = s.listing[:0]
+	for _, t := range s.report.Targets {
+		if !matchesGlob(s.filter, t.Project) || !matchesGlob(glob, t.Project) {
+			continue
+		}
+		s.listing = append(s.listing, t.Name)
+		fmt.Fprintf(s.out, "%3d  %s", len(s.listing), t.Name)
+		if t.Project != "" && t.Project != t.Name {
+			fmt.Fprintf(s.out, " (%s)", t.Project)
+		}
+		fmt.Fprintf(s.out, ": %s\n", t.Reason)
+	}
+	if len(s.listing) == 0 {
+		fmt.Fprintln(s.out, "No targets selected.")
+	}
+}
+
+func (s *tuiSession) listPackages(glob string) {
+	if glob != "" && !doublestar.ValidatePattern(glob) {
+		fmt.Fprintf(s.out, "Invalid glob %q\n", glob)
+		return
+	}
+	s.listing = s.listing[:0]
+	for _, p := range s.packages() {
+		if !matchesGlob(glob, p.Name) {
+			continue
+		}
+		s.listing = append(s.listing, p.Name)
+		fmt.Fprintf(s.out, "%3d  %s: %s", len(s.listing), p.Name, p.Reason)
+		if len(p.Targets) > 0 {
+			fmt.Fprintf(s.out, ", %d targets", len(p.Targets))
+		}
+		fmt.Fprintln(s.out)
+	}
+	if len(s.listing) == 0 {
+		fmt.Fprintln(s.out, "No packages affected.")
+	}
+}
+
+// why prints what is known about a package or target. A name can be both: a target
+// is usually the package it tests.
+func (s *tuiSession) why(arg string) {
+	name := arg
+	if n, err := strconv.Atoi(arg); err == nil {
+		if n < 1 || n > len(s.listing) {
+			fmt.Fprintf(s.out, "No entry %d in the last list.\n", n)
+			return
+		}
+		name = s.listing[n-1]
+	}
+	if name == "" {
+		fmt.Fprintln(s.out, "Usage: why <name|#>")
+		return
+	}
+	found := false
+	for _, t := range s.report.Targets {
+		if t.Name != name {
+			continue
+		}
+		found = true
+		fmt.Fprintf(s.out, "Target %s: %s\n", t.Name, t.Reason)
+		for _, d := range t.Detections {
+			fmt.Fprintf(s.out, "  detected: %s\n", d)
+		}
+		if t.Project != "" && t.Project != t.Name {
+			s.whyPackage(t.Project)
+		}
+	}
+	if s.whyPackage(name) {
+		found = true
+	}
+	if !found {
+		fmt.Fprintf(s.out, "%s is not affected.\n", name)
+	}
+}
+
+func (s *tuiSession) whyPackage(name string) bool {
+	for _, p := range s.report.Packages {
+		if p.Name != name {
+			continue
+		}
+		fmt.Fprintf(s.out, "Package %s (%s): %s\n", p.Name, p.Folder, p.Reason)
+		if len(p.Details) > 0 {
+			if p.Reason == "dependency" {
+				fmt.Fprintf(s.out, "  chain: %s\n", strings.Join(p.Details, " → "))
+			} else {
+				for _, d := range p.Details {
+					fmt.Fprintf(s.out, "  %s\n", d)
+				}
+			}
+		}
+		for _, e := range p.Exports {
+			fmt.Fprintf(s.out, "  exports %s: %s\n", e.Specifier, strings.Join(e.Names, ", "))
+		}
+		for _, seed := range p.Seeds {
+			fmt.Fprintf(s.out, "  seed %s (%s:%d)", seed.Symbol, seed.File, seed.Line)
+			if seed.Change != "" {
+				fmt.Fprintf(s.out, " [%s]", seed.Change)
+			}
+			fmt.Fprintln(s.out)
+		}
+		if len(p.Targets) > 0 {
+			fmt.Fprintf(s.out, "  targets: %s\n", strings.Join(p.Targets, ", "))
+		}
+		return true
+	}
+	return false
+}
+
+// matchesGlob reports whether name matches glob; an empty glob matches everything.
+func matchesGlob(glob, name string) bool {
+	if glob == "" {
+		return true
+	}
+	matched, _ := doublestar.Match(glob, name)
+	return matched
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
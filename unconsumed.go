@@ -0,0 +1,65 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"goodchanges/internal/analyzer"
+	"goodchanges/internal/log"
+	"goodchanges/internal/rush"
+)
+
+// findUnconsumedExports returns the affected exports of libraries that no dependent
+// workspace project imports, keyed by import specifier. Exports of libraries without
+// dependents are all unconsumed.
+func findUnconsumedExports(projectMap map[string]*rush.ProjectInfo, affectedExports map[string][]analyzer.AffectedExport) map[string]map[string]bool {
+	var consumerFolders []string
+	seen := make(map[string]bool)
+	for pkgName := range affectedExports {
+		info := projectMap[pkgName]
+		if info == nil {
+			continue
+		}
+		for _, dependent := range info.DependedOnBy {
+			if di := projectMap[dependent]; di != nil && !seen[di.ProjectFolder] {
+				seen[di.ProjectFolder] = true
+				consumerFolders = append(consumerFolders, di.ProjectFolder)
+			}
+		}
+	}
+	sort.Strings(consumerFolders)
+	index := analyzer.BuildImportIndex(consumerFolders, "", "")
+
+	unconsumed := make(map[string]map[string]bool)
+	for pkgName, affected := range affectedExports {
+		for _, ae := range affected {
+			specifier := pkgName + strings.TrimPrefix(ae.EntrypointPath, ".")
+			for _, name := range ae.ExportNames {
+				if index.Consumed(specifier, name) {
+					continue
+				}
+				if unconsumed[specifier] == nil {
+					unconsumed[specifier] = make(map[string]bool)
+				}
+				unconsumed[specifier][name] = true
+			}
+		}
+	}
+
+	if len(unconsumed) > 0 {
+		log.Basicf("Affected exports not imported by any workspace project:")
+		for _, specifier := range sortedKeys(unconsumed) {
+			log.Basicf("  %s: %s", specifier, strings.Join(sortedKeys(unconsumed[specifier]), ", "))
+		}
+	}
+	return unconsumed
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
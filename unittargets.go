@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+
+	"goodchanges/internal/analyzer"
+	"goodchanges/internal/log"
+	"goodchanges/internal/rush"
+)
+
+// flagUnitOutput (UNIT_OUTPUT) is the file the unit targets of affected libraries are
+// written to; flagUnitResults (UNIT_RESULTS) holds the outcome of those unit targets,
+// and the targets gated by a failed one are not selected.
+var flagUnitOutput string
+var flagUnitResults string
+
+// UnitTarget is a library's own unit-test target ("type": "unit") whose library has
+// affected exports, written to UNIT_OUTPUT so the pipeline can run it before the
+// selected targets.
+type UnitTarget struct {
+	Name    string   `json:"name"`
+	Package string   `json:"package"`
+	Command string   `json:"command"`
+	Exports []string `json:"exports"` // affected exports, as "specifier#name" for non-root entrypoints
+	// Gates are the selected targets of the library and of the packages depending on it.
+	Gates []string `json:"gates,omitempty"`
+}
+
+// unitTargets returns the unit targets whose library has affected exports, sorted by
+// name. After a timeout every unit target is listed, as exports may have been missed.
+func unitTargets(defs []*DetectorTarget, projectMap map[string]*rush.ProjectInfo, affectedExports map[string][]analyzer.AffectedExport, selected []*TargetResult, targets []*DetectorTarget, timedOut bool) []UnitTarget {
+	targetProjects := make(map[string]string, len(targets))
+	for _, t := range targets {
+		targetProjects[t.Name] = t.Project.PackageName
+	}
+	var units []UnitTarget
+	for _, def := range defs {
+		pkg := def.Project.PackageName
+		var exports []string
+		for _, ae := range affectedExports[pkg] {
+			for _, name := range ae.ExportNames {
+				if ae.EntrypointPath != "." {
+					name = pkg + strings.TrimPrefix(ae.EntrypointPath, ".") + "#" + name
+				}
+				exports = append(exports, name)
+			}
+		}
+		if len(exports) == 0 && !timedOut {
+			continue
+		}
+		sort.Strings(exports)
+		unit := UnitTarget{Name: def.Name, Package: pkg, Command: *def.Def.Command, Exports: slices.Compact(exports)}
+		if unit.Exports == nil {
+			unit.Exports = []string{}
+		}
+		dependents := rush.FindTransitiveDependents(projectMap, []string{pkg})
+		for _, r := range selected {
+			if dependents[targetProjects[r.Name]] {
+				unit.Gates = append(unit.Gates, r.Name)
+			}
+		}
+		units = append(units, unit)
+	}
+	sort.Slice(units, func(i, j int) bool { return units[i].Name < units[j].Name })
+	return units
+}
+
+// readUnitResults reads a UNIT_RESULTS file: unit target names mapped to "passed" or
+// "failed".
+func readUnitResults(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var results map[string]string
+	if err := json.Unmarshal(data, &results); err != nil {
+		return nil, err
+	}
+	for name, outcome := range results {
+		if outcome != "passed" && outcome != "failed" {
+			return nil, fmt.Errorf("unit target %s: invalid result %q: must be \"passed\" or \"failed\"", name, outcome)
+		}
+	}
+	return results, nil
+}
+
+// gateOnUnitResults drops the selected targets gated by a failed unit target: they
+// would test a library whose own tests fail. The reasons of dropped targets are updated.
+func gateOnUnitResults(selected []*TargetResult, units []UnitTarget, results map[string]string, reasons map[string]string) []*TargetResult {
+	gatedBy := make(map[string]string) // target name → failed unit target
+	for _, u := range units {
+		if results[u.Name] != "failed" {
+			continue
+		}
+		for _, name := range u.Gates {
+			if _, ok := gatedBy[name]; !ok {
+				gatedBy[name] = u.Name
+			}
+		}
+	}
+	if len(gatedBy) == 0 {
+		return selected
+	}
+	kept := selected[:0:0]
+	for _, r := range selected {
+		unit, gated := gatedBy[r.Name]
+		if !gated {
+			kept = append(kept, r)
+			continue
+		}
+		fmt.Fprintf(os.Stderr, "Warning: target %s not selected: unit target %s failed\n", r.Name, unit)
+		reasons[r.Name] = "gated by failed unit target " + unit
+	}
+	log.Basicf("Unit results gated %d selected targets", len(selected)-len(kept))
+	return kept
+}
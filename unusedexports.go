@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/bmatcuk/doublestar/v4"
+
+	"goodchanges/internal/analyzer"
+	"goodchanges/internal/rush"
+)
+
+// runUnusedExports implements `goodchanges unused-exports <package>`: it lists the
+// exports of the package's entrypoints that no workspace project imports.
+func runUnusedExports(args []string) int {
+	if len(args) != 1 || args[0] == "" {
+		fmt.Fprintln(os.Stderr, "Usage: goodchanges unused-exports <package>")
+		return 2
+	}
+	pkgName := args[0]
+
+	index, rushConfig, err := loadWorkspaceImportIndex()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	info := rush.BuildProjectMapFor(rushConfig, []string{pkgName})[pkgName]
+	if info == nil {
+		fmt.Fprintf(os.Stderr, "Error: %s is not a project in rush.json\n", pkgName)
+		return 1
+	}
+	cfg, err := rush.LoadProjectConfig(info.ProjectFolder, pkgName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+	entrypoints := analyzer.FindEntrypoints(info.ProjectFolder, info.Package, cfg)
+	if len(entrypoints) == 0 {
+		fmt.Fprintf(os.Stderr, "Error: no entrypoints of %s resolve to source files\n", pkgName)
+		return 1
+	}
+
+	total := 0
+	for _, ep := range entrypoints {
+		// Wildcard entrypoints ("./utils/*") are imported through any matching subpath.
+		pattern := pkgName + strings.TrimPrefix(ep.ExportPath, ".")
+		var specifiers []string
+		for _, s := range index.Specifiers() {
+			if matched, _ := doublestar.Match(pattern, s); matched {
+				specifiers = append(specifiers, s)
+			}
+		}
+		exports := analyzer.CollectEntrypointExports(info.ProjectFolder, ep)
+		var unused []string
+		for _, name := range exports {
+			consumed := false
+			for _, s := range specifiers {
+				if index.Consumed(s, name) {
+					consumed = true
+					break
+				}
+			}
+			if !consumed {
+				unused = append(unused, name)
+			}
+		}
+		sort.Strings(unused)
+		fmt.Printf("%s (%s): %d of %d exports unused\n", pattern, ep.SourceFile, len(unused), len(exports))
+		for _, name := range unused {
+			fmt.Printf("  %s\n", name)
+		}
+		total += len(unused)
+	}
+	fmt.Printf("%d unused exports in %d entrypoints of %s\n", total, len(entrypoints), pkgName)
+	return 0
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"goodchanges/internal/analyzer"
+	"goodchanges/internal/rush"
+	"goodchanges/internal/tsparse"
+)
+
+// importIndexCachePath is where subcommands cache the workspace import index,
+// relative to the repository root (common/temp is git-ignored in rush repos).
+const importIndexCachePath = "common/temp/goodchanges/import-index.json"
+
+// runWhoImports implements `goodchanges who-imports <package>[#symbol]`: it lists
+// every workspace file importing the package (any of its entrypoints) or, with a
+// symbol, importing that export from the given specifier, grouped by project.
+func runWhoImports(args []string) int {
+	if len(args) != 1 || args[0] == "" {
+		fmt.Fprintln(os.Stderr, "Usage: goodchanges who-imports <package>[#symbol]")
+		return 2
+	}
+	specifier, symbol, _ := strings.Cut(args[0], "#")
+
+	index, rushConfig, err := loadWorkspaceImportIndex()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return 1
+	}
+
+	var uses []analyzer.ImportUse
+	if symbol != "" {
+		uses = index.Uses(specifier, symbol)
+	} else {
+		for _, s := range index.Specifiers() {
+			if s == specifier || strings.HasPrefix(s, specifier+"/") {
+				uses = append(uses, index.Uses(s, "")...)
+			}
+		}
+	}
+
+	packageNames := make(map[string]string) // project folder → package name
+	for _, rp := range rushConfig.Projects {
+		packageNames[rp.ProjectFolder] = rp.PackageName
+	}
+	byProject := make(map[string][]analyzer.ImportUse)
+	for _, use := range uses {
+		byProject[use.ProjectFolder] = append(byProject[use.ProjectFolder], use)
+	}
+	files := 0
+	for _, folder := range sortedKeys(byProject) {
+		projectUses := byProject[folder]
+		sort.Slice(projectUses, func(i, j int) bool {
+			if projectUses[i].File != projectUses[j].File {
+				return projectUses[i].File < projectUses[j].File
+			}
+			return projectUses[i].Specifier < projectUses[j].Specifier
+		})
+		fileSet := make(map[string]bool)
+		for _, use := range projectUses {
+			fileSet[use.File] = true
+		}
+		files += len(fileSet)
+		fmt.Printf("%s (%s): %d files\n", packageNames[folder], folder, len(fileSet))
+		for _, use := range projectUses {
+			fmt.Printf("  %s: %s {%s}\n", filepath.Join(folder, use.File), use.Specifier, strings.Join(use.Names, ", "))
+		}
+	}
+	fmt.Printf("%d files in %d projects import %s\n", files, len(byProject), args[0])
+	return 0
+}
+
+// loadWorkspaceImportIndex indexes the package imports of every rush project, reusing
+// the on-disk cache for unchanged files.
+func loadWorkspaceImportIndex() (*analyzer.ImportIndex, *rush.Config, error) {
+	loadEnvFlags()
+	rushConfig, err := rush.LoadConfig(".")
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading rush config: %w", err)
+	}
+	if err := tsparse.SetBackend(flagParserBackend, flagParserCommand); err != nil {
+		return nil, nil, err
+	}
+	defer tsparse.CloseBackend()
+	analyzer.ProjectFolders = rushConfig.ProjectFolders()
+	rootCfg, err := rush.LoadRootConfig(".")
+	if err != nil {
+		return nil, nil, fmt.Errorf("in .goodchangesrc.json config:\n%w", err)
+	}
+	if rootCfg != nil {
+		analyzer.SkipDirs = rootCfg.SkipDirs
+	}
+
+	var folders []string
+	for _, rp := range rushConfig.Projects {
+		folders = append(folders, rp.ProjectFolder)
+	}
+	// Parser output depends on the goodchanges version and the parser backend.
+	cacheKey := strings.TrimSpace(version) + "/" + flagParserBackend + "/" + flagParserCommand
+	return analyzer.BuildImportIndex(folders, importIndexCachePath, cacheKey), rushConfig, nil
+}